@@ -1,8 +1,11 @@
 package v1alpha1
 
 import (
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
@@ -26,8 +29,70 @@ type CoordinatorSpec struct {
 	HttpsKeyPairSecretName string `json:"httpsKeyPairSecretName,omitempty"`
 	// +kubebuilder:validation:Optional
 	HttpsKeyPairSecretKey string `json:"httpsKeyPairSecretKey,omitempty"`
+	// Password of the keystore in plain text. It is written to the config map of the coordinator.
+	// Deprecated: use httpsKeyPairPasswordSecret.
 	// +kubebuilder:validation:Optional
 	HttpsKeyPairPassword string `json:"httpsKeyPairPassword,omitempty"`
+	// Key of a Secret that holds the password of the keystore. The password is passed to
+	// the coordinator in an environment variable. Cannot be used along with httpsKeyPairPassword.
+	// +kubebuilder:validation:Optional
+	HttpsKeyPairPasswordSecret *v1.SecretKeySelector `json:"httpsKeyPairPasswordSecret,omitempty"`
+	// Certificate of the HTTPS port provisioned by the operator. Requires httpsEnabled and
+	// cannot be used along with httpsKeyPairSecretName.
+	// +kubebuilder:validation:Optional
+	Tls *TLSSpec `json:"tls,omitempty"`
+
+	// Kind of the workload that manages the coordinator pod. With StatefulSet, the
+	// coordinator gets a stable network identity through the pod discovery service and
+	// can have a persistent volume for node.data-dir.
+	// Changing it on a running cluster replaces the coordinator.
+	// Defaults to ReplicaSet.
+	// +kubebuilder:validation:Enum=ReplicaSet;StatefulSet
+	// +kubebuilder:validation:Optional
+	Workload CoordinatorWorkload `json:"workload,omitempty"`
+
+	// Persistent volume mounted at node.data-dir of the coordinator.
+	// Applicable only when workload is StatefulSet.
+	// +kubebuilder:validation:Optional
+	DataVolume *DataVolumeSpec `json:"dataVolume,omitempty"`
+
+	// Volume mounted at node.data-dir of the coordinator. Cannot be used along with dataVolume.
+	// +kubebuilder:validation:Optional
+	Storage *StorageSpec `json:"storage,omitempty"`
+
+	// Overrides of the default probes of the coordinator
+	// +kubebuilder:validation:Optional
+	Probes *ProbesSpec `json:"probes,omitempty"`
+
+	// PodDisruptionBudget of the coordinator. Defaults to minAvailable 1, so a node drain
+	// waits till the coordinator pod is deleted by hand.
+	// +kubebuilder:validation:Optional
+	DisruptionBudget *DisruptionBudgetSpec `json:"disruptionBudget,omitempty"`
+
+	SchedulingSpec `json:",inline"`
+
+	PodExtensionsSpec `json:",inline"`
+}
+
+// +k8s:openapi-gen=true
+type CoordinatorWorkload string
+
+const (
+	CoordinatorReplicaSetWorkload  CoordinatorWorkload = "ReplicaSet"
+	CoordinatorStatefulSetWorkload CoordinatorWorkload = "StatefulSet"
+)
+
+// +k8s:openapi-gen=true
+type DataVolumeSpec struct {
+	// Size of the volume e.g. 10Gi
+	// +kubebuilder:validation:Required
+	Size string `json:"size"`
+	// Name of the StorageClass of the volume. The default StorageClass is used if not specified.
+	// +kubebuilder:validation:Optional
+	StorageClassName *string `json:"storageClassName,omitempty"`
+	// Defaults to ReadWriteOnce.
+	// +kubebuilder:validation:Optional
+	AccessModes []v1.PersistentVolumeAccessMode `json:"accessModes,omitempty"`
 }
 
 // +k8s:openapi-gen=true
@@ -60,8 +125,185 @@ type WorkerSpec struct {
 
 	// +kubebuilder:validation:Optional
 	Autoscaling AutoscalingSpec `json:"autoscaling,omitempty"`
+
+	// Kind of the workload that manages the worker pods. With Deployment, the worker
+	// pods get rollout history and can be rolled back using kubectl rollout undo.
+	// Changing it on a running cluster migrates the workers to the new workload.
+	// Defaults to ReplicaSet.
+	// +kubebuilder:validation:Enum=ReplicaSet;Deployment
+	// +kubebuilder:validation:Optional
+	Workload WorkerWorkload `json:"workload,omitempty"`
+
+	// Strategy used by the worker Deployment to replace the workers.
+	// Defaults to RollingUpdate with maxSurge 1 and maxUnavailable 0.
+	// Applicable only when workload is Deployment.
+	// +kubebuilder:validation:Optional
+	UpdateStrategy *appsv1.DeploymentStrategy `json:"updateStrategy,omitempty"`
+
+	// Number of old worker ReplicaSets to retain for rollback.
+	// Applicable only when workload is Deployment.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Optional
+	RevisionHistoryLimit *int32 `json:"revisionHistoryLimit,omitempty"`
+
+	// Windows of time in which the number of workers, or the bounds of the autoscaling,
+	// are overridden. When windows overlap, the first one in the list is used.
+	// +kubebuilder:validation:Optional
+	Schedules []ScalingSchedule `json:"schedules,omitempty"`
+
+	// Volume mounted at node.data-dir of the workers. It is also used for spilling
+	// when spillEnabled is set.
+	// +kubebuilder:validation:Optional
+	Storage *StorageSpec `json:"storage,omitempty"`
+
+	// Drains the workers on a node that is about to be terminated, e.g. a spot node that
+	// has received a termination notice. It applies to the workers of the worker pools too.
+	// +kubebuilder:validation:Optional
+	NodeTermination *NodeTerminationSpec `json:"nodeTermination,omitempty"`
+
+	// Overrides of the default probes of the workers. They apply to the worker pools too.
+	// +kubebuilder:validation:Optional
+	Probes *ProbesSpec `json:"probes,omitempty"`
+
+	// PodDisruptionBudget of the workers. Defaults to maxUnavailable 1.
+	// It applies to each worker pool too.
+	// +kubebuilder:validation:Optional
+	DisruptionBudget *DisruptionBudgetSpec `json:"disruptionBudget,omitempty"`
+
+	SchedulingSpec `json:",inline"`
+
+	PodExtensionsSpec `json:",inline"`
+}
+
+// Budget of the voluntary disruptions, e.g. the evictions of a node drain, of the
+// coordinator or of the workers. Only one of minAvailable and maxUnavailable can be set.
+// +k8s:openapi-gen=true
+type DisruptionBudgetSpec struct {
+	// Defaults to true
+	// +kubebuilder:validation:Optional
+	Enabled *bool `json:"enabled,omitempty"`
+	// Number or percentage of the pods that must remain available. A number is capped at
+	// the minimum number of pods, i.e. the count or the minReplicas of the autoscaling.
+	// +kubebuilder:validation:Optional
+	MinAvailable *intstr.IntOrString `json:"minAvailable,omitempty"`
+	// Number or percentage of the pods that can be unavailable
+	// +kubebuilder:validation:Optional
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+}
+
+// Certificate of the coordinator provisioned by the operator. The certificate covers the
+// coordinator service, the DNS name of the coordinator pod and the given hostnames.
+// +k8s:openapi-gen=true
+type TLSSpec struct {
+	// SelfSigned generates a CA and a server certificate signed by it. CertManager requests
+	// the certificate from cert-manager. Defaults to SelfSigned.
+	// +kubebuilder:validation:Enum=SelfSigned;CertManager
+	// +kubebuilder:validation:Optional
+	Mode TLSMode `json:"mode,omitempty"`
+	// Additional DNS names of the certificate e.g. the host name of an ingress or a load balancer
+	// +kubebuilder:validation:Optional
+	Hostnames []string `json:"hostnames,omitempty"`
+	// Validity of the server certificate. Defaults to 2160h.
+	// +kubebuilder:validation:Optional
+	Duration *metav1.Duration `json:"duration,omitempty"`
+	// The certificate is renewed this long before it expires. Defaults to 720h.
+	// +kubebuilder:validation:Optional
+	RenewBefore *metav1.Duration `json:"renewBefore,omitempty"`
+	// Issuer of cert-manager that signs the certificate. Required with CertManager.
+	// +kubebuilder:validation:Optional
+	IssuerRef *IssuerReference `json:"issuerRef,omitempty"`
+}
+
+// +k8s:openapi-gen=true
+type TLSMode string
+
+const (
+	TLSSelfSigned  TLSMode = "SelfSigned"
+	TLSCertManager TLSMode = "CertManager"
+)
+
+// Reference to an Issuer or a ClusterIssuer of cert-manager
+// +k8s:openapi-gen=true
+type IssuerReference struct {
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+	// Issuer or ClusterIssuer. Defaults to Issuer.
+	// +kubebuilder:validation:Optional
+	Kind string `json:"kind,omitempty"`
+	// Defaults to cert-manager.io
+	// +kubebuilder:validation:Optional
+	Group string `json:"group,omitempty"`
+}
+
+// Overrides of the default probes of the presto container. The fields that are set in a
+// probe replace those of the default probe. A probe without a handler keeps the default
+// handler, so only the timings can be changed.
+// +k8s:openapi-gen=true
+type ProbesSpec struct {
+	// +kubebuilder:validation:Optional
+	LivenessProbe *v1.Probe `json:"livenessProbe,omitempty"`
+	// +kubebuilder:validation:Optional
+	ReadinessProbe *v1.Probe `json:"readinessProbe,omitempty"`
+	// +kubebuilder:validation:Optional
+	StartupProbe *v1.Probe `json:"startupProbe,omitempty"`
 }
 
+// Taints and conditions that mark a node that is about to be terminated
+// +k8s:openapi-gen=true
+type NodeTerminationSpec struct {
+	// Defaults to true when nodeTermination is specified
+	// +kubebuilder:validation:Optional
+	Enabled *bool `json:"enabled,omitempty"`
+	// Keys of the taints put on a node that is about to be terminated. Defaults to the
+	// taints of the AWS and GCP node termination handlers and of the cluster autoscaler.
+	// +kubebuilder:validation:Optional
+	Taints []string `json:"taints,omitempty"`
+	// Types of the node conditions that mark a node that is about to be terminated
+	// when their status is True, e.g. a condition set by the node problem detector.
+	// +kubebuilder:validation:Optional
+	Conditions []string `json:"conditions,omitempty"`
+	// Whether the workers on a cordoned node are drained. Defaults to true.
+	// +kubebuilder:validation:Optional
+	Cordon *bool `json:"cordon,omitempty"`
+}
+
+// A window of time starting at each time matched by the cron expression
+// +k8s:openapi-gen=true
+type ScalingSchedule struct {
+	// Name of the window, shown in the status when it is active
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+	// Cron expression with five fields, e.g. "0 8 * * 1-5", for the start of the window
+	// +kubebuilder:validation:Required
+	Schedule string `json:"schedule"`
+	// Length of the window e.g. 10h or 30m
+	// +kubebuilder:validation:Required
+	Duration metav1.Duration `json:"duration"`
+	// IANA time zone of the cron expression e.g. Europe/Berlin. Defaults to UTC.
+	// +kubebuilder:validation:Optional
+	TimeZone string `json:"timeZone,omitempty"`
+	// Number of workers during the window. Applicable when autoscaling is not enabled.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Optional
+	Count *int32 `json:"count,omitempty"`
+	// Minimum number of workers during the window. Applicable when autoscaling is enabled.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Optional
+	MinReplicas *int32 `json:"minReplicas,omitempty"`
+	// Maximum number of workers during the window. Applicable when autoscaling is enabled.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Optional
+	MaxReplicas *int32 `json:"maxReplicas,omitempty"`
+}
+
+// +k8s:openapi-gen=true
+type WorkerWorkload string
+
+const (
+	WorkerReplicaSetWorkload WorkerWorkload = "ReplicaSet"
+	WorkerDeploymentWorkload WorkerWorkload = "Deployment"
+)
+
 // +k8s:openapi-gen=true
 type AutoscalingSpec struct  {
 	// +kubebuilder:validation:Optional
@@ -80,6 +322,127 @@ type AutoscalingSpec struct  {
 	// +kubebuilder:validation:Minimum=1
 	// +kubebuilder:validation:Optional
 	TargetCPUUtilizationPercentage  *int32 `json:"targetCPUUtilizationPercentage,omitempty"`
+
+	// Target average memory utilization of the workers as a percentage of the requested memory
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Optional
+	TargetMemoryUtilizationPercentage *int32 `json:"targetMemoryUtilizationPercentage,omitempty"`
+
+	// Additional metrics of the HPA e.g. Pods, Object or External metrics.
+	// These are used along with the CPU and memory targets.
+	// +kubebuilder:validation:Optional
+	Metrics []autoscalingv2beta2.MetricSpec `json:"metrics,omitempty"`
+
+	// Scaling behavior of the HPA in the up and down directions.
+	// Honoured by Kubernetes 1.18 and later.
+	// +kubebuilder:validation:Optional
+	Behavior *HPABehavior `json:"behavior,omitempty"`
+
+	// With CPU, the workers are scaled by an HPA based on the CPU utilization.
+	// With QueryLoad, the workers are scaled by the operator based on the query
+	// load reported by the coordinator. Defaults to CPU.
+	// +kubebuilder:validation:Enum=CPU;QueryLoad
+	// +kubebuilder:validation:Optional
+	Mode AutoscalingMode `json:"mode,omitempty"`
+
+	// Applicable only when mode is QueryLoad
+	// +kubebuilder:validation:Optional
+	QueryLoad *QueryLoadAutoscalingSpec `json:"queryLoad,omitempty"`
+}
+
+// Same as HorizontalPodAutoscalerBehavior of the newer Kubernetes API
+// +k8s:openapi-gen=true
+type HPABehavior struct {
+	// +kubebuilder:validation:Optional
+	ScaleUp *HPAScalingRules `json:"scaleUp,omitempty"`
+	// +kubebuilder:validation:Optional
+	ScaleDown *HPAScalingRules `json:"scaleDown,omitempty"`
+}
+
+// +k8s:openapi-gen=true
+type HPAScalingRules struct {
+	// Number of seconds for which past recommendations are considered while scaling
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=3600
+	// +kubebuilder:validation:Optional
+	StabilizationWindowSeconds *int32 `json:"stabilizationWindowSeconds,omitempty"`
+	// Which of the policies is used. Disabled turns off scaling in this direction
+	// +kubebuilder:validation:Enum=Max;Min;Disabled
+	// +kubebuilder:validation:Optional
+	SelectPolicy *HPAScalingPolicySelect `json:"selectPolicy,omitempty"`
+	// +kubebuilder:validation:Optional
+	Policies []HPAScalingPolicy `json:"policies,omitempty"`
+}
+
+// +k8s:openapi-gen=true
+type HPAScalingPolicySelect string
+
+const (
+	MaxPolicySelect      HPAScalingPolicySelect = "Max"
+	MinPolicySelect      HPAScalingPolicySelect = "Min"
+	DisabledPolicySelect HPAScalingPolicySelect = "Disabled"
+)
+
+// +k8s:openapi-gen=true
+type HPAScalingPolicy struct {
+	// +kubebuilder:validation:Enum=Pods;Percent
+	// +kubebuilder:validation:Required
+	Type string `json:"type"`
+	// Number of pods or percentage of the pods that can be added or removed
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Required
+	Value int32 `json:"value"`
+	// Window in which the change is limited by this policy
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=1800
+	// +kubebuilder:validation:Required
+	PeriodSeconds int32 `json:"periodSeconds"`
+}
+
+// +k8s:openapi-gen=true
+type AutoscalingMode string
+
+const (
+	CPUAutoscalingMode       AutoscalingMode = "CPU"
+	QueryLoadAutoscalingMode AutoscalingMode = "QueryLoad"
+)
+
+// The number of workers needed for each of the targets is computed and the largest
+// of them is taken as the desired number of workers.
+// +k8s:openapi-gen=true
+type QueryLoadAutoscalingSpec struct {
+	// Number of queued queries per worker
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Optional
+	TargetQueuedQueriesPerWorker *int32 `json:"targetQueuedQueriesPerWorker,omitempty"`
+	// Number of running queries per worker
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Optional
+	TargetRunningQueriesPerWorker *int32 `json:"targetRunningQueriesPerWorker,omitempty"`
+	// Number of splits of the running queries that are waiting for a worker thread, per worker.
+	// The queued splits are used in place of the blocked splits, which Presto reports only in
+	// the details of each query.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Optional
+	TargetQueuedSplitsPerWorker *int32 `json:"targetQueuedSplitsPerWorker,omitempty"`
+	// The workers are scaled up only to the lowest number of workers desired
+	// during this window. Defaults to 60 seconds.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Optional
+	ScaleUpStabilizationWindowSeconds *int32 `json:"scaleUpStabilizationWindowSeconds,omitempty"`
+	// The workers are scaled down only to the highest number of workers desired
+	// during this window. Defaults to 300 seconds.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Optional
+	ScaleDownStabilizationWindowSeconds *int32 `json:"scaleDownStabilizationWindowSeconds,omitempty"`
+	// Minimum time after the last scaling before the workers are scaled up. Defaults to 60 seconds.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Optional
+	ScaleUpCooldownSeconds *int32 `json:"scaleUpCooldownSeconds,omitempty"`
+	// Minimum time after the last scaling before the workers are scaled down. Defaults to 300 seconds.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Optional
+	ScaleDownCooldownSeconds *int32 `json:"scaleDownCooldownSeconds,omitempty"`
 }
 
 // +k8s:openapi-gen=true
@@ -220,8 +583,133 @@ type ServiceSpec struct {
 	IPFamily *v1.IPFamily `json:"ipFamily,omitempty" protobuf:"bytes,15,opt,name=ipFamily,Configcasttype=IPFamily"`
 }
 
+// Environment variables and containers added to the presto pods
+// +k8s:openapi-gen=true
+type PodExtensionsSpec struct {
+	// Environment variables of the presto container. A variable set for the role
+	// overrides the one of the same name in spec.common.
+	// +kubebuilder:validation:Optional
+	Env []v1.EnvVar `json:"env,omitempty"`
+	// Sources of the environment variables of the presto container
+	// +kubebuilder:validation:Optional
+	EnvFrom []v1.EnvFromSource `json:"envFrom,omitempty"`
+	// Containers run before the presto container. They can mount the volumes of the pod.
+	// +kubebuilder:validation:Optional
+	InitContainers []v1.Container `json:"initContainers,omitempty"`
+	// Containers run along with the presto container. They can mount the volumes of the pod.
+	// +kubebuilder:validation:Optional
+	Sidecars []v1.Container `json:"sidecars,omitempty"`
+}
+
+// Scheduling constraints of the presto pods
+// +k8s:openapi-gen=true
+type SchedulingSpec struct {
+	// +kubebuilder:validation:Optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	// +kubebuilder:validation:Optional
+	Tolerations []v1.Toleration `json:"tolerations,omitempty"`
+	// By default, the coordinator and the workers of a cluster prefer not to run on
+	// the same node. The default is not added when podAntiAffinity is specified.
+	// +kubebuilder:validation:Optional
+	Affinity *v1.Affinity `json:"affinity,omitempty"`
+	// Alpha in Kubernetes 1.16 and needs the EvenPodsSpread feature gate till 1.18.
+	// +kubebuilder:validation:Optional
+	TopologySpreadConstraints []v1.TopologySpreadConstraint `json:"topologySpreadConstraints,omitempty"`
+	// +kubebuilder:validation:Optional
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+}
+
+// +k8s:openapi-gen=true
+type StorageType string
+
+const (
+	// volume that lives as long as the pod on the disk of the node, or in memory
+	EmptyDirStorage StorageType = "EmptyDir"
+	// directory of the node e.g. the mount point of a local SSD
+	HostPathStorage StorageType = "HostPath"
+)
+
+// +k8s:openapi-gen=true
+type StorageSpec struct {
+	// +kubebuilder:validation:Enum=EmptyDir;HostPath
+	// +kubebuilder:validation:Required
+	Type StorageType `json:"type"`
+	// Medium of an EmptyDir volume. With Memory, a tmpfs is mounted and the data
+	// counts against the memory limit of the container.
+	// +kubebuilder:validation:Enum=Memory
+	// +kubebuilder:validation:Optional
+	Medium v1.StorageMedium `json:"medium,omitempty"`
+	// Size limit of an EmptyDir volume e.g. 50Gi
+	// +kubebuilder:validation:Optional
+	SizeLimit string `json:"sizeLimit,omitempty"`
+	// Path on the node of a HostPath volume. Required when type is HostPath.
+	// +kubebuilder:validation:Optional
+	Path string `json:"path,omitempty"`
+	// Spills to the volume the queries that do not fit in memory. Applicable to the workers.
+	// +kubebuilder:validation:Optional
+	SpillEnabled bool `json:"spillEnabled,omitempty"`
+	// Request of ephemeral-storage of the container e.g. 10Gi
+	// +kubebuilder:validation:Optional
+	EphemeralStorageRequest string `json:"ephemeralStorageRequest,omitempty"`
+	// Limit of ephemeral-storage of the container e.g. 20Gi. The pod is evicted when its
+	// container layer, logs and EmptyDir volumes on the disk exceed it.
+	// +kubebuilder:validation:Optional
+	EphemeralStorageLimit string `json:"ephemeralStorageLimit,omitempty"`
+}
+
 // +k8s:openapi-gen=true
 type HMSSpec struct {
+	// Deploys a Hive Metastore along with the cluster and adds a hive catalog that uses it
+	// +kubebuilder:validation:Optional
+	Enabled bool `json:"enabled,omitempty"`
+	// Image of the metastore. The image has to run the metastore when the SERVICE_NAME
+	// environment variable is metastore. Defaults to apache/hive:3.1.3.
+	// +kubebuilder:validation:Optional
+	Image string `json:"image,omitempty"`
+	// Location of the warehouse of managed tables. It has to be reachable from the
+	// presto pods e.g. an s3a:// or hdfs:// location. Defaults to /opt/hive/data/warehouse.
+	// +kubebuilder:validation:Optional
+	WarehouseDir string `json:"warehouseDir,omitempty"`
+	// Database that stores the metadata. Defaults to an embedded Derby database
+	// that is lost when the metastore pod restarts.
+	// +kubebuilder:validation:Optional
+	Database *HMSDatabaseSpec `json:"database,omitempty"`
+	// +kubebuilder:validation:Optional
+	MemoryLimit string `json:"memoryLimit,omitempty"`
+	// +kubebuilder:validation:Optional
+	CpuLimit string `json:"cpuLimit,omitempty"`
+	// Additional properties of hive-site.xml
+	// +kubebuilder:validation:Optional
+	AdditionalProps map[string]string `json:"additionalProps,omitempty"`
+	// Name of the catalog added for the metastore. Defaults to hive.
+	// +kubebuilder:validation:Optional
+	CatalogName string `json:"catalogName,omitempty"`
+	// Additional properties of the hive catalog e.g. the credentials of the object store
+	// +kubebuilder:validation:Optional
+	CatalogProps map[string]string `json:"catalogProps,omitempty"`
+}
+
+// +k8s:openapi-gen=true
+type HMSDatabaseSpec struct {
+	// Type of the database as known to the hive schematool
+	// +kubebuilder:validation:Enum=derby;postgres;mysql;oracle;mssql
+	// +kubebuilder:validation:Required
+	Type string `json:"type"`
+	// JDBC URL of an external database. Required for all types but derby.
+	// +kubebuilder:validation:Optional
+	JDBCURL string `json:"jdbcURL,omitempty"`
+	// JDBC driver class. The driver has to be present in the image.
+	// +kubebuilder:validation:Optional
+	DriverClassName string `json:"driverClassName,omitempty"`
+	// Secret in the same namespace holding the user name and the password of the database
+	// +kubebuilder:validation:Optional
+	CredentialsSecret string `json:"credentialsSecret,omitempty"`
+	// Key of the user name in the secret. Defaults to username.
+	// +kubebuilder:validation:Optional
+	UsernameKey string `json:"usernameKey,omitempty"`
+	// Key of the password in the secret. Defaults to password.
+	// +kubebuilder:validation:Optional
+	PasswordKey string `json:"passwordKey,omitempty"`
 }
 
 // +k8s:openapi-gen=true
@@ -247,9 +735,9 @@ type PrestoSpec struct {
 	// +kubebuilder:validation:Optional
 	Service ServiceSpec `json:"service,omitempty"`
 	// +kubebuilder:validation:Optional
-	InternalHiveMetaStore HMSSpec `json:"internalHiveMetaStore",omitempty`
+	InternalHiveMetaStore HMSSpec `json:"internalHiveMetaStore,omitempty"`
 	// +kubebuilder:validation:Optional
-	ImageDetails ImageSpec `json:"imageDetails",omitempty`
+	ImageDetails ImageSpec `json:"imageDetails,omitempty"`
 	//additionalPrestoPropFiles:
 	//   access-control.properties: |
 	//    access-control.name=read-only
@@ -259,8 +747,128 @@ type PrestoSpec struct {
 	//    jdbc.user=myuser
 	//    jdbc.password=mypassword
 	// +kubebuilder:validation:Optional
-	AdditionalPrestoPropFiles map[string]string `json:"additionalPrestoPropFiles",omitempty`
+	AdditionalPrestoPropFiles map[string]string `json:"additionalPrestoPropFiles,omitempty"`
 	Volumes []PrestoVolumeSpec `json:"volumes,omitempty"`
+	// Additional groups of workers with their own resources, scheduling and scaling.
+	// They register with the same coordinator as the workers of spec.worker.
+	// +kubebuilder:validation:Optional
+	WorkerPools []WorkerPoolSpec `json:"workerPools,omitempty"`
+	// Scales the coordinator and the workers to zero. The configuration, the services and
+	// the UUID of the cluster are retained. Setting it back to false resumes the cluster.
+	// +kubebuilder:validation:Optional
+	Suspend *bool `json:"suspend,omitempty"`
+	// Hibernates the cluster when it has not run any queries for a while
+	// +kubebuilder:validation:Optional
+	IdlePolicy *IdlePolicySpec `json:"idlePolicy,omitempty"`
+	// Environment variables and containers added to the pods of both the coordinator
+	// and the workers
+	// +kubebuilder:validation:Optional
+	Common *PodExtensionsSpec `json:"common,omitempty"`
+	// Encrypts the traffic between the coordinator and the workers. Requires
+	// spec.coordinator.httpsEnabled.
+	// +kubebuilder:validation:Optional
+	InternalTLS *InternalTLSSpec `json:"internalTLS,omitempty"`
+	// Authentication of the users of the coordinator. Requires spec.coordinator.httpsEnabled
+	// and cannot be used along with internalTLS.
+	// +kubebuilder:validation:Optional
+	Authentication *AuthenticationSpec `json:"authentication,omitempty"`
+}
+
+// Authentication of the users that connect to the HTTPS port of the coordinator
+// +k8s:openapi-gen=true
+type AuthenticationSpec struct {
+	// Password authenticates the users with the password file managed by the operator.
+	// Defaults to Password.
+	// +kubebuilder:validation:Enum=Password
+	// +kubebuilder:validation:Optional
+	Mode AuthenticationMode `json:"mode,omitempty"`
+	// Users of the Password mode
+	// +kubebuilder:validation:Optional
+	Password *PasswordAuthenticationSpec `json:"password,omitempty"`
+}
+
+type AuthenticationMode string
+
+const (
+	AuthenticationPassword AuthenticationMode = "Password"
+)
+
+// The operator generates the password file of the coordinator from the users in a Secret
+// +k8s:openapi-gen=true
+type PasswordAuthenticationSpec struct {
+	// Name of a Secret in the namespace of the cluster. Its keys are the names of the users
+	// and the values are their passwords.
+	// +kubebuilder:validation:Required
+	UsersSecret string `json:"usersSecret"`
+}
+
+// Certificate of the presto nodes generated by the operator for the internal communication.
+// The nodes serve only HTTPS and authenticate each other with a shared secret.
+// +k8s:openapi-gen=true
+type InternalTLSSpec struct {
+	// Validity of the certificate of the nodes. Defaults to 2160h.
+	// +kubebuilder:validation:Optional
+	Duration *metav1.Duration `json:"duration,omitempty"`
+	// The certificate is renewed this long before it expires. Defaults to 720h.
+	// +kubebuilder:validation:Optional
+	RenewBefore *metav1.Duration `json:"renewBefore,omitempty"`
+}
+
+// A group of workers managed by a ReplicaSet of its own. The fields that are not
+// specified are taken from spec.worker, except count and autoscaling.
+// +k8s:openapi-gen=true
+type WorkerPoolSpec struct {
+	// Name of the pool. It is a part of the names of the resources of the pool.
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	// +kubebuilder:validation:MaxLength=40
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+	// +kubebuilder:validation:Maximum=10000
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Optional
+	Count *int32 `json:"count,omitempty"`
+	// +kubebuilder:validation:Optional
+	MemoryLimit string `json:"memoryLimit,omitempty"`
+	// +kubebuilder:validation:Optional
+	CpuLimit string `json:"cpuLimit,omitempty"`
+	// +kubebuilder:validation:Optional
+	CpuRequest string `json:"cpuRequest,omitempty"`
+	// +kubebuilder:validation:Optional
+	AdditionalJVMConfig string `json:"additionalJVMConfig,omitempty"`
+	// Merged with the additional properties of spec.worker. The properties of the pool win.
+	// +kubebuilder:validation:Optional
+	AdditionalProps map[string]string `json:"additionalProps,omitempty"`
+	// Autoscaling of the pool through an HPA. The QueryLoad mode is not supported for pools.
+	// +kubebuilder:validation:Optional
+	Autoscaling *AutoscalingSpec `json:"autoscaling,omitempty"`
+	// +kubebuilder:validation:Optional
+	Storage *StorageSpec `json:"storage,omitempty"`
+
+	SchedulingSpec `json:",inline"`
+}
+
+// +k8s:openapi-gen=true
+type WorkerPoolStatus struct {
+	Name string `json:"name"`
+	// +kubebuilder:validation:Optional
+	ReplicaSet string `json:"replicaSet,omitempty"`
+	// +kubebuilder:validation:Optional
+	HpaName string `json:"hpaName,omitempty"`
+	// +kubebuilder:validation:Optional
+	PdbName string `json:"pdbName,omitempty"`
+	// +kubebuilder:validation:Optional
+	DesiredWorkers int32 `json:"desiredWorkers"`
+	// +kubebuilder:validation:Optional
+	CurrentWorkers int32 `json:"currentWorkers"`
+}
+
+// +k8s:openapi-gen=true
+type IdlePolicySpec struct {
+	// Minutes without running or queued queries after which the cluster is hibernated.
+	// A hibernated cluster is resumed by annotating it with falarica.io/resume.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Required
+	HibernateAfterMinutes int32 `json:"hibernateAfterMinutes"`
 }
 
 type PrestoVolumeSpec struct {
@@ -320,8 +928,12 @@ type PrestoStatus struct {
 	// +kubebuilder:validation:Optional
 	WorkerReplicaset string `json:"workerReplicaset"`
 	// +kubebuilder:validation:Optional
+	WorkerDeployment string `json:"workerDeployment,omitempty"`
+	// +kubebuilder:validation:Optional
 	CoordinatorReplicaset string `json:"coordinatorReplicaset"`
 	// +kubebuilder:validation:Optional
+	CoordinatorStatefulSet string `json:"coordinatorStatefulSet,omitempty"`
+	// +kubebuilder:validation:Optional
 	HpaName string `json:"hpaName"`
 	// +kubebuilder:validation:Optional
 	ClusterState ClusterState `json:"clusterState"`
@@ -333,8 +945,143 @@ type PrestoStatus struct {
 	CoordinatorCPU string `json:"coordinatorCPU,omitempty"`
 	// +kubebuilder:validation:Optional
 	WorkerCPU string `json:"workerCPU,omitempty"`
+	// Hash of the configuration that all the pods of the cluster are running with
+	// +kubebuilder:validation:Optional
+	ConfigHash string `json:"configHash,omitempty"`
+	// Progress of the rolling restart that applies a changed configuration
+	// +kubebuilder:validation:Optional
+	RolloutState RolloutState `json:"rolloutState,omitempty"`
+	// Number of workers running with the latest configuration
+	// +kubebuilder:validation:Optional
+	UpdatedWorkers int32 `json:"updatedWorkers,omitempty"`
+	// Latest observations of the state of the cluster
+	// +kubebuilder:validation:Optional
+	Conditions []PrestoCondition `json:"conditions,omitempty"`
+	// Following are reported by the coordinator
+	// +kubebuilder:validation:Optional
+	PrestoVersion string `json:"prestoVersion,omitempty"`
+	// Workers registered with the coordinator
+	// +kubebuilder:validation:Optional
+	ActiveWorkers int32 `json:"activeWorkers,omitempty"`
+	// Nodes responding to the coordinator
+	// +kubebuilder:validation:Optional
+	ActiveNodes int32 `json:"activeNodes,omitempty"`
+	// Nodes that the coordinator has failed to reach
+	// +kubebuilder:validation:Optional
+	FailedNodes int32 `json:"failedNodes,omitempty"`
+	// +kubebuilder:validation:Optional
+	RunningQueries int32 `json:"runningQueries,omitempty"`
+	// +kubebuilder:validation:Optional
+	QueuedQueries int32 `json:"queuedQueries,omitempty"`
+	// +kubebuilder:validation:Optional
+	BlockedQueries int32 `json:"blockedQueries,omitempty"`
+	// Last time the operator scaled the workers based on the query load
+	// +kubebuilder:validation:Optional
+	LastScaleTime *metav1.Time `json:"lastScaleTime,omitempty"`
+	// Workers that are draining their tasks before they are removed on a scale down
+	// +kubebuilder:validation:Optional
+	DecommissioningWorkers []string `json:"decommissioningWorkers,omitempty"`
+	// Workers of each worker pool
+	// +kubebuilder:validation:Optional
+	WorkerPools []WorkerPoolStatus `json:"workerPools,omitempty"`
+	// Name of the scaling schedule whose window is active
+	// +kubebuilder:validation:Optional
+	ActiveSchedule string `json:"activeSchedule,omitempty"`
+	// Next time at which the active scaling schedule changes
+	// +kubebuilder:validation:Optional
+	NextScheduleTransition *metav1.Time `json:"nextScheduleTransition,omitempty"`
+	// Why the cluster is hibernated, Suspended or Idle
+	// +kubebuilder:validation:Optional
+	HibernationReason HibernationReason `json:"hibernationReason,omitempty"`
+	// Time at which the cluster was hibernated
+	// +kubebuilder:validation:Optional
+	HibernatedAt *metav1.Time `json:"hibernatedAt,omitempty"`
+	// Number of workers before the cluster was hibernated. Restored on resume.
+	// +kubebuilder:validation:Optional
+	HibernatedWorkers int32 `json:"hibernatedWorkers,omitempty"`
+	// Last time queries were seen running or queued on the cluster. Tracked with the idle policy.
+	// +kubebuilder:validation:Optional
+	LastActivityTime *metav1.Time `json:"lastActivityTime,omitempty"`
+	// Deployment of the Hive Metastore deployed by the operator
+	// +kubebuilder:validation:Optional
+	HiveMetastore string `json:"hiveMetastore,omitempty"`
+	// Thrift URI of the Hive Metastore deployed by the operator
+	// +kubebuilder:validation:Optional
+	HiveMetastoreURI string `json:"hiveMetastoreURI,omitempty"`
+	// PodDisruptionBudget of the coordinator
+	// +kubebuilder:validation:Optional
+	CoordinatorPDB string `json:"coordinatorPDB,omitempty"`
+	// PodDisruptionBudget of the workers
+	// +kubebuilder:validation:Optional
+	WorkerPDB string `json:"workerPDB,omitempty"`
+	// Secret that holds the certificate of the coordinator provisioned by the operator
+	// +kubebuilder:validation:Optional
+	TlsSecret string `json:"tlsSecret,omitempty"`
+	// Expiry of the certificate of the coordinator provisioned by the operator
+	// +kubebuilder:validation:Optional
+	TlsNotAfter *metav1.Time `json:"tlsNotAfter,omitempty"`
+	// Secret that holds the certificate of the presto nodes for the internal communication
+	// +kubebuilder:validation:Optional
+	InternalTlsSecret string `json:"internalTlsSecret,omitempty"`
+	// Expiry of the certificate of the presto nodes for the internal communication
+	// +kubebuilder:validation:Optional
+	InternalTlsNotAfter *metav1.Time `json:"internalTlsNotAfter,omitempty"`
+	// Secret that holds internal-communication.shared-secret of the cluster
+	// +kubebuilder:validation:Optional
+	SharedSecret string `json:"sharedSecret,omitempty"`
+	// Last time the shared secret was generated
+	// +kubebuilder:validation:Optional
+	SharedSecretRotationTime *metav1.Time `json:"sharedSecretRotationTime,omitempty"`
+	// Secret that holds the password file of the coordinator
+	// +kubebuilder:validation:Optional
+	PasswordFileSecret string `json:"passwordFileSecret,omitempty"`
+}
+
+// PrestoCondition has the same fields as the Condition type of the newer Kubernetes API
+// so that kubectl wait and the tools that understand conditions work with Presto.
+// +k8s:openapi-gen=true
+type PrestoCondition struct {
+	// +kubebuilder:validation:Required
+	Type PrestoConditionType `json:"type"`
+	// +kubebuilder:validation:Enum=True;False;Unknown
+	// +kubebuilder:validation:Required
+	Status v1.ConditionStatus `json:"status"`
+	// Generation of the Presto resource that the condition was set for
+	// +kubebuilder:validation:Optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Last time the condition changed from one status to another
+	// +kubebuilder:validation:Required
+	LastTransitionTime metav1.Time `json:"lastTransitionTime"`
+	// Reason for the last transition in CamelCase
+	// +kubebuilder:validation:Required
+	Reason string `json:"reason"`
+	// Human readable details about the last transition
+	// +kubebuilder:validation:Optional
+	Message string `json:"message"`
 }
 
+// +k8s:openapi-gen=true
+type PrestoConditionType string
+
+const (
+	// all the components of the cluster are up and running with the latest configuration
+	ConditionReady PrestoConditionType = "Ready"
+	// coordinator pod is ready
+	ConditionCoordinatorReady PrestoConditionType = "CoordinatorReady"
+	// desired number of workers are available
+	ConditionWorkersAvailable PrestoConditionType = "WorkersAvailable"
+	// all the pods are running with the latest configuration
+	ConditionConfigApplied PrestoConditionType = "ConfigApplied"
+	// HPA for the workers is configured
+	ConditionAutoscalerReady PrestoConditionType = "AutoscalerReady"
+	// catalogs in the spec are valid and the catalog secrets are present
+	ConditionCatalogsValid PrestoConditionType = "CatalogsValid"
+	// Hive Metastore deployed by the operator is available
+	ConditionHiveMetastoreReady PrestoConditionType = "HiveMetastoreReady"
+	// operator failed to reconcile the cluster
+	ConditionDegraded PrestoConditionType = "Degraded"
+)
+
 // +k8s:openapi-gen=true
 type ClusterState string
 
@@ -343,6 +1090,27 @@ const (
 	ClusterReadyState  ClusterState = "Ready"
 	ClusterPending ClusterState = "Pending"
 	ClusterUnknown ClusterState = "Unknown"
+	ClusterHibernated ClusterState = "Hibernated"
+)
+
+// +k8s:openapi-gen=true
+type HibernationReason string
+
+const (
+	HibernationSuspended HibernationReason = "Suspended"
+	HibernationIdle      HibernationReason = "Idle"
+)
+
+// +k8s:openapi-gen=true
+type RolloutState string
+
+const (
+	// no rollout is in progress
+	RolloutComplete RolloutState = ""
+	// workers are being restarted one at a time
+	RolloutWorkers RolloutState = "RollingWorkers"
+	// all workers have been restarted, coordinator is being restarted
+	RolloutCoordinator RolloutState = "RollingCoordinator"
 )
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -353,8 +1121,13 @@ const (
 // +kubebuilder:resource:path=prestos,scope=Namespaced
 // +kubebuilder:printcolumn:name="Coordinator",type="string",JSONPath=`.status.coordinatorAddress`
 // +kubebuilder:printcolumn:name="ClusterState",type="string",JSONPath=`.status.clusterState`
+// +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=`.status.conditions[?(@.type=="Ready")].status`
 // +kubebuilder:printcolumn:name="CoordinatorCPU",type="string",JSONPath=`.status.coordinatorCPU`
 // +kubebuilder:printcolumn:name="WorkersCPU",type="string",JSONPath=`.status.workerCPU`
+// +kubebuilder:printcolumn:name="Version",type="string",JSONPath=`.status.prestoVersion`,priority=1
+// +kubebuilder:printcolumn:name="RunningQueries",type="integer",JSONPath=`.status.runningQueries`,priority=1
+// +kubebuilder:printcolumn:name="QueuedQueries",type="integer",JSONPath=`.status.queuedQueries`,priority=1
+// +kubebuilder:printcolumn:name="Schedule",type="string",JSONPath=`.status.activeSchedule`,priority=1
 // +kubebuilder:printcolumn:name="DesiredWorkers",type="string",JSONPath=`.status.desiredWorkers`
 // +kubebuilder:printcolumn:name="CurrentWorkers",type="string",JSONPath=`.status.currentWorkers`
 type Presto struct {
@@ -25,7 +25,11 @@ var _ webhook.Validator = &Presto{}
 func (r *Presto) ValidateCreate() error {
 	log.Info("validate create", "name", r.Name)
 
-	return nil
+	errs := r.validateHTTPSPassword()
+	errs = append(errs, r.validateTLS()...)
+	errs = append(errs, r.validateInternalTLS()...)
+	errs = append(errs, r.validateAuthentication()...)
+	return r.toInvalidError(errs)
 }
 
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
@@ -37,6 +41,14 @@ func (r *Presto) ValidateUpdate(old runtime.Object) error {
 
 func (r *Presto) validatePrestoUpdate(old runtime.Object) error {
 	errs := r.validatePrestoSpec(old.(*Presto))
+	errs = append(errs, r.validateHTTPSPassword()...)
+	errs = append(errs, r.validateTLS()...)
+	errs = append(errs, r.validateInternalTLS()...)
+	errs = append(errs, r.validateAuthentication()...)
+	return r.toInvalidError(errs)
+}
+
+func (r *Presto) toInvalidError(errs field.ErrorList) error {
 	if len(errs) == 0 {
 		return nil
 	}
@@ -45,13 +57,99 @@ func (r *Presto) validatePrestoUpdate(old runtime.Object) error {
 		r.Name, errs)
 }
 
+// the password of the keystore can be given either in plain text or in a secret
+func (r *Presto) validateHTTPSPassword() field.ErrorList {
+	var allErrs field.ErrorList
+	if len(r.Spec.Coordinator.HttpsKeyPairPassword) != 0 && r.Spec.Coordinator.HttpsKeyPairPasswordSecret != nil {
+		allErrs = append(allErrs, field.Forbidden(
+			field.NewPath("spec", "coordinator", "httpsKeyPairPassword"),
+			"cannot be specified along with httpsKeyPairPasswordSecret"))
+	}
+	return allErrs
+}
+
+// the certificate provisioned by the operator replaces the keystore of the user
+func (r *Presto) validateTLS() field.ErrorList {
+	var allErrs field.ErrorList
+	tls := r.Spec.Coordinator.Tls
+	if tls == nil {
+		return allErrs
+	}
+	tlsPath := field.NewPath("spec", "coordinator", "tls")
+	if len(r.Spec.Coordinator.HttpsKeyPairSecretName) != 0 {
+		allErrs = append(allErrs, field.Forbidden(
+			field.NewPath("spec", "coordinator", "httpsKeyPairSecretName"),
+			"cannot be specified along with tls"))
+	}
+	if tls.Mode == TLSCertManager && tls.IssuerRef == nil {
+		allErrs = append(allErrs, field.Required(tlsPath.Child("issuerRef"),
+			"has to be specified when the mode is CertManager"))
+	}
+	if tls.Duration != nil && tls.RenewBefore != nil && tls.Duration.Duration <= tls.RenewBefore.Duration {
+		allErrs = append(allErrs, field.Invalid(tlsPath.Child("renewBefore"), tls.RenewBefore.Duration.String(),
+			"has to be less than duration"))
+	}
+	return allErrs
+}
+
+// the nodes serve only HTTPS with internal TLS
+func (r *Presto) validateInternalTLS() field.ErrorList {
+	var allErrs field.ErrorList
+	internalTLS := r.Spec.InternalTLS
+	if internalTLS == nil {
+		return allErrs
+	}
+	if !r.Spec.Coordinator.HttpsEnabled {
+		allErrs = append(allErrs, field.Required(field.NewPath("spec", "coordinator", "httpsEnabled"),
+			"has to be true when internalTLS is specified"))
+	}
+	if len(r.Spec.Coordinator.HttpsKeyPairSecretName) != 0 {
+		allErrs = append(allErrs, field.Forbidden(
+			field.NewPath("spec", "coordinator", "httpsKeyPairSecretName"),
+			"cannot be specified along with internalTLS"))
+	}
+	if internalTLS.Duration != nil && internalTLS.RenewBefore != nil &&
+		internalTLS.Duration.Duration <= internalTLS.RenewBefore.Duration {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "internalTLS", "renewBefore"),
+			internalTLS.RenewBefore.Duration.String(), "has to be less than duration"))
+	}
+	return allErrs
+}
+
+// presto authenticates the users only on the HTTPS port
+func (r *Presto) validateAuthentication() field.ErrorList {
+	var allErrs field.ErrorList
+	auth := r.Spec.Authentication
+	if auth == nil {
+		return allErrs
+	}
+	authPath := field.NewPath("spec", "authentication")
+	if !r.Spec.Coordinator.HttpsEnabled {
+		allErrs = append(allErrs, field.Required(field.NewPath("spec", "coordinator", "httpsEnabled"),
+			"has to be true when authentication is specified"))
+	}
+	if r.Spec.InternalTLS != nil {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec", "internalTLS"),
+			"cannot be specified along with authentication"))
+	}
+	if auth.Password == nil || len(auth.Password.UsersSecret) == 0 {
+		allErrs = append(allErrs, field.Required(authPath.Child("password", "usersSecret"),
+			"has to be specified when the mode is Password"))
+	}
+	if _, ok := r.Spec.AdditionalPrestoPropFiles["password-authenticator.properties"]; ok {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec", "additionalPrestoPropFiles"),
+			"cannot have password-authenticator.properties along with authentication"))
+	}
+	return allErrs
+}
+
 func (r *Presto) validatePrestoSpec(old *Presto) field.ErrorList {
 	// The field helpers from the kubernetes API machinery help us return nicely
 	// structured validation errors.
 	var allErrs field.ErrorList
 	if (old.Spec.Coordinator.CpuRequest != r.Spec.Coordinator.CpuRequest) {
-		err := &field.Error{"FieldImmutable", "Field is Immutable",
-			"Spec.Coordinator.CpuRequest", "Field Spec.Coordinator.CpuRequest is Immutable"}
+		err := &field.Error{Type: "FieldImmutable", Field: "Field is Immutable",
+			BadValue: "Spec.Coordinator.CpuRequest", Detail: "Field Spec.Coordinator.CpuRequest is Immutable"}
 		allErrs = append(allErrs, err)
 	}
 	return allErrs
@@ -11,18 +11,68 @@ import (
 
 func GetOpenAPIDefinitions(ref common.ReferenceCallback) map[string]common.OpenAPIDefinition {
 	return map[string]common.OpenAPIDefinition{
-		"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.AutoscalingSpec": schema_pkg_apis_falarica_v1alpha1_AutoscalingSpec(ref),
-		"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.CatalogList":     schema_pkg_apis_falarica_v1alpha1_CatalogList(ref),
-		"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.CatalogSecret":   schema_pkg_apis_falarica_v1alpha1_CatalogSecret(ref),
-		"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.CatalogSpec":     schema_pkg_apis_falarica_v1alpha1_CatalogSpec(ref),
-		"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.CoordinatorSpec": schema_pkg_apis_falarica_v1alpha1_CoordinatorSpec(ref),
-		"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.HMSSpec":         schema_pkg_apis_falarica_v1alpha1_HMSSpec(ref),
-		"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.ImageSpec":       schema_pkg_apis_falarica_v1alpha1_ImageSpec(ref),
-		"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.Presto":          schema_pkg_apis_falarica_v1alpha1_Presto(ref),
-		"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.PrestoSpec":      schema_pkg_apis_falarica_v1alpha1_PrestoSpec(ref),
-		"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.PrestoStatus":    schema_pkg_apis_falarica_v1alpha1_PrestoStatus(ref),
-		"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.ServiceSpec":     schema_pkg_apis_falarica_v1alpha1_ServiceSpec(ref),
-		"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.WorkerSpec":      schema_pkg_apis_falarica_v1alpha1_WorkerSpec(ref),
+		"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.AuthenticationSpec":         schema_pkg_apis_falarica_v1alpha1_AuthenticationSpec(ref),
+		"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.AutoscalingSpec":            schema_pkg_apis_falarica_v1alpha1_AutoscalingSpec(ref),
+		"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.CatalogList":                schema_pkg_apis_falarica_v1alpha1_CatalogList(ref),
+		"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.CatalogSecret":              schema_pkg_apis_falarica_v1alpha1_CatalogSecret(ref),
+		"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.CatalogSpec":                schema_pkg_apis_falarica_v1alpha1_CatalogSpec(ref),
+		"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.CoordinatorSpec":            schema_pkg_apis_falarica_v1alpha1_CoordinatorSpec(ref),
+		"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.DataVolumeSpec":             schema_pkg_apis_falarica_v1alpha1_DataVolumeSpec(ref),
+		"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.DisruptionBudgetSpec":       schema_pkg_apis_falarica_v1alpha1_DisruptionBudgetSpec(ref),
+		"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.HMSDatabaseSpec":            schema_pkg_apis_falarica_v1alpha1_HMSDatabaseSpec(ref),
+		"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.HMSSpec":                    schema_pkg_apis_falarica_v1alpha1_HMSSpec(ref),
+		"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.HPABehavior":                schema_pkg_apis_falarica_v1alpha1_HPABehavior(ref),
+		"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.HPAScalingPolicy":           schema_pkg_apis_falarica_v1alpha1_HPAScalingPolicy(ref),
+		"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.HPAScalingRules":            schema_pkg_apis_falarica_v1alpha1_HPAScalingRules(ref),
+		"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.IdlePolicySpec":             schema_pkg_apis_falarica_v1alpha1_IdlePolicySpec(ref),
+		"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.ImageSpec":                  schema_pkg_apis_falarica_v1alpha1_ImageSpec(ref),
+		"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.InternalTLSSpec":            schema_pkg_apis_falarica_v1alpha1_InternalTLSSpec(ref),
+		"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.IssuerReference":            schema_pkg_apis_falarica_v1alpha1_IssuerReference(ref),
+		"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.NodeTerminationSpec":        schema_pkg_apis_falarica_v1alpha1_NodeTerminationSpec(ref),
+		"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.PasswordAuthenticationSpec": schema_pkg_apis_falarica_v1alpha1_PasswordAuthenticationSpec(ref),
+		"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.PodExtensionsSpec":          schema_pkg_apis_falarica_v1alpha1_PodExtensionsSpec(ref),
+		"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.Presto":                     schema_pkg_apis_falarica_v1alpha1_Presto(ref),
+		"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.PrestoCondition":            schema_pkg_apis_falarica_v1alpha1_PrestoCondition(ref),
+		"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.PrestoSpec":                 schema_pkg_apis_falarica_v1alpha1_PrestoSpec(ref),
+		"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.PrestoStatus":               schema_pkg_apis_falarica_v1alpha1_PrestoStatus(ref),
+		"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.ProbesSpec":                 schema_pkg_apis_falarica_v1alpha1_ProbesSpec(ref),
+		"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.QueryLoadAutoscalingSpec":   schema_pkg_apis_falarica_v1alpha1_QueryLoadAutoscalingSpec(ref),
+		"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.ScalingSchedule":            schema_pkg_apis_falarica_v1alpha1_ScalingSchedule(ref),
+		"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.SchedulingSpec":             schema_pkg_apis_falarica_v1alpha1_SchedulingSpec(ref),
+		"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.ServiceSpec":                schema_pkg_apis_falarica_v1alpha1_ServiceSpec(ref),
+		"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.StorageSpec":                schema_pkg_apis_falarica_v1alpha1_StorageSpec(ref),
+		"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.TLSSpec":                    schema_pkg_apis_falarica_v1alpha1_TLSSpec(ref),
+		"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.WorkerPoolSpec":             schema_pkg_apis_falarica_v1alpha1_WorkerPoolSpec(ref),
+		"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.WorkerPoolStatus":           schema_pkg_apis_falarica_v1alpha1_WorkerPoolStatus(ref),
+		"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.WorkerSpec":                 schema_pkg_apis_falarica_v1alpha1_WorkerSpec(ref),
+	}
+}
+
+func schema_pkg_apis_falarica_v1alpha1_AuthenticationSpec(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "Authentication of the users that connect to the HTTPS port of the coordinator",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"mode": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Password authenticates the users with the password file managed by the operator. Defaults to Password.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"password": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Users of the Password mode",
+							Ref:         ref("github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.PasswordAuthenticationSpec"),
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.PasswordAuthenticationSpec"},
 	}
 }
 
@@ -56,9 +106,50 @@ func schema_pkg_apis_falarica_v1alpha1_AutoscalingSpec(ref common.ReferenceCallb
 							Format: "int32",
 						},
 					},
+					"targetMemoryUtilizationPercentage": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Target average memory utilization of the workers as a percentage of the requested memory",
+							Type:        []string{"integer"},
+							Format:      "int32",
+						},
+					},
+					"metrics": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Additional metrics of the HPA e.g. Pods, Object or External metrics. These are used along with the CPU and memory targets.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("k8s.io/api/autoscaling/v2beta2.MetricSpec"),
+									},
+								},
+							},
+						},
+					},
+					"behavior": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Scaling behavior of the HPA in the up and down directions. Honoured by Kubernetes 1.18 and later.",
+							Ref:         ref("github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.HPABehavior"),
+						},
+					},
+					"mode": {
+						SchemaProps: spec.SchemaProps{
+							Description: "With CPU, the workers are scaled by an HPA based on the CPU utilization. With QueryLoad, the workers are scaled by the operator based on the query load reported by the coordinator. Defaults to CPU.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"queryLoad": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Applicable only when mode is QueryLoad",
+							Ref:         ref("github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.QueryLoadAutoscalingSpec"),
+						},
+					},
 				},
 			},
 		},
+		Dependencies: []string{
+			"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.HPABehavior", "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.QueryLoadAutoscalingSpec", "k8s.io/api/autoscaling/v2beta2.MetricSpec"},
 	}
 }
 
@@ -223,327 +314,1817 @@ func schema_pkg_apis_falarica_v1alpha1_CoordinatorSpec(ref common.ReferenceCallb
 					},
 					"httpsKeyPairPassword": {
 						SchemaProps: spec.SchemaProps{
-							Type:   []string{"string"},
-							Format: "",
+							Description: "Password of the keystore in plain text. It is written to the config map of the coordinator. Deprecated: use httpsKeyPairPasswordSecret.",
+							Type:        []string{"string"},
+							Format:      "",
 						},
 					},
-				},
-				Required: []string{"memoryLimit", "cpuLimit"},
-			},
-		},
-	}
-}
-
-func schema_pkg_apis_falarica_v1alpha1_HMSSpec(ref common.ReferenceCallback) common.OpenAPIDefinition {
-	return common.OpenAPIDefinition{
-		Schema: spec.Schema{
-			SchemaProps: spec.SchemaProps{
-				Type: []string{"object"},
-			},
-		},
-	}
-}
-
-func schema_pkg_apis_falarica_v1alpha1_ImageSpec(ref common.ReferenceCallback) common.OpenAPIDefinition {
-	return common.OpenAPIDefinition{
-		Schema: spec.Schema{
-			SchemaProps: spec.SchemaProps{
-				Type: []string{"object"},
-				Properties: map[string]spec.Schema{
-					"name": {
+					"httpsKeyPairPasswordSecret": {
 						SchemaProps: spec.SchemaProps{
-							Type:   []string{"string"},
-							Format: "",
+							Description: "Key of a Secret that holds the password of the keystore. The password is passed to the coordinator in an environment variable. Cannot be used along with httpsKeyPairPassword.",
+							Ref:         ref("k8s.io/api/core/v1.SecretKeySelector"),
 						},
 					},
-					"prestoPath": {
+					"tls": {
 						SchemaProps: spec.SchemaProps{
-							Description: "\n\t+kubebuilder:validation:Optional",
-							Type:        []string{"string"},
-							Format:      "",
+							Description: "Certificate of the HTTPS port provisioned by the operator. Requires httpsEnabled and cannot be used along with httpsKeyPairSecretName.",
+							Ref:         ref("github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.TLSSpec"),
 						},
 					},
-				},
-				Required: []string{"name", "prestoPath"},
-			},
-		},
-	}
-}
-
-func schema_pkg_apis_falarica_v1alpha1_Presto(ref common.ReferenceCallback) common.OpenAPIDefinition {
-	return common.OpenAPIDefinition{
-		Schema: spec.Schema{
-			SchemaProps: spec.SchemaProps{
-				Description: "Presto is the Schema for the prestos API",
-				Type:        []string{"object"},
-				Properties: map[string]spec.Schema{
-					"kind": {
+					"workload": {
 						SchemaProps: spec.SchemaProps{
-							Description: "Kind is a string value representing the REST resource this object represents. Servers may infer this from the endpoint the client submits requests to. Cannot be updated. In CamelCase. More info: https://git.k8s.io/community/contributors/devel/sig-architecture/api-conventions.md#types-kinds",
+							Description: "Kind of the workload that manages the coordinator pod. With StatefulSet, the coordinator gets a stable network identity through the pod discovery service and can have a persistent volume for node.data-dir. Changing it on a running cluster replaces the coordinator. Defaults to ReplicaSet.",
 							Type:        []string{"string"},
 							Format:      "",
 						},
 					},
-					"apiVersion": {
+					"dataVolume": {
 						SchemaProps: spec.SchemaProps{
-							Description: "APIVersion defines the versioned schema of this representation of an object. Servers should convert recognized schemas to the latest internal value, and may reject unrecognized values. More info: https://git.k8s.io/community/contributors/devel/sig-architecture/api-conventions.md#resources",
-							Type:        []string{"string"},
-							Format:      "",
+							Description: "Persistent volume mounted at node.data-dir of the coordinator. Applicable only when workload is StatefulSet.",
+							Ref:         ref("github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.DataVolumeSpec"),
 						},
 					},
-					"metadata": {
+					"storage": {
 						SchemaProps: spec.SchemaProps{
-							Ref: ref("k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta"),
+							Description: "Volume mounted at node.data-dir of the coordinator. Cannot be used along with dataVolume.",
+							Ref:         ref("github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.StorageSpec"),
 						},
 					},
-					"spec": {
+					"probes": {
 						SchemaProps: spec.SchemaProps{
-							Ref: ref("github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.PrestoSpec"),
+							Description: "Overrides of the default probes of the coordinator",
+							Ref:         ref("github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.ProbesSpec"),
 						},
 					},
-					"status": {
+					"disruptionBudget": {
 						SchemaProps: spec.SchemaProps{
-							Ref: ref("github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.PrestoStatus"),
+							Description: "PodDisruptionBudget of the coordinator. Defaults to minAvailable 1, so a node drain waits till the coordinator pod is deleted by hand.",
+							Ref:         ref("github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.DisruptionBudgetSpec"),
 						},
 					},
-				},
-			},
-		},
-		Dependencies: []string{
-			"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.PrestoSpec", "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.PrestoStatus", "k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta"},
-	}
-}
-
-func schema_pkg_apis_falarica_v1alpha1_PrestoSpec(ref common.ReferenceCallback) common.OpenAPIDefinition {
-	return common.OpenAPIDefinition{
-		Schema: spec.Schema{
-			SchemaProps: spec.SchemaProps{
-				Description: "PrestoSpec defines the desired state of Presto",
-				Type:        []string{"object"},
-				Properties: map[string]spec.Schema{
-					"coordinator": {
+					"nodeSelector": {
 						SchemaProps: spec.SchemaProps{
-							Description: "INSERT ADDITIONAL SPEC FIELDS - desired state of Important: Run \"operator-sdk generate k8s\" to regenerate code after modifying this file Add custom validation using kubebuilder tags: https://book-v1.book.kubebuilder.io/beyond_basics/generating_crd.html\n\t+kubebuilder:validation:Required",
-							Ref:         ref("github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.CoordinatorSpec"),
+							Type: []string{"object"},
+							AdditionalProperties: &spec.SchemaOrBool{
+								Allows: true,
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Type:   []string{"string"},
+										Format: "",
+									},
+								},
+							},
 						},
 					},
-					"worker": {
+					"tolerations": {
 						SchemaProps: spec.SchemaProps{
-							Ref: ref("github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.WorkerSpec"),
+							Type: []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("k8s.io/api/core/v1.Toleration"),
+									},
+								},
+							},
 						},
 					},
-					"catalogs": {
+					"affinity": {
 						SchemaProps: spec.SchemaProps{
-							Ref: ref("github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.CatalogList"),
+							Description: "By default, the coordinator and the workers of a cluster prefer not to run on the same node. The default is not added when podAntiAffinity is specified.",
+							Ref:         ref("k8s.io/api/core/v1.Affinity"),
 						},
 					},
-					"service": {
+					"topologySpreadConstraints": {
 						SchemaProps: spec.SchemaProps{
-							Ref: ref("github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.ServiceSpec"),
+							Description: "Alpha in Kubernetes 1.16 and needs the EvenPodsSpread feature gate till 1.18.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("k8s.io/api/core/v1.TopologySpreadConstraint"),
+									},
+								},
+							},
 						},
 					},
-					"internalHiveMetaStore": {
+					"priorityClassName": {
 						SchemaProps: spec.SchemaProps{
-							Ref: ref("github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.HMSSpec"),
+							Type:   []string{"string"},
+							Format: "",
 						},
 					},
-					"imageDetails": {
+					"env": {
 						SchemaProps: spec.SchemaProps{
-							Ref: ref("github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.ImageSpec"),
+							Description: "Environment variables of the presto container. A variable set for the role overrides the one of the same name in spec.common.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("k8s.io/api/core/v1.EnvVar"),
+									},
+								},
+							},
 						},
 					},
-					"additionalPrestoPropFiles": {
+					"envFrom": {
 						SchemaProps: spec.SchemaProps{
-							Description: "additionalPrestoPropFiles:\n  access-control.properties: |\n   access-control.name=read-only\n event-listener.properties: |\n   event-listener.name=event-logger\n   jdbc.url=jdbc:postgresql://example.com:5432/eventlog\n   jdbc.user=myuser\n   jdbc.password=mypassword",
-							Type:        []string{"object"},
-							AdditionalProperties: &spec.SchemaOrBool{
-								Allows: true,
+							Description: "Sources of the environment variables of the presto container",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
 								Schema: &spec.Schema{
 									SchemaProps: spec.SchemaProps{
-										Type:   []string{"string"},
-										Format: "",
+										Ref: ref("k8s.io/api/core/v1.EnvFromSource"),
 									},
 								},
 							},
 						},
 					},
-					"volumes": {
+					"initContainers": {
 						SchemaProps: spec.SchemaProps{
-							Type: []string{"array"},
+							Description: "Containers run before the presto container. They can mount the volumes of the pod.",
+							Type:        []string{"array"},
 							Items: &spec.SchemaOrArray{
 								Schema: &spec.Schema{
 									SchemaProps: spec.SchemaProps{
-										Ref: ref("github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.PrestoVolumeSpec"),
+										Ref: ref("k8s.io/api/core/v1.Container"),
+									},
+								},
+							},
+						},
+					},
+					"sidecars": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Containers run along with the presto container. They can mount the volumes of the pod.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("k8s.io/api/core/v1.Container"),
 									},
 								},
 							},
 						},
 					},
 				},
-				Required: []string{"coordinator", "worker", "internalHiveMetaStore", "imageDetails", "additionalPrestoPropFiles"},
+				Required: []string{"memoryLimit", "cpuLimit"},
 			},
 		},
 		Dependencies: []string{
-			"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.CatalogList", "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.CoordinatorSpec", "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.HMSSpec", "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.ImageSpec", "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.PrestoVolumeSpec", "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.ServiceSpec", "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.WorkerSpec"},
+			"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.DataVolumeSpec", "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.DisruptionBudgetSpec", "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.ProbesSpec", "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.StorageSpec", "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.TLSSpec", "k8s.io/api/core/v1.Affinity", "k8s.io/api/core/v1.Container", "k8s.io/api/core/v1.EnvFromSource", "k8s.io/api/core/v1.EnvVar", "k8s.io/api/core/v1.SecretKeySelector", "k8s.io/api/core/v1.Toleration", "k8s.io/api/core/v1.TopologySpreadConstraint"},
 	}
 }
 
-func schema_pkg_apis_falarica_v1alpha1_PrestoStatus(ref common.ReferenceCallback) common.OpenAPIDefinition {
+func schema_pkg_apis_falarica_v1alpha1_DataVolumeSpec(ref common.ReferenceCallback) common.OpenAPIDefinition {
 	return common.OpenAPIDefinition{
 		Schema: spec.Schema{
 			SchemaProps: spec.SchemaProps{
-				Description: "PrestoStatus defines the observed state of Presto",
-				Type:        []string{"object"},
+				Type: []string{"object"},
 				Properties: map[string]spec.Schema{
-					"uuid": {
+					"size": {
 						SchemaProps: spec.SchemaProps{
-							Description: "INSERT ADDITIONAL STATUS FIELD - define observed state of cluster Important: Run \"operator-sdk generate k8s\" to regenerate code after modifying this file Add custom validation using kubebuilder tags: https://book-v1.book.kubebuilder.io/beyond_basics/generating_crd.html",
+							Description: "Size of the volume e.g. 10Gi",
 							Type:        []string{"string"},
 							Format:      "",
 						},
 					},
-					"desiredWorkers": {
+					"storageClassName": {
 						SchemaProps: spec.SchemaProps{
-							Type:   []string{"integer"},
-							Format: "int32",
+							Description: "Name of the StorageClass of the volume. The default StorageClass is used if not specified.",
+							Type:        []string{"string"},
+							Format:      "",
 						},
 					},
-					"currentWorkers": {
+					"accessModes": {
 						SchemaProps: spec.SchemaProps{
-							Type:   []string{"integer"},
-							Format: "int32",
+							Description: "Defaults to ReadWriteOnce.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Type:   []string{"string"},
+										Format: "",
+									},
+								},
+							},
 						},
 					},
-					"headlessService": {
+				},
+				Required: []string{"size"},
+			},
+		},
+	}
+}
+
+func schema_pkg_apis_falarica_v1alpha1_DisruptionBudgetSpec(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "Budget of the voluntary disruptions, e.g. the evictions of a node drain, of the coordinator or of the workers. Only one of minAvailable and maxUnavailable can be set.",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"enabled": {
 						SchemaProps: spec.SchemaProps{
-							Type:   []string{"string"},
-							Format: "",
+							Description: "Defaults to true",
+							Type:        []string{"boolean"},
+							Format:      "",
 						},
 					},
-					"service": {
+					"minAvailable": {
 						SchemaProps: spec.SchemaProps{
-							Type:   []string{"string"},
-							Format: "",
+							Description: "Number or percentage of the pods that must remain available. A number is capped at the minimum number of pods, i.e. the count or the minReplicas of the autoscaling.",
+							Ref:         ref("k8s.io/apimachinery/pkg/util/intstr.IntOrString"),
 						},
 					},
-					"coordinatorAddress": {
+					"maxUnavailable": {
 						SchemaProps: spec.SchemaProps{
-							Type:   []string{"string"},
-							Format: "",
+							Description: "Number or percentage of the pods that can be unavailable",
+							Ref:         ref("k8s.io/apimachinery/pkg/util/intstr.IntOrString"),
 						},
 					},
-					"catalogConfig": {
+				},
+			},
+		},
+		Dependencies: []string{
+			"k8s.io/apimachinery/pkg/util/intstr.IntOrString"},
+	}
+}
+
+func schema_pkg_apis_falarica_v1alpha1_HMSDatabaseSpec(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Type: []string{"object"},
+				Properties: map[string]spec.Schema{
+					"type": {
 						SchemaProps: spec.SchemaProps{
-							Type:   []string{"string"},
-							Format: "",
+							Description: "Type of the database as known to the hive schematool",
+							Type:        []string{"string"},
+							Format:      "",
 						},
 					},
-					"coordinatorConfig": {
+					"jdbcURL": {
 						SchemaProps: spec.SchemaProps{
-							Type:   []string{"string"},
-							Format: "",
+							Description: "JDBC URL of an external database. Required for all types but derby.",
+							Type:        []string{"string"},
+							Format:      "",
 						},
 					},
-					"workerConfig": {
+					"driverClassName": {
 						SchemaProps: spec.SchemaProps{
-							Type:   []string{"string"},
-							Format: "",
+							Description: "JDBC driver class. The driver has to be present in the image.",
+							Type:        []string{"string"},
+							Format:      "",
 						},
 					},
-					"workerReplicaset": {
+					"credentialsSecret": {
 						SchemaProps: spec.SchemaProps{
-							Type:   []string{"string"},
-							Format: "",
+							Description: "Secret in the same namespace holding the user name and the password of the database",
+							Type:        []string{"string"},
+							Format:      "",
 						},
 					},
-					"coordinatorReplicaset": {
+					"usernameKey": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Key of the user name in the secret. Defaults to username.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"passwordKey": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Key of the password in the secret. Defaults to password.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+				},
+				Required: []string{"type"},
+			},
+		},
+	}
+}
+
+func schema_pkg_apis_falarica_v1alpha1_HMSSpec(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Type: []string{"object"},
+				Properties: map[string]spec.Schema{
+					"enabled": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Deploys a Hive Metastore along with the cluster and adds a hive catalog that uses it",
+							Type:        []string{"boolean"},
+							Format:      "",
+						},
+					},
+					"image": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Image of the metastore. The image has to run the metastore when the SERVICE_NAME environment variable is metastore. Defaults to apache/hive:3.1.3.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"warehouseDir": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Location of the warehouse of managed tables. It has to be reachable from the presto pods e.g. an s3a:// or hdfs:// location. Defaults to /opt/hive/data/warehouse.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"database": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Database that stores the metadata. Defaults to an embedded Derby database that is lost when the metastore pod restarts.",
+							Ref:         ref("github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.HMSDatabaseSpec"),
+						},
+					},
+					"memoryLimit": {
 						SchemaProps: spec.SchemaProps{
 							Type:   []string{"string"},
 							Format: "",
 						},
 					},
-					"hpaName": {
+					"cpuLimit": {
 						SchemaProps: spec.SchemaProps{
 							Type:   []string{"string"},
 							Format: "",
 						},
 					},
-					"clusterState": {
+					"additionalProps": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Additional properties of hive-site.xml",
+							Type:        []string{"object"},
+							AdditionalProperties: &spec.SchemaOrBool{
+								Allows: true,
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Type:   []string{"string"},
+										Format: "",
+									},
+								},
+							},
+						},
+					},
+					"catalogName": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Name of the catalog added for the metastore. Defaults to hive.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"catalogProps": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Additional properties of the hive catalog e.g. the credentials of the object store",
+							Type:        []string{"object"},
+							AdditionalProperties: &spec.SchemaOrBool{
+								Allows: true,
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Type:   []string{"string"},
+										Format: "",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.HMSDatabaseSpec"},
+	}
+}
+
+func schema_pkg_apis_falarica_v1alpha1_HPABehavior(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "Same as HorizontalPodAutoscalerBehavior of the newer Kubernetes API",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"scaleUp": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.HPAScalingRules"),
+						},
+					},
+					"scaleDown": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.HPAScalingRules"),
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.HPAScalingRules"},
+	}
+}
+
+func schema_pkg_apis_falarica_v1alpha1_HPAScalingPolicy(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Type: []string{"object"},
+				Properties: map[string]spec.Schema{
+					"type": {
 						SchemaProps: spec.SchemaProps{
 							Type:   []string{"string"},
 							Format: "",
 						},
 					},
-					"errorReason": {
+					"value": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Number of pods or percentage of the pods that can be added or removed",
+							Type:        []string{"integer"},
+							Format:      "int32",
+						},
+					},
+					"periodSeconds": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Window in which the change is limited by this policy",
+							Type:        []string{"integer"},
+							Format:      "int32",
+						},
+					},
+				},
+				Required: []string{"type", "value", "periodSeconds"},
+			},
+		},
+	}
+}
+
+func schema_pkg_apis_falarica_v1alpha1_HPAScalingRules(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Type: []string{"object"},
+				Properties: map[string]spec.Schema{
+					"stabilizationWindowSeconds": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Number of seconds for which past recommendations are considered while scaling",
+							Type:        []string{"integer"},
+							Format:      "int32",
+						},
+					},
+					"selectPolicy": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Which of the policies is used. Disabled turns off scaling in this direction",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"policies": {
+						SchemaProps: spec.SchemaProps{
+							Type: []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.HPAScalingPolicy"),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.HPAScalingPolicy"},
+	}
+}
+
+func schema_pkg_apis_falarica_v1alpha1_IdlePolicySpec(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Type: []string{"object"},
+				Properties: map[string]spec.Schema{
+					"hibernateAfterMinutes": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Minutes without running or queued queries after which the cluster is hibernated. A hibernated cluster is resumed by annotating it with falarica.io/resume.",
+							Type:        []string{"integer"},
+							Format:      "int32",
+						},
+					},
+				},
+				Required: []string{"hibernateAfterMinutes"},
+			},
+		},
+	}
+}
+
+func schema_pkg_apis_falarica_v1alpha1_ImageSpec(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Type: []string{"object"},
+				Properties: map[string]spec.Schema{
+					"name": {
 						SchemaProps: spec.SchemaProps{
 							Type:   []string{"string"},
 							Format: "",
 						},
 					},
-					"modificationTime": {
+					"prestoPath": {
 						SchemaProps: spec.SchemaProps{
-							Ref: ref("k8s.io/apimachinery/pkg/apis/meta/v1.Time"),
+							Description: "\n\t+kubebuilder:validation:Optional",
+							Type:        []string{"string"},
+							Format:      "",
 						},
 					},
-					"coordinatorCPU": {
+				},
+				Required: []string{"name", "prestoPath"},
+			},
+		},
+	}
+}
+
+func schema_pkg_apis_falarica_v1alpha1_InternalTLSSpec(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "Certificate of the presto nodes generated by the operator for the internal communication. The nodes serve only HTTPS and authenticate each other with a shared secret.",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"duration": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Validity of the certificate of the nodes. Defaults to 2160h.",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Duration"),
+						},
+					},
+					"renewBefore": {
+						SchemaProps: spec.SchemaProps{
+							Description: "The certificate is renewed this long before it expires. Defaults to 720h.",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Duration"),
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"k8s.io/apimachinery/pkg/apis/meta/v1.Duration"},
+	}
+}
+
+func schema_pkg_apis_falarica_v1alpha1_IssuerReference(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "Reference to an Issuer or a ClusterIssuer of cert-manager",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"name": {
 						SchemaProps: spec.SchemaProps{
 							Type:   []string{"string"},
 							Format: "",
 						},
 					},
-					"workerCPU": {
+					"kind": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Issuer or ClusterIssuer. Defaults to Issuer.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"group": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Defaults to cert-manager.io",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+				},
+				Required: []string{"name"},
+			},
+		},
+	}
+}
+
+func schema_pkg_apis_falarica_v1alpha1_NodeTerminationSpec(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "Taints and conditions that mark a node that is about to be terminated",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"enabled": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Defaults to true when nodeTermination is specified",
+							Type:        []string{"boolean"},
+							Format:      "",
+						},
+					},
+					"taints": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Keys of the taints put on a node that is about to be terminated. Defaults to the taints of the AWS and GCP node termination handlers and of the cluster autoscaler.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Type:   []string{"string"},
+										Format: "",
+									},
+								},
+							},
+						},
+					},
+					"conditions": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Types of the node conditions that mark a node that is about to be terminated when their status is True, e.g. a condition set by the node problem detector.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Type:   []string{"string"},
+										Format: "",
+									},
+								},
+							},
+						},
+					},
+					"cordon": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Whether the workers on a cordoned node are drained. Defaults to true.",
+							Type:        []string{"boolean"},
+							Format:      "",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func schema_pkg_apis_falarica_v1alpha1_PasswordAuthenticationSpec(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "The operator generates the password file of the coordinator from the users in a Secret",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"usersSecret": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Name of a Secret in the namespace of the cluster. Its keys are the names of the users and the values are their passwords.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+				},
+				Required: []string{"usersSecret"},
+			},
+		},
+	}
+}
+
+func schema_pkg_apis_falarica_v1alpha1_PodExtensionsSpec(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "Environment variables and containers added to the presto pods",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"env": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Environment variables of the presto container. A variable set for the role overrides the one of the same name in spec.common.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("k8s.io/api/core/v1.EnvVar"),
+									},
+								},
+							},
+						},
+					},
+					"envFrom": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Sources of the environment variables of the presto container",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("k8s.io/api/core/v1.EnvFromSource"),
+									},
+								},
+							},
+						},
+					},
+					"initContainers": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Containers run before the presto container. They can mount the volumes of the pod.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("k8s.io/api/core/v1.Container"),
+									},
+								},
+							},
+						},
+					},
+					"sidecars": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Containers run along with the presto container. They can mount the volumes of the pod.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("k8s.io/api/core/v1.Container"),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"k8s.io/api/core/v1.Container", "k8s.io/api/core/v1.EnvFromSource", "k8s.io/api/core/v1.EnvVar"},
+	}
+}
+
+func schema_pkg_apis_falarica_v1alpha1_Presto(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "Presto is the Schema for the prestos API",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"kind": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Kind is a string value representing the REST resource this object represents. Servers may infer this from the endpoint the client submits requests to. Cannot be updated. In CamelCase. More info: https://git.k8s.io/community/contributors/devel/sig-architecture/api-conventions.md#types-kinds",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"apiVersion": {
+						SchemaProps: spec.SchemaProps{
+							Description: "APIVersion defines the versioned schema of this representation of an object. Servers should convert recognized schemas to the latest internal value, and may reject unrecognized values. More info: https://git.k8s.io/community/contributors/devel/sig-architecture/api-conventions.md#resources",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"metadata": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta"),
+						},
+					},
+					"spec": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.PrestoSpec"),
+						},
+					},
+					"status": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.PrestoStatus"),
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.PrestoSpec", "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.PrestoStatus", "k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta"},
+	}
+}
+
+func schema_pkg_apis_falarica_v1alpha1_PrestoCondition(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "PrestoCondition has the same fields as the Condition type of the newer Kubernetes API so that kubectl wait and the tools that understand conditions work with Presto.",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"type": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"status": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"observedGeneration": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Generation of the Presto resource that the condition was set for",
+							Type:        []string{"integer"},
+							Format:      "int64",
+						},
+					},
+					"lastTransitionTime": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Last time the condition changed from one status to another",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Time"),
+						},
+					},
+					"reason": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Reason for the last transition in CamelCase",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"message": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Human readable details about the last transition",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+				},
+				Required: []string{"type", "status", "lastTransitionTime", "reason", "message"},
+			},
+		},
+		Dependencies: []string{
+			"k8s.io/apimachinery/pkg/apis/meta/v1.Time"},
+	}
+}
+
+func schema_pkg_apis_falarica_v1alpha1_PrestoSpec(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "PrestoSpec defines the desired state of Presto",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"coordinator": {
+						SchemaProps: spec.SchemaProps{
+							Description: "INSERT ADDITIONAL SPEC FIELDS - desired state of Important: Run \"operator-sdk generate k8s\" to regenerate code after modifying this file Add custom validation using kubebuilder tags: https://book-v1.book.kubebuilder.io/beyond_basics/generating_crd.html\n\t+kubebuilder:validation:Required",
+							Ref:         ref("github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.CoordinatorSpec"),
+						},
+					},
+					"worker": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.WorkerSpec"),
+						},
+					},
+					"catalogs": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.CatalogList"),
+						},
+					},
+					"service": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.ServiceSpec"),
+						},
+					},
+					"internalHiveMetaStore": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.HMSSpec"),
+						},
+					},
+					"imageDetails": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.ImageSpec"),
+						},
+					},
+					"additionalPrestoPropFiles": {
+						SchemaProps: spec.SchemaProps{
+							Description: "additionalPrestoPropFiles:\n  access-control.properties: |\n   access-control.name=read-only\n event-listener.properties: |\n   event-listener.name=event-logger\n   jdbc.url=jdbc:postgresql://example.com:5432/eventlog\n   jdbc.user=myuser\n   jdbc.password=mypassword",
+							Type:        []string{"object"},
+							AdditionalProperties: &spec.SchemaOrBool{
+								Allows: true,
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Type:   []string{"string"},
+										Format: "",
+									},
+								},
+							},
+						},
+					},
+					"volumes": {
+						SchemaProps: spec.SchemaProps{
+							Type: []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.PrestoVolumeSpec"),
+									},
+								},
+							},
+						},
+					},
+					"workerPools": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Additional groups of workers with their own resources, scheduling and scaling. They register with the same coordinator as the workers of spec.worker.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.WorkerPoolSpec"),
+									},
+								},
+							},
+						},
+					},
+					"suspend": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Scales the coordinator and the workers to zero. The configuration, the services and the UUID of the cluster are retained. Setting it back to false resumes the cluster.",
+							Type:        []string{"boolean"},
+							Format:      "",
+						},
+					},
+					"idlePolicy": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Hibernates the cluster when it has not run any queries for a while",
+							Ref:         ref("github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.IdlePolicySpec"),
+						},
+					},
+					"common": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Environment variables and containers added to the pods of both the coordinator and the workers",
+							Ref:         ref("github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.PodExtensionsSpec"),
+						},
+					},
+					"internalTLS": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Encrypts the traffic between the coordinator and the workers. Requires spec.coordinator.httpsEnabled.",
+							Ref:         ref("github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.InternalTLSSpec"),
+						},
+					},
+					"authentication": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Authentication of the users of the coordinator. Requires spec.coordinator.httpsEnabled and cannot be used along with internalTLS.",
+							Ref:         ref("github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.AuthenticationSpec"),
+						},
+					},
+				},
+				Required: []string{"coordinator", "worker"},
+			},
+		},
+		Dependencies: []string{
+			"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.AuthenticationSpec", "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.CatalogList", "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.CoordinatorSpec", "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.HMSSpec", "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.IdlePolicySpec", "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.ImageSpec", "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.InternalTLSSpec", "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.PodExtensionsSpec", "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.PrestoVolumeSpec", "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.ServiceSpec", "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.WorkerPoolSpec", "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.WorkerSpec"},
+	}
+}
+
+func schema_pkg_apis_falarica_v1alpha1_PrestoStatus(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "PrestoStatus defines the observed state of Presto",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"uuid": {
+						SchemaProps: spec.SchemaProps{
+							Description: "INSERT ADDITIONAL STATUS FIELD - define observed state of cluster Important: Run \"operator-sdk generate k8s\" to regenerate code after modifying this file Add custom validation using kubebuilder tags: https://book-v1.book.kubebuilder.io/beyond_basics/generating_crd.html",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"desiredWorkers": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"integer"},
+							Format: "int32",
+						},
+					},
+					"currentWorkers": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"integer"},
+							Format: "int32",
+						},
+					},
+					"headlessService": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"service": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"coordinatorAddress": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"catalogConfig": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"coordinatorConfig": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"workerConfig": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"workerReplicaset": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"workerDeployment": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"coordinatorReplicaset": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"coordinatorStatefulSet": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"hpaName": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"clusterState": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"errorReason": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"modificationTime": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("k8s.io/apimachinery/pkg/apis/meta/v1.Time"),
+						},
+					},
+					"coordinatorCPU": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"workerCPU": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"configHash": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Hash of the configuration that all the pods of the cluster are running with",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"rolloutState": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Progress of the rolling restart that applies a changed configuration",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"updatedWorkers": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Number of workers running with the latest configuration",
+							Type:        []string{"integer"},
+							Format:      "int32",
+						},
+					},
+					"conditions": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Latest observations of the state of the cluster",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.PrestoCondition"),
+									},
+								},
+							},
+						},
+					},
+					"prestoVersion": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Following are reported by the coordinator",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"activeWorkers": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Workers registered with the coordinator",
+							Type:        []string{"integer"},
+							Format:      "int32",
+						},
+					},
+					"activeNodes": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Nodes responding to the coordinator",
+							Type:        []string{"integer"},
+							Format:      "int32",
+						},
+					},
+					"failedNodes": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Nodes that the coordinator has failed to reach",
+							Type:        []string{"integer"},
+							Format:      "int32",
+						},
+					},
+					"runningQueries": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"integer"},
+							Format: "int32",
+						},
+					},
+					"queuedQueries": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"integer"},
+							Format: "int32",
+						},
+					},
+					"blockedQueries": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"integer"},
+							Format: "int32",
+						},
+					},
+					"lastScaleTime": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Last time the operator scaled the workers based on the query load",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Time"),
+						},
+					},
+					"decommissioningWorkers": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Workers that are draining their tasks before they are removed on a scale down",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Type:   []string{"string"},
+										Format: "",
+									},
+								},
+							},
+						},
+					},
+					"workerPools": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Workers of each worker pool",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.WorkerPoolStatus"),
+									},
+								},
+							},
+						},
+					},
+					"activeSchedule": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Name of the scaling schedule whose window is active",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"nextScheduleTransition": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Next time at which the active scaling schedule changes",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Time"),
+						},
+					},
+					"hibernationReason": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Why the cluster is hibernated, Suspended or Idle",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"hibernatedAt": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Time at which the cluster was hibernated",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Time"),
+						},
+					},
+					"hibernatedWorkers": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Number of workers before the cluster was hibernated. Restored on resume.",
+							Type:        []string{"integer"},
+							Format:      "int32",
+						},
+					},
+					"lastActivityTime": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Last time queries were seen running or queued on the cluster. Tracked with the idle policy.",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Time"),
+						},
+					},
+					"hiveMetastore": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Deployment of the Hive Metastore deployed by the operator",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"hiveMetastoreURI": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Thrift URI of the Hive Metastore deployed by the operator",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"coordinatorPDB": {
+						SchemaProps: spec.SchemaProps{
+							Description: "PodDisruptionBudget of the coordinator",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"workerPDB": {
+						SchemaProps: spec.SchemaProps{
+							Description: "PodDisruptionBudget of the workers",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"tlsSecret": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Secret that holds the certificate of the coordinator provisioned by the operator",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"tlsNotAfter": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Expiry of the certificate of the coordinator provisioned by the operator",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Time"),
+						},
+					},
+					"internalTlsSecret": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Secret that holds the certificate of the presto nodes for the internal communication",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"internalTlsNotAfter": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Expiry of the certificate of the presto nodes for the internal communication",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Time"),
+						},
+					},
+					"sharedSecret": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Secret that holds internal-communication.shared-secret of the cluster",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"sharedSecretRotationTime": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Last time the shared secret was generated",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Time"),
+						},
+					},
+					"passwordFileSecret": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Secret that holds the password file of the coordinator",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+				},
+				Required: []string{"uuid", "desiredWorkers", "currentWorkers", "headlessService", "service", "coordinatorAddress", "catalogConfig", "coordinatorConfig", "workerConfig", "workerReplicaset", "coordinatorReplicaset", "hpaName", "clusterState", "errorReason"},
+			},
+		},
+		Dependencies: []string{
+			"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.PrestoCondition", "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.WorkerPoolStatus", "k8s.io/apimachinery/pkg/apis/meta/v1.Time"},
+	}
+}
+
+func schema_pkg_apis_falarica_v1alpha1_ProbesSpec(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "Overrides of the default probes of the presto container. The fields that are set in a probe replace those of the default probe. A probe without a handler keeps the default handler, so only the timings can be changed.",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"livenessProbe": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("k8s.io/api/core/v1.Probe"),
+						},
+					},
+					"readinessProbe": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("k8s.io/api/core/v1.Probe"),
+						},
+					},
+					"startupProbe": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("k8s.io/api/core/v1.Probe"),
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"k8s.io/api/core/v1.Probe"},
+	}
+}
+
+func schema_pkg_apis_falarica_v1alpha1_QueryLoadAutoscalingSpec(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "The number of workers needed for each of the targets is computed and the largest of them is taken as the desired number of workers.",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"targetQueuedQueriesPerWorker": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Number of queued queries per worker",
+							Type:        []string{"integer"},
+							Format:      "int32",
+						},
+					},
+					"targetRunningQueriesPerWorker": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Number of running queries per worker",
+							Type:        []string{"integer"},
+							Format:      "int32",
+						},
+					},
+					"targetQueuedSplitsPerWorker": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Number of splits of the running queries that are waiting for a worker thread, per worker. The queued splits are used in place of the blocked splits, which Presto reports only in the details of each query.",
+							Type:        []string{"integer"},
+							Format:      "int32",
+						},
+					},
+					"scaleUpStabilizationWindowSeconds": {
+						SchemaProps: spec.SchemaProps{
+							Description: "The workers are scaled up only to the lowest number of workers desired during this window. Defaults to 60 seconds.",
+							Type:        []string{"integer"},
+							Format:      "int32",
+						},
+					},
+					"scaleDownStabilizationWindowSeconds": {
+						SchemaProps: spec.SchemaProps{
+							Description: "The workers are scaled down only to the highest number of workers desired during this window. Defaults to 300 seconds.",
+							Type:        []string{"integer"},
+							Format:      "int32",
+						},
+					},
+					"scaleUpCooldownSeconds": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Minimum time after the last scaling before the workers are scaled up. Defaults to 60 seconds.",
+							Type:        []string{"integer"},
+							Format:      "int32",
+						},
+					},
+					"scaleDownCooldownSeconds": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Minimum time after the last scaling before the workers are scaled down. Defaults to 300 seconds.",
+							Type:        []string{"integer"},
+							Format:      "int32",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func schema_pkg_apis_falarica_v1alpha1_ScalingSchedule(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "A window of time starting at each time matched by the cron expression",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"name": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Name of the window, shown in the status when it is active",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"schedule": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Cron expression with five fields, e.g. \"0 8 * * 1-5\", for the start of the window",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"duration": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Length of the window e.g. 10h or 30m",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Duration"),
+						},
+					},
+					"timeZone": {
+						SchemaProps: spec.SchemaProps{
+							Description: "IANA time zone of the cron expression e.g. Europe/Berlin. Defaults to UTC.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"count": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Number of workers during the window. Applicable when autoscaling is not enabled.",
+							Type:        []string{"integer"},
+							Format:      "int32",
+						},
+					},
+					"minReplicas": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Minimum number of workers during the window. Applicable when autoscaling is enabled.",
+							Type:        []string{"integer"},
+							Format:      "int32",
+						},
+					},
+					"maxReplicas": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Maximum number of workers during the window. Applicable when autoscaling is enabled.",
+							Type:        []string{"integer"},
+							Format:      "int32",
+						},
+					},
+				},
+				Required: []string{"name", "schedule", "duration"},
+			},
+		},
+		Dependencies: []string{
+			"k8s.io/apimachinery/pkg/apis/meta/v1.Duration"},
+	}
+}
+
+func schema_pkg_apis_falarica_v1alpha1_SchedulingSpec(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "Scheduling constraints of the presto pods",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"nodeSelector": {
+						SchemaProps: spec.SchemaProps{
+							Type: []string{"object"},
+							AdditionalProperties: &spec.SchemaOrBool{
+								Allows: true,
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Type:   []string{"string"},
+										Format: "",
+									},
+								},
+							},
+						},
+					},
+					"tolerations": {
+						SchemaProps: spec.SchemaProps{
+							Type: []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("k8s.io/api/core/v1.Toleration"),
+									},
+								},
+							},
+						},
+					},
+					"affinity": {
+						SchemaProps: spec.SchemaProps{
+							Description: "By default, the coordinator and the workers of a cluster prefer not to run on the same node. The default is not added when podAntiAffinity is specified.",
+							Ref:         ref("k8s.io/api/core/v1.Affinity"),
+						},
+					},
+					"topologySpreadConstraints": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Alpha in Kubernetes 1.16 and needs the EvenPodsSpread feature gate till 1.18.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("k8s.io/api/core/v1.TopologySpreadConstraint"),
+									},
+								},
+							},
+						},
+					},
+					"priorityClassName": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"k8s.io/api/core/v1.Affinity", "k8s.io/api/core/v1.Toleration", "k8s.io/api/core/v1.TopologySpreadConstraint"},
+	}
+}
+
+func schema_pkg_apis_falarica_v1alpha1_ServiceSpec(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "ServiceSpec describes the attributes that a user creates on a service. Following is a copy of v1.ServiceSpec except that Ports is an optional field and Selectors field is removed.",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"nodePort": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"integer"},
+							Format: "int32",
+						},
+					},
+					"port": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"integer"},
+							Format: "int32",
+						},
+					},
+					"clusterIP": {
+						SchemaProps: spec.SchemaProps{
+							Description: "clusterIP is the IP address of the service and is usually assigned randomly by the master. If an address is specified manually and is not in use by others, it will be allocated to the service; otherwise, creation of the service will fail. This field can not be changed through updates. Valid values are \"None\", empty string (\"\"), or a valid IP address. \"None\" can be specified for headless services when proxying is not required. Only applies to types ClusterIP, NodePort, and LoadBalancer. Ignored if type is ExternalName. More info: https://kubernetes.io/docs/concepts/services-networking/service/#virtual-ips-and-service-proxies",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"type": {
+						SchemaProps: spec.SchemaProps{
+							Description: "type determines how the Service is exposed. Defaults to ClusterIP. Valid options are ExternalName, ClusterIP, NodePort, and LoadBalancer. \"ExternalName\" maps to the specified externalName. \"ClusterIP\" allocates a cluster-internal IP address for load-balancing to endpoints. Endpoints are determined by the selector or if that is not specified, by manual construction of an Endpoints object. If clusterIP is \"None\", no virtual IP is allocated and the endpoints are published as a set of endpoints rather than a stable IP. \"NodePort\" builds on ClusterIP and allocates a port on every node which routes to the clusterIP. \"LoadBalancer\" builds on NodePort and creates an external load-balancer (if supported in the current cloud) which routes to the clusterIP. More info: https://kubernetes.io/docs/concepts/services-networking/service/#publishing-services-service-types",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"externalIPs": {
+						SchemaProps: spec.SchemaProps{
+							Description: "externalIPs is a list of IP addresses for which nodes in the cluster will also accept traffic for this service.  These IPs are not managed by Kubernetes.  The user is responsible for ensuring that traffic arrives at a node with this IP.  A common example is external load-balancers that are not part of the Kubernetes system.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Type:   []string{"string"},
+										Format: "",
+									},
+								},
+							},
+						},
+					},
+					"sessionAffinity": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Supports \"ClientIP\" and \"None\". Used to maintain session affinity. Enable client IP based session affinity. Must be ClientIP or None. Defaults to None. More info: https://kubernetes.io/docs/concepts/services-networking/service/#virtual-ips-and-service-proxies",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"loadBalancerIP": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Only applies to Service Type: LoadBalancer LoadBalancer will get created with the IP specified in this field. This feature depends on whether the underlying cloud-provider supports specifying the loadBalancerIP when a load balancer is created. This field will be ignored if the cloud-provider does not support the feature.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"loadBalancerSourceRanges": {
+						SchemaProps: spec.SchemaProps{
+							Description: "If specified and supported by the platform, this will restrict traffic through the cloud-provider load-balancer will be restricted to the specified client IPs. This field will be ignored if the cloud-provider does not support the feature.\" More info: https://kubernetes.io/docs/tasks/access-application-cluster/configure-cloud-provider-firewall/",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Type:   []string{"string"},
+										Format: "",
+									},
+								},
+							},
+						},
+					},
+					"externalName": {
+						SchemaProps: spec.SchemaProps{
+							Description: "externalName is the external reference that kubedns or equivalent will return as a CNAME record for this service. No proxying will be involved. Must be a valid RFC-1123 hostname (https://tools.ietf.org/html/rfc1123) and requires Type to be ExternalName.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"externalTrafficPolicy": {
+						SchemaProps: spec.SchemaProps{
+							Description: "externalTrafficPolicy denotes if this Service desires to route external traffic to node-local or cluster-wide endpoints. \"Local\" preserves the client source IP and avoids a second hop for LoadBalancer and Nodeport type services, but risks potentially imbalanced traffic spreading. \"Cluster\" obscures the client source IP and may cause a second hop to another node, but should have good overall load-spreading.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"healthCheckNodePort": {
+						SchemaProps: spec.SchemaProps{
+							Description: "healthCheckNodePort specifies the healthcheck nodePort for the service. If not specified, HealthCheckNodePort is created by the service api backend with the allocated nodePort. Will use user-specified nodePort value if specified by the client. Only effects when Type is set to LoadBalancer and ExternalTrafficPolicy is set to Local.",
+							Type:        []string{"integer"},
+							Format:      "int32",
+						},
+					},
+					"publishNotReadyAddresses": {
+						SchemaProps: spec.SchemaProps{
+							Description: "publishNotReadyAddresses, when set to true, indicates that DNS implementations must publish the notReadyAddresses of subsets for the Endpoints associated with the Service. The default value is false. The primary use case for setting this field is to use a StatefulSet's Headless Service to propagate SRV records for its Pods without respect to their readiness for purpose of peer discovery.",
+							Type:        []string{"boolean"},
+							Format:      "",
+						},
+					},
+					"sessionAffinityConfig": {
+						SchemaProps: spec.SchemaProps{
+							Description: "sessionAffinityConfig contains the configurations of session affinity.",
+							Ref:         ref("k8s.io/api/core/v1.SessionAffinityConfig"),
+						},
+					},
+					"ipFamily": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"k8s.io/api/core/v1.SessionAffinityConfig"},
+	}
+}
+
+func schema_pkg_apis_falarica_v1alpha1_StorageSpec(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Type: []string{"object"},
+				Properties: map[string]spec.Schema{
+					"type": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"medium": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Medium of an EmptyDir volume. With Memory, a tmpfs is mounted and the data counts against the memory limit of the container.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"sizeLimit": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Size limit of an EmptyDir volume e.g. 50Gi",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"path": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Path on the node of a HostPath volume. Required when type is HostPath.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"spillEnabled": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Spills to the volume the queries that do not fit in memory. Applicable to the workers.",
+							Type:        []string{"boolean"},
+							Format:      "",
+						},
+					},
+					"ephemeralStorageRequest": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Request of ephemeral-storage of the container e.g. 10Gi",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"ephemeralStorageLimit": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Limit of ephemeral-storage of the container e.g. 20Gi. The pod is evicted when its container layer, logs and EmptyDir volumes on the disk exceed it.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+				},
+				Required: []string{"type"},
+			},
+		},
+	}
+}
+
+func schema_pkg_apis_falarica_v1alpha1_TLSSpec(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "Certificate of the coordinator provisioned by the operator. The certificate covers the coordinator service, the DNS name of the coordinator pod and the given hostnames.",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"mode": {
+						SchemaProps: spec.SchemaProps{
+							Description: "SelfSigned generates a CA and a server certificate signed by it. CertManager requests the certificate from cert-manager. Defaults to SelfSigned.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"hostnames": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Additional DNS names of the certificate e.g. the host name of an ingress or a load balancer",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Type:   []string{"string"},
+										Format: "",
+									},
+								},
+							},
+						},
+					},
+					"duration": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Validity of the server certificate. Defaults to 2160h.",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Duration"),
+						},
+					},
+					"renewBefore": {
+						SchemaProps: spec.SchemaProps{
+							Description: "The certificate is renewed this long before it expires. Defaults to 720h.",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Duration"),
+						},
+					},
+					"issuerRef": {
 						SchemaProps: spec.SchemaProps{
-							Type:   []string{"string"},
-							Format: "",
+							Description: "Issuer of cert-manager that signs the certificate. Required with CertManager.",
+							Ref:         ref("github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.IssuerReference"),
 						},
 					},
 				},
-				Required: []string{"uuid", "desiredWorkers", "currentWorkers", "headlessService", "service", "coordinatorAddress", "catalogConfig", "coordinatorConfig", "workerConfig", "workerReplicaset", "coordinatorReplicaset", "hpaName", "clusterState", "errorReason"},
 			},
 		},
 		Dependencies: []string{
-			"k8s.io/apimachinery/pkg/apis/meta/v1.Time"},
+			"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.IssuerReference", "k8s.io/apimachinery/pkg/apis/meta/v1.Duration"},
 	}
 }
 
-func schema_pkg_apis_falarica_v1alpha1_ServiceSpec(ref common.ReferenceCallback) common.OpenAPIDefinition {
+func schema_pkg_apis_falarica_v1alpha1_WorkerPoolSpec(ref common.ReferenceCallback) common.OpenAPIDefinition {
 	return common.OpenAPIDefinition{
 		Schema: spec.Schema{
 			SchemaProps: spec.SchemaProps{
-				Description: "ServiceSpec describes the attributes that a user creates on a service. Following is a copy of v1.ServiceSpec except that Ports is an optional field and Selectors field is removed.",
+				Description: "A group of workers managed by a ReplicaSet of its own. The fields that are not specified are taken from spec.worker, except count and autoscaling.",
 				Type:        []string{"object"},
 				Properties: map[string]spec.Schema{
-					"nodePort": {
+					"name": {
 						SchemaProps: spec.SchemaProps{
-							Type:   []string{"integer"},
-							Format: "int32",
+							Description: "Name of the pool. It is a part of the names of the resources of the pool.",
+							Type:        []string{"string"},
+							Format:      "",
 						},
 					},
-					"port": {
+					"count": {
 						SchemaProps: spec.SchemaProps{
 							Type:   []string{"integer"},
 							Format: "int32",
 						},
 					},
-					"clusterIP": {
+					"memoryLimit": {
 						SchemaProps: spec.SchemaProps{
-							Description: "clusterIP is the IP address of the service and is usually assigned randomly by the master. If an address is specified manually and is not in use by others, it will be allocated to the service; otherwise, creation of the service will fail. This field can not be changed through updates. Valid values are \"None\", empty string (\"\"), or a valid IP address. \"None\" can be specified for headless services when proxying is not required. Only applies to types ClusterIP, NodePort, and LoadBalancer. Ignored if type is ExternalName. More info: https://kubernetes.io/docs/concepts/services-networking/service/#virtual-ips-and-service-proxies",
-							Type:        []string{"string"},
-							Format:      "",
+							Type:   []string{"string"},
+							Format: "",
 						},
 					},
-					"type": {
+					"cpuLimit": {
 						SchemaProps: spec.SchemaProps{
-							Description: "type determines how the Service is exposed. Defaults to ClusterIP. Valid options are ExternalName, ClusterIP, NodePort, and LoadBalancer. \"ExternalName\" maps to the specified externalName. \"ClusterIP\" allocates a cluster-internal IP address for load-balancing to endpoints. Endpoints are determined by the selector or if that is not specified, by manual construction of an Endpoints object. If clusterIP is \"None\", no virtual IP is allocated and the endpoints are published as a set of endpoints rather than a stable IP. \"NodePort\" builds on ClusterIP and allocates a port on every node which routes to the clusterIP. \"LoadBalancer\" builds on NodePort and creates an external load-balancer (if supported in the current cloud) which routes to the clusterIP. More info: https://kubernetes.io/docs/concepts/services-networking/service/#publishing-services-service-types",
-							Type:        []string{"string"},
-							Format:      "",
+							Type:   []string{"string"},
+							Format: "",
 						},
 					},
-					"externalIPs": {
+					"cpuRequest": {
 						SchemaProps: spec.SchemaProps{
-							Description: "externalIPs is a list of IP addresses for which nodes in the cluster will also accept traffic for this service.  These IPs are not managed by Kubernetes.  The user is responsible for ensuring that traffic arrives at a node with this IP.  A common example is external load-balancers that are not part of the Kubernetes system.",
-							Type:        []string{"array"},
-							Items: &spec.SchemaOrArray{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"additionalJVMConfig": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"additionalProps": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Merged with the additional properties of spec.worker. The properties of the pool win.",
+							Type:        []string{"object"},
+							AdditionalProperties: &spec.SchemaOrBool{
+								Allows: true,
 								Schema: &spec.Schema{
 									SchemaProps: spec.SchemaProps{
 										Type:   []string{"string"},
@@ -553,25 +2134,22 @@ func schema_pkg_apis_falarica_v1alpha1_ServiceSpec(ref common.ReferenceCallback)
 							},
 						},
 					},
-					"sessionAffinity": {
+					"autoscaling": {
 						SchemaProps: spec.SchemaProps{
-							Description: "Supports \"ClientIP\" and \"None\". Used to maintain session affinity. Enable client IP based session affinity. Must be ClientIP or None. Defaults to None. More info: https://kubernetes.io/docs/concepts/services-networking/service/#virtual-ips-and-service-proxies",
-							Type:        []string{"string"},
-							Format:      "",
+							Description: "Autoscaling of the pool through an HPA. The QueryLoad mode is not supported for pools.",
+							Ref:         ref("github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.AutoscalingSpec"),
 						},
 					},
-					"loadBalancerIP": {
+					"storage": {
 						SchemaProps: spec.SchemaProps{
-							Description: "Only applies to Service Type: LoadBalancer LoadBalancer will get created with the IP specified in this field. This feature depends on whether the underlying cloud-provider supports specifying the loadBalancerIP when a load balancer is created. This field will be ignored if the cloud-provider does not support the feature.",
-							Type:        []string{"string"},
-							Format:      "",
+							Ref: ref("github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.StorageSpec"),
 						},
 					},
-					"loadBalancerSourceRanges": {
+					"nodeSelector": {
 						SchemaProps: spec.SchemaProps{
-							Description: "If specified and supported by the platform, this will restrict traffic through the cloud-provider load-balancer will be restricted to the specified client IPs. This field will be ignored if the cloud-provider does not support the feature.\" More info: https://kubernetes.io/docs/tasks/access-application-cluster/configure-cloud-provider-firewall/",
-							Type:        []string{"array"},
-							Items: &spec.SchemaOrArray{
+							Type: []string{"object"},
+							AdditionalProperties: &spec.SchemaOrBool{
+								Allows: true,
 								Schema: &spec.Schema{
 									SchemaProps: spec.SchemaProps{
 										Type:   []string{"string"},
@@ -581,51 +2159,98 @@ func schema_pkg_apis_falarica_v1alpha1_ServiceSpec(ref common.ReferenceCallback)
 							},
 						},
 					},
-					"externalName": {
+					"tolerations": {
 						SchemaProps: spec.SchemaProps{
-							Description: "externalName is the external reference that kubedns or equivalent will return as a CNAME record for this service. No proxying will be involved. Must be a valid RFC-1123 hostname (https://tools.ietf.org/html/rfc1123) and requires Type to be ExternalName.",
-							Type:        []string{"string"},
-							Format:      "",
+							Type: []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("k8s.io/api/core/v1.Toleration"),
+									},
+								},
+							},
 						},
 					},
-					"externalTrafficPolicy": {
+					"affinity": {
 						SchemaProps: spec.SchemaProps{
-							Description: "externalTrafficPolicy denotes if this Service desires to route external traffic to node-local or cluster-wide endpoints. \"Local\" preserves the client source IP and avoids a second hop for LoadBalancer and Nodeport type services, but risks potentially imbalanced traffic spreading. \"Cluster\" obscures the client source IP and may cause a second hop to another node, but should have good overall load-spreading.",
-							Type:        []string{"string"},
-							Format:      "",
+							Description: "By default, the coordinator and the workers of a cluster prefer not to run on the same node. The default is not added when podAntiAffinity is specified.",
+							Ref:         ref("k8s.io/api/core/v1.Affinity"),
 						},
 					},
-					"healthCheckNodePort": {
+					"topologySpreadConstraints": {
 						SchemaProps: spec.SchemaProps{
-							Description: "healthCheckNodePort specifies the healthcheck nodePort for the service. If not specified, HealthCheckNodePort is created by the service api backend with the allocated nodePort. Will use user-specified nodePort value if specified by the client. Only effects when Type is set to LoadBalancer and ExternalTrafficPolicy is set to Local.",
-							Type:        []string{"integer"},
-							Format:      "int32",
+							Description: "Alpha in Kubernetes 1.16 and needs the EvenPodsSpread feature gate till 1.18.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("k8s.io/api/core/v1.TopologySpreadConstraint"),
+									},
+								},
+							},
 						},
 					},
-					"publishNotReadyAddresses": {
+					"priorityClassName": {
 						SchemaProps: spec.SchemaProps{
-							Description: "publishNotReadyAddresses, when set to true, indicates that DNS implementations must publish the notReadyAddresses of subsets for the Endpoints associated with the Service. The default value is false. The primary use case for setting this field is to use a StatefulSet's Headless Service to propagate SRV records for its Pods without respect to their readiness for purpose of peer discovery.",
-							Type:        []string{"boolean"},
-							Format:      "",
+							Type:   []string{"string"},
+							Format: "",
 						},
 					},
-					"sessionAffinityConfig": {
+				},
+				Required: []string{"name"},
+			},
+		},
+		Dependencies: []string{
+			"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.AutoscalingSpec", "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.StorageSpec", "k8s.io/api/core/v1.Affinity", "k8s.io/api/core/v1.Toleration", "k8s.io/api/core/v1.TopologySpreadConstraint"},
+	}
+}
+
+func schema_pkg_apis_falarica_v1alpha1_WorkerPoolStatus(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Type: []string{"object"},
+				Properties: map[string]spec.Schema{
+					"name": {
 						SchemaProps: spec.SchemaProps{
-							Description: "sessionAffinityConfig contains the configurations of session affinity.",
-							Ref:         ref("k8s.io/api/core/v1.SessionAffinityConfig"),
+							Type:   []string{"string"},
+							Format: "",
 						},
 					},
-					"ipFamily": {
+					"replicaSet": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"hpaName": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"pdbName": {
 						SchemaProps: spec.SchemaProps{
 							Type:   []string{"string"},
 							Format: "",
 						},
 					},
+					"desiredWorkers": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"integer"},
+							Format: "int32",
+						},
+					},
+					"currentWorkers": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"integer"},
+							Format: "int32",
+						},
+					},
 				},
+				Required: []string{"name", "desiredWorkers", "currentWorkers"},
 			},
 		},
-		Dependencies: []string{
-			"k8s.io/api/core/v1.SessionAffinityConfig"},
 	}
 }
 
@@ -691,11 +2316,171 @@ func schema_pkg_apis_falarica_v1alpha1_WorkerSpec(ref common.ReferenceCallback)
 							Ref: ref("github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.AutoscalingSpec"),
 						},
 					},
+					"workload": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Kind of the workload that manages the worker pods. With Deployment, the worker pods get rollout history and can be rolled back using kubectl rollout undo. Changing it on a running cluster migrates the workers to the new workload. Defaults to ReplicaSet.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"updateStrategy": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Strategy used by the worker Deployment to replace the workers. Defaults to RollingUpdate with maxSurge 1 and maxUnavailable 0. Applicable only when workload is Deployment.",
+							Ref:         ref("k8s.io/api/apps/v1.DeploymentStrategy"),
+						},
+					},
+					"revisionHistoryLimit": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Number of old worker ReplicaSets to retain for rollback. Applicable only when workload is Deployment.",
+							Type:        []string{"integer"},
+							Format:      "int32",
+						},
+					},
+					"schedules": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Windows of time in which the number of workers, or the bounds of the autoscaling, are overridden. When windows overlap, the first one in the list is used.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.ScalingSchedule"),
+									},
+								},
+							},
+						},
+					},
+					"storage": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Volume mounted at node.data-dir of the workers. It is also used for spilling when spillEnabled is set.",
+							Ref:         ref("github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.StorageSpec"),
+						},
+					},
+					"nodeTermination": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Drains the workers on a node that is about to be terminated, e.g. a spot node that has received a termination notice. It applies to the workers of the worker pools too.",
+							Ref:         ref("github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.NodeTerminationSpec"),
+						},
+					},
+					"probes": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Overrides of the default probes of the workers. They apply to the worker pools too.",
+							Ref:         ref("github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.ProbesSpec"),
+						},
+					},
+					"disruptionBudget": {
+						SchemaProps: spec.SchemaProps{
+							Description: "PodDisruptionBudget of the workers. Defaults to maxUnavailable 1. It applies to each worker pool too.",
+							Ref:         ref("github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.DisruptionBudgetSpec"),
+						},
+					},
+					"nodeSelector": {
+						SchemaProps: spec.SchemaProps{
+							Type: []string{"object"},
+							AdditionalProperties: &spec.SchemaOrBool{
+								Allows: true,
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Type:   []string{"string"},
+										Format: "",
+									},
+								},
+							},
+						},
+					},
+					"tolerations": {
+						SchemaProps: spec.SchemaProps{
+							Type: []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("k8s.io/api/core/v1.Toleration"),
+									},
+								},
+							},
+						},
+					},
+					"affinity": {
+						SchemaProps: spec.SchemaProps{
+							Description: "By default, the coordinator and the workers of a cluster prefer not to run on the same node. The default is not added when podAntiAffinity is specified.",
+							Ref:         ref("k8s.io/api/core/v1.Affinity"),
+						},
+					},
+					"topologySpreadConstraints": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Alpha in Kubernetes 1.16 and needs the EvenPodsSpread feature gate till 1.18.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("k8s.io/api/core/v1.TopologySpreadConstraint"),
+									},
+								},
+							},
+						},
+					},
+					"priorityClassName": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"env": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Environment variables of the presto container. A variable set for the role overrides the one of the same name in spec.common.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("k8s.io/api/core/v1.EnvVar"),
+									},
+								},
+							},
+						},
+					},
+					"envFrom": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Sources of the environment variables of the presto container",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("k8s.io/api/core/v1.EnvFromSource"),
+									},
+								},
+							},
+						},
+					},
+					"initContainers": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Containers run before the presto container. They can mount the volumes of the pod.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("k8s.io/api/core/v1.Container"),
+									},
+								},
+							},
+						},
+					},
+					"sidecars": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Containers run along with the presto container. They can mount the volumes of the pod.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("k8s.io/api/core/v1.Container"),
+									},
+								},
+							},
+						},
+					},
 				},
 				Required: []string{"memoryLimit", "cpuLimit", "count"},
 			},
 		},
 		Dependencies: []string{
-			"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.AutoscalingSpec"},
+			"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.AutoscalingSpec", "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.DisruptionBudgetSpec", "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.NodeTerminationSpec", "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.ProbesSpec", "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.ScalingSchedule", "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1.StorageSpec", "k8s.io/api/apps/v1.DeploymentStrategy", "k8s.io/api/core/v1.Affinity", "k8s.io/api/core/v1.Container", "k8s.io/api/core/v1.EnvFromSource", "k8s.io/api/core/v1.EnvVar", "k8s.io/api/core/v1.Toleration", "k8s.io/api/core/v1.TopologySpreadConstraint"},
 	}
 }
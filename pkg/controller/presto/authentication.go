@@ -0,0 +1,262 @@
+package presto
+
+import (
+	"context"
+	"fmt"
+	falaricav1alpha1 "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1"
+	"golang.org/x/crypto/bcrypt"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sort"
+	"strings"
+)
+
+/*
+  Password authentication uses the file password authenticator of Presto. The users are kept
+  by the user in a secret, whose keys are the user names and values are the passwords. The
+  operator hashes the passwords with bcrypt into password.db, and keeps it in a secret owned
+  by the cluster. That secret is projected into the config directory of the coordinator next
+  to password-authenticator.properties, which is a part of the config map of the coordinator.
+  The version of the users secret from which the file was generated is kept in an annotation
+  of the password file secret, so the file is generated again only when the users change.
+  The clusters are indexed on the name of their users secret, so that a secret event is mapped
+  only to the clusters that authenticate with it.
+  A projected secret is updated in the running pod by the kubelet, and the coordinator reloads
+  the password file periodically. So a change of the users does not restart the coordinator.
+  Presto authenticates only the requests on the HTTPS port. The operator, the probes and the
+  readiness script reach the coordinator on the HTTP port, which is why authentication cannot
+  be used along with internal TLS that disables the HTTP port.
+*/
+
+const (
+	passwordAuthenticatorPropsKey = "password-authenticator.properties"
+	passwordFileKey               = "password.db"
+	// annotation of the password file secret with the name and the version of the users secret
+	usersSecretVersionAnnotation = "falarica.io/users-secret-version"
+	// cost of the bcrypt hashes. Presto does not accept a cost below 8
+	bcryptCost = 10
+	// field index of the clusters on the name of their users secret
+	usersSecretField = "spec.authentication.password.usersSecret"
+)
+
+func isPasswordAuthEnabled(presto *falaricav1alpha1.Presto) bool {
+	return presto.Spec.Authentication != nil
+}
+
+func validateAuthentication(presto *falaricav1alpha1.Presto) error {
+	if !presto.Spec.Coordinator.HttpsEnabled {
+		return &OperatorError{errormsg: "authentication requires HTTPS to be enabled on the coordinator"}
+	}
+	if isInternalTLSEnabled(presto) {
+		return &OperatorError{errormsg: "authentication cannot be specified along with internalTLS"}
+	}
+	if presto.Spec.Authentication.Password == nil || len(presto.Spec.Authentication.Password.UsersSecret) == 0 {
+		return &OperatorError{errormsg: "authentication.password.usersSecret has to be specified"}
+	}
+	if _, ok := presto.Spec.AdditionalPrestoPropFiles[passwordAuthenticatorPropsKey]; ok {
+		return &OperatorError{errormsg: fmt.Sprintf("%s is generated by the operator with authentication. "+
+			"Cannot be specified in additionalPrestoPropFiles", passwordAuthenticatorPropsKey)}
+	}
+	return nil
+}
+
+// returns the properties of the coordinator for password authentication
+func getAuthenticationProps() map[string]string {
+	return map[string]string{
+		"http-server.authentication.type": "PASSWORD",
+	}
+}
+
+// returns password-authenticator.properties of the coordinator
+func getPasswordAuthenticatorProps(presto *falaricav1alpha1.Presto) string {
+	var sb strings.Builder
+	sb.WriteString("password-authenticator.name=file\n")
+	sb.WriteString(fmt.Sprintf("file.password-file=%s/%s\n", getPrestoPath(presto), passwordFileKey))
+	return sb.String()
+}
+
+// returns the projection of the password file into the config directory of the coordinator
+func getPasswordFileProjection(presto *falaricav1alpha1.Presto) corev1.VolumeProjection {
+	return corev1.VolumeProjection{
+		Secret: &corev1.SecretProjection{
+			LocalObjectReference: corev1.LocalObjectReference{
+				Name: getPasswordFileSecretName(presto.Status.Uuid),
+			},
+			Items: []corev1.KeyToPath{{Key: passwordFileKey, Path: passwordFileKey}},
+		},
+	}
+}
+
+// returns the password file with a bcrypt hash of the password of each user. Presto accepts
+// only the $2y$ prefix, which is the same algorithm as the $2a$ of the go implementation.
+func buildPasswordFile(users map[string][]byte) ([]byte, error) {
+	userNames := make([]string, 0, len(users))
+	for user := range users {
+		userNames = append(userNames, user)
+	}
+	sort.Strings(userNames)
+	var sb strings.Builder
+	for _, user := range userNames {
+		if len(users[user]) == 0 {
+			return nil, &OperatorError{errormsg: fmt.Sprintf("password of the user %s is empty", user)}
+		}
+		hash, err := bcrypt.GenerateFromPassword(users[user], bcryptCost)
+		if err != nil {
+			return nil, err
+		}
+		sb.WriteString(fmt.Sprintf("%s:$2y$%s\n", user, strings.TrimPrefix(string(hash), "$2a$")))
+	}
+	return []byte(sb.String()), nil
+}
+
+// generates the password file from the users secret when the users have changed.
+// returns the action taken
+func syncPasswordFileSecret(r *ReconcilePresto, presto *falaricav1alpha1.Presto,
+	baseLabels map[string]string) (string, error) {
+	ctx := context.Background()
+	usersSecretName := presto.Spec.Authentication.Password.UsersSecret
+	usersSecret := &corev1.Secret{}
+	err := r.client.Get(ctx, types.NamespacedName{Namespace: presto.Namespace, Name: usersSecretName}, usersSecret)
+	if errors.IsNotFound(err) {
+		return "", &OperatorError{errormsg: fmt.Sprintf("users secret %s not found", usersSecretName)}
+	}
+	if err != nil {
+		return "", err
+	}
+	if len(usersSecret.Data) == 0 {
+		return "", &OperatorError{errormsg: fmt.Sprintf("users secret %s has no users", usersSecretName)}
+	}
+	version := fmt.Sprintf("%s/%s", usersSecretName, usersSecret.ResourceVersion)
+
+	secretName := getPasswordFileSecretName(presto.Status.Uuid)
+	existing := &corev1.Secret{}
+	err = r.client.Get(ctx, types.NamespacedName{Namespace: presto.Namespace, Name: secretName}, existing)
+	if errors.IsNotFound(err) {
+		existing = nil
+	} else if err != nil {
+		return "", err
+	} else if existing.Annotations[usersSecretVersionAnnotation] == version {
+		return "", nil
+	}
+
+	passwordFile, err := buildPasswordFile(usersSecret.Data)
+	if err != nil {
+		return "", err
+	}
+	if existing == nil {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            secretName,
+				Namespace:       presto.Namespace,
+				Labels:          baseLabels,
+				Annotations:     map[string]string{usersSecretVersionAnnotation: version},
+				OwnerReferences: []metav1.OwnerReference{*getOwnerReference(presto)},
+			},
+			Data: map[string][]byte{passwordFileKey: passwordFile},
+		}
+		return "Created", r.client.Create(ctx, secret)
+	}
+	secretCopy := existing.DeepCopy()
+	if secretCopy.Annotations == nil {
+		secretCopy.Annotations = map[string]string{}
+	}
+	secretCopy.Annotations[usersSecretVersionAnnotation] = version
+	secretCopy.Data = map[string][]byte{passwordFileKey: passwordFile}
+	return "Updated", r.client.Update(ctx, secretCopy)
+}
+
+// generates the password file of the coordinator when spec.authentication is set, and
+// removes it otherwise. returns error, changesMade
+func (r *ReconcilePresto) passwordFile(presto *falaricav1alpha1.Presto,
+	baseLabels map[string]string,
+	ctx context.Context) (error, bool) {
+	secretName := getPasswordFileSecretName(presto.Status.Uuid)
+	if !isPasswordAuthEnabled(presto) {
+		if len(presto.Status.PasswordFileSecret) == 0 {
+			return nil, false
+		}
+		deleted, err := deleteIfExists(r, presto, secretName, &corev1.Secret{})
+		if err != nil {
+			r.log.Error(err, "failed to delete the password file secret "+secretName)
+			return err, false
+		}
+		if deleted {
+			r.eventRecorder.Eventf(presto, corev1.EventTypeNormal, "Deleted",
+				"Deleted the password file of the coordinator. %s", secretName)
+		}
+		emptySecret := ""
+		r.updateStatus(presto, ctx, ClusterUpdateAction{passwordFile: &emptySecret})
+		return nil, false
+	}
+
+	err := validateAuthentication(presto)
+	action := ""
+	if err == nil {
+		action, err = syncPasswordFileSecret(r, presto, baseLabels)
+	}
+	if err != nil {
+		r.log.Error(err, "failed to generate the password file")
+		errorReason := fmt.Sprintf("Failed to generate the password file %s", err.Error())
+		r.updateStatus(presto, ctx, ClusterUpdateAction{
+			errorReason:  &errorReason,
+			clusterState: falaricav1alpha1.ClusterFailedState,
+			conditions:   failureConditions(presto, "AuthenticationFailed", errorReason),
+		})
+		r.eventRecorder.Eventf(presto, corev1.EventTypeWarning, "Failed", "%s", errorReason)
+		return err, false
+	}
+	if len(action) != 0 {
+		r.eventRecorder.Eventf(presto, corev1.EventTypeNormal, action,
+			"%s the password file of the coordinator %s from the users secret %s", action, secretName,
+			presto.Spec.Authentication.Password.UsersSecret)
+	}
+	if presto.Status.PasswordFileSecret != secretName {
+		r.updateStatus(presto, ctx, ClusterUpdateAction{passwordFile: &secretName})
+	}
+	// an updated file is reloaded by the running coordinator
+	return nil, action == "Created"
+}
+
+// extracts the name of the users secret of a cluster for the field index
+func indexUsersSecret(obj runtime.Object) []string {
+	presto, ok := obj.(*falaricav1alpha1.Presto)
+	if !ok {
+		return nil
+	}
+	auth := presto.Spec.Authentication
+	if auth == nil || auth.Password == nil || len(auth.Password.UsersSecret) == 0 {
+		return nil
+	}
+	return []string{auth.Password.UsersSecret}
+}
+
+// maps a users secret to the clusters that authenticate with it
+func (r *ReconcilePresto) prestosOfUsersSecret(obj handler.MapObject) []reconcile.Request {
+	secret, ok := obj.Object.(*corev1.Secret)
+	if !ok {
+		return nil
+	}
+	prestos := &falaricav1alpha1.PrestoList{}
+	if err := r.client.List(context.Background(), prestos, client.InNamespace(secret.Namespace),
+		client.MatchingField(usersSecretField, secret.Name)); err != nil {
+		r.log.Error(err, "failed to list the presto clusters of namespace "+secret.Namespace)
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, presto := range prestos.Items {
+		auth := presto.Spec.Authentication
+		if auth != nil && auth.Password != nil && auth.Password.UsersSecret == secret.Name {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+				Namespace: presto.Namespace,
+				Name:      presto.Name,
+			}})
+		}
+	}
+	return requests
+}
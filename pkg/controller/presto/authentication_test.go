@@ -0,0 +1,45 @@
+package presto
+
+import (
+	"testing"
+
+	falaricav1alpha1 "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+)
+
+func newTestPrestoWithUsers(name string, namespace string, usersSecret string) *falaricav1alpha1.Presto {
+	presto := newTestPresto()
+	presto.Name = name
+	presto.Namespace = namespace
+	presto.Spec.Authentication = &falaricav1alpha1.AuthenticationSpec{
+		Password: &falaricav1alpha1.PasswordAuthenticationSpec{UsersSecret: usersSecret},
+	}
+	return presto
+}
+
+func TestIndexUsersSecret(t *testing.T) {
+	if values := indexUsersSecret(newTestPrestoWithUsers("a", testNamespace, "users")); len(values) != 1 || values[0] != "users" {
+		t.Errorf("unexpected index values %v", values)
+	}
+	if values := indexUsersSecret(newTestPresto()); len(values) != 0 {
+		t.Errorf("a cluster without authentication is indexed %v", values)
+	}
+	if values := indexUsersSecret(&corev1.Secret{}); len(values) != 0 {
+		t.Errorf("a secret is indexed %v", values)
+	}
+}
+
+func TestPrestosOfUsersSecret(t *testing.T) {
+	r := newTestReconciler(t,
+		newTestPrestoWithUsers("a", testNamespace, "users"),
+		newTestPrestoWithUsers("b", testNamespace, "other"),
+		newTestPrestoWithUsers("c", "other", "users"),
+	)
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "users", Namespace: testNamespace}}
+	requests := r.prestosOfUsersSecret(handler.MapObject{Meta: secret, Object: secret})
+	if len(requests) != 1 || requests[0].Name != "a" || requests[0].Namespace != testNamespace {
+		t.Errorf("unexpected requests %v", requests)
+	}
+}
@@ -1,21 +1,62 @@
 package presto
 
 import (
+	"context"
 	"fmt"
 	"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"strings"
 )
 
+// return createdConfig, updatedConfig, error
 func createCatalogConfig(presto *v1alpha1.Presto, r *ReconcilePresto,
-	lbls map[string]string) (bool, error) {
+	lbls map[string]string) (bool, bool, error) {
 	catalogConfigName := getCatalogConfigMapName(presto.Status.Uuid)
 	configMap, err := buildCatalogConfigMap(presto, catalogConfigName, lbls)
 	if err != nil {
-		return false, err
+		return false, false, err
 	}
-	return createConfigMap(catalogConfigName, presto, r.client, configMap, lbls)
+	return createOrUpdateConfigMap(catalogConfigName, presto, r.client, configMap, lbls)
+}
+
+// validates the catalogs in the spec and checks that the catalog secrets are present.
+// The pods do not start if a catalog secret is missing.
+func validateCatalogs(presto *v1alpha1.Presto, r *ReconcilePresto) error {
+	catalogNames := make(map[string]bool)
+	for _, catalog := range presto.Spec.Catalogs.CatalogSpec {
+		if catalogNames[catalog.Name] {
+			return &OperatorError{fmt.Sprintf("catalog %s is specified more than once", catalog.Name)}
+		}
+		catalogNames[catalog.Name] = true
+		if len(catalog.Content["connector.name"]) == 0 {
+			return &OperatorError{fmt.Sprintf("connector.name is not specified for catalog %s", catalog.Name)}
+		}
+	}
+	for _, catalogSecret := range presto.Spec.Catalogs.CatalogSecrets {
+		if catalogNames[catalogSecret.SecretKey] {
+			return &OperatorError{fmt.Sprintf("catalog %s is specified more than once", catalogSecret.SecretKey)}
+		}
+		catalogNames[catalogSecret.SecretKey] = true
+		secret := &corev1.Secret{}
+		err := r.client.Get(context.TODO(), types.NamespacedName{
+			Namespace: presto.Namespace,
+			Name:      catalogSecret.SecretName,
+		}, secret)
+		if errors.IsNotFound(err) {
+			return &OperatorError{fmt.Sprintf("catalog secret %s is not found", catalogSecret.SecretName)}
+		}
+		if err != nil {
+			return err
+		}
+		if _, ok := secret.Data[catalogSecret.SecretKey]; !ok {
+			return &OperatorError{fmt.Sprintf("key %s is not found in catalog secret %s",
+				catalogSecret.SecretKey, catalogSecret.SecretName)}
+		}
+	}
+	return nil
 }
 
 func getCatalogVolumeMount(presto *v1alpha1.Presto, podSpec *corev1.PodSpec) *corev1.VolumeMount {
@@ -92,8 +133,8 @@ func buildCatalogConfigMap(presto *v1alpha1.Presto,
 	catalogData := make(map[string]string)
 	for _, catalog := range presto.Spec.Catalogs.CatalogSpec {
 		var sb strings.Builder
-		for key, value := range catalog.Content {
-			sb.WriteString(fmt.Sprintf("%s=%s\n", key, value))
+		for _, key := range sortedKeys(catalog.Content) {
+			sb.WriteString(fmt.Sprintf("%s=%s\n", key, catalog.Content[key]))
 		}
 		catalogData[catalog.Name + catalogFileSuffix] = sb.String()
 	}
@@ -103,6 +144,9 @@ func buildCatalogConfigMap(presto *v1alpha1.Presto,
 		// add .properties to the catalog name. as we are not asking that as part of catalog name
 		catalogData[k + catalogFileSuffix] = v
 	}
+	if hmsCatalog, content, ok := getHMSCatalog(presto); ok {
+		catalogData[hmsCatalog + catalogFileSuffix] = content
+	}
 
 	return &corev1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
@@ -7,7 +7,9 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"reflect"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sort"
 )
 
 func getPodDiscoveryServiceName(clusterUUID string) string {
@@ -18,10 +20,16 @@ func getPodDiscoveryServiceLabel(clusterUUID string) (string, string) {
 	return "pod-discovery", clusterUUID
 }
 
-func getCoordinatorInternalName(clusterUUID string) string {
+// returns the DNS name of the coordinator pod within the pod discovery service.
+// The pod of a statefulset gets the name of the statefulset with the ordinal as hostname.
+func getCoordinatorInternalName(presto *v1alpha1.Presto) string {
+	hostname := getCoordinatorContainerName(presto.Status.Uuid)
+	if isCoordinatorStatefulSetEnabled(presto) {
+		hostname = getCoordinatorStatefulSetName(presto.Status.Uuid) + "-0"
+	}
 	return fmt.Sprintf("%s.%s",
-		getCoordinatorContainerName(clusterUUID),
-		getPodDiscoveryServiceName(clusterUUID))
+		hostname,
+		getPodDiscoveryServiceName(presto.Status.Uuid))
 }
 
 func getExternalServiceName(clusterUUID string) string {
@@ -39,10 +47,26 @@ func getCoordinatorReplicaset(clusterUUID string) string {
 	return "coordinatorreplicaset-" + clusterUUID[:8]
 }
 
+func getCoordinatorStatefulSetName(clusterUUID string) string {
+	return "coordinatorstatefulset-" + clusterUUID[:8]
+}
+
+func getCoordinatorDataVolName(clusterUUID string) string {
+	return "coordinatordata-" + clusterUUID[:8]
+}
+
+func getWorkerDataVolName(clusterUUID string) string {
+	return "workerdata-" + clusterUUID[:8]
+}
+
 func getWorkerReplicaSet(clusterUUID string) string {
 	return "workerreplicaset-" + clusterUUID[:8]
 }
 
+func getWorkerDeploymentName(clusterUUID string) string {
+	return "workerdeployment-" + clusterUUID[:8]
+}
+
 func getCatalogConfigMapName(clusterUUID string) string {
 	return "catalogconfig-" + clusterUUID[:8]
 }
@@ -55,6 +79,47 @@ func getHTTPSSecretVolName(clusterUUID string) string {
 	return "httpssecret-" + clusterUUID[:8]
 }
 
+// secret of the certificate of the coordinator provisioned by the operator
+func getCoordinatorTLSSecretName(clusterUUID string) string {
+	return "coordinatortls-" + clusterUUID[:8]
+}
+
+// name of the cert-manager Certificate of the coordinator
+func getCertificateName(clusterUUID string) string {
+	return "coordinatorcert-" + clusterUUID[:8]
+}
+
+// secret in which cert-manager stores the certificate of the coordinator
+func getCertificateSecretName(clusterUUID string) string {
+	return "coordinatorcert-" + clusterUUID[:8]
+}
+
+// secret of the certificate of the internal communication
+func getInternalTLSSecretName(clusterUUID string) string {
+	return "internaltls-" + clusterUUID[:8]
+}
+
+// secret of the password file of the coordinator
+func getPasswordFileSecretName(clusterUUID string) string {
+	return "passwordfile-" + clusterUUID[:8]
+}
+
+func getInternalTLSVolName(clusterUUID string) string {
+	return "internaltls-" + clusterUUID[:8]
+}
+
+const workerDiscoveryServicePrefix = "worker-discovery-"
+
+// headless service that gives the workers their DNS names when internal TLS is enabled
+func getWorkerDiscoveryServiceName(clusterUUID string) string {
+	return workerDiscoveryServicePrefix + clusterUUID[:8]
+}
+
+// secret that holds the shared secret of the internal communication
+func getSharedSecretName(clusterUUID string) string {
+	return "sharedsecret-" + clusterUUID[:8]
+}
+
 func getHPAName(clusterUUID string) string {
 	return "hpa-" + clusterUUID[:8]
 }
@@ -82,6 +147,58 @@ func getWorkerPodLabel(clusterUUID string) (string, string) {
 	return "worker", clusterUUID
 }
 
+// label of the worker pods that are being decommissioned
+func getDecommissioningPodLabel(clusterUUID string) (string, string) {
+	return "decommissioning", clusterUUID
+}
+
+// name of the deployment and the service of the hive metastore
+func getHMSName(clusterUUID string) string {
+	return "hivemetastore-" + clusterUUID[:8]
+}
+
+func getHMSConfigMapName(clusterUUID string) string {
+	return "hmsconfig-" + clusterUUID[:8]
+}
+
+func getHMSPodLabel(clusterUUID string) (string, string) {
+	return "hivemetastore", clusterUUID
+}
+
+func getWorkerPoolReplicaSetName(clusterUUID string, pool string) string {
+	return "workerpool-" + pool + "-" + clusterUUID[:8]
+}
+
+func getWorkerPoolConfigMapName(clusterUUID string, pool string) string {
+	return "workerpoolconfig-" + pool + "-" + clusterUUID[:8]
+}
+
+func getWorkerPoolHPAName(clusterUUID string, pool string) string {
+	return "hpa-" + pool + "-" + clusterUUID[:8]
+}
+
+func getWorkerPoolPDBName(clusterUUID string, pool string) string {
+	return "workerpoolpdb-" + pool + "-" + clusterUUID[:8]
+}
+
+func getCoordinatorPDBName(clusterUUID string) string {
+	return "coordinatorpdb-" + clusterUUID[:8]
+}
+
+func getWorkerPDBName(clusterUUID string) string {
+	return "workerpdb-" + clusterUUID[:8]
+}
+
+// label of the pods of all the worker pools
+func getWorkerPoolPodLabel(clusterUUID string) (string, string) {
+	return "workerpool", clusterUUID
+}
+
+// label of the pods of the worker pool
+func getWorkerPoolNamePodLabel(clusterUUID string, pool string) (string, string) {
+	return workerPoolLabelPrefix + pool, clusterUUID
+}
+
 func getCoordinatorPodLabels(baseLabels map[string]string, clusterUUID string) map[string]string {
 	lbls := make(map[string]string)
 	for key, value := range baseLabels {
@@ -120,8 +237,11 @@ func getOwnerReference(app *v1alpha1.Presto) *metav1.OwnerReference {
 	}
 }
 
-func createConfigMap(configMapName string, presto *v1alpha1.Presto, c client.Client,
-	configMap *corev1.ConfigMap, lbls map[string]string) (bool, error) {
+// creates the config map if it is not present. If it is present and the rendered
+// data differs from what is in the cluster, the difference is patched.
+// returns created, updated, error
+func createOrUpdateConfigMap(configMapName string, presto *v1alpha1.Presto, c client.Client,
+	configMap *corev1.ConfigMap, lbls map[string]string) (bool, bool, error) {
 	oldConfigMaps := &corev1.ConfigMapList{}
 	err := c.List(context.TODO(),
 		oldConfigMaps,
@@ -130,19 +250,40 @@ func createConfigMap(configMapName string, presto *v1alpha1.Presto, c client.Cli
 			LabelSelector: labels.SelectorFromSet(lbls),
 		})
 	if err != nil {
-		return false, err
+		return false, false, err
 	}
 	for _, cm := range oldConfigMaps.Items {
 		if cm.Name == configMapName {
-			return false, nil
+			if reflect.DeepEqual(cm.Data, configMap.Data) ||
+				(len(cm.Data) == 0 && len(configMap.Data) == 0) {
+				return false, false, nil
+			}
+			cmCopy := cm.DeepCopy()
+			cmCopy.Data = configMap.Data
+			patchErr := c.Patch(context.Background(), cmCopy, client.MergeFrom(&cm))
+			if patchErr != nil {
+				return false, false, patchErr
+			}
+			return false, true, nil
 		}
 	}
 	createErr := c.Create(context.Background(), configMap)
 	if createErr != nil {
-		return false, createErr
+		return false, false, createErr
 	} else {
-		return true, createErr
+		return true, false, createErr
+	}
+}
+
+// returns the keys of the map in sorted order. Used for rendering the
+// properties files so that the content does not change across reconciles.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
 	}
+	sort.Strings(keys)
+	return keys
 }
 
 func getPrestoPath(presto *v1alpha1.Presto) string {
@@ -0,0 +1,186 @@
+package presto
+
+import (
+	"fmt"
+	falaricav1alpha1 "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1"
+	v1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+/*
+  The steps of Reconcile set the conditions of the Presto status. A condition is
+  replaced when it is set again. The transition time, taken from the clock of the
+  reconciler when the status is updated, changes only when the status of the condition
+  changes.
+  Ready is true when the coordinator is ready, the workers are available, the hive
+  metastore deployed by the operator is available, the pods are running with the
+  latest configuration and the cluster is not degraded.
+*/
+
+func newCondition(presto *falaricav1alpha1.Presto, conditionType falaricav1alpha1.PrestoConditionType,
+	status corev1.ConditionStatus, reason string, message string) falaricav1alpha1.PrestoCondition {
+	return falaricav1alpha1.PrestoCondition{
+		Type:               conditionType,
+		Status:             status,
+		ObservedGeneration: presto.Generation,
+		Reason:             reason,
+		Message:            message,
+	}
+}
+
+// returns the conditions to be set when a step of Reconcile fails. The given condition
+// types are set to false along with Ready and the cluster is marked as degraded.
+func failureConditions(presto *falaricav1alpha1.Presto, reason string, message string,
+	conditionTypes ...falaricav1alpha1.PrestoConditionType) []falaricav1alpha1.PrestoCondition {
+	var conditions []falaricav1alpha1.PrestoCondition
+	for _, conditionType := range conditionTypes {
+		conditions = append(conditions, newCondition(presto, conditionType, corev1.ConditionFalse,
+			reason, message))
+	}
+	return append(conditions,
+		newCondition(presto, falaricav1alpha1.ConditionDegraded, corev1.ConditionTrue, reason, message),
+		newCondition(presto, falaricav1alpha1.ConditionReady, corev1.ConditionFalse, reason, message))
+}
+
+func findCondition(conditions []falaricav1alpha1.PrestoCondition,
+	conditionType falaricav1alpha1.PrestoConditionType) *falaricav1alpha1.PrestoCondition {
+	for i := range conditions {
+		if conditions[i].Type == conditionType {
+			return &conditions[i]
+		}
+	}
+	return nil
+}
+
+func isConditionTrue(conditions []falaricav1alpha1.PrestoCondition,
+	conditionType falaricav1alpha1.PrestoConditionType) bool {
+	condition := findCondition(conditions, conditionType)
+	return condition != nil && condition.Status == corev1.ConditionTrue
+}
+
+// adds the condition or replaces the existing condition of the same type.
+// The transition time of the existing condition is retained if the status has not changed.
+// returns whether the conditions changed
+func setCondition(conditions *[]falaricav1alpha1.PrestoCondition,
+	newCondition falaricav1alpha1.PrestoCondition, now metav1.Time) bool {
+	newCondition.LastTransitionTime = now
+	existing := findCondition(*conditions, newCondition.Type)
+	if existing == nil {
+		*conditions = append(*conditions, newCondition)
+		return true
+	}
+	if existing.Status == newCondition.Status &&
+		existing.Reason == newCondition.Reason &&
+		existing.Message == newCondition.Message &&
+		existing.ObservedGeneration == newCondition.ObservedGeneration {
+		return false
+	}
+	if existing.Status == newCondition.Status {
+		newCondition.LastTransitionTime = existing.LastTransitionTime
+	}
+	*existing = newCondition
+	return true
+}
+
+// returns the conditions of the cluster once all the steps of Reconcile have succeeded
+// and the state reported by the coordinator. The coordinator is considered ready once
+// the presto server has started. The workers are considered available once they have
+// registered with the coordinator.
+func (r *ReconcilePresto) clusterConditions(presto *falaricav1alpha1.Presto,
+	workerReplicaSet *v1.ReplicaSet, workerDeployment *v1.Deployment,
+	workerPoolReplicaSets []*v1.ReplicaSet, configApplied bool) ([]falaricav1alpha1.PrestoCondition, *prestoState) {
+	var conditions []falaricav1alpha1.PrestoCondition
+	notReadyReason := ""
+	notReadyMessage := ""
+
+	podReady := false
+	coordinatorPods, err := getPrestoPods(r, presto, getCoordinatorPodLabel)
+	if err != nil {
+		r.log.Error(err, "Failed to find the coordinator pod")
+	}
+	for i := range coordinatorPods {
+		if coordinatorPods[i].DeletionTimestamp == nil && isPodReady(&coordinatorPods[i]) {
+			podReady = true
+		}
+	}
+	var state *prestoState
+	if !podReady {
+		notReadyReason = "CoordinatorNotReady"
+		notReadyMessage = "Coordinator pod is not ready"
+	} else if state, err = r.getPrestoState(presto); err != nil {
+		notReadyReason = "CoordinatorUnreachable"
+		notReadyMessage = fmt.Sprintf("Failed to query the coordinator: %s", err.Error())
+	} else if state.info.Starting {
+		notReadyReason = "PrestoStarting"
+		notReadyMessage = "Presto server is starting"
+	}
+	if len(notReadyReason) == 0 {
+		conditions = append(conditions, newCondition(presto, falaricav1alpha1.ConditionCoordinatorReady,
+			corev1.ConditionTrue, "CoordinatorReady",
+			fmt.Sprintf("Presto %s has started", state.info.NodeVersion.Version)))
+	} else {
+		conditions = append(conditions, newCondition(presto, falaricav1alpha1.ConditionCoordinatorReady,
+			corev1.ConditionFalse, notReadyReason, notReadyMessage))
+	}
+
+	var desiredWorkers, availableWorkers int32
+	if workerDeployment != nil {
+		desiredWorkers = *workerDeployment.Spec.Replicas
+		availableWorkers = workerDeployment.Status.AvailableReplicas
+	} else if workerReplicaSet != nil {
+		desiredWorkers = *workerReplicaSet.Spec.Replicas
+		availableWorkers = workerReplicaSet.Status.AvailableReplicas
+	}
+	for _, poolReplicaSet := range workerPoolReplicaSets {
+		desiredWorkers += *poolReplicaSet.Spec.Replicas
+		availableWorkers += poolReplicaSet.Status.AvailableReplicas
+	}
+	workersReason := ""
+	workersMessage := fmt.Sprintf("%d of %d workers are available", availableWorkers, desiredWorkers)
+	if availableWorkers < desiredWorkers {
+		workersReason = "WorkersUnavailable"
+	} else if state == nil || state.stats == nil {
+		workersReason = "CoordinatorNotReady"
+		workersMessage = "Registered workers are not known till the coordinator is ready"
+	} else if int32(state.stats.ActiveWorkers) < desiredWorkers {
+		workersReason = "WorkersNotRegistered"
+		workersMessage = fmt.Sprintf("%d of %d workers have registered with the coordinator",
+			state.stats.ActiveWorkers, desiredWorkers)
+	}
+	if len(workersReason) == 0 {
+		conditions = append(conditions, newCondition(presto, falaricav1alpha1.ConditionWorkersAvailable,
+			corev1.ConditionTrue, "WorkersAvailable", workersMessage))
+	} else {
+		if len(notReadyReason) == 0 {
+			notReadyReason = workersReason
+			notReadyMessage = workersMessage
+		}
+		conditions = append(conditions, newCondition(presto, falaricav1alpha1.ConditionWorkersAvailable,
+			corev1.ConditionFalse, workersReason, workersMessage))
+	}
+
+	if isHiveMetastoreEnabled(presto) {
+		hmsCondition := getHiveMetastoreCondition(r, presto)
+		if hmsCondition.Status != corev1.ConditionTrue && len(notReadyReason) == 0 {
+			notReadyReason = hmsCondition.Reason
+			notReadyMessage = hmsCondition.Message
+		}
+		conditions = append(conditions, hmsCondition)
+	}
+
+	if !configApplied && len(notReadyReason) == 0 {
+		notReadyReason = "RollingOut"
+		notReadyMessage = "Pods are being restarted with the latest configuration"
+	}
+	conditions = append(conditions, newCondition(presto, falaricav1alpha1.ConditionDegraded,
+		corev1.ConditionFalse, "Reconciled", "All the components of the cluster have been reconciled"))
+	if len(notReadyReason) == 0 {
+		conditions = append(conditions, newCondition(presto, falaricav1alpha1.ConditionReady,
+			corev1.ConditionTrue, "Ready", "Cluster is ready"))
+	} else {
+		conditions = append(conditions, newCondition(presto, falaricav1alpha1.ConditionReady,
+			corev1.ConditionFalse, notReadyReason, notReadyMessage))
+	}
+	return conditions, state
+}
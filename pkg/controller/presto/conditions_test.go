@@ -0,0 +1,207 @@
+package presto
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	falaricav1alpha1 "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1"
+	"github.com/falarica/steerd-presto-operator/pkg/prestoclient"
+	v1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+// returns a fake coordinator. A path that is not in responses returns 500.
+func newFakeCoordinator(responses map[string]string, requests *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(requests, 1)
+		body, ok := responses[req.URL.Path]
+		if !ok {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+}
+
+func newTestReplicaSet(replicas int32, available int32) *v1.ReplicaSet {
+	return &v1.ReplicaSet{
+		Spec:   v1.ReplicaSetSpec{Replicas: &replicas},
+		Status: v1.ReplicaSetStatus{AvailableReplicas: available},
+	}
+}
+
+func TestClusterConditions(t *testing.T) {
+	startedInfo := `{"nodeVersion":{"version":"333"},"coordinator":true,"starting":false}`
+	twoNodes := `[{"uri":"http://10.0.0.2:8080"},{"uri":"http://10.0.0.3:8080"}]`
+	tests := []struct {
+		name             string
+		coordinatorReady bool
+		responses        map[string]string
+		workers          *v1.ReplicaSet
+		configApplied    bool
+		ready            corev1.ConditionStatus
+		readyReason      string
+		coordinatorCond  string
+		workersCond      string
+		noRequests       bool
+	}{
+		{
+			name:             "ready",
+			coordinatorReady: true,
+			responses: map[string]string{"/v1/info": startedInfo, "/v1/node": twoNodes,
+				"/v1/node/failed": `[]`, "/v1/cluster": `{"activeWorkers":2,"runningQueries":1}`},
+			workers:         newTestReplicaSet(2, 2),
+			configApplied:   true,
+			ready:           corev1.ConditionTrue,
+			readyReason:     "Ready",
+			coordinatorCond: "CoordinatorReady",
+			workersCond:     "WorkersAvailable",
+		},
+		{
+			name:             "coordinator pod not ready",
+			coordinatorReady: false,
+			workers:          newTestReplicaSet(2, 2),
+			configApplied:    true,
+			ready:            corev1.ConditionFalse,
+			readyReason:      "CoordinatorNotReady",
+			coordinatorCond:  "CoordinatorNotReady",
+			workersCond:      "CoordinatorNotReady",
+			noRequests:       true,
+		},
+		{
+			name:             "coordinator unreachable",
+			coordinatorReady: true,
+			responses:        map[string]string{},
+			workers:          newTestReplicaSet(2, 2),
+			configApplied:    true,
+			ready:            corev1.ConditionFalse,
+			readyReason:      "CoordinatorUnreachable",
+			coordinatorCond:  "CoordinatorUnreachable",
+			workersCond:      "CoordinatorNotReady",
+		},
+		{
+			name:             "presto starting",
+			coordinatorReady: true,
+			responses:        map[string]string{"/v1/info": `{"nodeVersion":{"version":"333"},"starting":true}`},
+			workers:          newTestReplicaSet(2, 2),
+			configApplied:    true,
+			ready:            corev1.ConditionFalse,
+			readyReason:      "PrestoStarting",
+			coordinatorCond:  "PrestoStarting",
+			workersCond:      "CoordinatorNotReady",
+		},
+		{
+			name:             "workers not registered",
+			coordinatorReady: true,
+			responses: map[string]string{"/v1/info": startedInfo, "/v1/node": `[]`,
+				"/v1/node/failed": `[]`, "/v1/cluster": `{"activeWorkers":1}`},
+			workers:         newTestReplicaSet(2, 2),
+			configApplied:   true,
+			ready:           corev1.ConditionFalse,
+			readyReason:     "WorkersNotRegistered",
+			coordinatorCond: "CoordinatorReady",
+			workersCond:     "WorkersNotRegistered",
+		},
+		{
+			name:             "workers unavailable",
+			coordinatorReady: true,
+			responses: map[string]string{"/v1/info": startedInfo, "/v1/node": twoNodes,
+				"/v1/node/failed": `[]`, "/v1/cluster": `{"activeWorkers":2}`},
+			workers:         newTestReplicaSet(3, 2),
+			configApplied:   true,
+			ready:           corev1.ConditionFalse,
+			readyReason:     "WorkersUnavailable",
+			coordinatorCond: "CoordinatorReady",
+			workersCond:     "WorkersUnavailable",
+		},
+		{
+			name:             "rolling out",
+			coordinatorReady: true,
+			responses: map[string]string{"/v1/info": startedInfo, "/v1/node": twoNodes,
+				"/v1/node/failed": `[]`, "/v1/cluster": `{"activeWorkers":2}`},
+			workers:         newTestReplicaSet(2, 2),
+			configApplied:   false,
+			ready:           corev1.ConditionFalse,
+			readyReason:     "RollingOut",
+			coordinatorCond: "CoordinatorReady",
+			workersCond:     "WorkersAvailable",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var requests int32
+			server := newFakeCoordinator(test.responses, &requests)
+			defer server.Close()
+
+			presto := newTestPresto()
+			objs := []runtime.Object{presto,
+				newTestPod("coordinator-0", getCoordinatorPodLabel, "node-1", test.coordinatorReady)}
+			r := newTestReconciler(t, objs...)
+			r.prestoClient = func(presto *falaricav1alpha1.Presto) *prestoclient.Client {
+				return prestoclient.New(server.URL, nil)
+			}
+
+			conditions, state := r.clusterConditions(presto, test.workers, nil, nil, test.configApplied)
+
+			ready := findCondition(conditions, falaricav1alpha1.ConditionReady)
+			if ready == nil || ready.Status != test.ready || ready.Reason != test.readyReason {
+				t.Errorf("Ready condition %+v, expected %s %s", ready, test.ready, test.readyReason)
+			}
+			coordinator := findCondition(conditions, falaricav1alpha1.ConditionCoordinatorReady)
+			if coordinator == nil || coordinator.Reason != test.coordinatorCond {
+				t.Errorf("CoordinatorReady condition %+v, expected %s", coordinator, test.coordinatorCond)
+			}
+			workers := findCondition(conditions, falaricav1alpha1.ConditionWorkersAvailable)
+			if workers == nil || workers.Reason != test.workersCond {
+				t.Errorf("WorkersAvailable condition %+v, expected %s", workers, test.workersCond)
+			}
+			if test.noRequests && atomic.LoadInt32(&requests) != 0 {
+				t.Errorf("the coordinator was queried %d times before its pod was ready", requests)
+			}
+			if test.readyReason == "Ready" {
+				if state == nil || state.activeNodes != 2 || state.stats.RunningQueries != 1 {
+					t.Errorf("unexpected state %+v", state)
+				}
+			}
+		})
+	}
+}
+
+func TestConditionTransitionTime(t *testing.T) {
+	presto := newTestPresto()
+	r := newTestReconciler(t, presto)
+	fakeClock := clock.NewFakeClock(mustParseTime(t, "2026-10-19T09:00:00Z"))
+	r.clock = fakeClock
+	setReady := func(status corev1.ConditionStatus, message string) *falaricav1alpha1.PrestoCondition {
+		presto := getTestPresto(t, r)
+		r.updateStatus(presto, context.Background(), ClusterUpdateAction{
+			conditions: []falaricav1alpha1.PrestoCondition{
+				newCondition(presto, falaricav1alpha1.ConditionReady, status, "Test", message),
+			},
+		})
+		return findCondition(getTestPresto(t, r).Status.Conditions, falaricav1alpha1.ConditionReady)
+	}
+
+	condition := setReady(corev1.ConditionFalse, "starting")
+	if !condition.LastTransitionTime.Time.Equal(fakeClock.Now()) {
+		t.Errorf("transition time %v, expected %v", condition.LastTransitionTime, fakeClock.Now())
+	}
+	// the time is retained while the status does not change
+	started := fakeClock.Now()
+	fakeClock.Step(time.Minute)
+	condition = setReady(corev1.ConditionFalse, "still starting")
+	if !condition.LastTransitionTime.Time.Equal(started) {
+		t.Errorf("transition time %v, expected %v", condition.LastTransitionTime, started)
+	}
+	fakeClock.Step(time.Minute)
+	condition = setReady(corev1.ConditionTrue, "ready")
+	if !condition.LastTransitionTime.Time.Equal(fakeClock.Now()) {
+		t.Errorf("transition time %v, expected %v", condition.LastTransitionTime, fakeClock.Now())
+	}
+}
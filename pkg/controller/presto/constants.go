@@ -8,9 +8,38 @@ const (
 	mountPath               = "/etc/presto"
 	httpsVolPath            = "/etc/httpssecret"
 	prestoShutdownScript    = "presto_shutdown.sh"
+	// environment variable of the coordinator that holds the password of the HTTPS keystore
+	httpsKeystorePasswordEnv = "HTTPS_KEYSTORE_PASSWORD"
+	// script run by the readiness probe of the presto containers
+	prestoReadinessScript   = "presto_ready.sh"
 	DefaultTerminationGracePeriodSeconds = 7200
 	catalogFileSuffix       = ".properties"
 	catalogMountPath        = "/catalog/"
+	// node.data-dir of the presto pods
+	dataDirPath             = "/data/presto"
+	// annotation on the pod template that holds the hash of the rendered configuration
+	configHashAnnotation    = "falarica.io/config-hash"
+	// annotation on the coordinator pod template that holds the hash of its TLS keystore
+	tlsHashAnnotation       = "falarica.io/tls-hash"
+	// annotation on the pod template that holds the hash of the pod spec generated by the operator
+	podSpecHashAnnotation   = "falarica.io/podspec-hash"
+	// annotation on the coordinator statefulset that holds the hash of its volume claim templates
+	volumeClaimHashAnnotation = "falarica.io/volumeclaim-hash"
+	// annotation on the HPA that holds the hash of the behavior set by the operator
+	hpaBehaviorHashAnnotation = "falarica.io/hpa-behavior-hash"
+	// annotation on a decommissioned worker pod that holds the time the decommissioning started
+	decommissionStartAnnotation = "falarica.io/decommission-start"
+	// annotation on a decommissioned worker pod that holds its container restarts at that time
+	decommissionRestartsAnnotation = "falarica.io/decommission-restarts"
+	// thrift port of the hive metastore
+	hmsThriftPort           = 9083
+	defaultHMSImage         = "apache/hive:3.1.3"
+	defaultHMSWarehouseDir  = "/opt/hive/data/warehouse"
+	defaultHMSCatalogName   = "hive"
+	// directory of the hive metastore pod that holds the warehouse and the derby database
+	hmsDataPath             = "/opt/hive/data"
+	hmsConfigPath           = "/opt/hive/conf"
+	hiveSiteKey             = "hive-site.xml"
 	// script called during shutdown. Picked from OneOneStar repo https://gist.github.com/oneonestar/ea75a608d58aa7e40cc952ad20e5a31a
 	// Have made it a string so that a separate file is not needed at the run time.
 	// the string has to be formatted to pass the mountpath of config.properties
@@ -22,12 +51,15 @@ set -x
 http_port="$(cat {MOUNT_PATH}/config.properties | grep 'http-server.http.port' | sed 's/^.*=\(.*\)$/\1/')"
 https_port="$(cat {MOUNT_PATH}/config.properties | grep 'http-server.https.port' | sed 's/^.*=\(.*\)$/\1/')"
 
-if [ -n "$http_port" ] ; then
-    res=$(curl -s -o /dev/null -w "%{http_code}"  -XPUT --data '"SHUTTING_DOWN"' -H "Content-type: application/json" http://localhost:${http_port}/v1/info/state)
+# the HTTP port is disabled with internal TLS
+if [ -n "$http_port" ] && ! grep -q 'http-server.http.enabled=false' {MOUNT_PATH}/config.properties; then
+    url=http://localhost:${http_port}
+    res=$(curl -s -o /dev/null -w "%{http_code}"  -XPUT --data '"SHUTTING_DOWN"' -H "Content-type: application/json" ${url}/v1/info/state)
 fi
 
 if [ -z "$res" -o "$res" != "200" ] && [ -n "$https_port" ]; then
-    res=$(curl -k -s -o /dev/null -w "%{http_code}"  -XPUT --data '"SHUTTING_DOWN"' -H "Content-type: application/json" https://localhost:${https_port}/v1/info/state)
+    url=https://localhost:${https_port}
+    res=$(curl -k -s -o /dev/null -w "%{http_code}"  -XPUT --data '"SHUTTING_DOWN"' -H "Content-type: application/json" ${url}/v1/info/state)
 fi
 
 if [ -z "$res" -o "$res" != "200" ] ; then
@@ -35,9 +67,25 @@ if [ -z "$res" -o "$res" != "200" ] ; then
   exit -1
 else
   # Server is shutting down. Block until the server is actually down.
-  while curl http://localhost:${http_port}/v1/info/state; do
+  while curl -k ${url}/v1/info/state; do
     sleep 3
   done
 fi
+`
+	// script run by the readiness probe. The server is ready once it has started and is ACTIVE.
+	// A worker that is SHUTTING_DOWN is not ready, so that it leaves the endpoints.
+	// The HTTPS port is used when the HTTP port is disabled by internal TLS. The string has
+	// to be formatted to pass the mountpath of config.properties
+	readinessScriptContent = `
+#!/bin/sh
+config={MOUNT_PATH}/config.properties
+url="http://localhost:$(grep 'http-server.http.port' $config | sed 's/^.*=\(.*\)$/\1/')"
+if grep -q 'http-server.http.enabled=false' $config; then
+    url="https://localhost:$(grep 'http-server.https.port' $config | sed 's/^.*=\(.*\)$/\1/')"
+fi
+info=$(curl -k -s -f --max-time 4 ${url}/v1/info) || exit 1
+echo "$info" | grep -q '"starting":false' || exit 1
+state=$(curl -k -s -f --max-time 4 ${url}/v1/info/state) || exit 1
+[ "$state" = '"ACTIVE"' ]
 `
 )
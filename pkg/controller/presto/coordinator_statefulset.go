@@ -0,0 +1,272 @@
+package presto
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	falaricav1alpha1 "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1"
+	v1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+/*
+  The coordinator can be managed through a StatefulSet instead of a bare ReplicaSet.
+  The StatefulSet is bound to the pod discovery service, so the coordinator pod gets a
+  stable DNS name without setting the hostname and the subdomain of the pod.
+  Optionally, a persistent volume is mounted at node.data-dir of the coordinator.
+  The StatefulSet replaces the coordinator pod itself when the pod template changes.
+  Volume claim templates of a StatefulSet cannot be changed, so the StatefulSet is
+  recreated when the data volume changes. The claims are retained by Kubernetes.
+  There can be only one coordinator. When the workload of a running cluster is changed,
+  the old workload is deleted before the new one is created.
+*/
+
+func isCoordinatorStatefulSetEnabled(presto *falaricav1alpha1.Presto) bool {
+	return presto.Spec.Coordinator.Workload == falaricav1alpha1.CoordinatorStatefulSetWorkload
+}
+
+func getCoordinatorStatefulSet(r *ReconcilePresto, presto *falaricav1alpha1.Presto) (*v1.StatefulSet, error) {
+	statefulSet := &v1.StatefulSet{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{
+		Namespace: presto.Namespace,
+		Name:      getCoordinatorStatefulSetName(presto.Status.Uuid),
+	}, statefulSet)
+	if err != nil {
+		return nil, err
+	}
+	return statefulSet, nil
+}
+
+func getCoordinatorVolumeClaimTemplates(presto *falaricav1alpha1.Presto) ([]corev1.PersistentVolumeClaim, error) {
+	dataVolume := presto.Spec.Coordinator.DataVolume
+	if dataVolume == nil {
+		return nil, nil
+	}
+	size, err := resource.ParseQuantity(dataVolume.Size)
+	if err != nil {
+		return nil, &OperatorError{fmt.Sprintf("cannot parse presto.Spec.Coordinator.DataVolume.Size: "+
+			"'%v': %v", dataVolume.Size, err)}
+	}
+	accessModes := dataVolume.AccessModes
+	if len(accessModes) == 0 {
+		accessModes = []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}
+	}
+	return []corev1.PersistentVolumeClaim{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: getCoordinatorDataVolName(presto.Status.Uuid),
+			},
+			Spec: corev1.PersistentVolumeClaimSpec{
+				AccessModes:      accessModes,
+				StorageClassName: dataVolume.StorageClassName,
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{
+						corev1.ResourceStorage: size,
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+// returns the hash of the volume claim templates. The templates get defaulted
+// by the API server, so the hash is compared instead of the templates themselves
+func getVolumeClaimHash(claims []corev1.PersistentVolumeClaim) (string, error) {
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(claimsJSON))[:16], nil
+}
+
+func createStatefulSetForCoordinator(r *ReconcilePresto, presto *falaricav1alpha1.Presto,
+	lbls map[string]string) (*v1.StatefulSet, error) {
+	// the pod template is same as that of the coordinator replicaset
+	replicaSet, err := createReplicaSetForCoordinator(r, presto, lbls)
+	if err != nil {
+		return nil, err
+	}
+	template := replicaSet.Spec.Template
+	// the owner of the pod is the statefulset
+	template.OwnerReferences = nil
+	claims, err := getCoordinatorVolumeClaimTemplates(presto)
+	if err != nil {
+		return nil, err
+	}
+	claimHash, err := getVolumeClaimHash(claims)
+	if err != nil {
+		return nil, err
+	}
+	return &v1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            getCoordinatorStatefulSetName(presto.Status.Uuid),
+			Namespace:       presto.Namespace,
+			OwnerReferences: []metav1.OwnerReference{*getOwnerReference(presto)},
+			Labels:          lbls,
+			Annotations: map[string]string{
+				volumeClaimHashAnnotation: claimHash,
+			},
+		},
+		Spec: v1.StatefulSetSpec{
+			Replicas: func() *int32 { i := int32(1); return &i }(),
+			Selector: &metav1.LabelSelector{
+				MatchLabels: lbls,
+			},
+			ServiceName:          getPodDiscoveryServiceName(presto.Status.Uuid),
+			Template:             template,
+			VolumeClaimTemplates: claims,
+			UpdateStrategy: v1.StatefulSetUpdateStrategy{
+				Type: v1.RollingUpdateStatefulSetStrategyType,
+			},
+		},
+	}, nil
+}
+
+// returns statefulSet, created, updated, error
+func createUpdateStatefulSetForCoordinator(r *ReconcilePresto, presto *falaricav1alpha1.Presto,
+	lbls map[string]string) (*v1.StatefulSet, bool, bool, error) {
+	created := false
+	updated := false
+	statefulSet, err := getCoordinatorStatefulSet(r, presto)
+	if errors.IsNotFound(err) {
+		statefulSet, err = createStatefulSetForCoordinator(r, presto, lbls)
+		if err != nil {
+			return nil, created, updated, err
+		}
+		err = r.client.Create(context.Background(), statefulSet)
+		if err != nil {
+			return nil, created, updated, err
+		}
+		created = true
+		return statefulSet, created, updated, nil
+	}
+	if err != nil {
+		return nil, created, updated, err
+	}
+	if statefulSet.DeletionTimestamp != nil {
+		// being recreated. Wait for it to go away.
+		return statefulSet, created, updated, nil
+	}
+	desired, err := createStatefulSetForCoordinator(r, presto, lbls)
+	if err != nil {
+		return nil, created, updated, err
+	}
+	if statefulSet.Annotations[volumeClaimHashAnnotation] != desired.Annotations[volumeClaimHashAnnotation] {
+		// volume claim templates cannot be updated. The statefulset is created again
+		// with the new templates on the next reconcile.
+		r.log.Info("data volume changed, recreating coordinator statefulset " + statefulSet.Name)
+		err = r.client.Delete(context.Background(), statefulSet)
+		if err != nil && !errors.IsNotFound(err) {
+			return nil, created, updated, err
+		}
+		updated = true
+		return statefulSet, created, updated, nil
+	}
+	desiredHash := desired.Spec.Template.Annotations[podSpecHashAnnotation]
+	if statefulSet.Spec.Template.Annotations[podSpecHashAnnotation] != desiredHash {
+		statefulSetCopy := statefulSet.DeepCopy()
+		if statefulSetCopy.Spec.Template.Annotations == nil {
+			statefulSetCopy.Spec.Template.Annotations = make(map[string]string)
+		}
+		statefulSetCopy.Spec.Template.Annotations[podSpecHashAnnotation] = desiredHash
+		statefulSetCopy.Spec.Template.Spec = desired.Spec.Template.Spec
+		err = r.client.Update(context.Background(), statefulSetCopy)
+		if err != nil {
+			return nil, created, updated, err
+		}
+		statefulSet = statefulSetCopy
+		updated = true
+	}
+	return statefulSet, created, updated, nil
+}
+
+// stamps the config and TLS hashes on the pod template so that the statefulset restarts
+// the coordinator. returns whether the statefulset was updated
+func updateStatefulSetConfigHash(r *ReconcilePresto, statefulSet *v1.StatefulSet,
+	configHash string, tlsHash string) (bool, error) {
+	statefulSetCopy := statefulSet.DeepCopy()
+	if !setTemplateHashes(&statefulSetCopy.Spec.Template, configHash, tlsHash) {
+		return false, nil
+	}
+	err := r.client.Update(context.Background(), statefulSetCopy)
+	if err != nil {
+		return false, err
+	}
+	statefulSetCopy.DeepCopyInto(statefulSet)
+	return true, nil
+}
+
+// returns whether the statefulset is running its pod with the current template
+func isStatefulSetRolledOut(statefulSet *v1.StatefulSet) bool {
+	replicas := *statefulSet.Spec.Replicas
+	status := statefulSet.Status
+	return status.ObservedGeneration >= statefulSet.Generation &&
+		status.UpdatedReplicas >= replicas &&
+		status.CurrentRevision == status.UpdateRevision &&
+		status.ReadyReplicas >= replicas
+}
+
+// A statefulset does not replace a pod that is not ready. If the coordinator pod of an
+// older revision is not ready, it is deleted so that it comes up with the current template.
+// returns the name of the deleted pod
+func restartStuckStatefulSetPod(r *ReconcilePresto, pods []corev1.Pod,
+	statefulSet *v1.StatefulSet) (string, error) {
+	for _, pod := range pods {
+		if pod.DeletionTimestamp != nil || isPodReady(&pod) ||
+			!metav1.IsControlledBy(&pod, statefulSet) ||
+			pod.Labels[v1.StatefulSetRevisionLabel] == statefulSet.Status.UpdateRevision {
+			continue
+		}
+		err := r.client.Delete(context.Background(), &pod)
+		if err != nil && !errors.IsNotFound(err) {
+			return "", err
+		}
+		return pod.Name, nil
+	}
+	return "", nil
+}
+
+// deletes the coordinator replicaset when the coordinator runs as a statefulset.
+// returns whether the replicaset was deleted
+func removeCoordinatorReplicaSet(r *ReconcilePresto, presto *falaricav1alpha1.Presto) (bool, error) {
+	replicaSet, err := getReplicaSet(r, presto, getCoordinatorPodLabel)
+	if errors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if replicaSet.DeletionTimestamp != nil {
+		return false, nil
+	}
+	err = r.client.Delete(context.Background(), replicaSet)
+	if err != nil && !errors.IsNotFound(err) {
+		return false, err
+	}
+	return true, nil
+}
+
+// deletes the coordinator statefulset when the coordinator runs as a replicaset.
+// returns whether the statefulset was deleted
+func removeCoordinatorStatefulSet(r *ReconcilePresto, presto *falaricav1alpha1.Presto) (bool, error) {
+	statefulSet, err := getCoordinatorStatefulSet(r, presto)
+	if errors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if statefulSet.DeletionTimestamp != nil {
+		return false, nil
+	}
+	err = r.client.Delete(context.Background(), statefulSet)
+	if err != nil && !errors.IsNotFound(err) {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,323 @@
+package presto
+
+import (
+	"context"
+	"fmt"
+	falaricav1alpha1 "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1"
+	"github.com/falarica/steerd-presto-operator/pkg/prestoclient"
+	v1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sort"
+	"strconv"
+	"time"
+)
+
+/*
+  When the operator lowers the number of workers, a ReplicaSet would delete any of its
+  pods and the queries running on them would fail unless the preStop hook manages to
+  drain them. Instead, the operator picks the workers to remove itself. Workers that are
+  not ready come first, then the workers running the fewest tasks as reported by /v1/task.
+  The picked workers are put into SHUTTING_DOWN through /v1/info/state and are detached
+  from their ReplicaSet by replacing the worker label with the decommissioning label.
+  The replicas are lowered after that, so the ReplicaSet has nothing left to delete.
+  The detached pods remain owned by the Presto object.
+  Every reconcile checks the decommissioning workers. A worker is deleted once it has no
+  active tasks, once the presto server has exited after draining, or once the termination
+  grace period of the workers has passed since the decommissioning started.
+  Kubernetes 1.16 has no pod deletion cost, so scale downs done by the HPA cannot be
+  intercepted and are still drained only by the preStop hook.
+*/
+
+const (
+	// the coordinator learns that a worker is shutting down on its next poll of the
+	// worker. A worker without tasks is deleted only after this period.
+	decommissionSettlePeriod = time.Minute
+	// grace period for deleting a worker that has not drained within its grace period
+	decommissionKillGracePeriodSeconds = 30
+	gracePeriodOverReason              = "the termination grace period is over"
+)
+
+// a worker that can be decommissioned
+type workerCandidate struct {
+	pod         corev1.Pod
+	ready       bool
+	activeTasks int
+}
+
+func countActiveTasks(tasks []prestoclient.TaskInfo) int {
+	count := 0
+	for _, task := range tasks {
+		if task.IsActive() {
+			count++
+		}
+	}
+	return count
+}
+
+func getContainerRestarts(pod *corev1.Pod) int32 {
+	var restarts int32
+	for _, status := range pod.Status.ContainerStatuses {
+		restarts += status.RestartCount
+	}
+	return restarts
+}
+
+func getWorkerGracePeriod(presto *falaricav1alpha1.Presto) time.Duration {
+	if presto.Spec.Worker.TerminationGracePeriodSeconds == nil {
+		return DefaultTerminationGracePeriodSeconds * time.Second
+	}
+	return time.Duration(*presto.Spec.Worker.TerminationGracePeriodSeconds) * time.Second
+}
+
+// returns the workers to be removed. The workers that are not ready or not reachable
+// are picked first, then the workers with fewer active tasks.
+func selectWorkersToRemove(candidates []workerCandidate, count int) []corev1.Pod {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].ready != candidates[j].ready {
+			return !candidates[i].ready
+		}
+		if candidates[i].activeTasks != candidates[j].activeTasks {
+			return candidates[i].activeTasks < candidates[j].activeTasks
+		}
+		return candidates[i].pod.Name < candidates[j].pod.Name
+	})
+	var pods []corev1.Pod
+	for i := 0; i < count && i < len(candidates); i++ {
+		pods = append(pods, candidates[i].pod)
+	}
+	return pods
+}
+
+// returns the running worker pods controlled by the replicaset or by the replicasets
+// of the deployment. The replicaset can be that of a worker pool.
+func getWorkerPodsOf(r *ReconcilePresto, presto *falaricav1alpha1.Presto,
+	workerReplicaSet *v1.ReplicaSet, workerDeployment *v1.Deployment) ([]corev1.Pod, error) {
+	controllers := make(map[types.UID]bool)
+	var podLabels map[string]string
+	if workerReplicaSet != nil {
+		controllers[workerReplicaSet.UID] = true
+		podLabels = workerReplicaSet.Spec.Selector.MatchLabels
+	}
+	if workerDeployment != nil {
+		podLabels = workerDeployment.Spec.Selector.MatchLabels
+		replicaSets := &v1.ReplicaSetList{}
+		k, v := getWorkerPodLabel(presto.Status.Uuid)
+		err := r.client.List(context.TODO(), replicaSets, &client.ListOptions{
+			Namespace:     presto.Namespace,
+			LabelSelector: labels.SelectorFromSet(labels.Set{k: v}),
+		})
+		if err != nil {
+			return nil, err
+		}
+		for i := range replicaSets.Items {
+			if metav1.IsControlledBy(&replicaSets.Items[i], workerDeployment) {
+				controllers[replicaSets.Items[i].UID] = true
+			}
+		}
+	}
+	pods := &corev1.PodList{}
+	err := r.client.List(context.TODO(), pods, &client.ListOptions{
+		Namespace:     presto.Namespace,
+		LabelSelector: labels.SelectorFromSet(podLabels),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var workerPods []corev1.Pod
+	for _, pod := range pods.Items {
+		controllerRef := metav1.GetControllerOf(&pod)
+		if pod.DeletionTimestamp != nil || controllerRef == nil || !controllers[controllerRef.UID] {
+			continue
+		}
+		workerPods = append(workerPods, pod)
+	}
+	return workerPods, nil
+}
+
+// detaches the pod from its replicaset so that it is not deleted by the replicaset.
+// The pod remains owned by the presto object so that it is garbage collected along with it.
+func detachWorkerPod(r *ReconcilePresto, presto *falaricav1alpha1.Presto, pod *corev1.Pod,
+	now time.Time) error {
+	podCopy := pod.DeepCopy()
+	wk, _ := getWorkerPodLabel(presto.Status.Uuid)
+	delete(podCopy.Labels, wk)
+	pk, _ := getWorkerPoolPodLabel(presto.Status.Uuid)
+	delete(podCopy.Labels, pk)
+	dk, dv := getDecommissioningPodLabel(presto.Status.Uuid)
+	podCopy.Labels[dk] = dv
+	if podCopy.Annotations == nil {
+		podCopy.Annotations = make(map[string]string)
+	}
+	podCopy.Annotations[decommissionStartAnnotation] = now.UTC().Format(time.RFC3339)
+	podCopy.Annotations[decommissionRestartsAnnotation] = strconv.Itoa(int(getContainerRestarts(pod)))
+	ownerReference := getOwnerReference(presto)
+	notController := false
+	ownerReference.Controller = &notController
+	podCopy.OwnerReferences = []metav1.OwnerReference{*ownerReference}
+	return r.client.Update(context.Background(), podCopy)
+}
+
+// puts the given number of workers into SHUTTING_DOWN and detaches them from their
+// replicaset. It is called before the replicas are lowered. returns the number by which
+// the replicas can be lowered. A worker that fails to be detached is not counted, so that
+// the replicaset does not delete a busy worker of its choice in its place. It is retried
+// on the next reconcile. The replicas that do not have a pod yet are counted.
+func (r *ReconcilePresto) decommissionWorkers(presto *falaricav1alpha1.Presto,
+	workerReplicaSet *v1.ReplicaSet, workerDeployment *v1.Deployment, count int32) (int32, error) {
+	pods, err := getWorkerPodsOf(r, presto, workerReplicaSet, workerDeployment)
+	if err != nil {
+		return 0, err
+	}
+	ctx := context.Background()
+	var candidates []workerCandidate
+	for _, pod := range pods {
+		candidate := workerCandidate{pod: pod, ready: isPodReady(&pod)}
+		if candidate.ready {
+			tasks, err := r.workerClient(presto, &pod).Tasks(ctx)
+			if err != nil {
+				r.log.Info(fmt.Sprintf("PrestoCluster %s: cannot get the tasks of worker %s: %s",
+					presto.Name, pod.Name, err.Error()))
+				candidate.ready = false
+			} else {
+				candidate.activeTasks = countActiveTasks(tasks)
+			}
+		}
+		candidates = append(candidates, candidate)
+	}
+	now := r.clock.Now()
+	removable := count
+	for _, pod := range selectWorkersToRemove(candidates, int(count)) {
+		if isPodReady(&pod) {
+			// retried on the later reconciles if the worker cannot be reached now
+			err := r.workerClient(presto, &pod).SetState(ctx, prestoclient.NodeShuttingDown)
+			if err != nil {
+				r.log.Info(fmt.Sprintf("PrestoCluster %s: cannot shut down worker %s: %s",
+					presto.Name, pod.Name, err.Error()))
+			}
+		}
+		err := detachWorkerPod(r, presto, &pod, now)
+		if err != nil {
+			r.log.Error(err, "failed to detach worker pod "+pod.Name)
+			r.eventRecorder.Eventf(presto, corev1.EventTypeWarning, "Failed",
+				"Failed to decommission worker %s %s", pod.Name, err.Error())
+			removable--
+			continue
+		}
+		r.eventRecorder.Eventf(presto, corev1.EventTypeNormal, "Decommissioning",
+			"Decommissioning worker %s. It is deleted once its tasks have finished", pod.Name)
+		r.log.Info(fmt.Sprintf("PrestoCluster %s: decommissioning worker %s", presto.Name, pod.Name))
+	}
+	return removable, nil
+}
+
+// returns the reason for deleting the decommissioning worker or an empty string if the
+// worker is still draining. The worker is put into SHUTTING_DOWN again if it is active.
+func (r *ReconcilePresto) getDecommissionedReason(presto *falaricav1alpha1.Presto,
+	pod *corev1.Pod, now time.Time) string {
+	started, err := time.Parse(time.RFC3339, pod.Annotations[decommissionStartAnnotation])
+	if err != nil {
+		return "its decommissioning start time is not known"
+	}
+	if now.Sub(started) >= getWorkerGracePeriod(presto) {
+		return gracePeriodOverReason
+	}
+	restarts, err := strconv.Atoi(pod.Annotations[decommissionRestartsAnnotation])
+	if err != nil || int(getContainerRestarts(pod)) > restarts ||
+		pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		// presto exits once it has drained
+		return "it has shut down"
+	}
+	ctx := context.Background()
+	workerClient := r.workerClient(presto, pod)
+	state, err := workerClient.State(ctx)
+	if err != nil {
+		if !isPodReady(pod) {
+			return "it has shut down"
+		}
+		r.log.Info(fmt.Sprintf("PrestoCluster %s: cannot get the state of worker %s: %s",
+			presto.Name, pod.Name, err.Error()))
+		return ""
+	}
+	if state == prestoclient.NodeActive {
+		err = workerClient.SetState(ctx, prestoclient.NodeShuttingDown)
+		if err != nil {
+			r.log.Info(fmt.Sprintf("PrestoCluster %s: cannot shut down worker %s: %s",
+				presto.Name, pod.Name, err.Error()))
+		}
+		return ""
+	}
+	tasks, err := workerClient.Tasks(ctx)
+	if err != nil {
+		r.log.Info(fmt.Sprintf("PrestoCluster %s: cannot get the tasks of worker %s: %s",
+			presto.Name, pod.Name, err.Error()))
+		return ""
+	}
+	if countActiveTasks(tasks) == 0 && now.Sub(started) >= decommissionSettlePeriod {
+		return "it has no active tasks"
+	}
+	return ""
+}
+
+// deletes the decommissioning workers that have drained and records the workers
+// that are still draining in the status
+func (r *ReconcilePresto) removeDecommissionedWorkers(presto *falaricav1alpha1.Presto,
+	ctx context.Context) error {
+	pods, err := getPrestoPods(r, presto, getDecommissioningPodLabel)
+	if err != nil {
+		r.log.Error(err, "failed to list the decommissioning workers")
+		return err
+	}
+	now := r.clock.Now()
+	draining := []string{}
+	for i := range pods {
+		pod := &pods[i]
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+		reason := r.getDecommissionedReason(presto, pod, now)
+		if len(reason) == 0 {
+			draining = append(draining, pod.Name)
+			continue
+		}
+		var deleteOptions []client.DeleteOption
+		if reason == gracePeriodOverReason {
+			// presto is not given another grace period by the preStop hook
+			deleteOptions = append(deleteOptions, client.GracePeriodSeconds(decommissionKillGracePeriodSeconds))
+		}
+		err = r.client.Delete(context.Background(), pod, deleteOptions...)
+		if err != nil && !errors.IsNotFound(err) {
+			r.log.Error(err, "failed to delete decommissioned worker "+pod.Name)
+			r.eventRecorder.Eventf(presto, corev1.EventTypeWarning, "Failed",
+				"Failed to delete decommissioned worker %s %s", pod.Name, err.Error())
+			draining = append(draining, pod.Name)
+			continue
+		}
+		r.eventRecorder.Eventf(presto, corev1.EventTypeNormal, "Decommissioned",
+			"Deleted worker %s as %s", pod.Name, reason)
+		r.log.Info(fmt.Sprintf("PrestoCluster %s: deleted decommissioned worker %s as %s",
+			presto.Name, pod.Name, reason))
+	}
+	if !equalStrings(draining, presto.Status.DecommissioningWorkers) {
+		r.updateStatus(presto, ctx, ClusterUpdateAction{
+			decommissioningWorkers: &draining,
+		})
+	}
+	return nil
+}
+
+func equalStrings(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,410 @@
+package presto
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	falaricav1alpha1 "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1"
+	"github.com/falarica/steerd-presto-operator/pkg/prestoclient"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// state of a fake worker
+type fakeWorker struct {
+	state       string
+	activeTasks int
+	unreachable bool
+}
+
+// returns a fake server for the given workers, told apart by the name of their pod which
+// prefixes the path. The requests that change the state are recorded.
+func newFakeWorkerStates(workers map[string]*fakeWorker, requests *[]string) *httptest.Server {
+	var mutex sync.Mutex
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		parts := strings.SplitN(strings.TrimPrefix(req.URL.Path, "/"), "/", 2)
+		worker, ok := workers[parts[0]]
+		if !ok || worker.unreachable {
+			http.Error(w, "unreachable", http.StatusServiceUnavailable)
+			return
+		}
+		switch {
+		case parts[1] == "v1/info/state" && req.Method == http.MethodPut:
+			body, _ := ioutil.ReadAll(req.Body)
+			*requests = append(*requests, parts[0]+" "+string(body))
+			json.Unmarshal(body, &worker.state)
+		case parts[1] == "v1/info/state":
+			json.NewEncoder(w).Encode(worker.state)
+		case parts[1] == "v1/task":
+			var tasks []prestoclient.TaskInfo
+			for i := 0; i < worker.activeTasks; i++ {
+				tasks = append(tasks, prestoclient.TaskInfo{TaskStatus: prestoclient.TaskStatus{
+					TaskID: fmt.Sprintf("query.%d.0", i), State: "RUNNING"}})
+			}
+			tasks = append(tasks, prestoclient.TaskInfo{TaskStatus: prestoclient.TaskStatus{
+				TaskID: "finished.0.0", State: "FINISHED"}})
+			json.NewEncoder(w).Encode(tasks)
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+}
+
+// returns a worker pod controlled by the worker replicaset of the hibernation tests
+func newTestWorkerPod(name string, ready bool) *corev1.Pod {
+	pod := newTestPod(name, getWorkerPodLabel, "node-1", ready)
+	controller := true
+	pod.OwnerReferences = []metav1.OwnerReference{{
+		APIVersion: "apps/v1",
+		Kind:       "ReplicaSet",
+		Name:       "worker-" + testClusterUUID[:8],
+		UID:        "worker-replicaset-uid",
+		Controller: &controller,
+	}}
+	return pod
+}
+
+// a client that fails to update the given pod
+type podUpdateFailingClient struct {
+	client.Client
+	podName string
+}
+
+func (c podUpdateFailingClient) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	if pod, ok := obj.(*corev1.Pod); ok && pod.Name == c.podName {
+		return fmt.Errorf("pod %s cannot be updated", pod.Name)
+	}
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func isTestPodDetached(t *testing.T, r *ReconcilePresto, name string) bool {
+	pod := &corev1.Pod{}
+	err := r.client.Get(context.Background(), types.NamespacedName{Namespace: testNamespace, Name: name}, pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dk, dv := getDecommissioningPodLabel(testClusterUUID)
+	wk, _ := getWorkerPodLabel(testClusterUUID)
+	_, worker := pod.Labels[wk]
+	controller := metav1.GetControllerOf(pod)
+	if pod.Labels[dk] != dv {
+		if !worker || controller == nil {
+			t.Errorf("pod %s is neither a worker nor decommissioning %v", name, pod.Labels)
+		}
+		return false
+	}
+	if worker || controller != nil || len(pod.OwnerReferences) != 1 ||
+		pod.OwnerReferences[0].UID != "presto-uid" || len(pod.Annotations[decommissionStartAnnotation]) == 0 {
+		t.Errorf("pod %s is not detached properly %v %v %v", name, pod.Labels, pod.Annotations, pod.OwnerReferences)
+	}
+	return true
+}
+
+func TestSelectWorkersToRemove(t *testing.T) {
+	candidate := func(name string, ready bool, activeTasks int) workerCandidate {
+		return workerCandidate{pod: corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name}},
+			ready: ready, activeTasks: activeTasks}
+	}
+	candidates := []workerCandidate{
+		candidate("worker-a", true, 5),
+		candidate("worker-b", true, 1),
+		candidate("worker-c", false, 0),
+		candidate("worker-d", true, 1),
+		candidate("worker-e", true, 0),
+		candidate("worker-f", false, 0),
+	}
+	var names []string
+	for _, pod := range selectWorkersToRemove(candidates, 5) {
+		names = append(names, pod.Name)
+	}
+	// not ready first, then the fewest active tasks, then the name
+	expected := []string{"worker-c", "worker-f", "worker-e", "worker-b", "worker-d"}
+	if strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Errorf("selected %v, expected %v", names, expected)
+	}
+	if pods := selectWorkersToRemove(candidates[:2], 3); len(pods) != 2 {
+		t.Errorf("selected %d workers out of 2", len(pods))
+	}
+}
+
+func TestDecommissionWorkers(t *testing.T) {
+	var requests []string
+	workers := map[string]*fakeWorker{
+		"worker-0": {state: prestoclient.NodeActive, activeTasks: 3},
+		"worker-1": {state: prestoclient.NodeActive, activeTasks: 1},
+		"worker-2": {state: prestoclient.NodeActive, activeTasks: 2},
+		"worker-3": {state: prestoclient.NodeActive, unreachable: true},
+		"worker-4": {state: prestoclient.NodeActive},
+	}
+	server := newFakeWorkerStates(workers, &requests)
+	defer server.Close()
+
+	presto := newTestPresto()
+	replicaSet := newTestWorkerReplicaSet(presto, 5)
+	objs := []runtime.Object{presto, replicaSet,
+		newTestWorkerPod("worker-0", true),
+		newTestWorkerPod("worker-1", true),
+		newTestWorkerPod("worker-2", true),
+		newTestWorkerPod("worker-3", true),
+		newTestWorkerPod("worker-4", false),
+	}
+	r := newTestReconciler(t, objs...)
+	r.workerClient = func(presto *falaricav1alpha1.Presto, pod *corev1.Pod) *prestoclient.Client {
+		return prestoclient.New(server.URL+"/"+pod.Name, nil)
+	}
+
+	removable, err := r.decommissionWorkers(presto, replicaSet, nil, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removable != 3 {
+		t.Errorf("%d workers removable, expected 3", removable)
+	}
+	// the worker that is not ready, the worker whose tasks cannot be read, then the worker
+	// with the fewest tasks
+	for name, detached := range map[string]bool{
+		"worker-0": false, "worker-1": true, "worker-2": false, "worker-3": true, "worker-4": true,
+	} {
+		if isTestPodDetached(t, r, name) != detached {
+			t.Errorf("pod %s detached: %v, expected %v", name, !detached, detached)
+		}
+	}
+	// only the ready workers are put into SHUTTING_DOWN. worker-3 cannot be reached
+	sort.Strings(requests)
+	if len(requests) != 1 || requests[0] != `worker-1 "SHUTTING_DOWN"` {
+		t.Errorf("unexpected requests %v", requests)
+	}
+	if workers["worker-1"].state != prestoclient.NodeShuttingDown {
+		t.Errorf("worker-1 is %s", workers["worker-1"].state)
+	}
+}
+
+func TestScaleWorkersLowersReplicasByDetachedWorkers(t *testing.T) {
+	var requests []string
+	workers := map[string]*fakeWorker{
+		"worker-0": {state: prestoclient.NodeActive, activeTasks: 3},
+		"worker-1": {state: prestoclient.NodeActive, activeTasks: 1},
+		"worker-2": {state: prestoclient.NodeActive, activeTasks: 2},
+	}
+	server := newFakeWorkerStates(workers, &requests)
+	defer server.Close()
+
+	presto := newTestPresto()
+	// a replica without a pod yet does not need to be detached
+	replicaSet := newTestWorkerReplicaSet(presto, 4)
+	objs := []runtime.Object{presto, replicaSet,
+		newTestWorkerPod("worker-0", true),
+		newTestWorkerPod("worker-1", true),
+		newTestWorkerPod("worker-2", true),
+	}
+	r := newTestReconciler(t, objs...)
+	r.workerClient = func(presto *falaricav1alpha1.Presto, pod *corev1.Pod) *prestoclient.Client {
+		return prestoclient.New(server.URL+"/"+pod.Name, nil)
+	}
+
+	// worker-1 and worker-2 have the fewest tasks. worker-1 cannot be detached, so the
+	// replicas are lowered only by one and the replicaset does not delete a worker of its choice
+	r.client = podUpdateFailingClient{Client: r.client, podName: "worker-1"}
+	err := scaleWorkers(r, presto, getTestWorkerReplicaSet(t, r), nil, 4, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replicas := getTestWorkerReplicas(t, r); replicas != 3 {
+		t.Errorf("the replicas were lowered to %d, expected 3", replicas)
+	}
+	if isTestPodDetached(t, r, "worker-1") || !isTestPodDetached(t, r, "worker-2") || isTestPodDetached(t, r, "worker-0") {
+		t.Error("unexpected workers detached")
+	}
+	if !hasEvent(getTestEvents(r), "Warning Failed Failed to decommission worker worker-1") {
+		t.Error("no event for the worker that failed to be detached")
+	}
+
+	// without failures the replicas are lowered by the number of workers removed
+	r.client = r.client.(podUpdateFailingClient).Client
+	err = scaleWorkers(r, presto, getTestWorkerReplicaSet(t, r), nil, 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replicas := getTestWorkerReplicas(t, r); replicas != 2 {
+		t.Errorf("the replicas were lowered to %d, expected 2", replicas)
+	}
+	if !isTestPodDetached(t, r, "worker-1") {
+		t.Error("worker-1 was not detached on the retry")
+	}
+}
+
+func TestGetDecommissionedReason(t *testing.T) {
+	now := mustParseTime(t, "2026-10-19T09:00:00Z")
+	tests := []struct {
+		name        string
+		started     time.Duration
+		noStart     bool
+		restarts    int32
+		phase       corev1.PodPhase
+		ready       bool
+		worker      fakeWorker
+		reason      string
+		shutdownSet bool
+	}{
+		{
+			name:    "start time not known",
+			noStart: true,
+			ready:   true,
+			worker:  fakeWorker{state: prestoclient.NodeShuttingDown, activeTasks: 1},
+			reason:  "its decommissioning start time is not known",
+		},
+		{
+			name:    "grace period over",
+			started: 11 * time.Minute,
+			ready:   true,
+			worker:  fakeWorker{state: prestoclient.NodeShuttingDown, activeTasks: 1},
+			reason:  gracePeriodOverReason,
+		},
+		{
+			name:     "restarted",
+			started:  2 * time.Minute,
+			restarts: 1,
+			ready:    true,
+			worker:   fakeWorker{state: prestoclient.NodeActive},
+			reason:   "it has shut down",
+		},
+		{
+			name:    "exited",
+			started: 2 * time.Minute,
+			phase:   corev1.PodSucceeded,
+			worker:  fakeWorker{unreachable: true},
+			reason:  "it has shut down",
+		},
+		{
+			name:    "not ready and unreachable",
+			started: 2 * time.Minute,
+			worker:  fakeWorker{unreachable: true},
+			reason:  "it has shut down",
+		},
+		{
+			name:    "ready and unreachable",
+			started: 2 * time.Minute,
+			ready:   true,
+			worker:  fakeWorker{unreachable: true},
+		},
+		{
+			name:        "active again",
+			started:     2 * time.Minute,
+			ready:       true,
+			worker:      fakeWorker{state: prestoclient.NodeActive},
+			shutdownSet: true,
+		},
+		{
+			name:    "active tasks",
+			started: 2 * time.Minute,
+			ready:   true,
+			worker:  fakeWorker{state: prestoclient.NodeShuttingDown, activeTasks: 2},
+		},
+		{
+			name:    "no active tasks within the settle period",
+			started: 30 * time.Second,
+			ready:   true,
+			worker:  fakeWorker{state: prestoclient.NodeShuttingDown},
+		},
+		{
+			name:    "no active tasks after the settle period",
+			started: decommissionSettlePeriod,
+			ready:   true,
+			worker:  fakeWorker{state: prestoclient.NodeShuttingDown},
+			reason:  "it has no active tasks",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var requests []string
+			worker := test.worker
+			server := newFakeWorkerStates(map[string]*fakeWorker{"worker-0": &worker}, &requests)
+			defer server.Close()
+
+			presto := newTestPresto()
+			gracePeriod := int64(600)
+			presto.Spec.Worker.TerminationGracePeriodSeconds = &gracePeriod
+			pod := newTestPod("worker-0", getDecommissioningPodLabel, "node-1", test.ready)
+			pod.Annotations = map[string]string{decommissionRestartsAnnotation: "0"}
+			if !test.noStart {
+				pod.Annotations[decommissionStartAnnotation] = now.Add(-test.started).Format(time.RFC3339)
+			}
+			pod.Status.ContainerStatuses = []corev1.ContainerStatus{{Name: "presto", RestartCount: test.restarts}}
+			if len(test.phase) != 0 {
+				pod.Status.Phase = test.phase
+			}
+			r := newTestReconciler(t, presto)
+			r.workerClient = func(presto *falaricav1alpha1.Presto, pod *corev1.Pod) *prestoclient.Client {
+				return prestoclient.New(server.URL+"/"+pod.Name, nil)
+			}
+
+			if reason := r.getDecommissionedReason(presto, pod, now); reason != test.reason {
+				t.Errorf("reason %q, expected %q", reason, test.reason)
+			}
+			if test.shutdownSet != (len(requests) == 1) {
+				t.Errorf("unexpected requests %v", requests)
+			}
+		})
+	}
+}
+
+func TestRemoveDecommissionedWorkers(t *testing.T) {
+	var requests []string
+	workers := map[string]*fakeWorker{
+		"worker-0": {state: prestoclient.NodeShuttingDown},
+		"worker-1": {state: prestoclient.NodeShuttingDown, activeTasks: 1},
+	}
+	server := newFakeWorkerStates(workers, &requests)
+	defer server.Close()
+
+	now := mustParseTime(t, "2026-10-19T09:00:00Z")
+	presto := newTestPresto()
+	objs := []runtime.Object{presto}
+	for name := range workers {
+		pod := newTestPod(name, getDecommissioningPodLabel, "node-1", true)
+		pod.Annotations = map[string]string{
+			decommissionStartAnnotation:    now.Add(-2 * time.Minute).Format(time.RFC3339),
+			decommissionRestartsAnnotation: strconv.Itoa(0),
+		}
+		objs = append(objs, pod)
+	}
+	r := newTestReconciler(t, objs...)
+	r.clock = clock.NewFakeClock(now)
+	r.workerClient = func(presto *falaricav1alpha1.Presto, pod *corev1.Pod) *prestoclient.Client {
+		return prestoclient.New(server.URL+"/"+pod.Name, nil)
+	}
+
+	if err := r.removeDecommissionedWorkers(presto, context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	pods, err := getPrestoPods(r, presto, getDecommissioningPodLabel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pods) != 1 || pods[0].Name != "worker-1" {
+		t.Errorf("unexpected decommissioning workers %v", pods)
+	}
+	draining := getTestPresto(t, r).Status.DecommissioningWorkers
+	if len(draining) != 1 || draining[0] != "worker-1" {
+		t.Errorf("unexpected draining workers in the status %v", draining)
+	}
+	if !hasEvent(getTestEvents(r), "Normal Decommissioned Deleted worker worker-0 as it has no active tasks") {
+		t.Error("no event for the deleted worker")
+	}
+}
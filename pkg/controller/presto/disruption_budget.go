@@ -0,0 +1,198 @@
+package presto
+
+import (
+	"context"
+	"fmt"
+	falaricav1alpha1 "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+/*
+  A node drain evicts the pods of the node and, without a PodDisruptionBudget, can evict
+  any number of workers at once or the only coordinator. The operator creates a
+  PodDisruptionBudget for the coordinator, for the workers of spec.worker and for the
+  workers of each worker pool, selecting the pods on the coordinator, worker or worker
+  pool label.
+  The coordinator defaults to minAvailable 1, so a drain waits for the coordinator pod to
+  be deleted by hand. The workers default to maxUnavailable 1. A number in minAvailable is
+  capped at the minimum number of pods, i.e. the count, or the minReplicas when autoscaling
+  is enabled, so that a scale down does not leave a budget that can never be met. The
+  budgets are updated on every reconcile, so they follow the count, the scaling schedules
+  and the autoscaling bounds. The decommissioning workers no longer have the worker label
+  and are not counted.
+*/
+
+func isDisruptionBudgetEnabled(budget *falaricav1alpha1.DisruptionBudgetSpec) bool {
+	return budget == nil || budget.Enabled == nil || *budget.Enabled
+}
+
+func validateDisruptionBudget(budget *falaricav1alpha1.DisruptionBudgetSpec, role string) error {
+	if budget != nil && budget.MinAvailable != nil && budget.MaxUnavailable != nil {
+		return &OperatorError{fmt.Sprintf("only one of minAvailable and maxUnavailable can be "+
+			"specified in the disruption budget of the %s", role)}
+	}
+	return nil
+}
+
+// returns the minimum number of workers as per the count or the autoscaling bounds
+func getMinWorkers(presto *falaricav1alpha1.Presto) int32 {
+	if checkAutoscalingEnabled(presto) && presto.Spec.Worker.Autoscaling.MinReplicas != nil {
+		return *presto.Spec.Worker.Autoscaling.MinReplicas
+	}
+	if presto.Spec.Worker.Count == nil {
+		return 0
+	}
+	return *presto.Spec.Worker.Count
+}
+
+func buildPodDisruptionBudget(presto *falaricav1alpha1.Presto, name string,
+	budget *falaricav1alpha1.DisruptionBudgetSpec, defaultBudget falaricav1alpha1.DisruptionBudgetSpec,
+	minPods int32, selector map[string]string, lbls map[string]string) *policyv1beta1.PodDisruptionBudget {
+	if budget == nil || (budget.MinAvailable == nil && budget.MaxUnavailable == nil) {
+		budget = &defaultBudget
+	}
+	spec := policyv1beta1.PodDisruptionBudgetSpec{
+		Selector: &metav1.LabelSelector{MatchLabels: selector},
+	}
+	if budget.MaxUnavailable != nil {
+		maxUnavailable := *budget.MaxUnavailable
+		spec.MaxUnavailable = &maxUnavailable
+	} else {
+		minAvailable := *budget.MinAvailable
+		if minAvailable.Type == intstr.Int && minAvailable.IntVal > minPods {
+			minAvailable = intstr.FromInt(int(minPods))
+		}
+		spec.MinAvailable = &minAvailable
+	}
+	return &policyv1beta1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       presto.Namespace,
+			Labels:          lbls,
+			OwnerReferences: []metav1.OwnerReference{*getOwnerReference(presto)},
+		},
+		Spec: spec,
+	}
+}
+
+// creates, updates or deletes the PodDisruptionBudget as per the spec.
+// returns the action taken or an empty string, error
+func syncPodDisruptionBudget(r *ReconcilePresto, presto *falaricav1alpha1.Presto,
+	desired *policyv1beta1.PodDisruptionBudget, enabled bool) (string, error) {
+	ctx := context.Background()
+	existing := &policyv1beta1.PodDisruptionBudget{}
+	err := r.client.Get(ctx, types.NamespacedName{Namespace: desired.Namespace, Name: desired.Name}, existing)
+	if err != nil && !errors.IsNotFound(err) {
+		return "", err
+	}
+	exists := err == nil
+	if !enabled {
+		if !exists {
+			return "", nil
+		}
+		err = r.client.Delete(ctx, existing)
+		if err != nil && !errors.IsNotFound(err) {
+			return "", err
+		}
+		return "Deleted", nil
+	}
+	if !exists {
+		return "Created", r.client.Create(ctx, desired)
+	}
+	if equality.Semantic.DeepEqual(existing.Spec, desired.Spec) {
+		return "", nil
+	}
+	existingCopy := existing.DeepCopy()
+	existingCopy.Spec = desired.Spec
+	return "Updated", r.client.Update(ctx, existingCopy)
+}
+
+func getCoordinatorPDB(presto *falaricav1alpha1.Presto, baseLabels map[string]string) *policyv1beta1.PodDisruptionBudget {
+	k, v := getCoordinatorPodLabel(presto.Status.Uuid)
+	one := intstr.FromInt(1)
+	return buildPodDisruptionBudget(presto, getCoordinatorPDBName(presto.Status.Uuid),
+		presto.Spec.Coordinator.DisruptionBudget, falaricav1alpha1.DisruptionBudgetSpec{MinAvailable: &one},
+		1, map[string]string{k: v}, getCoordinatorPodLabels(baseLabels, presto.Status.Uuid))
+}
+
+// returns the budget of the workers of spec.worker, or of a worker pool
+func getWorkerPDB(presto *falaricav1alpha1.Presto, name string, selector map[string]string,
+	lbls map[string]string) *policyv1beta1.PodDisruptionBudget {
+	one := intstr.FromInt(1)
+	return buildPodDisruptionBudget(presto, name, presto.Spec.Worker.DisruptionBudget,
+		falaricav1alpha1.DisruptionBudgetSpec{MaxUnavailable: &one}, getMinWorkers(presto), selector, lbls)
+}
+
+// creates or updates the PodDisruptionBudgets of the coordinator and the workers.
+// The budgets of the worker pools are handled by the worker pool step.
+// returns error, changesMade
+func (r *ReconcilePresto) disruptionBudgets(presto *falaricav1alpha1.Presto,
+	baseLabels map[string]string,
+	ctx context.Context) (error, bool) {
+	err := validateDisruptionBudget(presto.Spec.Coordinator.DisruptionBudget, "coordinator")
+	if err == nil {
+		err = validateDisruptionBudget(presto.Spec.Worker.DisruptionBudget, "workers")
+	}
+	if err != nil {
+		errorReason := err.Error()
+		r.updateStatus(presto, ctx, ClusterUpdateAction{
+			errorReason:  &errorReason,
+			clusterState: falaricav1alpha1.ClusterFailedState,
+			conditions:   failureConditions(presto, "InvalidDisruptionBudget", errorReason),
+		})
+		r.eventRecorder.Eventf(presto, corev1.EventTypeWarning, "Failed",
+			"Failed to create the disruption budgets %s", errorReason)
+		return err, false
+	}
+
+	k, v := getWorkerPodLabel(presto.Status.Uuid)
+	budgets := []struct {
+		pdb     *policyv1beta1.PodDisruptionBudget
+		enabled bool
+		role    string
+	}{
+		{getCoordinatorPDB(presto, baseLabels),
+			isDisruptionBudgetEnabled(presto.Spec.Coordinator.DisruptionBudget), "coordinator"},
+		{getWorkerPDB(presto, getWorkerPDBName(presto.Status.Uuid), map[string]string{k: v},
+			getWorkerPodLabels(baseLabels, presto.Status.Uuid)),
+			isDisruptionBudgetEnabled(presto.Spec.Worker.DisruptionBudget), "workers"},
+	}
+	changesMade := false
+	pdbNames := make([]string, len(budgets))
+	for i, budget := range budgets {
+		action, err := syncPodDisruptionBudget(r, presto, budget.pdb, budget.enabled)
+		if err != nil {
+			r.log.Error(err, "failed to create the disruption budget of the "+budget.role)
+			errorReason := fmt.Sprintf("Failed to create the disruption budget of the %s %s",
+				budget.role, err.Error())
+			r.updateStatus(presto, ctx, ClusterUpdateAction{
+				errorReason:  &errorReason,
+				clusterState: falaricav1alpha1.ClusterFailedState,
+				conditions:   failureConditions(presto, "DisruptionBudgetFailed", errorReason),
+			})
+			r.eventRecorder.Eventf(presto, corev1.EventTypeWarning, "Failed", "%s", errorReason)
+			return err, false
+		}
+		if len(action) != 0 {
+			r.eventRecorder.Eventf(presto, corev1.EventTypeNormal, action,
+				"%s PodDisruptionBudget of the %s. %s", action, budget.role, budget.pdb.Name)
+			changesMade = true
+		}
+		if budget.enabled {
+			pdbNames[i] = budget.pdb.Name
+		}
+	}
+	if pdbNames[0] != presto.Status.CoordinatorPDB || pdbNames[1] != presto.Status.WorkerPDB {
+		r.updateStatus(presto, ctx, ClusterUpdateAction{
+			coordinatorPDB: &pdbNames[0],
+			workerPDB:      &pdbNames[1],
+		})
+	}
+	return nil, changesMade
+}
@@ -0,0 +1,344 @@
+package presto
+
+import (
+	"context"
+	"fmt"
+	falaricav1alpha1 "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"time"
+)
+
+/*
+  A cluster is hibernated when spec.suspend is set, or by the idle policy when no query
+  has been running or queued for the configured number of minutes. The activity is sampled
+  from the coordinator on each reconcile and recorded in the status at most once a minute.
+  Hibernation scales the coordinator and the worker workloads to zero and deletes the HPA.
+  The hive metastore deployed by the operator is scaled to zero after the presto pods are gone.
+  The config maps, the services and the UUID of the cluster are retained. The number of
+  workers at that time is recorded in the status, and the remaining steps of Reconcile are
+  skipped while the cluster is hibernated. The worker pools are scaled to zero as well
+  and are scaled back by the worker pool step to their count or minimum replicas.
+  On resume the metastore, the coordinator and the workers are scaled back, and the HPA
+  is created again from the spec by the later steps. A suspended cluster is resumed by
+  clearing spec.suspend. An idle cluster is resumed by the falarica.io/resume annotation, which the
+  operator removes.
+*/
+
+const (
+	// annotation on the presto object that resumes a cluster hibernated by the idle policy
+	resumeAnnotation = "falarica.io/resume"
+	// the last activity time is written to the status at most once in this period
+	activityRecordPeriod = time.Minute
+)
+
+type hibernationStatus struct {
+	reason       falaricav1alpha1.HibernationReason
+	hibernatedAt *metav1.Time
+	workers      int32
+}
+
+func isSuspended(presto *falaricav1alpha1.Presto) bool {
+	return presto.Spec.Suspend != nil && *presto.Spec.Suspend
+}
+
+// a cluster is hibernated from the time its hibernation is recorded in the status till it
+// is resumed. The cluster state is not used as a failed step may have changed it.
+func isHibernated(presto *falaricav1alpha1.Presto) bool {
+	return presto.Status.HibernatedAt != nil || len(presto.Status.HibernationReason) != 0
+}
+
+// returns the state of a cluster whose resources have changed. A hibernated cluster
+// remains hibernated till it is resumed.
+func getPendingState(presto *falaricav1alpha1.Presto) falaricav1alpha1.ClusterState {
+	if isHibernated(presto) {
+		return ""
+	}
+	return falaricav1alpha1.ClusterPending
+}
+
+// returns the number of workers of the workload that manages the workers
+func getCurrentWorkerCount(r *ReconcilePresto, presto *falaricav1alpha1.Presto) (int32, error) {
+	if isWorkerDeploymentEnabled(presto) {
+		deployment, err := getWorkerDeployment(r, presto)
+		if err == nil {
+			return *deployment.Spec.Replicas, nil
+		}
+		if !errors.IsNotFound(err) {
+			return 0, err
+		}
+	} else {
+		replicaSet, err := getReplicaSet(r, presto, getWorkerPodLabel)
+		if err == nil {
+			return *replicaSet.Spec.Replicas, nil
+		}
+		if !errors.IsNotFound(err) {
+			return 0, err
+		}
+	}
+	return *presto.Spec.Worker.Count, nil
+}
+
+// sets the replicas of the coordinator and worker workloads that exist.
+// returns whether any of them was changed
+func scaleClusterWorkloads(r *ReconcilePresto, presto *falaricav1alpha1.Presto,
+	coordinatorReplicas int32, workerReplicas int32) (bool, error) {
+	changed := false
+	ctx := context.Background()
+	scale := func(obj runtime.Object, replicas *int32, desired int32) error {
+		if *replicas == desired {
+			return nil
+		}
+		*replicas = desired
+		changed = true
+		return r.client.Update(ctx, obj)
+	}
+	replicaSet, err := getReplicaSet(r, presto, getCoordinatorPodLabel)
+	if err == nil {
+		err = scale(replicaSet, replicaSet.Spec.Replicas, coordinatorReplicas)
+	}
+	if err != nil && !errors.IsNotFound(err) {
+		return changed, err
+	}
+	replicaSet, err = getReplicaSet(r, presto, getWorkerPodLabel)
+	if err == nil {
+		err = scale(replicaSet, replicaSet.Spec.Replicas, workerReplicas)
+	}
+	if err != nil && !errors.IsNotFound(err) {
+		return changed, err
+	}
+	statefulSet, err := getCoordinatorStatefulSet(r, presto)
+	if err == nil {
+		err = scale(statefulSet, statefulSet.Spec.Replicas, coordinatorReplicas)
+	}
+	if err != nil && !errors.IsNotFound(err) {
+		return changed, err
+	}
+	deployment, err := getWorkerDeployment(r, presto)
+	if err == nil {
+		err = scale(deployment, deployment.Spec.Replicas, workerReplicas)
+	}
+	if err != nil && !errors.IsNotFound(err) {
+		return changed, err
+	}
+	return changed, nil
+}
+
+// deletes the HPA and the decommissioning workers of a hibernated cluster
+func removeHibernatedResources(r *ReconcilePresto, presto *falaricav1alpha1.Presto,
+	baseLabels map[string]string) (bool, error) {
+	changed := false
+	ctx := context.Background()
+	hpa, err := getPrestoHPA(r, presto, getHPAName(presto.Status.Uuid), baseLabels)
+	if err == nil {
+		err = r.client.Delete(ctx, hpa)
+		changed = true
+	}
+	if err != nil && !errors.IsNotFound(err) {
+		return changed, err
+	}
+	pods, err := getPrestoPods(r, presto, getDecommissioningPodLabel)
+	if err != nil {
+		return changed, err
+	}
+	for i := range pods {
+		if pods[i].DeletionTimestamp != nil {
+			continue
+		}
+		err = r.client.Delete(ctx, &pods[i])
+		if err != nil && !errors.IsNotFound(err) {
+			return changed, err
+		}
+		changed = true
+	}
+	return changed, nil
+}
+
+// samples the query activity of the coordinator and returns whether the cluster has
+// been idle for longer than the idle policy allows
+func (r *ReconcilePresto) isIdle(presto *falaricav1alpha1.Presto, ctx context.Context, now time.Time) bool {
+	idlePolicy := presto.Spec.IdlePolicy
+	if idlePolicy == nil {
+		return false
+	}
+	lastActivity := presto.Status.LastActivityTime
+	stats, err := r.prestoClient(presto).Cluster(ctx)
+	if err != nil {
+		// the coordinator may be starting. The cluster is not hibernated
+		// till the activity is known.
+		return false
+	}
+	active := stats.RunningQueries+stats.QueuedQueries+stats.BlockedQueries > 0
+	if lastActivity == nil || (active && now.Sub(lastActivity.Time) >= activityRecordPeriod) {
+		activityTime := metav1.NewTime(now)
+		r.updateStatus(presto, ctx, ClusterUpdateAction{
+			lastActivityTime: &activityTime,
+		})
+		return false
+	}
+	if active {
+		return false
+	}
+	return now.Sub(lastActivity.Time) >= time.Duration(idlePolicy.HibernateAfterMinutes)*time.Minute
+}
+
+// removes the resume annotation from the presto object
+func removeResumeAnnotation(r *ReconcilePresto, presto *falaricav1alpha1.Presto) error {
+	if _, ok := presto.Annotations[resumeAnnotation]; !ok {
+		return nil
+	}
+	prestoCopy, err := r.getPresto(presto)
+	if err != nil {
+		return err
+	}
+	if prestoCopy == nil {
+		return nil
+	}
+	patchBase := prestoCopy.DeepCopy()
+	delete(prestoCopy.Annotations, resumeAnnotation)
+	return r.client.Patch(context.Background(), prestoCopy, client.MergeFrom(patchBase))
+}
+
+func hibernatedConditions(presto *falaricav1alpha1.Presto,
+	reason falaricav1alpha1.HibernationReason) []falaricav1alpha1.PrestoCondition {
+	message := fmt.Sprintf("Cluster is hibernated. Reason: %s", reason)
+	return []falaricav1alpha1.PrestoCondition{
+		newCondition(presto, falaricav1alpha1.ConditionCoordinatorReady, corev1.ConditionFalse,
+			"Hibernated", message),
+		newCondition(presto, falaricav1alpha1.ConditionWorkersAvailable, corev1.ConditionFalse,
+			"Hibernated", message),
+		newCondition(presto, falaricav1alpha1.ConditionReady, corev1.ConditionFalse,
+			"Hibernated", message),
+	}
+}
+
+// hibernates or resumes the cluster. returns error, hibernated. The remaining steps of
+// Reconcile are skipped when the cluster is hibernated or has just been resumed.
+func (r *ReconcilePresto) hibernation(presto *falaricav1alpha1.Presto,
+	baseLabels map[string]string, ctx context.Context) (error, bool) {
+	now := r.clock.Now()
+	reason := presto.Status.HibernationReason
+	if isSuspended(presto) {
+		reason = falaricav1alpha1.HibernationSuspended
+	} else if reason == falaricav1alpha1.HibernationSuspended {
+		// suspend has been cleared
+		reason = ""
+	} else if reason == falaricav1alpha1.HibernationIdle {
+		if _, ok := presto.Annotations[resumeAnnotation]; ok {
+			reason = ""
+		}
+	} else if !isHibernated(presto) && r.isIdle(presto, ctx, now) {
+		reason = falaricav1alpha1.HibernationIdle
+	}
+
+	if len(reason) == 0 {
+		if !isHibernated(presto) {
+			return nil, false
+		}
+		return r.resume(presto, ctx, now), true
+	}
+
+	hibernation := &hibernationStatus{
+		reason:       reason,
+		hibernatedAt: presto.Status.HibernatedAt,
+		workers:      presto.Status.HibernatedWorkers,
+	}
+	if !isHibernated(presto) {
+		// the number of workers is recorded before the workloads are scaled to zero, and is
+		// not changed till the cluster is resumed, so a failed attempt does not lose it
+		workers, err := getCurrentWorkerCount(r, presto)
+		if err != nil {
+			return err, false
+		}
+		hibernatedAt := metav1.NewTime(now)
+		hibernation.hibernatedAt = &hibernatedAt
+		hibernation.workers = workers
+		if _, err = r.updateStatus(presto, ctx, ClusterUpdateAction{hibernation: hibernation}); err != nil {
+			return err, false
+		}
+	}
+	scaled, err := scaleClusterWorkloads(r, presto, 0, 0)
+	if err == nil {
+		var poolsScaled bool
+		poolsScaled, err = hibernateWorkerPools(r, presto)
+		scaled = scaled || poolsScaled
+	}
+	if err == nil {
+		var removed bool
+		removed, err = removeHibernatedResources(r, presto, baseLabels)
+		scaled = scaled || removed
+	}
+	if err == nil {
+		// the metastore is stopped once the presto pods are gone
+		var stopped bool
+		stopped, err = stopHiveMetastore(r, presto)
+		scaled = scaled || stopped
+	}
+	if err != nil {
+		errorReason := fmt.Sprintf("Failed to hibernate the cluster %s", err.Error())
+		r.updateStatus(presto, ctx, ClusterUpdateAction{
+			errorReason:  &errorReason,
+			clusterState: falaricav1alpha1.ClusterFailedState,
+			conditions:   failureConditions(presto, "HibernationFailed", errorReason),
+		})
+		r.eventRecorder.Eventf(presto, corev1.EventTypeWarning, "Failed",
+			"Failed to hibernate the cluster %s", err.Error())
+		return err, false
+	}
+	if presto.Status.ClusterState != falaricav1alpha1.ClusterHibernated ||
+		presto.Status.HibernationReason != reason || scaled {
+		hpaName := ""
+		noError := ""
+		r.updateStatus(presto, ctx, ClusterUpdateAction{
+			clusterState: falaricav1alpha1.ClusterHibernated,
+			errorReason:  &noError,
+			hpaName:      &hpaName,
+			hibernation:  hibernation,
+			conditions:   hibernatedConditions(presto, reason),
+		})
+	}
+	if presto.Status.ClusterState != falaricav1alpha1.ClusterHibernated {
+		r.eventRecorder.Eventf(presto, corev1.EventTypeNormal, "Hibernated",
+			"Hibernated the cluster with %d workers. Reason: %s", hibernation.workers, reason)
+		r.log.Info(fmt.Sprintf("PrestoCluster %s: hibernated. Reason: %s", presto.Name, reason))
+	}
+	return nil, true
+}
+
+// scales the coordinator and the workers back to what they were before the hibernation
+func (r *ReconcilePresto) resume(presto *falaricav1alpha1.Presto, ctx context.Context, now time.Time) error {
+	workers := presto.Status.HibernatedWorkers
+	if workers < 1 {
+		workers = *presto.Spec.Worker.Count
+	}
+	_, err := scaleHiveMetastore(r, presto, 1)
+	if err == nil {
+		_, err = scaleClusterWorkloads(r, presto, 1, workers)
+	}
+	if err == nil {
+		err = removeResumeAnnotation(r, presto)
+	}
+	if err != nil {
+		errorReason := fmt.Sprintf("Failed to resume the cluster %s", err.Error())
+		r.updateStatus(presto, ctx, ClusterUpdateAction{
+			errorReason: &errorReason,
+			conditions:  failureConditions(presto, "ResumeFailed", errorReason),
+		})
+		r.eventRecorder.Eventf(presto, corev1.EventTypeWarning, "Failed",
+			"Failed to resume the cluster %s", err.Error())
+		return err
+	}
+	activityTime := metav1.NewTime(now)
+	r.updateStatus(presto, ctx, ClusterUpdateAction{
+		clusterState:     falaricav1alpha1.ClusterPending,
+		hibernation:      &hibernationStatus{},
+		lastActivityTime: &activityTime,
+	})
+	r.eventRecorder.Eventf(presto, corev1.EventTypeNormal, "Resumed",
+		"Resumed the cluster with %d workers", workers)
+	r.log.Info(fmt.Sprintf("PrestoCluster %s: resumed with %d workers", presto.Name, workers))
+	return nil
+}
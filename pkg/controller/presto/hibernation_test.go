@@ -0,0 +1,108 @@
+package presto
+
+import (
+	"context"
+	"testing"
+
+	falaricav1alpha1 "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1"
+	v1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// returns the worker replicaset of the test cluster
+func newTestWorkerReplicaSet(presto *falaricav1alpha1.Presto, replicas int32) *v1.ReplicaSet {
+	k, v := getWorkerPodLabel(testClusterUUID)
+	return &v1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "worker-" + testClusterUUID[:8],
+			Namespace:       testNamespace,
+			UID:             "worker-replicaset-uid",
+			Labels:          map[string]string{k: v},
+			OwnerReferences: []metav1.OwnerReference{*getOwnerReference(presto)},
+		},
+		Spec: v1.ReplicaSetSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{k: v}},
+		},
+	}
+}
+
+func getTestWorkerReplicas(t *testing.T, r *ReconcilePresto) int32 {
+	replicaSet := &v1.ReplicaSet{}
+	err := r.client.Get(context.Background(),
+		types.NamespacedName{Namespace: testNamespace, Name: "worker-" + testClusterUUID[:8]}, replicaSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return *replicaSet.Spec.Replicas
+}
+
+func TestHibernation(t *testing.T) {
+	ctx := context.Background()
+	presto := newTestPresto()
+	suspend := true
+	presto.Spec.Suspend = &suspend
+	r := newTestReconciler(t, presto, newTestWorkerReplicaSet(presto, 3))
+
+	err, hibernated := r.hibernation(getTestPresto(t, r), nil, ctx)
+	if err != nil || !hibernated {
+		t.Fatalf("hibernation returned %v, %v", err, hibernated)
+	}
+	status := getTestPresto(t, r).Status
+	if status.ClusterState != falaricav1alpha1.ClusterHibernated ||
+		status.HibernationReason != falaricav1alpha1.HibernationSuspended ||
+		status.HibernatedWorkers != 3 || status.HibernatedAt == nil {
+		t.Errorf("unexpected status after hibernation %+v", status)
+	}
+	if replicas := getTestWorkerReplicas(t, r); replicas != 0 {
+		t.Errorf("the workers were scaled to %d", replicas)
+	}
+	if !hasEvent(getTestEvents(r), "Normal Hibernated") {
+		t.Error("no Hibernated event")
+	}
+
+	// the steps that run before hibernation do not mark a hibernated cluster as pending
+	err, _ = r.headlessServiceConfig(getTestPresto(t, r), map[string]string{}, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state := getTestPresto(t, r).Status.ClusterState; state != falaricav1alpha1.ClusterHibernated {
+		t.Errorf("the state of the hibernated cluster changed to %s", state)
+	}
+
+	// a cluster whose hibernation failed is still hibernated, and the number of workers is
+	// not overwritten by the retry
+	presto = getTestPresto(t, r)
+	presto.Status.ClusterState = falaricav1alpha1.ClusterFailedState
+	if err = r.client.Status().Update(ctx, presto); err != nil {
+		t.Fatal(err)
+	}
+	err, hibernated = r.hibernation(getTestPresto(t, r), nil, ctx)
+	if err != nil || !hibernated {
+		t.Fatalf("hibernation returned %v, %v", err, hibernated)
+	}
+	status = getTestPresto(t, r).Status
+	if status.ClusterState != falaricav1alpha1.ClusterHibernated || status.HibernatedWorkers != 3 {
+		t.Errorf("unexpected status after the retry %+v", status)
+	}
+
+	// resume scales the workers back to the recorded number
+	presto = getTestPresto(t, r)
+	suspend = false
+	presto.Spec.Suspend = &suspend
+	if err = r.client.Update(ctx, presto); err != nil {
+		t.Fatal(err)
+	}
+	err, _ = r.hibernation(getTestPresto(t, r), nil, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status = getTestPresto(t, r).Status
+	if status.ClusterState != falaricav1alpha1.ClusterPending || isHibernated(getTestPresto(t, r)) {
+		t.Errorf("unexpected status after resume %+v", status)
+	}
+	if replicas := getTestWorkerReplicas(t, r); replicas != 3 {
+		t.Errorf("the workers were resumed with %d replicas", replicas)
+	}
+}
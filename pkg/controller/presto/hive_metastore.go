@@ -0,0 +1,593 @@
+package presto
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/xml"
+	"fmt"
+	falaricav1alpha1 "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1"
+	v1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"strings"
+)
+
+/*
+  With spec.internalHiveMetaStore.enabled, a Hive Metastore is deployed along with the
+  cluster as a Deployment with one replica and a ClusterIP service, both owned by the
+  Presto object. hive-site.xml is rendered into a config map and mounted in the metastore
+  pod. The metadata is kept in an embedded Derby database by default, which is lost when
+  the pod restarts, or in an external database given by its JDBC URL. The user name and
+  the password of the external database are read from a secret into the environment of
+  the pod and substituted by Hive, so they are not written to the config map.
+  A hive catalog pointing at the metastore service is added to the catalog config map
+  unless the spec has a catalog of the same name.
+  The metastore is reconciled before the coordinator so that it starts first. When the
+  cluster is hibernated it is scaled down only after all the presto pods are gone. When
+  the metastore is disabled, its resources are deleted after the hive catalog has been
+  removed from the catalog config map. When the Presto object is deleted, the resources
+  are garbage collected through the owner reference.
+*/
+
+// JDBC driver of each database type supported by the hive schematool
+var hmsDefaultDrivers = map[string]string{
+	"derby":    "org.apache.derby.jdbc.EmbeddedDriver",
+	"postgres": "org.postgresql.Driver",
+	"mysql":    "com.mysql.jdbc.Driver",
+	"oracle":   "oracle.jdbc.OracleDriver",
+	"mssql":    "com.microsoft.sqlserver.jdbc.SQLServerDriver",
+}
+
+const (
+	hmsContainerName = "hivemetastore"
+	hmsDataVolName   = "hmsdata"
+	hmsConfigVolName = "hmsconfig"
+	// environment variables of the metastore pod that hold the database credentials
+	hmsDBUserEnv     = "HMS_DB_USER"
+	hmsDBPasswordEnv = "HMS_DB_PASSWORD"
+)
+
+func isHiveMetastoreEnabled(presto *falaricav1alpha1.Presto) bool {
+	return presto.Spec.InternalHiveMetaStore.Enabled
+}
+
+// returns the thrift URI of the metastore service
+func getHMSURI(presto *falaricav1alpha1.Presto) string {
+	return fmt.Sprintf("thrift://%s.%s.svc:%d", getHMSName(presto.Status.Uuid),
+		presto.Namespace, hmsThriftPort)
+}
+
+func getHMSCatalogName(presto *falaricav1alpha1.Presto) string {
+	if len(presto.Spec.InternalHiveMetaStore.CatalogName) != 0 {
+		return presto.Spec.InternalHiveMetaStore.CatalogName
+	}
+	return defaultHMSCatalogName
+}
+
+// returns the name and the content of the catalog of the metastore. ok is false when
+// the metastore is not enabled or the spec has a catalog of the same name.
+func getHMSCatalog(presto *falaricav1alpha1.Presto) (string, string, bool) {
+	if !isHiveMetastoreEnabled(presto) {
+		return "", "", false
+	}
+	catalogName := getHMSCatalogName(presto)
+	for _, specCatalog := range presto.Spec.Catalogs.CatalogSpec {
+		if specCatalog.Name == catalogName {
+			return "", "", false
+		}
+	}
+	for _, specCatalogSecret := range presto.Spec.Catalogs.CatalogSecrets {
+		if specCatalogSecret.SecretKey == catalogName {
+			return "", "", false
+		}
+	}
+	props := map[string]string{
+		"connector.name":     "hive-hadoop2",
+		"hive.metastore.uri": getHMSURI(presto),
+	}
+	for k, v := range presto.Spec.InternalHiveMetaStore.CatalogProps {
+		props[k] = v
+	}
+	var sb strings.Builder
+	for _, key := range sortedKeys(props) {
+		sb.WriteString(fmt.Sprintf("%s=%s\n", key, props[key]))
+	}
+	return catalogName, sb.String(), true
+}
+
+func getHMSLabels(presto *falaricav1alpha1.Presto) map[string]string {
+	k, v := getHMSPodLabel(presto.Status.Uuid)
+	return map[string]string{
+		"clusterUUID": presto.Status.Uuid,
+		"clusterName": presto.Name,
+		k:             v,
+	}
+}
+
+func getHMSDatabase(presto *falaricav1alpha1.Presto) falaricav1alpha1.HMSDatabaseSpec {
+	if presto.Spec.InternalHiveMetaStore.Database != nil {
+		return *presto.Spec.InternalHiveMetaStore.Database
+	}
+	return falaricav1alpha1.HMSDatabaseSpec{Type: "derby"}
+}
+
+func getHMSSecretKeys(database falaricav1alpha1.HMSDatabaseSpec) (string, string) {
+	usernameKey := database.UsernameKey
+	if len(usernameKey) == 0 {
+		usernameKey = "username"
+	}
+	passwordKey := database.PasswordKey
+	if len(passwordKey) == 0 {
+		passwordKey = "password"
+	}
+	return usernameKey, passwordKey
+}
+
+// validates the metastore spec and checks that the database secret is present
+func validateHiveMetastore(presto *falaricav1alpha1.Presto, r *ReconcilePresto) error {
+	database := getHMSDatabase(presto)
+	if _, ok := hmsDefaultDrivers[database.Type]; !ok {
+		return &OperatorError{fmt.Sprintf("unsupported metastore database type %s", database.Type)}
+	}
+	if database.Type != "derby" && len(database.JDBCURL) == 0 {
+		return &OperatorError{fmt.Sprintf("jdbcURL is required for the metastore database type %s",
+			database.Type)}
+	}
+	if len(database.CredentialsSecret) == 0 {
+		return nil
+	}
+	secret := &corev1.Secret{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{
+		Namespace: presto.Namespace,
+		Name:      database.CredentialsSecret,
+	}, secret)
+	if errors.IsNotFound(err) {
+		return &OperatorError{fmt.Sprintf("metastore database secret %s is not found",
+			database.CredentialsSecret)}
+	}
+	if err != nil {
+		return err
+	}
+	usernameKey, passwordKey := getHMSSecretKeys(database)
+	for _, key := range []string{usernameKey, passwordKey} {
+		if _, ok := secret.Data[key]; !ok {
+			return &OperatorError{fmt.Sprintf("key %s is not found in metastore database secret %s",
+				key, database.CredentialsSecret)}
+		}
+	}
+	return nil
+}
+
+// renders hive-site.xml. The properties are sorted so that the content does not
+// change across reconciles.
+func buildHiveSite(presto *falaricav1alpha1.Presto) (string, error) {
+	hms := presto.Spec.InternalHiveMetaStore
+	database := getHMSDatabase(presto)
+	warehouseDir := hms.WarehouseDir
+	if len(warehouseDir) == 0 {
+		warehouseDir = defaultHMSWarehouseDir
+	}
+	jdbcURL := database.JDBCURL
+	if len(jdbcURL) == 0 {
+		jdbcURL = fmt.Sprintf("jdbc:derby:;databaseName=%s/metastore_db;create=true", hmsDataPath)
+	}
+	driver := database.DriverClassName
+	if len(driver) == 0 {
+		driver = hmsDefaultDrivers[database.Type]
+	}
+	props := map[string]string{
+		"hive.metastore.warehouse.dir":          warehouseDir,
+		"javax.jdo.option.ConnectionURL":        jdbcURL,
+		"javax.jdo.option.ConnectionDriverName": driver,
+	}
+	if len(database.CredentialsSecret) != 0 {
+		props["javax.jdo.option.ConnectionUserName"] = fmt.Sprintf("${env.%s}", hmsDBUserEnv)
+		props["javax.jdo.option.ConnectionPassword"] = fmt.Sprintf("${env.%s}", hmsDBPasswordEnv)
+	}
+	for k, v := range hms.AdditionalProps {
+		props[k] = v
+	}
+	var buf bytes.Buffer
+	buf.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<configuration>\n")
+	for _, key := range sortedKeys(props) {
+		buf.WriteString("  <property>\n    <name>")
+		if err := xml.EscapeText(&buf, []byte(key)); err != nil {
+			return "", err
+		}
+		buf.WriteString("</name>\n    <value>")
+		if err := xml.EscapeText(&buf, []byte(props[key])); err != nil {
+			return "", err
+		}
+		buf.WriteString("</value>\n  </property>\n")
+	}
+	buf.WriteString("</configuration>\n")
+	return buf.String(), nil
+}
+
+func buildHMSConfigMap(presto *falaricav1alpha1.Presto, lbls map[string]string) (*corev1.ConfigMap, error) {
+	hiveSite, err := buildHiveSite(presto)
+	if err != nil {
+		return nil, err
+	}
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            getHMSConfigMapName(presto.Status.Uuid),
+			Namespace:       presto.Namespace,
+			Labels:          lbls,
+			OwnerReferences: []metav1.OwnerReference{*getOwnerReference(presto)},
+		},
+		Data: map[string]string{hiveSiteKey: hiveSite},
+	}, nil
+}
+
+func getHMSResources(presto *falaricav1alpha1.Presto) (corev1.ResourceRequirements, error) {
+	hms := presto.Spec.InternalHiveMetaStore
+	resources := corev1.ResourceRequirements{}
+	limits := corev1.ResourceList{}
+	if len(hms.CpuLimit) != 0 {
+		quantity, err := resource.ParseQuantity(hms.CpuLimit)
+		if err != nil {
+			return resources, &OperatorError{fmt.Sprintf("cannot parse "+
+				"presto.Spec.InternalHiveMetaStore.CpuLimit: '%v': %v", hms.CpuLimit, err)}
+		}
+		limits[corev1.ResourceCPU] = quantity
+	}
+	if len(hms.MemoryLimit) != 0 {
+		quantity, err := resource.ParseQuantity(hms.MemoryLimit)
+		if err != nil {
+			return resources, &OperatorError{fmt.Sprintf("cannot parse "+
+				"presto.Spec.InternalHiveMetaStore.MemoryLimit: '%v': %v", hms.MemoryLimit, err)}
+		}
+		limits[corev1.ResourceMemory] = quantity
+	}
+	if len(limits) != 0 {
+		resources.Limits = limits
+		resources.Requests = limits
+	}
+	return resources, nil
+}
+
+func buildHMSDeployment(presto *falaricav1alpha1.Presto, lbls map[string]string,
+	configMap *corev1.ConfigMap, replicas int32) (*v1.Deployment, error) {
+	hms := presto.Spec.InternalHiveMetaStore
+	database := getHMSDatabase(presto)
+	image := hms.Image
+	if len(image) == 0 {
+		image = defaultHMSImage
+	}
+	resources, err := getHMSResources(presto)
+	if err != nil {
+		return nil, err
+	}
+	env := []corev1.EnvVar{
+		{Name: "SERVICE_NAME", Value: "metastore"},
+		{Name: "DB_DRIVER", Value: database.Type},
+	}
+	if len(database.CredentialsSecret) != 0 {
+		usernameKey, passwordKey := getHMSSecretKeys(database)
+		for _, secretEnv := range []struct{ name, key string }{
+			{hmsDBUserEnv, usernameKey},
+			{hmsDBPasswordEnv, passwordKey},
+		} {
+			env = append(env, corev1.EnvVar{
+				Name: secretEnv.name,
+				ValueFrom: &corev1.EnvVarSource{
+					SecretKeyRef: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: database.CredentialsSecret},
+						Key:                  secretEnv.key,
+					},
+				},
+			})
+		}
+	}
+	podSpec := corev1.PodSpec{
+		Containers: []corev1.Container{
+			{
+				Name:      hmsContainerName,
+				Image:     image,
+				Env:       env,
+				Resources: resources,
+				Ports: []corev1.ContainerPort{
+					{Name: "thrift", ContainerPort: hmsThriftPort},
+				},
+				ReadinessProbe: &corev1.Probe{
+					Handler: corev1.Handler{
+						TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt(hmsThriftPort)},
+					},
+					InitialDelaySeconds: 10,
+					PeriodSeconds:       10,
+				},
+				VolumeMounts: []corev1.VolumeMount{
+					{
+						Name:      hmsConfigVolName,
+						MountPath: hmsConfigPath + "/" + hiveSiteKey,
+						SubPath:   hiveSiteKey,
+					},
+					{
+						Name:      hmsDataVolName,
+						MountPath: hmsDataPath,
+					},
+				},
+			},
+		},
+		Volumes: []corev1.Volume{
+			{
+				Name: hmsConfigVolName,
+				VolumeSource: corev1.VolumeSource{
+					ConfigMap: &corev1.ConfigMapVolumeSource{
+						LocalObjectReference: corev1.LocalObjectReference{Name: configMap.Name},
+					},
+				},
+			},
+			{
+				Name:         hmsDataVolName,
+				VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+			},
+		},
+	}
+	podSpecHash, err := getPodSpecHash(&podSpec)
+	if err != nil {
+		return nil, err
+	}
+	// a file mounted with subPath is not updated, so the pod is restarted on a change
+	configHash := fmt.Sprintf("%x", sha256.Sum256([]byte(configMap.Data[hiveSiteKey])))[:16]
+	return &v1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            getHMSName(presto.Status.Uuid),
+			Namespace:       presto.Namespace,
+			Labels:          lbls,
+			OwnerReferences: []metav1.OwnerReference{*getOwnerReference(presto)},
+		},
+		Spec: v1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: lbls,
+			},
+			// the embedded derby database cannot be shared by two pods
+			Strategy: v1.DeploymentStrategy{Type: v1.RecreateDeploymentStrategyType},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: lbls,
+					Annotations: map[string]string{
+						configHashAnnotation:  configHash,
+						podSpecHashAnnotation: podSpecHash,
+					},
+				},
+				Spec: podSpec,
+			},
+		},
+	}, nil
+}
+
+func buildHMSService(presto *falaricav1alpha1.Presto, lbls map[string]string) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            getHMSName(presto.Status.Uuid),
+			Namespace:       presto.Namespace,
+			Labels:          lbls,
+			OwnerReferences: []metav1.OwnerReference{*getOwnerReference(presto)},
+		},
+		Spec: corev1.ServiceSpec{
+			Selector: lbls,
+			Type:     corev1.ServiceTypeClusterIP,
+			Ports: []corev1.ServicePort{
+				{
+					Name:       "thrift",
+					Port:       hmsThriftPort,
+					TargetPort: intstr.FromInt(hmsThriftPort),
+				},
+			},
+		},
+	}
+}
+
+func getHMSDeployment(r *ReconcilePresto, presto *falaricav1alpha1.Presto) (*v1.Deployment, error) {
+	deployment := &v1.Deployment{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{
+		Namespace: presto.Namespace,
+		Name:      getHMSName(presto.Status.Uuid),
+	}, deployment)
+	if err != nil {
+		return nil, err
+	}
+	return deployment, nil
+}
+
+// creates or updates the config map, the service and the deployment of the metastore.
+// returns created, updated, error
+func createUpdateHiveMetastore(r *ReconcilePresto, presto *falaricav1alpha1.Presto) (bool, bool, error) {
+	ctx := context.Background()
+	lbls := getHMSLabels(presto)
+	configMap, err := buildHMSConfigMap(presto, lbls)
+	if err != nil {
+		return false, false, err
+	}
+	cmCreated, cmUpdated, err := createOrUpdateConfigMap(configMap.Name, presto, r.client, configMap, lbls)
+	if err != nil {
+		return false, false, err
+	}
+	created := cmCreated
+	updated := cmUpdated
+
+	service := &corev1.Service{}
+	err = r.client.Get(ctx, types.NamespacedName{
+		Namespace: presto.Namespace,
+		Name:      getHMSName(presto.Status.Uuid),
+	}, service)
+	if errors.IsNotFound(err) {
+		err = r.client.Create(ctx, buildHMSService(presto, lbls))
+		created = true
+	}
+	if err != nil {
+		return created, updated, err
+	}
+
+	desired, err := buildHMSDeployment(presto, lbls, configMap, 1)
+	if err != nil {
+		return created, updated, err
+	}
+	deployment, err := getHMSDeployment(r, presto)
+	if errors.IsNotFound(err) {
+		return true, updated, r.client.Create(ctx, desired)
+	}
+	if err != nil {
+		return created, updated, err
+	}
+	template := deployment.Spec.Template
+	if template.Annotations[podSpecHashAnnotation] == desired.Spec.Template.Annotations[podSpecHashAnnotation] &&
+		template.Annotations[configHashAnnotation] == desired.Spec.Template.Annotations[configHashAnnotation] &&
+		*deployment.Spec.Replicas == 1 {
+		return created, updated, nil
+	}
+	deploymentCopy := deployment.DeepCopy()
+	deploymentCopy.Spec.Template = desired.Spec.Template
+	deploymentCopy.Spec.Replicas = desired.Spec.Replicas
+	return created, true, r.client.Update(ctx, deploymentCopy)
+}
+
+// deletes the resources of the metastore that exist. returns whether any was deleted
+func removeHiveMetastore(r *ReconcilePresto, presto *falaricav1alpha1.Presto) (bool, error) {
+	deleted := false
+	ctx := context.Background()
+	for _, obj := range []struct {
+		name   string
+		object runtime.Object
+	}{
+		{getHMSName(presto.Status.Uuid), &v1.Deployment{}},
+		{getHMSName(presto.Status.Uuid), &corev1.Service{}},
+		{getHMSConfigMapName(presto.Status.Uuid), &corev1.ConfigMap{}},
+	} {
+		err := r.client.Get(ctx, types.NamespacedName{Namespace: presto.Namespace, Name: obj.name}, obj.object)
+		if err == nil {
+			err = r.client.Delete(ctx, obj.object)
+			deleted = true
+		}
+		if err != nil && !errors.IsNotFound(err) {
+			return deleted, err
+		}
+	}
+	return deleted, nil
+}
+
+// sets the replicas of the metastore deployment if it exists. returns whether it was changed
+func scaleHiveMetastore(r *ReconcilePresto, presto *falaricav1alpha1.Presto, replicas int32) (bool, error) {
+	deployment, err := getHMSDeployment(r, presto)
+	if errors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if *deployment.Spec.Replicas == replicas {
+		return false, nil
+	}
+	deploymentCopy := deployment.DeepCopy()
+	deploymentCopy.Spec.Replicas = &replicas
+	return true, r.client.Update(context.Background(), deploymentCopy)
+}
+
+// scales the metastore of a hibernated cluster to zero once all the presto pods are gone.
+// returns whether it was changed
+func stopHiveMetastore(r *ReconcilePresto, presto *falaricav1alpha1.Presto) (bool, error) {
+	for _, podLabel := range []func(string) (string, string){
+		getCoordinatorPodLabel, getWorkerPodLabel, getWorkerPoolPodLabel, getDecommissioningPodLabel,
+	} {
+		pods, err := getPrestoPods(r, presto, podLabel)
+		if err != nil {
+			return false, err
+		}
+		if len(pods) != 0 {
+			return false, nil
+		}
+	}
+	return scaleHiveMetastore(r, presto, 0)
+}
+
+// returns the HiveMetastoreReady condition of an enabled metastore
+func getHiveMetastoreCondition(r *ReconcilePresto,
+	presto *falaricav1alpha1.Presto) falaricav1alpha1.PrestoCondition {
+	deployment, err := getHMSDeployment(r, presto)
+	if err != nil {
+		return newCondition(presto, falaricav1alpha1.ConditionHiveMetastoreReady, corev1.ConditionFalse,
+			"HiveMetastoreNotFound", fmt.Sprintf("Failed to get the metastore deployment: %s", err.Error()))
+	}
+	if deployment.Status.AvailableReplicas < 1 {
+		return newCondition(presto, falaricav1alpha1.ConditionHiveMetastoreReady, corev1.ConditionFalse,
+			"HiveMetastoreNotReady", "Hive Metastore pod is not ready")
+	}
+	return newCondition(presto, falaricav1alpha1.ConditionHiveMetastoreReady, corev1.ConditionTrue,
+		"HiveMetastoreReady", fmt.Sprintf("Hive Metastore is available at %s", getHMSURI(presto)))
+}
+
+// deploys the metastore when it is enabled and removes it otherwise.
+// returns error, changesMade
+func (r *ReconcilePresto) hiveMetastore(presto *falaricav1alpha1.Presto, ctx context.Context) (error, bool) {
+	if !isHiveMetastoreEnabled(presto) {
+		deleted, err := removeHiveMetastore(r, presto)
+		if err != nil {
+			r.log.Error(err, "failed to delete the hive metastore")
+			r.eventRecorder.Eventf(presto, corev1.EventTypeWarning, "Failed",
+				"Failed to delete the hive metastore %s", err.Error())
+			return err, false
+		}
+		if deleted || len(presto.Status.HiveMetastore) != 0 {
+			noHMS := ""
+			r.updateStatus(presto, ctx, ClusterUpdateAction{
+				hiveMetastore:    &noHMS,
+				hiveMetastoreURI: &noHMS,
+				conditions: []falaricav1alpha1.PrestoCondition{
+					newCondition(presto, falaricav1alpha1.ConditionHiveMetastoreReady, corev1.ConditionFalse,
+						"HiveMetastoreDisabled", "Hive Metastore is not enabled"),
+				},
+			})
+			r.eventRecorder.Eventf(presto, corev1.EventTypeNormal, "Deleted",
+				"Deleted the hive metastore %s", getHMSName(presto.Status.Uuid))
+			r.log.Info(fmt.Sprintf("PrestoCluster %s: deleted the hive metastore", presto.Name))
+		}
+		return nil, deleted
+	}
+
+	err := validateHiveMetastore(presto, r)
+	if err == nil {
+		var created, updated bool
+		created, updated, err = createUpdateHiveMetastore(r, presto)
+		if err == nil && (created || updated) {
+			name := getHMSName(presto.Status.Uuid)
+			uri := getHMSURI(presto)
+			r.updateStatus(presto, ctx, ClusterUpdateAction{
+				hiveMetastore:    &name,
+				hiveMetastoreURI: &uri,
+				clusterState:     falaricav1alpha1.ClusterPending,
+			})
+			reason := "Updated"
+			if created {
+				reason = "Created"
+			}
+			r.eventRecorder.Eventf(presto, corev1.EventTypeNormal, reason,
+				"%s the hive metastore %s. %s", reason, name, uri)
+			r.log.Info(fmt.Sprintf("PrestoCluster %s: %s the hive metastore", presto.Name,
+				strings.ToLower(reason)))
+			return nil, true
+		}
+	}
+	if err != nil {
+		r.log.Error(err, "failed to deploy the hive metastore")
+		errorReason := fmt.Sprintf("Failed to deploy the hive metastore %s", err.Error())
+		r.updateStatus(presto, ctx, ClusterUpdateAction{
+			errorReason:  &errorReason,
+			clusterState: falaricav1alpha1.ClusterFailedState,
+			conditions: failureConditions(presto, "HiveMetastoreFailed", errorReason,
+				falaricav1alpha1.ConditionHiveMetastoreReady),
+		})
+		r.eventRecorder.Eventf(presto, corev1.EventTypeWarning, "Failed",
+			"Failed to deploy the hive metastore %s", err.Error())
+		return err, false
+	}
+	return nil, false
+}
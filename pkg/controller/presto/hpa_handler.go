@@ -3,13 +3,20 @@ package presto
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 	"github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1"
 	v1 "k8s.io/api/apps/v1"
-	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -23,40 +30,57 @@ import (
   However, these cannot be changed for managed K8s services like GKE and EKS
   https://stackoverflow.com/questions/55815094/how-to-change-horizontal-pod-autoscaler-sync-period-field-in-kube-controller-m
   https://stackoverflow.com/questions/46317275/change-the-horizontal-pod-autoscaler-sync-period-with-gke
+
+  The HPA is created using autoscaling/v2beta2, the latest version of the API supported by
+  the Kubernetes client of the operator. It scales on the CPU and memory utilization and on
+  any Pods, Object or External metrics given in the spec. The downscale stabilization and
+  the scaling policies can be set per HPA through the behavior field, which is honoured by
+  Kubernetes 1.18 and later. The client does not know this field, so the HPA is sent as an
+  unstructured object and the hash of the behavior is kept as an annotation on the HPA.
+  An older API server drops the unknown field silently. So the HPA is read back after it is
+  created or updated, and a dropped behavior is reported with a Warning event.
 */
+// returns created, updated, deleted, whether the API server dropped the behavior, error
 func handleReplicaSet(
 	r *ReconcilePresto,
 	presto *v1alpha1.Presto,
-	workerReplicaSet *v1.ReplicaSet,
+	scaleTarget autoscalingv2beta2.CrossVersionObjectReference,
+	hpaName string,
 	lbls map[string]string,
-	ctx context.Context) (bool, bool, bool, error) {
+	ctx context.Context) (bool, bool, bool, bool, error) {
 
-	autoScalingEnabled := checkAutoscalingEnabled(presto)
+	// with the query load autoscaling, the operator scales the workers itself
+	autoScalingEnabled := checkAutoscalingEnabled(presto) && !isQueryLoadAutoscalingEnabled(presto)
 	created := false
 	updated := false
 	deleted := false
+	var hpaObject *unstructured.Unstructured
 
-	var hpa *autoscalingv1.HorizontalPodAutoscaler
+	var hpa *autoscalingv2beta2.HorizontalPodAutoscaler
 	exists := true
-	hpa, err := getPrestoHPA(r, presto, lbls)
+	hpa, err := getPrestoHPA(r, presto, hpaName, lbls)
 	if errors.IsNotFound(err) {
 		exists = false
 	} else if err != nil {
-		return created, updated, deleted, err
+		return created, updated, deleted, false, err
 	}
 	if exists {
 		if autoScalingEnabled {
-			if autoscaleSpecChanged(hpa, presto) {
+			if autoscaleSpecChanged(hpa, presto, scaleTarget) {
 				r.log.Info(fmt.Sprintf("HPA spec will be updated"))
-				hpa, err := createHPASpec(presto, workerReplicaSet,
+				hpa, err := createHPASpec(presto, scaleTarget, hpaName,
 					lbls, hpa.ObjectMeta.ResourceVersion)
 				if err != nil {
-					return created, updated, deleted, err
+					return created, updated, deleted, false, err
+				}
+				hpaObject, err = toUnstructuredHPA(hpa, presto.Spec.Worker.Autoscaling.Behavior)
+				if err != nil {
+					return created, updated, deleted, false, err
 				}
-				err = r.client.Update(ctx, hpa)
+				err = r.client.Update(ctx, hpaObject)
 				if err != nil && !errors.IsAlreadyExists(err) {
 					r.log.Error(err, "Failed to update HPA spec")
-					return created, updated, deleted, err
+					return created, updated, deleted, false, err
 				}
 				updated = true
 			}
@@ -66,36 +90,91 @@ func handleReplicaSet(
 			err := r.client.Delete(ctx, hpa)
 			if err != nil {
 				r.log.Error(err, "Failed to delete HPA spec")
-				return created, updated, deleted, err
+				return created, updated, deleted, false, err
 			}
 			deleted = true
 		}
 	} else if autoScalingEnabled {
 		r.log.Info(fmt.Sprintf("Creating HPA Spec"))
-		hpa, err := createHPASpec(presto, workerReplicaSet, lbls, "")
+		hpa, err := createHPASpec(presto, scaleTarget, hpaName, lbls, "")
+		if err != nil {
+			return created, updated, deleted, false, err
+		}
+		hpaObject, err = toUnstructuredHPA(hpa, presto.Spec.Worker.Autoscaling.Behavior)
 		if err != nil {
-			return created, updated, deleted, err
+			return created, updated, deleted, false, err
 		}
-		err = r.client.Create(ctx, hpa)
+		err = r.client.Create(ctx, hpaObject)
 		if err != nil && !errors.IsAlreadyExists(err) {
 			r.log.Error(err, "Failed to create HPA Spec")
-			return created, updated, deleted, err
+			return created, updated, deleted, false, err
 		}
 		created = true
 	}
-	return created, updated, deleted, nil
+	behaviorDropped := false
+	if created || updated {
+		behaviorDropped, err = isHPABehaviorDropped(r, hpaObject, presto.Spec.Worker.Autoscaling.Behavior, ctx)
+		if err != nil {
+			r.log.Error(err, "Failed to read back the HPA "+hpaName)
+			return created, updated, deleted, false, err
+		}
+		if behaviorDropped {
+			r.eventRecorder.Eventf(presto, corev1.EventTypeWarning, "BehaviorNotSupported",
+				"The API server dropped the behavior of HPA %s. It needs Kubernetes 1.18 or later", hpaName)
+		}
+	}
+	return created, updated, deleted, behaviorDropped, nil
 }
 
-func autoscaleSpecChanged(hpa *autoscalingv1.HorizontalPodAutoscaler,
-	presto *v1alpha1.Presto) bool {
-	return hpa.Spec.MaxReplicas != *presto.Spec.Worker.Autoscaling.MaxReplicas ||
-		*hpa.Spec.MinReplicas != *presto.Spec.Worker.Autoscaling.MinReplicas ||
-		*hpa.Spec.TargetCPUUtilizationPercentage != *presto.Spec.Worker.Autoscaling.TargetCPUUtilizationPercentage
+// reads the HPA back from the API server and returns whether the server dropped the
+// behavior that was sent
+func isHPABehaviorDropped(r *ReconcilePresto, hpaObject *unstructured.Unstructured,
+	behavior *v1alpha1.HPABehavior, ctx context.Context) (bool, error) {
+	if behavior == nil {
+		return false, nil
+	}
+	stored := &unstructured.Unstructured{}
+	stored.SetGroupVersionKind(hpaObject.GroupVersionKind())
+	err := r.client.Get(ctx, types.NamespacedName{
+		Namespace: hpaObject.GetNamespace(),
+		Name:      hpaObject.GetName(),
+	}, stored)
+	if err != nil {
+		return false, err
+	}
+	_, found, err := unstructured.NestedMap(stored.Object, "spec", "behavior")
+	return !found, err
 }
 
-func getPrestoHPA(r *ReconcilePresto,presto *v1alpha1.Presto,
-	lbls map[string]string) (*autoscalingv1.HorizontalPodAutoscaler, error) {
-	prestoHPA := &autoscalingv1.HorizontalPodAutoscalerList{}
+// returns whether the live HPA differs from the autoscaling spec. The metrics are compared
+// semantically. The behavior is not known to the client, so its hash is compared.
+func autoscaleSpecChanged(hpa *autoscalingv2beta2.HorizontalPodAutoscaler,
+	presto *v1alpha1.Presto, scaleTarget autoscalingv2beta2.CrossVersionObjectReference) bool {
+	autoscaling := presto.Spec.Worker.Autoscaling
+	if autoscaling.MinReplicas == nil || autoscaling.MaxReplicas == nil {
+		// createHPASpec reports the error
+		return true
+	}
+	metrics, err := getHPAMetrics(presto)
+	if err != nil {
+		return true
+	}
+	behaviorHash, err := getHPABehaviorHash(autoscaling.Behavior)
+	if err != nil {
+		return true
+	}
+	return hpa.Spec.ScaleTargetRef != scaleTarget ||
+		hpa.Spec.MaxReplicas != *autoscaling.MaxReplicas ||
+		hpa.Spec.MinReplicas == nil || *hpa.Spec.MinReplicas != *autoscaling.MinReplicas ||
+		!equality.Semantic.DeepEqual(hpa.Spec.Metrics, metrics) ||
+		hpa.Annotations[hpaBehaviorHashAnnotation] != behaviorHash
+}
+
+// returns the HPA of the given name. The HPAs of the worker pools have the labels of
+// the cluster as well, so the name is matched too.
+func getPrestoHPA(r *ReconcilePresto,presto *v1alpha1.Presto, hpaName string,
+	lbls map[string]string) (*autoscalingv2beta2.HorizontalPodAutoscaler, error) {
+	prestoHPA := &autoscalingv2beta2.HorizontalPodAutoscalerList{}
 	err := r.client.List(context.TODO(),
 		prestoHPA,
 		&client.ListOptions{
@@ -109,10 +188,24 @@ func getPrestoHPA(r *ReconcilePresto,presto *v1alpha1.Presto,
 		r.log.Error(err, "failed to list existing Presto HPA")
 		return nil, err
 	}
-	if len(prestoHPA.Items) == 0 {
-		return nil, errors.NewNotFound(v1.Resource("HPA"), "")
+	for i := range prestoHPA.Items {
+		if prestoHPA.Items[i].Name == hpaName {
+			return &prestoHPA.Items[i], nil
+		}
+	}
+	return nil, errors.NewNotFound(v1.Resource("HPA"), "")
+}
+
+// returns the AutoscalerReady condition of a created or updated HPA. It is false when the
+// API server dropped the behavior, as the HPA does not scale as specified.
+func getHPACondition(presto *v1alpha1.Presto, hpaName string, behaviorDropped bool) v1alpha1.PrestoCondition {
+	if behaviorDropped {
+		return newCondition(presto, v1alpha1.ConditionAutoscalerReady, corev1.ConditionFalse,
+			"BehaviorNotSupported", fmt.Sprintf("HPA %s is configured without the behavior, "+
+				"which needs Kubernetes 1.18 or later", hpaName))
 	}
-	return &prestoHPA.Items[0], nil
+	return newCondition(presto, v1alpha1.ConditionAutoscalerReady, corev1.ConditionTrue,
+		"HPAConfigured", fmt.Sprintf("HPA %s is configured", hpaName))
 }
 
 func checkAutoscalingEnabled(presto *v1alpha1.Presto) bool {
@@ -123,7 +216,7 @@ func checkAutoscalingEnabled(presto *v1alpha1.Presto) bool {
 	}
 }
 
-func isCreatedByHpaController(hpa *autoscalingv1.HorizontalPodAutoscaler, presto *v1alpha1.Presto) bool {
+func isCreatedByHpaController(hpa *autoscalingv2beta2.HorizontalPodAutoscaler, presto *v1alpha1.Presto) bool {
 	for _, ref := range hpa.OwnerReferences {
 		if ref.Name == presto.Name && ref.Kind == presto.Kind {
 			return true
@@ -132,9 +225,92 @@ func isCreatedByHpaController(hpa *autoscalingv1.HorizontalPodAutoscaler, presto
 	return false
 }
 
+// returns the reference of the workload that manages the workers
+func getWorkerScaleTarget(workerReplicaSet *v1.ReplicaSet,
+	workerDeployment *v1.Deployment) autoscalingv2beta2.CrossVersionObjectReference {
+	if workerDeployment != nil {
+		return autoscalingv2beta2.CrossVersionObjectReference{
+			APIVersion: v1.SchemeGroupVersion.String(),
+			Kind:       "Deployment",
+			Name:       workerDeployment.Name,
+		}
+	}
+	return autoscalingv2beta2.CrossVersionObjectReference{
+		APIVersion: v1.SchemeGroupVersion.String(),
+		Kind:       "ReplicaSet",
+		Name:       workerReplicaSet.Name,
+	}
+}
+
+// returns the metrics of the HPA. The CPU and memory targets come first
+// followed by the additional metrics of the spec.
+func getHPAMetrics(presto *v1alpha1.Presto) ([]autoscalingv2beta2.MetricSpec, error) {
+	autoscaling := presto.Spec.Worker.Autoscaling
+	var metrics []autoscalingv2beta2.MetricSpec
+	if autoscaling.TargetCPUUtilizationPercentage != nil {
+		metrics = append(metrics, getResourceMetric(corev1.ResourceCPU,
+			*autoscaling.TargetCPUUtilizationPercentage))
+	}
+	if autoscaling.TargetMemoryUtilizationPercentage != nil {
+		metrics = append(metrics, getResourceMetric(corev1.ResourceMemory,
+			*autoscaling.TargetMemoryUtilizationPercentage))
+	}
+	metrics = append(metrics, autoscaling.Metrics...)
+	if len(metrics) == 0 {
+		return nil, &OperatorError{errormsg: "One of TargetCPUUtilizationPercentage, " +
+			"TargetMemoryUtilizationPercentage or Metrics has to be specified"}
+	}
+	return metrics, nil
+}
+
+func getResourceMetric(name corev1.ResourceName, utilization int32) autoscalingv2beta2.MetricSpec {
+	return autoscalingv2beta2.MetricSpec{
+		Type: autoscalingv2beta2.ResourceMetricSourceType,
+		Resource: &autoscalingv2beta2.ResourceMetricSource{
+			Name: name,
+			Target: autoscalingv2beta2.MetricTarget{
+				Type:               autoscalingv2beta2.UtilizationMetricType,
+				AverageUtilization: &utilization,
+			},
+		},
+	}
+}
+
+func getHPABehaviorHash(behavior *v1alpha1.HPABehavior) (string, error) {
+	behaviorJSON, err := json.Marshal(behavior)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(behaviorJSON))[:16], nil
+}
+
+// converts the HPA to an unstructured object and sets the behavior on it
+func toUnstructuredHPA(hpa *autoscalingv2beta2.HorizontalPodAutoscaler,
+	behavior *v1alpha1.HPABehavior) (*unstructured.Unstructured, error) {
+	hpa.TypeMeta = metav1.TypeMeta{
+		APIVersion: autoscalingv2beta2.SchemeGroupVersion.String(),
+		Kind:       "HorizontalPodAutoscaler",
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(hpa)
+	if err != nil {
+		return nil, err
+	}
+	if behavior != nil {
+		behaviorContent, err := runtime.DefaultUnstructuredConverter.ToUnstructured(behavior)
+		if err != nil {
+			return nil, err
+		}
+		err = unstructured.SetNestedField(content, behaviorContent, "spec", "behavior")
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &unstructured.Unstructured{Object: content}, nil
+}
+
 func createHPASpec(presto *v1alpha1.Presto,
-	workerReplicaSet *v1.ReplicaSet,
-	lbls map[string]string, resourceVersion string) (*autoscalingv1.HorizontalPodAutoscaler, error) {
+	scaleTarget autoscalingv2beta2.CrossVersionObjectReference, hpaName string,
+	lbls map[string]string, resourceVersion string) (*autoscalingv2beta2.HorizontalPodAutoscaler, error) {
 
 	if presto.Spec.Worker.Autoscaling.MinReplicas == nil {
 		return nil, &OperatorError{errormsg: "MinReplicas cannot be null"}
@@ -146,33 +322,36 @@ func createHPASpec(presto *v1alpha1.Presto,
 	}
 	maxReplicas := *presto.Spec.Worker.Autoscaling.MaxReplicas
 
-	if presto.Spec.Worker.Autoscaling.TargetCPUUtilizationPercentage == nil {
-		return nil, &OperatorError{errormsg: "TargetCPUUtilizationPercentage cannot be null"}
+	metrics, err := getHPAMetrics(presto)
+	if err != nil {
+		return nil, err
+	}
+	behaviorHash, err := getHPABehaviorHash(presto.Spec.Worker.Autoscaling.Behavior)
+	if err != nil {
+		return nil, err
 	}
-	targetCPUUtilization := *presto.Spec.Worker.Autoscaling.TargetCPUUtilizationPercentage
 
-	hpa := &autoscalingv1.HorizontalPodAutoscaler{
+	hpa := &autoscalingv2beta2.HorizontalPodAutoscaler{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: getHPAName(presto.Status.Uuid),
+			Name: hpaName,
 			Namespace: presto.Namespace,
 			Labels: lbls,
+			Annotations: map[string]string{
+				hpaBehaviorHashAnnotation: behaviorHash,
+			},
 			// resource version for optimistic concurrency control
 			ResourceVersion: resourceVersion,
 			OwnerReferences: []metav1.OwnerReference{
 				*getOwnerReference(presto),
 			},
 		},
-		Spec: autoscalingv1.HorizontalPodAutoscalerSpec{
-			ScaleTargetRef: autoscalingv1.CrossVersionObjectReference{
-				APIVersion: workerReplicaSet.APIVersion,
-				Kind:       workerReplicaSet.Kind,
-				Name:       workerReplicaSet.Name,
-			},
+		Spec: autoscalingv2beta2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: scaleTarget,
 			MinReplicas: &minReplicas,
 			MaxReplicas: maxReplicas,
-			TargetCPUUtilizationPercentage:  &targetCPUUtilization,
+			Metrics:     metrics,
 		},
 	}
 
 	return hpa, nil
-}
\ No newline at end of file
+}
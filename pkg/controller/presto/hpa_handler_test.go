@@ -0,0 +1,79 @@
+package presto
+
+import (
+	"context"
+	"testing"
+
+	falaricav1alpha1 "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// a client that drops the behavior of the HPA like an API server before Kubernetes 1.18
+type behaviorDroppingClient struct {
+	client.Client
+}
+
+func (c behaviorDroppingClient) Create(ctx context.Context, obj runtime.Object, opts ...client.CreateOption) error {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		unstructured.RemoveNestedField(u.Object, "spec", "behavior")
+	}
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func (c behaviorDroppingClient) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		unstructured.RemoveNestedField(u.Object, "spec", "behavior")
+	}
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func TestHPABehaviorDropped(t *testing.T) {
+	tests := []struct {
+		name    string
+		dropped bool
+	}{
+		{"behavior kept", false},
+		{"behavior dropped", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			enabled := true
+			window := int32(600)
+			presto := newTestPresto()
+			presto.Spec.Worker.Autoscaling = falaricav1alpha1.AutoscalingSpec{
+				Enabled:                        &enabled,
+				MinReplicas:                    int32Ptr(1),
+				MaxReplicas:                    int32Ptr(5),
+				TargetCPUUtilizationPercentage: int32Ptr(80),
+				Behavior: &falaricav1alpha1.HPABehavior{
+					ScaleDown: &falaricav1alpha1.HPAScalingRules{StabilizationWindowSeconds: &window},
+				},
+			}
+			r := newTestReconciler(t, presto)
+			if test.dropped {
+				r.client = behaviorDroppingClient{Client: r.client}
+			}
+			replicaSet := newTestWorkerReplicaSet(presto, 1)
+			baseLabels := map[string]string{"clusterName": testClusterName}
+
+			err, _ := r.hpaReplicaset(getTestPresto(t, r), baseLabels, context.Background(),
+				getWorkerScaleTarget(replicaSet, nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			condition := findCondition(getTestPresto(t, r).Status.Conditions, falaricav1alpha1.ConditionAutoscalerReady)
+			expected := "HPAConfigured"
+			if test.dropped {
+				expected = "BehaviorNotSupported"
+			}
+			if condition == nil || condition.Reason != expected {
+				t.Errorf("unexpected autoscaler condition %v, expected %s", condition, expected)
+			}
+			if hasEvent(getTestEvents(r), "Warning BehaviorNotSupported") != test.dropped {
+				t.Errorf("unexpected events %v", getTestEvents(r))
+			}
+		})
+	}
+}
@@ -0,0 +1,381 @@
+package presto
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	falaricav1alpha1 "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"reflect"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+  With spec.internalTLS, the coordinator and the workers talk to each other only over HTTPS.
+  The operator generates a CA and one certificate for all the nodes, and keeps them as PEM in
+  a secret owned by the cluster along with
+    - keystore.pem, the private key followed by the certificate chain. It is the keystore
+      of the HTTPS port of the workers and the client keystore of the internal communication.
+    - truststore.pem, the CA and, when the certificate of the coordinator is provisioned by the
+      operator, the CA of that certificate.
+  The nodes authenticate each other with the shared secret of the cluster.
+  The certificate covers the coordinator names, and *.worker-discovery-<uuid> for the workers.
+  The workers of a replicaset cannot get a DNS name from the pod discovery service, as the
+  DNS records of a headless service are created only for the pods that set a hostname. So the
+  workers set the worker discovery service as their subdomain and announce their FQDN to the
+  coordinator. The worker discovery service has no selector and its endpoints are kept by the
+  operator, with the name of each worker pod as the hostname of its address. The endpoints are
+  updated when the IP of a worker pod changes.
+  The HTTP port is disabled on all the nodes. The coordinator serves HTTPS on the port of the
+  service. Unless spec.coordinator.tls is set, it uses the internal certificate.
+  The certificate is renewed in the same way as the self signed certificate of the coordinator,
+  and the secret is part of the config hash.
+*/
+
+const (
+	internalTLSVolPath = "/etc/internaltls"
+	tlsTruststoreKey   = "truststore.pem"
+)
+
+func isInternalTLSEnabled(presto *falaricav1alpha1.Presto) bool {
+	return presto.Spec.InternalTLS != nil
+}
+
+func getInternalTLSDuration(presto *falaricav1alpha1.Presto) time.Duration {
+	if presto.Spec.InternalTLS.Duration == nil {
+		return defaultTLSDuration
+	}
+	return presto.Spec.InternalTLS.Duration.Duration
+}
+
+func getInternalTLSRenewBefore(presto *falaricav1alpha1.Presto) time.Duration {
+	if presto.Spec.InternalTLS.RenewBefore == nil {
+		return defaultTLSRenewBefore
+	}
+	return presto.Spec.InternalTLS.RenewBefore.Duration
+}
+
+func validateInternalTLS(presto *falaricav1alpha1.Presto) error {
+	if !presto.Spec.Coordinator.HttpsEnabled {
+		return &OperatorError{errormsg: "internalTLS requires HTTPS to be enabled on the coordinator"}
+	}
+	if len(presto.Spec.Coordinator.HttpsKeyPairSecretName) != 0 {
+		return &OperatorError{errormsg: "internalTLS cannot be specified along with HttpsKeyPairSecretName"}
+	}
+	if getInternalTLSDuration(presto) <= getInternalTLSRenewBefore(presto) {
+		return &OperatorError{errormsg: "duration of the internalTLS certificate has to be more than renewBefore"}
+	}
+	return nil
+}
+
+// returns the DNS names of the certificate of the nodes
+func getInternalTLSDNSNames(presto *falaricav1alpha1.Presto) []string {
+	names := getCoordinatorDNSNames(presto)
+	for _, name := range getQualifiedDNSNames(getWorkerDiscoveryServiceName(presto.Status.Uuid), presto.Namespace) {
+		names = append(names, "*."+name)
+	}
+	return names
+}
+
+// returns the URI of the discovery server on the coordinator used by the workers
+func getDiscoveryURI(presto *falaricav1alpha1.Presto) string {
+	httpPort, httpsPort := getHTTPPort(presto)
+	if isInternalTLSEnabled(presto) {
+		return fmt.Sprintf("https://%s:%d", getCoordinatorInternalName(presto), httpsPort)
+	}
+	return fmt.Sprintf("http://%s:%d", getCoordinatorInternalName(presto), httpPort)
+}
+
+// returns the properties of the internal communication common to the coordinator and the workers
+func getInternalTLSProps(presto *falaricav1alpha1.Presto) map[string]string {
+	return map[string]string{
+		"http-server.http.enabled":                     "false",
+		"internal-communication.https.required":        "true",
+		"internal-communication.https.keystore.path":   internalTLSVolPath + "/" + tlsKeystoreKey,
+		"internal-communication.https.truststore.path": internalTLSVolPath + "/" + tlsTruststoreKey,
+		"discovery.uri":                                getDiscoveryURI(presto),
+	}
+}
+
+// returns the properties of the HTTPS port of a worker
+func getWorkerInternalTLSProps(presto *falaricav1alpha1.Presto) map[string]string {
+	props := getInternalTLSProps(presto)
+	props["http-server.https.enabled"] = "true"
+	props["http-server.https.port"] = fmt.Sprintf("%d", prestoPort)
+	props["http-server.https.keystore.path"] = internalTLSVolPath + "/" + tlsKeystoreKey
+	// the FQDN of the worker is in the worker discovery service
+	props["node.internal-address-source"] = "FQDN"
+	return props
+}
+
+// mounts the internal certificate on the presto container. The workers get their DNS name
+// from the worker discovery service.
+func applyInternalTLS(presto *falaricav1alpha1.Presto, podSpec *corev1.PodSpec, isCoordinator bool) {
+	secretName := getInternalTLSSecretName(presto.Status.Uuid)
+	volName := getInternalTLSVolName(presto.Status.Uuid)
+	podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+		Name: volName,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{SecretName: secretName},
+		},
+	})
+	container := &podSpec.Containers[0]
+	container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+		Name:      volName,
+		ReadOnly:  true,
+		MountPath: internalTLSVolPath,
+	})
+	if !isCoordinator {
+		podSpec.Subdomain = getWorkerDiscoveryServiceName(presto.Status.Uuid)
+	}
+}
+
+// returns the CAs trusted by the nodes. The CA of the certificate of the coordinator is
+// trusted as well when it is provisioned by the operator.
+func buildTruststore(r *ReconcilePresto, presto *falaricav1alpha1.Presto,
+	data map[string][]byte) ([]byte, error) {
+	var truststore bytes.Buffer
+	truststore.Write(data[caCertKey])
+	if !isTLSEnabled(presto) {
+		return truststore.Bytes(), nil
+	}
+	secret := &corev1.Secret{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{
+		Namespace: presto.Namespace,
+		Name:      getCoordinatorTLSSecretName(presto.Status.Uuid),
+	}, secret)
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, err
+	}
+	if ca := secret.Data[caCertKey]; len(ca) != 0 && !bytes.Equal(ca, data[caCertKey]) {
+		truststore.Write(ca)
+	}
+	return truststore.Bytes(), nil
+}
+
+// creates the headless service without a selector that gives the workers their DNS names
+// returns created, error
+func createWorkerDiscoveryService(r *ReconcilePresto, presto *falaricav1alpha1.Presto,
+	baseLabels map[string]string) (bool, error) {
+	name := getWorkerDiscoveryServiceName(presto.Status.Uuid)
+	err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: presto.Namespace, Name: name},
+		&corev1.Service{})
+	if err == nil || !errors.IsNotFound(err) {
+		return false, err
+	}
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       presto.Namespace,
+			Labels:          baseLabels,
+			OwnerReferences: []metav1.OwnerReference{*getOwnerReference(presto)},
+		},
+		Spec: corev1.ServiceSpec{
+			ClusterIP: "None",
+			Ports:     []corev1.ServicePort{{Name: "https", Port: prestoPort}},
+		},
+	}
+	return true, r.client.Create(context.TODO(), service)
+}
+
+// sets the worker pods with an IP as the addresses of the worker discovery service
+// returns updated, error
+func syncWorkerDiscoveryEndpoints(r *ReconcilePresto, presto *falaricav1alpha1.Presto,
+	baseLabels map[string]string) (bool, error) {
+	name := getWorkerDiscoveryServiceName(presto.Status.Uuid)
+	pods := &corev1.PodList{}
+	err := r.client.List(context.TODO(), pods, &client.ListOptions{
+		Namespace:     presto.Namespace,
+		LabelSelector: labels.SelectorFromSet(labels.Set{"clusterUUID": presto.Status.Uuid}),
+	})
+	if err != nil {
+		return false, err
+	}
+	var addresses []corev1.EndpointAddress
+	for _, pod := range pods.Items {
+		if pod.Spec.Subdomain != name || len(pod.Status.PodIP) == 0 {
+			continue
+		}
+		addresses = append(addresses, corev1.EndpointAddress{
+			IP:       pod.Status.PodIP,
+			Hostname: pod.Name,
+			TargetRef: &corev1.ObjectReference{
+				Kind:      "Pod",
+				Namespace: pod.Namespace,
+				Name:      pod.Name,
+				UID:       pod.UID,
+			},
+		})
+	}
+	sort.Slice(addresses, func(i, j int) bool {
+		return addresses[i].Hostname < addresses[j].Hostname
+	})
+	var subsets []corev1.EndpointSubset
+	if len(addresses) != 0 {
+		subsets = []corev1.EndpointSubset{{
+			Addresses: addresses,
+			Ports:     []corev1.EndpointPort{{Name: "https", Port: prestoPort}},
+		}}
+	}
+
+	endpoints := &corev1.Endpoints{}
+	err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: presto.Namespace, Name: name}, endpoints)
+	if errors.IsNotFound(err) {
+		endpoints = &corev1.Endpoints{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            name,
+				Namespace:       presto.Namespace,
+				Labels:          baseLabels,
+				OwnerReferences: []metav1.OwnerReference{*getOwnerReference(presto)},
+			},
+			Subsets: subsets,
+		}
+		return true, r.client.Create(context.TODO(), endpoints)
+	}
+	if err != nil {
+		return false, err
+	}
+	if reflect.DeepEqual(endpoints.Subsets, subsets) {
+		return false, nil
+	}
+	endpointsCopy := endpoints.DeepCopy()
+	endpointsCopy.Subsets = subsets
+	return true, r.client.Update(context.TODO(), endpointsCopy)
+}
+
+// generates the internal certificate if needed and updates the secret.
+// returns the action taken on the secret, the reason the certificate was generated,
+// the expiry of the certificate, error
+func syncInternalTLSSecret(r *ReconcilePresto, presto *falaricav1alpha1.Presto,
+	baseLabels map[string]string, existing *corev1.Secret) (string, string, *metav1.Time, error) {
+	if err := validateInternalTLS(presto); err != nil {
+		return "", "", nil, err
+	}
+	var current map[string][]byte
+	if existing != nil {
+		current = getCertificateData(existing)
+	}
+	data, reason, err := getSelfSignedCertificates(presto, current, getInternalTLSDNSNames(presto),
+		getInternalTLSDuration(presto), getInternalTLSRenewBefore(presto), r.clock.Now())
+	if err != nil {
+		return "", "", nil, err
+	}
+	data[tlsKeystoreKey] = buildKeystore(data)
+	if data[tlsTruststoreKey], err = buildTruststore(r, presto, data); err != nil {
+		return "", "", nil, err
+	}
+	cert, err := decodeCertificate(data[tlsCertKey])
+	if err != nil {
+		return "", "", nil, err
+	}
+	action, err := syncTLSSecret(r, presto, getInternalTLSSecretName(presto.Status.Uuid),
+		string(falaricav1alpha1.TLSSelfSigned), baseLabels, existing, data)
+	notAfter := metav1.NewTime(cert.NotAfter)
+	return action, reason, &notAfter, err
+}
+
+// removes the internal certificate and the worker discovery service
+func removeInternalTLS(r *ReconcilePresto, presto *falaricav1alpha1.Presto) (bool, error) {
+	name := getWorkerDiscoveryServiceName(presto.Status.Uuid)
+	if _, err := deleteIfExists(r, presto, name, &corev1.Service{}); err != nil {
+		return false, err
+	}
+	if _, err := deleteIfExists(r, presto, name, &corev1.Endpoints{}); err != nil {
+		return false, err
+	}
+	return deleteIfExists(r, presto, getInternalTLSSecretName(presto.Status.Uuid), &corev1.Secret{})
+}
+
+// provisions the certificate of the internal communication when spec.internalTLS is set
+// and removes it otherwise. The addresses of the worker discovery service are kept up to date.
+// returns error, changesMade
+func (r *ReconcilePresto) internalTLS(presto *falaricav1alpha1.Presto,
+	baseLabels map[string]string,
+	ctx context.Context) (error, bool) {
+	secretName := getInternalTLSSecretName(presto.Status.Uuid)
+	if !isInternalTLSEnabled(presto) {
+		if len(presto.Status.InternalTlsSecret) == 0 {
+			return nil, false
+		}
+		deleted, err := removeInternalTLS(r, presto)
+		if err != nil {
+			r.log.Error(err, "failed to delete the internal tls secret "+secretName)
+			return err, false
+		}
+		if deleted {
+			r.eventRecorder.Eventf(presto, corev1.EventTypeNormal, "Deleted",
+				"Deleted the certificate of the internal communication. %s", secretName)
+		}
+		r.updateStatus(presto, ctx, ClusterUpdateAction{internalTLS: &tlsStatus{}})
+		return nil, false
+	}
+
+	existing := &corev1.Secret{}
+	err := r.client.Get(ctx, types.NamespacedName{Namespace: presto.Namespace, Name: secretName}, existing)
+	if errors.IsNotFound(err) {
+		existing = nil
+		err = nil
+	}
+	var action, reason string
+	var notAfter *metav1.Time
+	if err == nil {
+		action, reason, notAfter, err = syncInternalTLSSecret(r, presto, baseLabels, existing)
+	}
+	serviceCreated := false
+	if err == nil {
+		serviceCreated, err = createWorkerDiscoveryService(r, presto, baseLabels)
+	}
+	if err == nil {
+		_, err = syncWorkerDiscoveryEndpoints(r, presto, baseLabels)
+	}
+	if err != nil {
+		r.log.Error(err, "failed to provision the certificate of the internal communication")
+		errorReason := fmt.Sprintf("Failed to provision the certificate of the internal communication %s",
+			err.Error())
+		r.updateStatus(presto, ctx, ClusterUpdateAction{
+			errorReason:  &errorReason,
+			clusterState: falaricav1alpha1.ClusterFailedState,
+			conditions:   failureConditions(presto, "TLSFailed", errorReason),
+		})
+		r.eventRecorder.Eventf(presto, corev1.EventTypeWarning, "Failed", "%s", errorReason)
+		return err, false
+	}
+	if len(action) != 0 {
+		r.eventRecorder.Eventf(presto, corev1.EventTypeNormal, action,
+			"%s the certificate of the internal communication %s valid till %s. %s", action, secretName,
+			notAfter.Format(time.RFC3339), reason)
+	}
+	if serviceCreated {
+		r.eventRecorder.Eventf(presto, corev1.EventTypeNormal, "Created",
+			"Created Worker Discovery Service. %s", getWorkerDiscoveryServiceName(presto.Status.Uuid))
+	}
+	if presto.Status.InternalTlsSecret != secretName || presto.Status.InternalTlsNotAfter == nil ||
+		!presto.Status.InternalTlsNotAfter.Equal(notAfter) {
+		r.updateStatus(presto, ctx, ClusterUpdateAction{
+			internalTLS: &tlsStatus{secret: secretName, notAfter: notAfter},
+		})
+	}
+	return nil, action == "Created" || serviceCreated
+}
+
+// maps a worker pod that uses the worker discovery service to its cluster
+func (r *ReconcilePresto) prestoOfWorkerPod(obj handler.MapObject) []reconcile.Request {
+	pod, ok := obj.Object.(*corev1.Pod)
+	if !ok || len(pod.Labels["clusterName"]) == 0 ||
+		!strings.HasPrefix(pod.Spec.Subdomain, workerDiscoveryServicePrefix) {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{
+		Namespace: pod.Namespace,
+		Name:      pod.Labels["clusterName"],
+	}}}
+}
@@ -0,0 +1,181 @@
+package presto
+
+import (
+	"context"
+	"fmt"
+	falaricav1alpha1 "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1"
+	"github.com/falarica/steerd-presto-operator/pkg/prestoclient"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+/*
+  A spot or preemptible node is terminated a couple of minutes after it gets a termination
+  notice, and the queries running on its workers fail. The termination handlers of the
+  cloud providers and the cluster autoscaler taint such a node, and a node being drained
+  is cordoned. The operator watches the nodes. When a node gets one of the configured
+  taints, gets cordoned, or one of the configured node conditions becomes True, the
+  clusters with workers on that node are reconciled right away.
+  Each worker on such a node is put into SHUTTING_DOWN through /v1/info/state and detached
+  from its ReplicaSet like a decommissioned worker. The ReplicaSet starts a replacement at
+  once, which the taint or the cordon keeps off the node. The detached worker is deleted by
+  the decommissioning step once it has drained or the node is gone.
+  The pods are indexed on the name of their node so that a node event is mapped only to the
+  clusters that have pods on it.
+*/
+
+const (
+	// field index of the pods on the name of their node
+	podNodeNameField = "spec.nodeName"
+)
+
+// taints put on a node that is about to be terminated
+var defaultTerminationTaints = []string{
+	// AWS node termination handler on a spot interruption notice
+	"aws-node-termination-handler/spot-itn",
+	// GKE node termination event handler
+	"cloud.google.com/impending-node-termination",
+	// cluster autoscaler before it deletes a node
+	"ToBeDeletedByClusterAutoscaler",
+}
+
+func isNodeTerminationEnabled(presto *falaricav1alpha1.Presto) bool {
+	nodeTermination := presto.Spec.Worker.NodeTermination
+	return nodeTermination != nil && (nodeTermination.Enabled == nil || *nodeTermination.Enabled)
+}
+
+// returns why the node is considered to be terminating or an empty string
+func getNodeTerminationReason(nodeTermination *falaricav1alpha1.NodeTerminationSpec,
+	node *corev1.Node) string {
+	taints := nodeTermination.Taints
+	if len(taints) == 0 {
+		taints = defaultTerminationTaints
+	}
+	for _, taint := range node.Spec.Taints {
+		for _, key := range taints {
+			if taint.Key == key {
+				return fmt.Sprintf("it has the taint %s", key)
+			}
+		}
+	}
+	if node.Spec.Unschedulable && (nodeTermination.Cordon == nil || *nodeTermination.Cordon) {
+		return "it has been cordoned"
+	}
+	for _, condition := range node.Status.Conditions {
+		for _, conditionType := range nodeTermination.Conditions {
+			if string(condition.Type) == conditionType && condition.Status == corev1.ConditionTrue {
+				return fmt.Sprintf("its condition %s is True", conditionType)
+			}
+		}
+	}
+	return ""
+}
+
+// extracts the node name of a pod for the field index
+func indexPodNodeName(obj runtime.Object) []string {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok || len(pod.Spec.NodeName) == 0 {
+		return nil
+	}
+	return []string{pod.Spec.NodeName}
+}
+
+// maps a node to the presto clusters that have pods on it
+func (r *ReconcilePresto) prestosOnNode(obj handler.MapObject) []reconcile.Request {
+	pods := &corev1.PodList{}
+	err := r.client.List(context.TODO(), pods, client.MatchingField(podNodeNameField, obj.Meta.GetName()))
+	if err != nil {
+		r.log.Error(err, "failed to list the pods on node "+obj.Meta.GetName())
+		return nil
+	}
+	prestos := make(map[types.NamespacedName]bool)
+	var requests []reconcile.Request
+	for _, pod := range pods.Items {
+		if len(pod.Labels["clusterUUID"]) == 0 || len(pod.Labels["clusterName"]) == 0 {
+			continue
+		}
+		name := types.NamespacedName{Namespace: pod.Namespace, Name: pod.Labels["clusterName"]}
+		if !prestos[name] {
+			prestos[name] = true
+			requests = append(requests, reconcile.Request{NamespacedName: name})
+		}
+	}
+	return requests
+}
+
+// returns the workers of the cluster that are not being deleted, including the workers
+// of the worker pools
+func getActiveWorkerPods(r *ReconcilePresto, presto *falaricav1alpha1.Presto) ([]corev1.Pod, error) {
+	var workerPods []corev1.Pod
+	for _, podLabel := range []func(string) (string, string){getWorkerPodLabel, getWorkerPoolPodLabel} {
+		pods, err := getPrestoPods(r, presto, podLabel)
+		if err != nil {
+			return nil, err
+		}
+		for _, pod := range pods {
+			if pod.DeletionTimestamp == nil && len(pod.Spec.NodeName) != 0 {
+				workerPods = append(workerPods, pod)
+			}
+		}
+	}
+	return workerPods, nil
+}
+
+// puts the workers on the nodes that are about to be terminated into SHUTTING_DOWN and
+// detaches them from their replicaset so that they are replaced on other nodes
+func (r *ReconcilePresto) drainTerminatingNodes(presto *falaricav1alpha1.Presto, ctx context.Context) error {
+	if !isNodeTerminationEnabled(presto) {
+		return nil
+	}
+	pods, err := getActiveWorkerPods(r, presto)
+	if err != nil {
+		r.log.Error(err, "failed to list the worker pods")
+		return err
+	}
+	nodeReasons := make(map[string]string)
+	now := r.clock.Now()
+	for i := range pods {
+		pod := &pods[i]
+		reason, ok := nodeReasons[pod.Spec.NodeName]
+		if !ok {
+			node := &corev1.Node{}
+			err = r.client.Get(ctx, types.NamespacedName{Name: pod.Spec.NodeName}, node)
+			if err != nil && !errors.IsNotFound(err) {
+				r.log.Error(err, "failed to get node "+pod.Spec.NodeName)
+				return err
+			}
+			if err == nil {
+				reason = getNodeTerminationReason(presto.Spec.Worker.NodeTermination, node)
+			}
+			nodeReasons[pod.Spec.NodeName] = reason
+		}
+		if len(reason) == 0 {
+			continue
+		}
+		if isPodReady(pod) {
+			err = r.workerClient(presto, pod).SetState(ctx, prestoclient.NodeShuttingDown)
+			if err != nil {
+				r.log.Info(fmt.Sprintf("PrestoCluster %s: cannot shut down worker %s: %s",
+					presto.Name, pod.Name, err.Error()))
+			}
+		}
+		err = detachWorkerPod(r, presto, pod, now)
+		if err != nil {
+			r.log.Error(err, "failed to detach worker pod "+pod.Name)
+			r.eventRecorder.Eventf(presto, corev1.EventTypeWarning, "Failed",
+				"Failed to drain worker %s on terminating node %s %s", pod.Name, pod.Spec.NodeName, err.Error())
+			continue
+		}
+		r.eventRecorder.Eventf(presto, corev1.EventTypeWarning, "NodeTerminating",
+			"Draining worker %s as node %s is about to be terminated: %s. A replacement is being started",
+			pod.Name, pod.Spec.NodeName, reason)
+		r.log.Info(fmt.Sprintf("PrestoCluster %s: draining worker %s on terminating node %s: %s",
+			presto.Name, pod.Name, pod.Spec.NodeName, reason))
+	}
+	return nil
+}
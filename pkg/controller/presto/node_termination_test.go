@@ -0,0 +1,201 @@
+package presto
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+
+	falaricav1alpha1 "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1"
+	"github.com/falarica/steerd-presto-operator/pkg/prestoclient"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// returns a fake worker server that records the requests. The workers are told apart by
+// the name of their pod, which prefixes the path.
+func newFakeWorkers(requests *[]string) *httptest.Server {
+	var mutex sync.Mutex
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		mutex.Lock()
+		*requests = append(*requests, req.Method+" "+req.URL.Path+" "+string(body))
+		mutex.Unlock()
+		w.Write([]byte(`"SHUTTING_DOWN"`))
+	}))
+}
+
+func newTestNode(name string, taints ...corev1.Taint) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       corev1.NodeSpec{Taints: taints},
+	}
+}
+
+func TestGetNodeTerminationReason(t *testing.T) {
+	cordon := false
+	tests := []struct {
+		name            string
+		nodeTermination falaricav1alpha1.NodeTerminationSpec
+		node            *corev1.Node
+		terminating     bool
+	}{
+		{
+			name:        "default taint",
+			node:        newTestNode("node-1", corev1.Taint{Key: "aws-node-termination-handler/spot-itn"}),
+			terminating: true,
+		},
+		{
+			name:            "configured taint",
+			nodeTermination: falaricav1alpha1.NodeTerminationSpec{Taints: []string{"example.com/reclaim"}},
+			node:            newTestNode("node-1", corev1.Taint{Key: "example.com/reclaim"}),
+			terminating:     true,
+		},
+		{
+			name:            "default taint replaced by the configured taints",
+			nodeTermination: falaricav1alpha1.NodeTerminationSpec{Taints: []string{"example.com/reclaim"}},
+			node:            newTestNode("node-1", corev1.Taint{Key: "ToBeDeletedByClusterAutoscaler"}),
+		},
+		{
+			name: "other taint",
+			node: newTestNode("node-1", corev1.Taint{Key: "dedicated"}),
+		},
+		{
+			name:        "cordoned",
+			node:        &corev1.Node{Spec: corev1.NodeSpec{Unschedulable: true}},
+			terminating: true,
+		},
+		{
+			name:            "cordon ignored",
+			nodeTermination: falaricav1alpha1.NodeTerminationSpec{Cordon: &cordon},
+			node:            &corev1.Node{Spec: corev1.NodeSpec{Unschedulable: true}},
+		},
+		{
+			name:            "condition",
+			nodeTermination: falaricav1alpha1.NodeTerminationSpec{Conditions: []string{"TerminationScheduled"}},
+			node: &corev1.Node{Status: corev1.NodeStatus{Conditions: []corev1.NodeCondition{
+				{Type: "TerminationScheduled", Status: corev1.ConditionTrue}}}},
+			terminating: true,
+		},
+		{
+			name:            "condition not true",
+			nodeTermination: falaricav1alpha1.NodeTerminationSpec{Conditions: []string{"TerminationScheduled"}},
+			node: &corev1.Node{Status: corev1.NodeStatus{Conditions: []corev1.NodeCondition{
+				{Type: "TerminationScheduled", Status: corev1.ConditionFalse}}}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reason := getNodeTerminationReason(&test.nodeTermination, test.node)
+			if (len(reason) != 0) != test.terminating {
+				t.Errorf("unexpected reason %q", reason)
+			}
+		})
+	}
+}
+
+func TestDrainTerminatingNodes(t *testing.T) {
+	var requests []string
+	server := newFakeWorkers(&requests)
+	defer server.Close()
+
+	presto := newTestPresto()
+	presto.Spec.Worker.NodeTermination = &falaricav1alpha1.NodeTerminationSpec{}
+	objs := []runtime.Object{presto,
+		newTestNode("node-1", corev1.Taint{Key: "aws-node-termination-handler/spot-itn",
+			Effect: corev1.TaintEffectNoSchedule}),
+		newTestNode("node-2"),
+		newTestPod("worker-0", getWorkerPodLabel, "node-1", true),
+		newTestPod("worker-1", getWorkerPodLabel, "node-2", true),
+		newTestPod("worker-2", getWorkerPodLabel, "node-1", false),
+		newTestPod("pool-worker-0", getWorkerPoolPodLabel, "node-1", true),
+		newTestPod("pool-worker-1", getWorkerPoolPodLabel, "node-2", true),
+	}
+	r := newTestReconciler(t, objs...)
+	r.workerClient = func(presto *falaricav1alpha1.Presto, pod *corev1.Pod) *prestoclient.Client {
+		return prestoclient.New(server.URL+"/"+pod.Name, nil)
+	}
+
+	if err := r.drainTerminatingNodes(presto, context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the ready workers on the tainted node are shut down. The worker that is not ready
+	// is detached without a request.
+	sort.Strings(requests)
+	expected := []string{
+		`PUT /pool-worker-0/v1/info/state "SHUTTING_DOWN"`,
+		`PUT /worker-0/v1/info/state "SHUTTING_DOWN"`,
+	}
+	if len(requests) != len(expected) || requests[0] != expected[0] || requests[1] != expected[1] {
+		t.Errorf("unexpected requests %v, expected %v", requests, expected)
+	}
+
+	dk, dv := getDecommissioningPodLabel(testClusterUUID)
+	wk, _ := getWorkerPodLabel(testClusterUUID)
+	pk, _ := getWorkerPoolPodLabel(testClusterUUID)
+	for name, detached := range map[string]bool{
+		"worker-0": true, "worker-1": false, "worker-2": true, "pool-worker-0": true, "pool-worker-1": false,
+	} {
+		pod := &corev1.Pod{}
+		err := r.client.Get(context.Background(), types.NamespacedName{Namespace: testNamespace, Name: name}, pod)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, worker := pod.Labels[wk]
+		_, poolWorker := pod.Labels[pk]
+		_, started := pod.Annotations[decommissionStartAnnotation]
+		if detached && (pod.Labels[dk] != dv || worker || poolWorker || !started) {
+			t.Errorf("pod %s on the terminating node was not detached %v %v", name, pod.Labels, pod.Annotations)
+		}
+		if !detached && (pod.Labels[dk] == dv || !(worker || poolWorker) || started) {
+			t.Errorf("pod %s on another node was changed %v %v", name, pod.Labels, pod.Annotations)
+		}
+	}
+	if events := getTestEvents(r); len(events) != 3 || !hasEvent(events, "Warning NodeTerminating") {
+		t.Errorf("unexpected events %v", events)
+	}
+
+	// the detached workers are not drained again
+	requests = nil
+	if err := r.drainTerminatingNodes(presto, context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 0 {
+		t.Errorf("the workers were drained again %v", requests)
+	}
+}
+
+func TestDrainTerminatingNodesDisabled(t *testing.T) {
+	var requests []string
+	server := newFakeWorkers(&requests)
+	defer server.Close()
+
+	presto := newTestPresto()
+	objs := []runtime.Object{presto,
+		newTestNode("node-1", corev1.Taint{Key: "aws-node-termination-handler/spot-itn"}),
+		newTestPod("worker-0", getWorkerPodLabel, "node-1", true),
+	}
+	r := newTestReconciler(t, objs...)
+	r.workerClient = func(presto *falaricav1alpha1.Presto, pod *corev1.Pod) *prestoclient.Client {
+		return prestoclient.New(server.URL+"/"+pod.Name, nil)
+	}
+
+	if err := r.drainTerminatingNodes(presto, context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	pod := &corev1.Pod{}
+	err := r.client.Get(context.Background(), types.NamespacedName{Namespace: testNamespace, Name: "worker-0"}, pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wk, wv := getWorkerPodLabel(testClusterUUID)
+	if len(requests) != 0 || pod.Labels[wk] != wv {
+		t.Errorf("the worker was drained without nodeTermination %v %v", requests, pod.Labels)
+	}
+}
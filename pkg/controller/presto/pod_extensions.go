@@ -0,0 +1,115 @@
+package presto
+
+import (
+	"fmt"
+	falaricav1alpha1 "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+/*
+  Environment variables, e.g. credentials of an object store, proxy settings or
+  JAVA_TOOL_OPTIONS, and additional containers can be added to the presto pods, either for
+  a role in spec.coordinator and spec.worker or for both in spec.common.
+  The environment variables of spec.common come first, and a variable of the role replaces
+  the one of the same name in spec.common. The envFrom sources, the init containers and the
+  sidecars of spec.common come before those of the role. The sidecars follow the presto
+  container, which stays the first container of the pod.
+  The extensions are merged after the pod spec has been generated, so that they can be
+  validated against it. A container cannot take the name of the presto container or of
+  another container, a variable cannot replace one set by the operator, the additional
+  volumes cannot take the name of a volume of the operator, and a volume mount of a
+  container has to refer to a volume of the pod.
+*/
+
+func getPodExtensions(presto *falaricav1alpha1.Presto, isCoordinator bool) falaricav1alpha1.PodExtensionsSpec {
+	if isCoordinator {
+		return presto.Spec.Coordinator.PodExtensionsSpec
+	}
+	return presto.Spec.Worker.PodExtensionsSpec
+}
+
+// returns the variables of spec.common with those of the role merged in
+func mergeEnv(common []corev1.EnvVar, role []corev1.EnvVar) []corev1.EnvVar {
+	roleEnv := make(map[string]bool)
+	for _, env := range role {
+		roleEnv[env.Name] = true
+	}
+	var merged []corev1.EnvVar
+	for _, env := range common {
+		if !roleEnv[env.Name] {
+			merged = append(merged, env)
+		}
+	}
+	return append(merged, role...)
+}
+
+func validateContainerVolumeMounts(container *corev1.Container, volumes map[string]bool) error {
+	for _, mount := range container.VolumeMounts {
+		if !volumes[mount.Name] {
+			return &OperatorError{fmt.Sprintf("volume %s mounted by container %s is not a volume of the pod",
+				mount.Name, container.Name)}
+		}
+	}
+	return nil
+}
+
+// merges the environment variables and the containers of spec.common and of the role
+// into the generated pod spec
+func applyPodExtensions(presto *falaricav1alpha1.Presto, podSpec *corev1.PodSpec, isCoordinator bool) error {
+	role := getPodExtensions(presto, isCoordinator)
+	common := falaricav1alpha1.PodExtensionsSpec{}
+	if presto.Spec.Common != nil {
+		common = *presto.Spec.Common.DeepCopy()
+	}
+	role = *role.DeepCopy()
+
+	volumes := make(map[string]bool)
+	additionalVolumes := make(map[string]bool)
+	for _, volume := range presto.Spec.Volumes {
+		additionalVolumes[volume.Name] = true
+	}
+	for _, volume := range podSpec.Volumes {
+		if volumes[volume.Name] && additionalVolumes[volume.Name] {
+			return &OperatorError{fmt.Sprintf("additional volume %s has the name of a volume "+
+				"created by the operator", volume.Name)}
+		}
+		volumes[volume.Name] = true
+	}
+
+	prestoContainer := &podSpec.Containers[0]
+	generatedEnv := make(map[string]bool)
+	for _, env := range prestoContainer.Env {
+		generatedEnv[env.Name] = true
+	}
+	env := mergeEnv(common.Env, role.Env)
+	for _, e := range env {
+		if generatedEnv[e.Name] {
+			return &OperatorError{fmt.Sprintf("environment variable %s is set by the operator", e.Name)}
+		}
+	}
+	prestoContainer.Env = append(prestoContainer.Env, env...)
+	prestoContainer.EnvFrom = append(prestoContainer.EnvFrom, append(common.EnvFrom, role.EnvFrom...)...)
+
+	containerNames := map[string]bool{prestoContainer.Name: true}
+	initContainers := append(common.InitContainers, role.InitContainers...)
+	sidecars := append(common.Sidecars, role.Sidecars...)
+	for _, containers := range [][]corev1.Container{initContainers, sidecars} {
+		for i := range containers {
+			container := &containers[i]
+			if containerNames[container.Name] {
+				if container.Name == prestoContainer.Name {
+					return &OperatorError{fmt.Sprintf("container %s has the name of the presto container",
+						container.Name)}
+				}
+				return &OperatorError{fmt.Sprintf("container %s is specified more than once", container.Name)}
+			}
+			containerNames[container.Name] = true
+			if err := validateContainerVolumeMounts(container, volumes); err != nil {
+				return err
+			}
+		}
+	}
+	podSpec.InitContainers = append(podSpec.InitContainers, initContainers...)
+	podSpec.Containers = append(podSpec.Containers, sidecars...)
+	return nil
+}
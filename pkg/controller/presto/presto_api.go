@@ -0,0 +1,83 @@
+package presto
+
+import (
+	"context"
+	"fmt"
+	falaricav1alpha1 "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1"
+	"github.com/falarica/steerd-presto-operator/pkg/prestoclient"
+	corev1 "k8s.io/api/core/v1"
+	"time"
+)
+
+// timeout for each call to the REST API of the coordinator
+const prestoAPITimeout = 5 * time.Second
+
+// state of the cluster as reported by the coordinator
+type prestoState struct {
+	info        *prestoclient.ServerInfo
+	activeNodes int32
+	failedNodes int32
+	stats       *prestoclient.ClusterStats
+}
+
+// returns the client for the REST API of the coordinator. The coordinator is reached
+// through the coordinator service, on the HTTPS port if HTTPS is enabled. With password
+// authentication, the HTTP port is used as Presto authenticates only the HTTPS requests.
+// The coordinator service does not have the HTTP port then, so the coordinator pod is
+// reached on its name in the pod discovery service, like the workers do.
+func newPrestoClient(presto *falaricav1alpha1.Presto) *prestoclient.Client {
+	httpPort, httpsPort := getHTTPPort(presto)
+	host := fmt.Sprintf("%s.%s.svc", getExternalServiceName(presto.Status.Uuid), presto.Namespace)
+	if presto.Spec.Coordinator.HttpsEnabled {
+		if isPasswordAuthEnabled(presto) {
+			return prestoclient.New(fmt.Sprintf("http://%s.%s.svc:%d", getCoordinatorInternalName(presto),
+				presto.Namespace, httpPort), prestoclient.NewHTTPClient(prestoAPITimeout, false))
+		}
+		return prestoclient.New(fmt.Sprintf("https://%s:%d", host, httpsPort),
+			prestoclient.NewHTTPClient(prestoAPITimeout, true))
+	}
+	return prestoclient.New(fmt.Sprintf("http://%s:%d", host, httpPort),
+		prestoclient.NewHTTPClient(prestoAPITimeout, false))
+}
+
+// returns the client for the REST API of a worker. The worker is reached directly on
+// the IP of its pod. Workers listen on the HTTP port, or on HTTPS with internal TLS.
+func newWorkerClient(presto *falaricav1alpha1.Presto, pod *corev1.Pod) *prestoclient.Client {
+	if isInternalTLSEnabled(presto) {
+		return prestoclient.New(fmt.Sprintf("https://%s:%d", pod.Status.PodIP, prestoPort),
+			prestoclient.NewHTTPClient(prestoAPITimeout, true))
+	}
+	return prestoclient.New(fmt.Sprintf("http://%s:%d", pod.Status.PodIP, prestoPort),
+		prestoclient.NewHTTPClient(prestoAPITimeout, false))
+}
+
+// queries /v1/info, /v1/node, /v1/node/failed and /v1/cluster of the coordinator
+func (r *ReconcilePresto) getPrestoState(presto *falaricav1alpha1.Presto) (*prestoState, error) {
+	client := r.prestoClient(presto)
+	ctx := context.Background()
+	info, err := client.Info(ctx)
+	if err != nil {
+		return nil, err
+	}
+	state := &prestoState{info: info}
+	if info.Starting {
+		// nodes and stats are not of use till the server has started
+		return state, nil
+	}
+	nodes, err := client.Nodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	failedNodes, err := client.FailedNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := client.Cluster(ctx)
+	if err != nil {
+		return nil, err
+	}
+	state.activeNodes = int32(len(nodes))
+	state.failedNodes = int32(len(failedNodes))
+	state.stats = stats
+	return state, nil
+}
@@ -4,15 +4,19 @@ import (
 	"context"
 	"fmt"
 	falaricav1alpha1 "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1"
+	"github.com/falarica/steerd-presto-operator/pkg/prestoclient"
 	"github.com/go-logr/logr"
 	"github.com/google/uuid"
 	v1 "k8s.io/api/apps/v1"
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
 	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/clock"
 	"k8s.io/client-go/tools/record"
 	metrics "k8s.io/metrics/pkg/client/clientset/versioned/typed/metrics/v1beta1"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -60,6 +64,11 @@ func newReconciler(mgr manager.Manager, metricsClient *metrics.MetricsV1beta1Cli
 		eventRecorder:        mgr.GetEventRecorderFor(ControllerName),
 		periodicPrestoEvents: periodicPrestoEventChannel,
 		registeredPrestos:    new(sync.Map),
+		prestoClient:         newPrestoClient,
+		workerClient:         newWorkerClient,
+		clock:                clock.RealClock{},
+		workerRecommendations: new(sync.Map),
+		deferredWorkers:       new(sync.Map),
 	}
 	// a goroutine that sends periodic events for the registered prestos to a channel
 	// that channel is being watched by the controlller.
@@ -124,6 +133,62 @@ func add(mgr manager.Manager, r ReconcilePresto) error {
 		return err
 	}
 
+	err = c.Watch(&source.Kind{Type: &v1.Deployment{}}, &handler.EnqueueRequestForOwner{
+		IsController: true,
+		OwnerType:    &falaricav1alpha1.Presto{},
+	})
+	if err != nil {
+		return err
+	}
+
+	err = c.Watch(&source.Kind{Type: &v1.StatefulSet{}}, &handler.EnqueueRequestForOwner{
+		IsController: true,
+		OwnerType:    &falaricav1alpha1.Presto{},
+	})
+	if err != nil {
+		return err
+	}
+
+	err = c.Watch(&source.Kind{Type: &policyv1beta1.PodDisruptionBudget{}}, &handler.EnqueueRequestForOwner{
+		IsController: true,
+		OwnerType:    &falaricav1alpha1.Presto{},
+	})
+	if err != nil {
+		return err
+	}
+
+	// reconcile the clusters with pods on a node that is about to be terminated
+	err = mgr.GetFieldIndexer().IndexField(&corev1.Pod{}, podNodeNameField, indexPodNodeName)
+	if err != nil {
+		return err
+	}
+	err = c.Watch(&source.Kind{Type: &corev1.Node{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(r.prestosOnNode),
+	}, NodeTerminationPredicate{})
+	if err != nil {
+		return err
+	}
+
+	// keep the addresses of the worker discovery service up to date
+	err = c.Watch(&source.Kind{Type: &corev1.Pod{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(r.prestoOfWorkerPod),
+	}, PodIPPredicate{})
+	if err != nil {
+		return err
+	}
+
+	// a change of the users secret of password authentication regenerates the password file
+	err = mgr.GetFieldIndexer().IndexField(&falaricav1alpha1.Presto{}, usersSecretField, indexUsersSecret)
+	if err != nil {
+		return err
+	}
+	err = c.Watch(&source.Kind{Type: &corev1.Secret{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(r.prestosOfUsersSecret),
+	})
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -141,6 +206,15 @@ type ReconcilePresto struct {
 	eventRecorder        record.EventRecorder
 	periodicPrestoEvents chan event.GenericEvent
 	registeredPrestos    *sync.Map
+	// returns the client for the REST API of the coordinator
+	prestoClient         func(presto *falaricav1alpha1.Presto) *prestoclient.Client
+	// returns the client for the REST API of a worker
+	workerClient         func(presto *falaricav1alpha1.Presto, pod *corev1.Pod) *prestoclient.Client
+	clock                clock.Clock
+	// desired number of workers computed by the query load autoscaling for each presto
+	workerRecommendations *sync.Map
+	// number of workers of the last scaling deferred by the query load autoscaling for each presto
+	deferredWorkers *sync.Map
 }
 
 // Reconcile reads that state of the cluster for a Presto object and makes changes based on the state read
@@ -208,6 +282,42 @@ func (r *ReconcilePresto) Reconcile(request reconcile.Request) (reconcile.Result
 		return reconcile.Result{}, nil
 	}
 
+	// the shared secret is created before the pods that read it
+	err, changesMade = r.sharedSecret(presto, baseLabels, ctx)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if changesMade {
+		return reconcile.Result{}, nil
+	}
+
+	// the certificate is provisioned before the coordinator that reads it
+	err, changesMade = r.coordinatorTLS(presto, baseLabels, ctx)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if changesMade {
+		return reconcile.Result{}, nil
+	}
+
+	// the internal truststore has the CA of the certificate of the coordinator
+	err, changesMade = r.internalTLS(presto, baseLabels, ctx)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if changesMade {
+		return reconcile.Result{}, nil
+	}
+
+	// the password file is created before the coordinator that mounts it
+	err, changesMade = r.passwordFile(presto, baseLabels, ctx)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if changesMade {
+		return reconcile.Result{}, nil
+	}
+
 	err, changesMade = r.coordinatorConfig(presto, baseLabels, ctx)
 	if err != nil {
 		return reconcile.Result{}, err
@@ -232,7 +342,17 @@ func (r *ReconcilePresto) Reconcile(request reconcile.Request) (reconcile.Result
 		return reconcile.Result{}, nil
 	}
 
-	err, changesMade = r.coordinatorReplicaset(presto, baseLabels, ctx)
+	// the configuration is kept up to date while the cluster is hibernated
+	err, hibernated := r.hibernation(presto, baseLabels, ctx)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if hibernated {
+		return reconcile.Result{}, nil
+	}
+
+	// the metastore is started before the coordinator
+	err, changesMade = r.hiveMetastore(presto, ctx)
 	if err != nil {
 		return reconcile.Result{}, err
 	}
@@ -240,7 +360,69 @@ func (r *ReconcilePresto) Reconcile(request reconcile.Request) (reconcile.Result
 		return reconcile.Result{}, nil
 	}
 
-	err, changesMade, workerReplicaSet := r.workerReplicaset(presto, baseLabels, ctx)
+	if isCoordinatorStatefulSetEnabled(presto) {
+		err, changesMade = r.coordinatorStatefulSet(presto, baseLabels, ctx)
+	} else {
+		err, changesMade = r.coordinatorReplicaset(presto, baseLabels, ctx)
+	}
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if changesMade {
+		return reconcile.Result{}, nil
+	}
+
+	// the active scaling schedule overrides the worker count and the autoscaling bounds
+	err = r.scalingSchedule(presto, ctx)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	// drain the workers on the nodes that are about to be terminated. They are replaced
+	// by the worker steps.
+	err = r.drainTerminatingNodes(presto, ctx)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	var workerReplicaSet *v1.ReplicaSet
+	var workerDeployment *v1.Deployment
+	if isWorkerDeploymentEnabled(presto) {
+		err, changesMade, workerDeployment = r.workerDeployment(presto, baseLabels, ctx)
+	} else {
+		err, changesMade, workerReplicaSet = r.workerReplicaset(presto, baseLabels, ctx)
+	}
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if changesMade {
+		return reconcile.Result{}, nil
+	}
+
+	err, changesMade, workerPoolReplicaSets := r.workerPools(presto, baseLabels, ctx)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if changesMade {
+		return reconcile.Result{}, nil
+	}
+
+	// delete the workers removed on a scale down once they have drained
+	err = r.removeDecommissionedWorkers(presto, ctx)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	err, changesMade = r.hpaReplicaset(presto, baseLabels, ctx,
+		getWorkerScaleTarget(workerReplicaSet, workerDeployment))
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if changesMade {
+		return reconcile.Result{}, nil
+	}
+
+	err, changesMade = r.queryLoadAutoscale(presto, ctx, workerReplicaSet, workerDeployment)
 	if err != nil {
 		return reconcile.Result{}, err
 	}
@@ -248,7 +430,7 @@ func (r *ReconcilePresto) Reconcile(request reconcile.Request) (reconcile.Result
 		return reconcile.Result{}, nil
 	}
 
-	err, changesMade = r.hpaReplicaset(presto, baseLabels, ctx, workerReplicaSet)
+	err, changesMade = r.disruptionBudgets(presto, baseLabels, ctx)
 	if err != nil {
 		return reconcile.Result{}, err
 	}
@@ -256,25 +438,51 @@ func (r *ReconcilePresto) Reconcile(request reconcile.Request) (reconcile.Result
 		return reconcile.Result{}, nil
 	}
 
+	// restart the pods if the configuration has changed. The state of the rollout
+	// is rechecked on the periodic events.
+	err, rolloutInProgress := r.configRollout(presto, baseLabels, ctx)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	// all the steps have succeeded. Clear the error of an earlier failure.
+	noError := ""
+	conditions, prestoState := r.clusterConditions(presto, workerReplicaSet, workerDeployment,
+		workerPoolReplicaSets, !rolloutInProgress)
+
 	// Update the state based on coordinator pod phase
 	_, coordinatorPodPhase := r.getCoordinatorPodPhase(presto, baseLabels)
 	if coordinatorPodPhase == corev1.PodPending {
 		r.updateStatus(presto, ctx,ClusterUpdateAction{
 			clusterState: falaricav1alpha1.ClusterPending,
 			workerReplicaSet: workerReplicaSet,
+			workerDeployment: workerDeployment,
+			errorReason: &noError,
+			conditions: conditions,
 		})
 	} else if coordinatorPodPhase == corev1.PodFailed {
 		r.updateStatus(presto, ctx,ClusterUpdateAction{
 			clusterState: falaricav1alpha1.ClusterFailedState,
 			workerReplicaSet: workerReplicaSet,
+			workerDeployment: workerDeployment,
+			errorReason: &noError,
+			conditions: conditions,
 		})
 	}else if coordinatorPodPhase == corev1.PodRunning {
 		workerCPU := fmt.Sprintf("%d%%",r.getCPUUsage(presto, false))
 		coordinatorCPU := fmt.Sprintf("%d%%",r.getCPUUsage(presto, true))
+		// the presto server may still be starting even if the pod is running
+		clusterState := falaricav1alpha1.ClusterPending
+		if isConditionTrue(conditions, falaricav1alpha1.ConditionCoordinatorReady) {
+			clusterState = falaricav1alpha1.ClusterReadyState
+		}
 
 		r.updateStatus(presto, ctx,ClusterUpdateAction{
-			clusterState: falaricav1alpha1.ClusterReadyState,
+			clusterState: clusterState,
 			workerReplicaSet: workerReplicaSet,
+			workerDeployment: workerDeployment,
+			errorReason: &noError,
+			conditions: conditions,
+			prestoState: prestoState,
 			workerCPUUsage: &workerCPU,
 			coordinatorCPUUsage: &coordinatorCPU,
 		})
@@ -282,6 +490,9 @@ func (r *ReconcilePresto) Reconcile(request reconcile.Request) (reconcile.Result
 		r.updateStatus(presto, ctx,ClusterUpdateAction{
 			clusterState: falaricav1alpha1.ClusterUnknown,
 			workerReplicaSet: workerReplicaSet,
+			workerDeployment: workerDeployment,
+			errorReason: &noError,
+			conditions: conditions,
 		})
 	}
 	return ctrl.Result{}, nil
@@ -300,6 +511,7 @@ func (r *ReconcilePresto) headlessServiceConfig(presto *falaricav1alpha1.Presto,
 		r.updateStatus(presto, ctx,ClusterUpdateAction{
 			errorReason: &errorReason,
 			clusterState: falaricav1alpha1.ClusterFailedState,
+			conditions: failureConditions(presto, "ServiceFailed", errorReason),
 		})
 		return err, changesMade
 	} else {
@@ -313,7 +525,7 @@ func (r *ReconcilePresto) headlessServiceConfig(presto *falaricav1alpha1.Presto,
 			headlessSvc := getPodDiscoveryServiceName(presto.Status.Uuid)
 			r.updateStatus(presto, ctx,ClusterUpdateAction{
 				headlessService: &headlessSvc,
-				clusterState: falaricav1alpha1.ClusterPending,
+				clusterState: getPendingState(presto),
 			})
 		}
 	}
@@ -333,6 +545,7 @@ func (r *ReconcilePresto) serviceConfig(presto *falaricav1alpha1.Presto,
 		r.updateStatus(presto, ctx,ClusterUpdateAction{
 			errorReason: &errorReason,
 			clusterState: falaricav1alpha1.ClusterFailedState,
+			conditions: failureConditions(presto, "ServiceFailed", errorReason),
 		})
 		return err, changesMade, nil
 	} else {
@@ -345,7 +558,7 @@ func (r *ReconcilePresto) serviceConfig(presto *falaricav1alpha1.Presto,
 		if created || len(presto.Status.CoordinatorAddress) == 0 {
 			r.updateStatus(presto, ctx,ClusterUpdateAction{
 				service: service,
-				clusterState: falaricav1alpha1.ClusterPending,
+				clusterState: getPendingState(presto),
 			})
 		}
 	}
@@ -355,40 +568,62 @@ func (r *ReconcilePresto) serviceConfig(presto *falaricav1alpha1.Presto,
 func (r *ReconcilePresto) coordinatorConfig(presto *falaricav1alpha1.Presto,
 	baseLabels map[string]string,
 	ctx context.Context) (error, bool) {
-	created, err := createCoordinatorConfig(presto, r.client, baseLabels)
+	created, updated, err := createCoordinatorConfig(presto, r.client, baseLabels)
 	if err != nil {
 		r.log.Error(err, "failed to create coordinator config map")
 		errorReason := fmt.Sprintf("Failed to create coordinator config map %s", err.Error())
 		r.updateStatus(presto, ctx,ClusterUpdateAction{
 			errorReason: &errorReason,
 			clusterState: falaricav1alpha1.ClusterFailedState,
+			conditions: failureConditions(presto, "ConfigMapFailed", errorReason, falaricav1alpha1.ConditionConfigApplied),
 		})
 		r.eventRecorder.Eventf(presto, corev1.EventTypeWarning, "Failed",
 			"failed to create coordinator config map %s", err.Error())
 		return err, false
 	}
+	if (created || updated) && presto.Spec.Coordinator.HttpsEnabled &&
+		len(presto.Spec.Coordinator.HttpsKeyPairPassword) != 0 {
+		r.eventRecorder.Eventf(presto, corev1.EventTypeWarning, "Deprecated",
+			"httpsKeyPairPassword is deprecated as it is written to the config map. " +
+				"Use httpsKeyPairPasswordSecret")
+	}
 	if created {
 		cm := getCoordinatorConfigMapName(presto.Status.Uuid)
 		r.updateStatus(presto, ctx,ClusterUpdateAction{
 			coordinatorConfMap: &cm,
-			clusterState: falaricav1alpha1.ClusterPending,
+			clusterState: getPendingState(presto),
 		})
 		r.eventRecorder.Eventf(presto, corev1.EventTypeNormal, "Created",
 			"Created Coordinator Config. %s", cm)
 		r.log.Info("created coordinator config map")
 	}
-	return nil, created
+	if updated {
+		cm := getCoordinatorConfigMapName(presto.Status.Uuid)
+		r.updateStatus(presto, ctx,ClusterUpdateAction{
+			coordinatorConfMap: &cm,
+			clusterState: getPendingState(presto),
+			conditions: []falaricav1alpha1.PrestoCondition{
+				newCondition(presto, falaricav1alpha1.ConditionConfigApplied, corev1.ConditionFalse,
+					"ConfigChanged", fmt.Sprintf("Coordinator config %s has changed", cm)),
+			},
+		})
+		r.eventRecorder.Eventf(presto, corev1.EventTypeNormal, "Updated",
+			"Updated Coordinator Config. %s", cm)
+		r.log.Info("updated coordinator config map")
+	}
+	return nil, created || updated
 }
 func (r *ReconcilePresto) workerConfig(presto *falaricav1alpha1.Presto,
 	baseLabels map[string]string,
 	ctx context.Context) (error, bool) {
-	created, err := createWorkerConfig(presto, r.client, baseLabels)
+	created, updated, err := createWorkerConfig(presto, r.client, baseLabels)
 	if err != nil {
 		r.log.Error(err, "failed to create worker config map")
 		errorReason := fmt.Sprintf("Failed to create worker config map %s", err.Error())
 		r.updateStatus(presto, ctx,ClusterUpdateAction{
 			errorReason: &errorReason,
 			clusterState: falaricav1alpha1.ClusterFailedState,
+			conditions: failureConditions(presto, "ConfigMapFailed", errorReason, falaricav1alpha1.ConditionConfigApplied),
 		})
 		r.eventRecorder.Eventf(presto, corev1.EventTypeWarning, "Failed",
 			"Failed to create worker config map %s", err.Error())
@@ -398,24 +633,52 @@ func (r *ReconcilePresto) workerConfig(presto *falaricav1alpha1.Presto,
 		wm := getWorkerConfigMapName(presto.Status.Uuid)
 		r.updateStatus(presto, ctx,ClusterUpdateAction{
 			workerConfMap: &wm,
-			clusterState: falaricav1alpha1.ClusterPending,
+			clusterState: getPendingState(presto),
 		})
 		r.eventRecorder.Eventf(presto, corev1.EventTypeNormal, "Created",
 			"Created Worker Config. %s", wm)
 		r.log.Info("created worker config map")
 	}
-	return nil, created
+	if updated {
+		wm := getWorkerConfigMapName(presto.Status.Uuid)
+		r.updateStatus(presto, ctx,ClusterUpdateAction{
+			workerConfMap: &wm,
+			clusterState: getPendingState(presto),
+			conditions: []falaricav1alpha1.PrestoCondition{
+				newCondition(presto, falaricav1alpha1.ConditionConfigApplied, corev1.ConditionFalse,
+					"ConfigChanged", fmt.Sprintf("Worker config %s has changed", wm)),
+			},
+		})
+		r.eventRecorder.Eventf(presto, corev1.EventTypeNormal, "Updated",
+			"Updated Worker Config. %s", wm)
+		r.log.Info("updated worker config map")
+	}
+	return nil, created || updated
 }
 func (r *ReconcilePresto) catalogConfig(presto *falaricav1alpha1.Presto,
 	baseLabels map[string]string,
 	ctx context.Context) (error, bool) {
-	created, err := createCatalogConfig(presto, r, baseLabels)
+	err := validateCatalogs(presto, r)
+	if err != nil {
+		r.log.Error(err, "invalid catalogs")
+		errorReason := fmt.Sprintf("Invalid catalogs %s", err.Error())
+		r.updateStatus(presto, ctx,ClusterUpdateAction{
+			errorReason: &errorReason,
+			clusterState: falaricav1alpha1.ClusterFailedState,
+			conditions: failureConditions(presto, "InvalidCatalogs", errorReason, falaricav1alpha1.ConditionCatalogsValid),
+		})
+		r.eventRecorder.Eventf(presto, corev1.EventTypeWarning, "Failed",
+			"Invalid catalogs %s", err.Error())
+		return err, false
+	}
+	created, updated, err := createCatalogConfig(presto, r, baseLabels)
 	if err != nil {
 		r.log.Error(err, "failed to create catalog config map")
 		errorReason := fmt.Sprintf("Failed to create catalog config map %s", err.Error())
 		r.updateStatus(presto, ctx,ClusterUpdateAction{
 			errorReason: &errorReason,
 			clusterState: falaricav1alpha1.ClusterFailedState,
+			conditions: failureConditions(presto, "ConfigMapFailed", errorReason, falaricav1alpha1.ConditionConfigApplied),
 		})
 		r.eventRecorder.Eventf(presto, corev1.EventTypeWarning, "Failed",
 			"Failed to create catalog config map %s", err.Error())
@@ -425,19 +688,58 @@ func (r *ReconcilePresto) catalogConfig(presto *falaricav1alpha1.Presto,
 		cc := getCatalogConfigMapName(presto.Status.Uuid)
 		r.updateStatus(presto, ctx,ClusterUpdateAction{
 			catalogConfMap: &cc,
-			clusterState: falaricav1alpha1.ClusterPending,
+			clusterState: getPendingState(presto),
 		})
 		r.eventRecorder.Eventf(presto, corev1.EventTypeNormal, "Created",
 			"Created Catalog Config. %s", cc)
 		r.log.Info("catalog catalog config map")
 		created = true
 	}
-	return nil, created
+	if updated {
+		cc := getCatalogConfigMapName(presto.Status.Uuid)
+		r.updateStatus(presto, ctx,ClusterUpdateAction{
+			catalogConfMap: &cc,
+			clusterState: getPendingState(presto),
+			conditions: []falaricav1alpha1.PrestoCondition{
+				newCondition(presto, falaricav1alpha1.ConditionConfigApplied, corev1.ConditionFalse,
+					"ConfigChanged", fmt.Sprintf("Catalog config %s has changed", cc)),
+			},
+		})
+		r.eventRecorder.Eventf(presto, corev1.EventTypeNormal, "Updated",
+			"Updated Catalog Config. %s", cc)
+		r.log.Info("updated catalog config map")
+	}
+	r.updateStatus(presto, ctx,ClusterUpdateAction{
+		conditions: []falaricav1alpha1.PrestoCondition{
+			newCondition(presto, falaricav1alpha1.ConditionCatalogsValid, corev1.ConditionTrue,
+				"CatalogsValid", "All the catalogs are valid"),
+		},
+	})
+	return nil, created || updated
 }
 func (r *ReconcilePresto) coordinatorReplicaset(presto *falaricav1alpha1.Presto,
 	baseLabels map[string]string,
 	ctx context.Context) (error, bool) {
-	_, created, err := createUpdateReplicaSetForCoordinator(r, presto,
+	// there can be only one coordinator. Remove the statefulset before creating the replicaset.
+	deleted, err := removeCoordinatorStatefulSet(r, presto)
+	if err != nil {
+		r.log.Error(err, "failed to delete coordinator statefulset")
+		r.eventRecorder.Eventf(presto, corev1.EventTypeWarning, "Failed",
+			"Failed to delete coordinator statefulset %s", err.Error())
+		return err, false
+	}
+	if deleted {
+		statefulSetName := ""
+		r.log.Info("deleted coordinator statefulset to run the coordinator as replicaset")
+		r.updateStatus(presto, ctx,ClusterUpdateAction{
+			coordinatorStatefulSetName: &statefulSetName,
+			clusterState: falaricav1alpha1.ClusterPending,
+		})
+		r.eventRecorder.Eventf(presto, corev1.EventTypeNormal, "Deleted",
+			"Deleted Coordinator StatefulSet. %s", getCoordinatorStatefulSetName(presto.Status.Uuid))
+		return nil, true
+	}
+	replicaSet, created, updated, err := createUpdateReplicaSetForCoordinator(r, presto,
 		getCoordinatorPodLabels(baseLabels, presto.Status.Uuid))
 	if err != nil {
 		r.log.Error(err, "failed to create/update coordinator replicaset ")
@@ -445,6 +747,7 @@ func (r *ReconcilePresto) coordinatorReplicaset(presto *falaricav1alpha1.Presto,
 		r.updateStatus(presto, ctx,ClusterUpdateAction{
 			errorReason: &errorReason,
 			clusterState: falaricav1alpha1.ClusterFailedState,
+			conditions: failureConditions(presto, "CoordinatorFailed", errorReason, falaricav1alpha1.ConditionCoordinatorReady),
 		})
 		r.eventRecorder.Eventf(presto, corev1.EventTypeWarning, "Failed",
 			"Failed to create/update coordinator replicaset %s", err.Error())
@@ -460,7 +763,64 @@ func (r *ReconcilePresto) coordinatorReplicaset(presto *falaricav1alpha1.Presto,
 			"Created Coordinator Replicaset. %s", cr)
 		r.log.Info("created coordinator replicaset")
 	}
-	return nil, created
+	if updated {
+		r.eventRecorder.Eventf(presto, corev1.EventTypeNormal, "Updated",
+			"Updated Coordinator Replicaset. %s", replicaSet.Name)
+		r.log.Info("updated coordinator replicaset")
+	}
+	return nil, created || updated
+}
+func (r *ReconcilePresto) coordinatorStatefulSet(presto *falaricav1alpha1.Presto,
+	baseLabels map[string]string,
+	ctx context.Context) (error, bool) {
+	// there can be only one coordinator. Remove the replicaset before creating the statefulset.
+	deleted, err := removeCoordinatorReplicaSet(r, presto)
+	if err != nil {
+		r.log.Error(err, "failed to delete coordinator replicaset")
+		r.eventRecorder.Eventf(presto, corev1.EventTypeWarning, "Failed",
+			"Failed to delete coordinator replicaset %s", err.Error())
+		return err, false
+	}
+	if deleted {
+		replicaSetName := ""
+		r.log.Info("deleted coordinator replicaset to run the coordinator as statefulset")
+		r.updateStatus(presto, ctx,ClusterUpdateAction{
+			coordinatorReplicaSetName: &replicaSetName,
+			clusterState: falaricav1alpha1.ClusterPending,
+		})
+		r.eventRecorder.Eventf(presto, corev1.EventTypeNormal, "Deleted",
+			"Deleted Coordinator Replicaset. %s", presto.Status.CoordinatorReplicaset)
+		return nil, true
+	}
+	statefulSet, created, updated, err := createUpdateStatefulSetForCoordinator(r, presto,
+		getCoordinatorPodLabels(baseLabels, presto.Status.Uuid))
+	if err != nil {
+		r.log.Error(err, "failed to create/update coordinator statefulset ")
+		errorReason := fmt.Sprintf("Failed to create coordinator statefulset %s", err.Error())
+		r.updateStatus(presto, ctx,ClusterUpdateAction{
+			errorReason: &errorReason,
+			clusterState: falaricav1alpha1.ClusterFailedState,
+			conditions: failureConditions(presto, "CoordinatorFailed", errorReason, falaricav1alpha1.ConditionCoordinatorReady),
+		})
+		r.eventRecorder.Eventf(presto, corev1.EventTypeWarning, "Failed",
+			"Failed to create/update coordinator statefulset %s", err.Error())
+		return err, created
+	}
+	if created {
+		r.updateStatus(presto, ctx,ClusterUpdateAction{
+			coordinatorStatefulSetName: &statefulSet.Name,
+			clusterState: falaricav1alpha1.ClusterPending,
+		})
+		r.eventRecorder.Eventf(presto, corev1.EventTypeNormal, "Created",
+			"Created Coordinator StatefulSet. %s", statefulSet.Name)
+		r.log.Info("created coordinator statefulset")
+	}
+	if updated {
+		r.eventRecorder.Eventf(presto, corev1.EventTypeNormal, "Updated",
+			"Updated Coordinator StatefulSet. %s", statefulSet.Name)
+		r.log.Info("updated coordinator statefulset")
+	}
+	return nil, created || updated
 }
 func (r *ReconcilePresto) workerReplicaset(presto *falaricav1alpha1.Presto,
 	baseLabels map[string]string,
@@ -473,6 +833,7 @@ func (r *ReconcilePresto) workerReplicaset(presto *falaricav1alpha1.Presto,
 		r.updateStatus(presto, ctx,ClusterUpdateAction{
 			errorReason: &errorReason,
 			clusterState: falaricav1alpha1.ClusterFailedState,
+			conditions: failureConditions(presto, "WorkersFailed", errorReason, falaricav1alpha1.ConditionWorkersAvailable),
 		})
 		r.eventRecorder.Eventf(presto, corev1.EventTypeWarning, "Failed",
 			"Failed to create worker replicaset %s", err.Error())
@@ -498,21 +859,97 @@ func (r *ReconcilePresto) workerReplicaset(presto *falaricav1alpha1.Presto,
 			"Created Worker Replicaset. %s", workerReplicaSet.Name)
 		changesMade = true
 	}
+	deleted, err := removeWorkerDeploymentAfterMigration(r, presto, workerReplicaSet)
+	if err != nil {
+		r.log.Error(err, "failed to delete worker deployment")
+		r.eventRecorder.Eventf(presto, corev1.EventTypeWarning, "Failed",
+			"Failed to delete worker deployment %s", err.Error())
+		return err, changesMade, workerReplicaSet
+	}
+	if deleted {
+		deploymentName := ""
+		r.log.Info("deleted worker deployment after migrating workers to replicaset")
+		r.updateStatus(presto, ctx,ClusterUpdateAction{
+			workerDeploymentName: &deploymentName,
+		})
+		r.eventRecorder.Eventf(presto, corev1.EventTypeNormal, "Deleted",
+			"Deleted Worker Deployment. %s", getWorkerDeploymentName(presto.Status.Uuid))
+		changesMade = true
+	}
 	return nil, changesMade, workerReplicaSet
 }
 
+func (r *ReconcilePresto) workerDeployment(presto *falaricav1alpha1.Presto,
+	baseLabels map[string]string,
+	ctx context.Context) (error, bool, *v1.Deployment) {
+	changesMade := false
+	workerDeployment, created, updated, err := createUpdateDeploymentForWorker(r, presto,
+		getWorkerPodLabels(baseLabels, presto.Status.Uuid))
+	if err != nil {
+		errorReason := fmt.Sprintf("Failed to create worker deployment %s", err.Error())
+		r.updateStatus(presto, ctx,ClusterUpdateAction{
+			errorReason: &errorReason,
+			clusterState: falaricav1alpha1.ClusterFailedState,
+			conditions: failureConditions(presto, "WorkersFailed", errorReason, falaricav1alpha1.ConditionWorkersAvailable),
+		})
+		r.eventRecorder.Eventf(presto, corev1.EventTypeWarning, "Failed",
+			"Failed to create worker deployment %s", err.Error())
+		return err, changesMade, workerDeployment
+	}
+	if updated {
+		r.log.Info("updated worker deployment")
+		r.eventRecorder.Eventf(presto, corev1.EventTypeNormal, "Updated",
+			"Updated Worker Deployment. %s ", workerDeployment.Name)
+		r.updateStatus(presto, ctx,ClusterUpdateAction{
+			workerDeployment: workerDeployment,
+			clusterState: falaricav1alpha1.ClusterPending,
+		})
+		changesMade = true
+	}
+	if created {
+		r.log.Info("created worker deployment")
+		r.updateStatus(presto, ctx,ClusterUpdateAction{
+			workerDeployment: workerDeployment,
+			clusterState: falaricav1alpha1.ClusterPending,
+		})
+		r.eventRecorder.Eventf(presto, corev1.EventTypeNormal, "Created",
+			"Created Worker Deployment. %s", workerDeployment.Name)
+		changesMade = true
+	}
+	deleted, err := removeWorkerReplicaSetAfterMigration(r, presto, workerDeployment)
+	if err != nil {
+		r.log.Error(err, "failed to delete worker replicaset")
+		r.eventRecorder.Eventf(presto, corev1.EventTypeWarning, "Failed",
+			"Failed to delete worker replicaset %s", err.Error())
+		return err, changesMade, workerDeployment
+	}
+	if deleted {
+		replicaSetName := ""
+		r.log.Info("deleted worker replicaset after migrating workers to deployment")
+		r.updateStatus(presto, ctx,ClusterUpdateAction{
+			workerReplicaSetName: &replicaSetName,
+		})
+		r.eventRecorder.Eventf(presto, corev1.EventTypeNormal, "Deleted",
+			"Deleted Worker Replicaset. %s", presto.Status.WorkerReplicaset)
+		changesMade = true
+	}
+	return nil, changesMade, workerDeployment
+}
+
 func (r *ReconcilePresto) hpaReplicaset(presto *falaricav1alpha1.Presto,
 	baseLabels map[string]string,
 	ctx context.Context,
-	workerReplicaSet *v1.ReplicaSet) (error, bool) {
+	scaleTarget autoscalingv2beta2.CrossVersionObjectReference) (error, bool) {
 	changesMade := false
-	created, updated, deleted, err := handleReplicaSet(r, presto, workerReplicaSet, baseLabels, ctx)
+	created, updated, deleted, behaviorDropped, err := handleReplicaSet(r, presto, scaleTarget,
+		getHPAName(presto.Status.Uuid), baseLabels, ctx)
 	if err != nil {
 		r.log.Error(err, "failed to create/update autoscale replicaset")
 		errorReason := fmt.Sprintf("Failed to create autoscale config %s", err.Error())
 		r.updateStatus(presto, ctx,ClusterUpdateAction{
 			errorReason: &errorReason,
 			clusterState: falaricav1alpha1.ClusterFailedState,
+			conditions: failureConditions(presto, "AutoscalerFailed", errorReason, falaricav1alpha1.ConditionAutoscalerReady),
 		})
 		r.eventRecorder.Eventf(presto, corev1.EventTypeWarning, "Failed",
 			"failed to create/update autoscale replicaset %s", err.Error())
@@ -523,6 +960,7 @@ func (r *ReconcilePresto) hpaReplicaset(presto *falaricav1alpha1.Presto,
 		r.updateStatus(presto, ctx,ClusterUpdateAction{
 			hpaName: &hpaName,
 			clusterState: falaricav1alpha1.ClusterPending,
+			conditions: []falaricav1alpha1.PrestoCondition{getHPACondition(presto, hpaName, behaviorDropped)},
 		})
 		r.eventRecorder.Eventf(presto, corev1.EventTypeNormal, "Created",
 			"Created HPA. %s", hpaName)
@@ -530,6 +968,9 @@ func (r *ReconcilePresto) hpaReplicaset(presto *falaricav1alpha1.Presto,
 	}
 	if updated {
 		hpaName := getHPAName(presto.Status.Uuid)
+		r.updateStatus(presto, ctx,ClusterUpdateAction{
+			conditions: []falaricav1alpha1.PrestoCondition{getHPACondition(presto, hpaName, behaviorDropped)},
+		})
 		r.eventRecorder.Eventf(presto, corev1.EventTypeNormal, "Updated",
 			"Updated HPA. %s", hpaName)
 		changesMade = true
@@ -542,6 +983,10 @@ func (r *ReconcilePresto) hpaReplicaset(presto *falaricav1alpha1.Presto,
 		r.updateStatus(presto, ctx,ClusterUpdateAction{
 			hpaName: &hpa,
 			clusterState: falaricav1alpha1.ClusterPending,
+			conditions: []falaricav1alpha1.PrestoCondition{
+				newCondition(presto, falaricav1alpha1.ConditionAutoscalerReady, corev1.ConditionFalse,
+					"AutoscalingDisabled", "Autoscaling is not enabled"),
+			},
 		})
 		changesMade = true
 	}
@@ -555,12 +1000,36 @@ type ClusterUpdateAction struct  {
 	coordinatorConfMap *string
 	catalogConfMap *string
 	coordinatorReplicaSetName *string
+	coordinatorStatefulSetName *string
 	hpaName *string
 	workerReplicaSet *v1.ReplicaSet
+	workerReplicaSetName *string
+	workerDeployment *v1.Deployment
+	workerDeploymentName *string
 	clusterState falaricav1alpha1.ClusterState
 	errorReason *string
 	coordinatorCPUUsage *string
 	workerCPUUsage *string
+	configHash *string
+	rolloutState *falaricav1alpha1.RolloutState
+	updatedWorkers *int32
+	conditions []falaricav1alpha1.PrestoCondition
+	prestoState *prestoState
+	lastScaleTime *metav1.Time
+	decommissioningWorkers *[]string
+	scalingSchedule *scalingScheduleStatus
+	hibernation *hibernationStatus
+	lastActivityTime *metav1.Time
+	hiveMetastore *string
+	hiveMetastoreURI *string
+	workerPools *[]falaricav1alpha1.WorkerPoolStatus
+	coordinatorPDB *string
+	workerPDB *string
+	tls *tlsStatus
+	internalTLS *tlsStatus
+	sharedSecret *string
+	sharedSecretRotationTime *metav1.Time
+	passwordFile *string
 }
 
 func (r *ReconcilePresto) updateStatus(presto *falaricav1alpha1.Presto,
@@ -605,6 +1074,10 @@ func (r *ReconcilePresto) updateStatus(presto *falaricav1alpha1.Presto,
 		prestoCopy.Status.CoordinatorReplicaset = *updateAction.coordinatorReplicaSetName
 		update = true
 	}
+	if updateAction.coordinatorStatefulSetName != nil{
+		prestoCopy.Status.CoordinatorStatefulSet = *updateAction.coordinatorStatefulSetName
+		update = true
+	}
 	if updateAction.hpaName != nil{
 		prestoCopy.Status.HpaName = *updateAction.hpaName
 		update = true
@@ -625,6 +1098,18 @@ func (r *ReconcilePresto) updateStatus(presto *falaricav1alpha1.Presto,
 		prestoCopy.Status.CoordinatorCPU = *updateAction.coordinatorCPUUsage
 		update = true
 	}
+	if updateAction.configHash != nil{
+		prestoCopy.Status.ConfigHash = *updateAction.configHash
+		update = true
+	}
+	if updateAction.rolloutState != nil{
+		prestoCopy.Status.RolloutState = *updateAction.rolloutState
+		update = true
+	}
+	if updateAction.updatedWorkers != nil{
+		prestoCopy.Status.UpdatedWorkers = *updateAction.updatedWorkers
+		update = true
+	}
 	// Update worker count
 	if updateAction.workerReplicaSet != nil {
 		prestoCopy.Status.WorkerReplicaset = updateAction.workerReplicaSet.Name
@@ -632,6 +1117,102 @@ func (r *ReconcilePresto) updateStatus(presto *falaricav1alpha1.Presto,
 		prestoCopy.Status.CurrentWorkers = updateAction.workerReplicaSet.Status.AvailableReplicas
 		update = true
 	}
+	if updateAction.workerDeployment != nil {
+		prestoCopy.Status.WorkerDeployment = updateAction.workerDeployment.Name
+		prestoCopy.Status.DesiredWorkers = *updateAction.workerDeployment.Spec.Replicas
+		prestoCopy.Status.CurrentWorkers = updateAction.workerDeployment.Status.AvailableReplicas
+		update = true
+	}
+	if updateAction.workerReplicaSetName != nil {
+		prestoCopy.Status.WorkerReplicaset = *updateAction.workerReplicaSetName
+		update = true
+	}
+	if updateAction.workerDeploymentName != nil {
+		prestoCopy.Status.WorkerDeployment = *updateAction.workerDeploymentName
+		update = true
+	}
+	if updateAction.prestoState != nil {
+		prestoCopy.Status.PrestoVersion = updateAction.prestoState.info.NodeVersion.Version
+		prestoCopy.Status.ActiveNodes = updateAction.prestoState.activeNodes
+		prestoCopy.Status.FailedNodes = updateAction.prestoState.failedNodes
+		if stats := updateAction.prestoState.stats; stats != nil {
+			prestoCopy.Status.ActiveWorkers = int32(stats.ActiveWorkers)
+			prestoCopy.Status.RunningQueries = int32(stats.RunningQueries)
+			prestoCopy.Status.QueuedQueries = int32(stats.QueuedQueries)
+			prestoCopy.Status.BlockedQueries = int32(stats.BlockedQueries)
+		}
+		update = true
+	}
+	if updateAction.lastScaleTime != nil {
+		prestoCopy.Status.LastScaleTime = updateAction.lastScaleTime
+		update = true
+	}
+	if updateAction.decommissioningWorkers != nil {
+		prestoCopy.Status.DecommissioningWorkers = *updateAction.decommissioningWorkers
+		update = true
+	}
+	if updateAction.scalingSchedule != nil {
+		prestoCopy.Status.ActiveSchedule = updateAction.scalingSchedule.activeSchedule
+		prestoCopy.Status.NextScheduleTransition = updateAction.scalingSchedule.nextTransition
+		update = true
+	}
+	if updateAction.hibernation != nil {
+		prestoCopy.Status.HibernationReason = updateAction.hibernation.reason
+		prestoCopy.Status.HibernatedAt = updateAction.hibernation.hibernatedAt
+		prestoCopy.Status.HibernatedWorkers = updateAction.hibernation.workers
+		update = true
+	}
+	if updateAction.lastActivityTime != nil {
+		prestoCopy.Status.LastActivityTime = updateAction.lastActivityTime
+		update = true
+	}
+	if updateAction.hiveMetastore != nil {
+		prestoCopy.Status.HiveMetastore = *updateAction.hiveMetastore
+		update = true
+	}
+	if updateAction.hiveMetastoreURI != nil {
+		prestoCopy.Status.HiveMetastoreURI = *updateAction.hiveMetastoreURI
+		update = true
+	}
+	if updateAction.workerPools != nil {
+		prestoCopy.Status.WorkerPools = *updateAction.workerPools
+		update = true
+	}
+	if updateAction.coordinatorPDB != nil {
+		prestoCopy.Status.CoordinatorPDB = *updateAction.coordinatorPDB
+		update = true
+	}
+	if updateAction.workerPDB != nil {
+		prestoCopy.Status.WorkerPDB = *updateAction.workerPDB
+		update = true
+	}
+	if updateAction.tls != nil {
+		prestoCopy.Status.TlsSecret = updateAction.tls.secret
+		prestoCopy.Status.TlsNotAfter = updateAction.tls.notAfter
+		update = true
+	}
+	if updateAction.internalTLS != nil {
+		prestoCopy.Status.InternalTlsSecret = updateAction.internalTLS.secret
+		prestoCopy.Status.InternalTlsNotAfter = updateAction.internalTLS.notAfter
+		update = true
+	}
+	if updateAction.sharedSecret != nil {
+		prestoCopy.Status.SharedSecret = *updateAction.sharedSecret
+		update = true
+	}
+	if updateAction.sharedSecretRotationTime != nil {
+		prestoCopy.Status.SharedSecretRotationTime = updateAction.sharedSecretRotationTime
+		update = true
+	}
+	if updateAction.passwordFile != nil {
+		prestoCopy.Status.PasswordFileSecret = *updateAction.passwordFile
+		update = true
+	}
+	for _, condition := range updateAction.conditions {
+		if setCondition(&prestoCopy.Status.Conditions, condition, metav1.NewTime(r.clock.Now())) {
+			update = true
+		}
+	}
 
 	if update {
 		prestoCopy.Status.ModificationTime = metav1.Now()
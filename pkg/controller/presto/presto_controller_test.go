@@ -0,0 +1,89 @@
+package presto
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/falarica/steerd-presto-operator/pkg/apis"
+	falaricav1alpha1 "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/clock"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	testNamespace   = "default"
+	testClusterName = "mycluster"
+	testClusterUUID = "0123456789abcdef0123456789abcdef"
+)
+
+// returns a presto with the status of a cluster that has been created
+func newTestPresto() *falaricav1alpha1.Presto {
+	return &falaricav1alpha1.Presto{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       testClusterName,
+			Namespace:  testNamespace,
+			UID:        "presto-uid",
+			Generation: 1,
+		},
+		Spec: falaricav1alpha1.PrestoSpec{
+			Coordinator: falaricav1alpha1.CoordinatorSpec{MemoryLimit: "1Gi", CpuLimit: "1"},
+			Worker:      falaricav1alpha1.WorkerSpec{MemoryLimit: "1Gi", CpuLimit: "1"},
+		},
+		Status: falaricav1alpha1.PrestoStatus{
+			Uuid:         testClusterUUID,
+			ClusterState: falaricav1alpha1.ClusterReadyState,
+		},
+	}
+}
+
+// returns a reconciler backed by a fake client that has the given objects. The clients of
+// the REST API and the clock can be replaced by the tests.
+func newTestReconciler(t *testing.T, objs ...runtime.Object) *ReconcilePresto {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := apis.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return &ReconcilePresto{
+		client:                fake.NewFakeClientWithScheme(scheme, objs...),
+		log:                   logf.NullLogger{},
+		scheme:                scheme,
+		eventRecorder:         record.NewFakeRecorder(100),
+		registeredPrestos:     new(sync.Map),
+		prestoClient:          newPrestoClient,
+		workerClient:          newWorkerClient,
+		clock:                 clock.RealClock{},
+		workerRecommendations: new(sync.Map),
+		deferredWorkers:       new(sync.Map),
+	}
+}
+
+// returns a running pod with the given label of the cluster
+func newTestPod(name string, getLabel func(string) (string, string), nodeName string, ready bool) *corev1.Pod {
+	k, v := getLabel(testClusterUUID)
+	readyStatus := corev1.ConditionFalse
+	if ready {
+		readyStatus = corev1.ConditionTrue
+	}
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: testNamespace,
+			Labels:    map[string]string{k: v, "clusterName": testClusterName},
+		},
+		Spec: corev1.PodSpec{NodeName: nodeName},
+		Status: corev1.PodStatus{
+			Phase:      corev1.PodRunning,
+			PodIP:      "10.0.0.1",
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: readyStatus}},
+		},
+	}
+}
@@ -22,7 +22,15 @@ func createUpdateReplicaSetForWorker(r *ReconcilePresto, presto *falaricav1alpha
 	replicaSet, err := getReplicaSet(r, presto, getWorkerPodLabel)
 	// If the resource doesn't exist, we'll create it
 	if errors.IsNotFound(err) {
-		replicaSet, err = createReplicaSetForWorker(r, presto, lbls, *presto.Spec.Worker.Count)
+		workerCount := *presto.Spec.Worker.Count
+		// while migrating, start with as many workers as the deployment has
+		deployment, err := getWorkerDeployment(r, presto)
+		if err == nil {
+			workerCount = *deployment.Spec.Replicas
+		} else if !errors.IsNotFound(err) {
+			return nil, created, updated, err
+		}
+		replicaSet, err = createReplicaSetForWorker(r, presto, lbls, workerCount)
 		if err != nil {
 			r.log.Error(err,"Failed to create replicaSet object")
 			return nil, created, updated, err
@@ -45,8 +53,17 @@ func createUpdateReplicaSetForWorker(r *ReconcilePresto, presto *falaricav1alpha
 			if presto.Spec.Worker.Count != nil && *presto.Spec.Worker.Count != *replicaSet.Spec.Replicas {
 				r.log.Info(fmt.Sprintf("PrestoCluster %s workerCount: %d, replicaSet replicas: %d",
 					presto.Name, *presto.Spec.Worker.Count, *replicaSet.Spec.Replicas))
+				replicas := *presto.Spec.Worker.Count
+				if replicas < *replicaSet.Spec.Replicas {
+					removable, err := r.decommissionWorkers(presto, replicaSet, nil,
+						*replicaSet.Spec.Replicas-replicas)
+					if err != nil {
+						return nil, created, updated, err
+					}
+					replicas = *replicaSet.Spec.Replicas - removable
+				}
 				replicaSetCopy := replicaSet.DeepCopy()
-				replicaSetCopy.Spec.Replicas = presto.Spec.Worker.Count
+				replicaSetCopy.Spec.Replicas = &replicas
 				err = r.client.Update(context.Background(), replicaSetCopy)
 				replicaSet = replicaSetCopy
 				if err != nil {
@@ -55,6 +72,17 @@ func createUpdateReplicaSetForWorker(r *ReconcilePresto, presto *falaricav1alpha
 				updated = true
 			}
 		}
+		// image, resources or volumes may have changed. The pods are replaced by the rollout.
+		desiredReplicaSet, err := createReplicaSetForWorker(r, presto, lbls, *replicaSet.Spec.Replicas)
+		if err != nil {
+			return nil, created, updated, err
+		}
+		templateUpdated, err := updateReplicaSetPodTemplate(r, replicaSet, desiredReplicaSet)
+		if err != nil {
+			r.log.Error(err, "Failed to update pod template of replicaSet")
+			return nil, created, updated, err
+		}
+		updated = updated || templateUpdated
 	}
 	return replicaSet, created, updated, nil
 }
@@ -65,6 +93,14 @@ func createReplicaSetForWorker(r *ReconcilePresto, presto *falaricav1alpha1.Pres
 	if err != nil {
 		return nil, err
 	}
+	configHash, err := getConfigHash(r, presto)
+	if err != nil {
+		return nil, err
+	}
+	podSpecHash, err := getPodSpecHash(podSpec)
+	if err != nil {
+		return nil, err
+	}
 	return &v1.ReplicaSet{
 		ObjectMeta: metav1.ObjectMeta{
 			GenerateName: getWorkerReplicaSet(presto.Status.Uuid),
@@ -82,6 +118,10 @@ func createReplicaSetForWorker(r *ReconcilePresto, presto *falaricav1alpha1.Pres
 					OwnerReferences: []metav1.OwnerReference{*getOwnerReference(presto)},
 					Namespace:    presto.Namespace,
 					Labels: lbls,
+					Annotations: map[string]string{
+						configHashAnnotation:  configHash,
+						podSpecHashAnnotation: podSpecHash,
+					},
 				},
 				Spec: *podSpec,
 			},
@@ -118,8 +158,17 @@ func getPrestoWorkerPod(r *ReconcilePresto, presto *falaricav1alpha1.Presto) (*c
 				"'%v': %v", presto.Spec.Worker.CpuRequest, err)}
 		}
 	}
-	return createPrestoPodSpec(r, presto, false,
-		limitResource, requestResource), nil
+	err = addStorageResources(presto, false, limitResource, requestResource)
+	if err != nil {
+		return nil, err
+	}
+	podSpec := createPrestoPodSpec(r, presto, false,
+		limitResource, requestResource)
+	err = applyPodExtensions(presto, podSpec, false)
+	if err != nil {
+		return nil, err
+	}
+	return podSpec, nil
 }
 
 
@@ -140,34 +189,49 @@ func getReplicaSet(r *ReconcilePresto,presto *falaricav1alpha1.Presto,
 		r.log.Error(err, "failed to list existing Presto replicaset")
 		return nil, err
 	}
-	if len(existingReplicaSet.Items) == 0 {
-		return nil, errors.NewNotFound(v1.Resource("replicasets"), "")
+	// the replicasets created by the worker deployment have the same labels.
+	// Only the replicasets created by the operator are of interest.
+	for i := range existingReplicaSet.Items {
+		if metav1.IsControlledBy(&existingReplicaSet.Items[i], presto) {
+			return &existingReplicaSet.Items[i], nil
+		}
 	}
-	return &existingReplicaSet.Items[0], nil
+	return nil, errors.NewNotFound(v1.Resource("replicasets"), "")
 }
 
-// returns replicaSet, created, error
+// returns replicaSet, created, updated, error
 func createUpdateReplicaSetForCoordinator(r *ReconcilePresto, presto *falaricav1alpha1.Presto,
-	lbls map[string]string) (*v1.ReplicaSet, bool, error) {
+	lbls map[string]string) (*v1.ReplicaSet, bool, bool, error) {
 	created := false
+	updated := false
 	// Get the replicaSet with the name specified in PrestoCluster.spec
 	replicaSet, err := getReplicaSet(r, presto, getCoordinatorPodLabel)
 	// If the resource doesn't exist, we'll create it
 	if errors.IsNotFound(err) {
 		replicaSet, err = createReplicaSetForCoordinator(r, presto, lbls)
 		if err != nil {
-			return nil,created, err
+			return nil,created, updated, err
 		}
 		err = r.client.Create(context.Background(), replicaSet)
 		if err != nil {
-			return nil, created, err
+			return nil, created, updated, err
 		}
 		created = true
+		return replicaSet, created, updated, nil
 	}
 	if err != nil {
-		return nil, created, err
+		return nil, created, updated, err
 	}
-	return replicaSet, created, nil
+	// image, resources or volumes may have changed. The pod is replaced by the rollout.
+	desiredReplicaSet, err := createReplicaSetForCoordinator(r, presto, lbls)
+	if err != nil {
+		return nil, created, updated, err
+	}
+	updated, err = updateReplicaSetPodTemplate(r, replicaSet, desiredReplicaSet)
+	if err != nil {
+		return nil, created, updated, err
+	}
+	return replicaSet, created, updated, nil
 }
 
 func createReplicaSetForCoordinator(r *ReconcilePresto, presto *falaricav1alpha1.Presto,
@@ -176,6 +240,25 @@ func createReplicaSetForCoordinator(r *ReconcilePresto, presto *falaricav1alpha1
 	if err != nil {
 		return nil, err
 	}
+	configHash, err := getConfigHash(r, presto)
+	if err != nil {
+		return nil, err
+	}
+	tlsHash, err := getCoordinatorTLSHash(r, presto)
+	if err != nil {
+		return nil, err
+	}
+	podSpecHash, err := getPodSpecHash(podSpec)
+	if err != nil {
+		return nil, err
+	}
+	annotations := map[string]string{
+		configHashAnnotation:  configHash,
+		podSpecHashAnnotation: podSpecHash,
+	}
+	if len(tlsHash) != 0 {
+		annotations[tlsHashAnnotation] = tlsHash
+	}
 	return &v1.ReplicaSet{
 		ObjectMeta: metav1.ObjectMeta{
 			GenerateName: getCoordinatorReplicaset(presto.Status.Uuid),
@@ -193,6 +276,7 @@ func createReplicaSetForCoordinator(r *ReconcilePresto, presto *falaricav1alpha1
 					Namespace:    presto.Namespace,
 					OwnerReferences: []metav1.OwnerReference{*getOwnerReference(presto)},
 					Labels: lbls,
+					Annotations: annotations,
 				},
 				Spec: *podSpec,
 			},
@@ -211,10 +295,10 @@ func getPrestoCoordinatorPodSpec(r *ReconcilePresto, presto *falaricav1alpha1.Pr
 		return nil, &OperatorError{fmt.Sprintf("cannot parse presto.Spec.Coordinator.CpuLimit: " +
 			"'%v': %v", presto.Spec.Coordinator.CpuLimit, err)}
 	}
-	limitResource[corev1.ResourceMemory], err = resource.ParseQuantity(presto.Spec.Worker.MemoryLimit)
+	limitResource[corev1.ResourceMemory], err = resource.ParseQuantity(presto.Spec.Coordinator.MemoryLimit)
 	if err != nil {
-		return nil, &OperatorError{fmt.Sprintf("cannot parse presto.Spec.Worker.MemoryLimit: " +
-			"'%v': %v", presto.Spec.Worker.MemoryLimit, err)}
+		return nil, &OperatorError{fmt.Sprintf("cannot parse presto.Spec.Coordinator.MemoryLimit: " +
+			"'%v': %v", presto.Spec.Coordinator.MemoryLimit, err)}
 	}
 	if len(presto.Spec.Coordinator.CpuRequest) == 0 {
 		// set CPURequest same as limit if not specified
@@ -230,8 +314,17 @@ func getPrestoCoordinatorPodSpec(r *ReconcilePresto, presto *falaricav1alpha1.Pr
 				"'%v': %v", presto.Spec.Coordinator.CpuRequest, err)}
 		}
 	}
-	return createPrestoPodSpec(r, presto, true,
-		limitResource, requestResource), nil
+	err = addStorageResources(presto, true, limitResource, requestResource)
+	if err != nil {
+		return nil, err
+	}
+	podSpec := createPrestoPodSpec(r, presto, true,
+		limitResource, requestResource)
+	err = applyPodExtensions(presto, podSpec, true)
+	if err != nil {
+		return nil, err
+	}
+	return podSpec, nil
 }
 
 // Returns podCreated, error
@@ -284,19 +377,44 @@ func createPrestoPodSpec(r *ReconcilePresto, presto *falaricav1alpha1.Presto,
 		},
 	}
 
-	if isCoordinator {
+	// the statefulset sets the hostname and subdomain of its pod
+	if isCoordinator && !isCoordinatorStatefulSetEnabled(presto) {
 		podSpec.Hostname = getCoordinatorContainerName(presto.Status.Uuid)
 		podSpec.Subdomain = getPodDiscoveryServiceName(presto.Status.Uuid)
 	}
+	applySchedulingSpec(presto, podSpec, isCoordinator)
+	applyProbes(presto, &podSpec.Containers[0], isCoordinator)
 	catalogMount := getCatalogVolumeMount(presto, podSpec)
 	propsMount := getPropsVolumeMount(presto, podSpec, isCoordinator)
 	appendAdditionalVolumes(presto, &podSpec.Volumes)
 	podSpec.Containers[0].VolumeMounts = append(podSpec.Containers[0].VolumeMounts, *propsMount)
 	podSpec.Containers[0].VolumeMounts = append(podSpec.Containers[0].VolumeMounts, *catalogMount)
-	if isCoordinator && presto.Spec.Coordinator.HttpsEnabled {
+	// with internal TLS, the coordinator uses the internal certificate unless tls is set
+	if isCoordinator && presto.Spec.Coordinator.HttpsEnabled &&
+		(isTLSEnabled(presto) || !isInternalTLSEnabled(presto)) {
 		httpsMount := getHTTPSVolumeMount(presto, podSpec)
 		podSpec.Containers[0].VolumeMounts = append(podSpec.Containers[0].VolumeMounts, *httpsMount)
+		if passwordSecret := presto.Spec.Coordinator.HttpsKeyPairPasswordSecret; passwordSecret != nil &&
+			!isTLSEnabled(presto) {
+			podSpec.Containers[0].Env = append(podSpec.Containers[0].Env, corev1.EnvVar{
+				Name:      httpsKeystorePasswordEnv,
+				ValueFrom: &corev1.EnvVarSource{SecretKeyRef: passwordSecret.DeepCopy()},
+			})
+		}
 	}
+	if isCoordinator && isCoordinatorStatefulSetEnabled(presto) && presto.Spec.Coordinator.DataVolume != nil {
+		// the volume comes from the volume claim template of the statefulset
+		podSpec.Containers[0].VolumeMounts = append(podSpec.Containers[0].VolumeMounts, corev1.VolumeMount{
+			Name:      getCoordinatorDataVolName(presto.Status.Uuid),
+			MountPath: dataDirPath,
+		})
+	} else if storageMount := getStorageVolumeMount(presto, podSpec, isCoordinator); storageMount != nil {
+		podSpec.Containers[0].VolumeMounts = append(podSpec.Containers[0].VolumeMounts, *storageMount)
+	}
+	if isInternalTLSEnabled(presto) {
+		applyInternalTLS(presto, podSpec, isCoordinator)
+	}
+	applySharedSecret(presto, podSpec)
 	appendAdditionalVolumeMounts(presto, &podSpec.Containers[0].VolumeMounts)
 	return podSpec
 }
@@ -329,11 +447,15 @@ func appendAdditionalVolumeMounts(presto *falaricav1alpha1.Presto,
 
 func getHTTPSVolumeMount(presto *falaricav1alpha1.Presto,
 	podSpec *corev1.PodSpec) *corev1.VolumeMount {
+	secretName := presto.Spec.Coordinator.HttpsKeyPairSecretName
+	if isTLSEnabled(presto) {
+		secretName = getCoordinatorTLSSecretName(presto.Status.Uuid)
+	}
 	httpsSecretVolume := corev1.Volume{
 		Name: getHTTPSSecretVolName(presto.Status.Uuid),
 		VolumeSource: corev1.VolumeSource{
 			Secret: &corev1.SecretVolumeSource{
-				SecretName:  presto.Spec.Coordinator.HttpsKeyPairSecretName,
+				SecretName:  secretName,
 			},
 		},
 	}
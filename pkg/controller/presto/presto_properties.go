@@ -44,6 +44,10 @@ func buildConfigMap(presto *v1alpha1.Presto, isCoordinator bool, configMapName s
 		jvmConfigKey:        jvmConfig,
 		// added the shutdown script in etc folder to avoid mounting another volume
 		prestoShutdownScript: strings.ReplaceAll(shutdownScriptContent, "{MOUNT_PATH}", getPrestoPath(presto)),
+		prestoReadinessScript: strings.ReplaceAll(readinessScriptContent, "{MOUNT_PATH}", getPrestoPath(presto)),
+	}
+	if isCoordinator && isPasswordAuthEnabled(presto) {
+		propertiesFiles[passwordAuthenticatorPropsKey] = getPasswordAuthenticatorProps(presto)
 	}
 	for filename, content := range presto.Spec.AdditionalPrestoPropFiles {
 		propertiesFiles[filename] = content
@@ -59,31 +63,32 @@ func buildConfigMap(presto *v1alpha1.Presto, isCoordinator bool, configMapName s
 	}, nil
 }
 
-// return createdConfig, error
+// return createdConfig, updatedConfig, error
 func createCoordinatorConfig(presto *v1alpha1.Presto, c client.Client,
-	lbls map[string]string) (bool, error) {
+	lbls map[string]string) (bool, bool, error) {
 	configMapName := getCoordinatorConfigMapName(presto.Status.Uuid)
 	configMap, err := buildConfigMap(presto, true, configMapName, lbls)
 	if err != nil {
-		return false, err
+		return false, false, err
 	}
-	return createConfigMap(configMapName, presto, c, configMap, lbls)
+	return createOrUpdateConfigMap(configMapName, presto, c, configMap, lbls)
 }
 
+// return createdConfig, updatedConfig, error
 func createWorkerConfig(presto *v1alpha1.Presto, c client.Client,
-	lbls map[string]string) (bool, error) {
+	lbls map[string]string) (bool, bool, error) {
 	configMapName := getWorkerConfigMapName(presto.Status.Uuid)
 	configMap, err := buildConfigMap(presto, false, configMapName, lbls)
 	if err != nil {
-		return false, err
+		return false, false, err
 	}
-	return createConfigMap(configMapName, presto, c, configMap, lbls)
+	return createOrUpdateConfigMap(configMapName, presto, c, configMap, lbls)
 }
 
 func coordinatorNodePropsMap() string {
 	var sb strings.Builder
 	sb.WriteString(fmt.Sprintf("node.environment=prestoproduction\n"))
-	sb.WriteString(fmt.Sprintf("node.data-dir=/data/presto\n"))
+	sb.WriteString(fmt.Sprintf("node.data-dir=%s\n", dataDirPath))
 	return sb.String()
 }
 
@@ -106,20 +111,20 @@ func coordinatorJVMConfigMap(presto *v1alpha1.Presto) (string, error) {
 }
 
 func coordinatorConfigPropsMap(presto *v1alpha1.Presto) (string, error) {
-	coordinatorInternalName := getCoordinatorInternalName(presto.Status.Uuid)
+	coordinatorInternalName := getCoordinatorInternalName(presto)
 	systemProps, err := getSystemProps(presto, coordinatorInternalName)
 	if err != nil {
 		return "", err
 	}
 	var sb strings.Builder
-	for key, value := range systemProps {
-		sb.WriteString(fmt.Sprintf("%s=%s\n", key, value))
+	for _, key := range sortedKeys(systemProps) {
+		sb.WriteString(fmt.Sprintf("%s=%s\n", key, systemProps[key]))
 	}
-	for key, value := range presto.Spec.Coordinator.AdditionalProps {
+	for _, key := range sortedKeys(presto.Spec.Coordinator.AdditionalProps) {
 		if _, ok := systemProps[key]; ok {
-			return "", &OperatorError{"%s is a system property. Cannot be specified as additional property"}
+			return "", &OperatorError{fmt.Sprintf("%s is a system property. Cannot be specified as additional property", key)}
 		} else {
-			sb.WriteString(fmt.Sprintf("%s=%s\n", key, value))
+			sb.WriteString(fmt.Sprintf("%s=%s\n", key, presto.Spec.Coordinator.AdditionalProps[key]))
 		}
 	}
 	return sb.String(), nil
@@ -145,19 +150,64 @@ func getHTTPPort(presto *v1alpha1.Presto) (int32, int32) {
 	}
 }
 
+// returns the value of http-server.https.keystore.key. The password in a secret is
+// substituted by presto from the environment, so that it is not written to the config map.
+func getHTTPSKeystoreKey(presto *v1alpha1.Presto) (string, error) {
+	password := presto.Spec.Coordinator.HttpsKeyPairPassword
+	passwordSecret := presto.Spec.Coordinator.HttpsKeyPairPasswordSecret
+	if len(password) != 0 && passwordSecret != nil {
+		return "", &OperatorError{errormsg: "only one of HttpsKeyPairPassword and HttpsKeyPairPasswordSecret " +
+			"can be specified"}
+	}
+	if passwordSecret != nil {
+		return fmt.Sprintf("${ENV:%s}", httpsKeystorePasswordEnv), nil
+	}
+	if len(password) == 0 {
+		return "", &OperatorError{errormsg: "HttpsKeyPairPasswordSecret has to be specified when HTTPS is enabled"}
+	}
+	return password, nil
+}
+
+// returns the keystore properties of the HTTPS port. The keystore provisioned by the operator
+// is a PEM file that does not need a password.
+func getHTTPSKeystoreProps(presto *v1alpha1.Presto) (map[string]string, error) {
+	if isTLSEnabled(presto) {
+		if err := validateTLS(presto); err != nil {
+			return nil, err
+		}
+		return map[string]string{
+			"http-server.https.keystore.path": httpsVolPath + "/" + tlsKeystoreKey,
+		}, nil
+	}
+	if isInternalTLSEnabled(presto) {
+		return map[string]string{
+			"http-server.https.keystore.path": internalTLSVolPath + "/" + tlsKeystoreKey,
+		}, nil
+	}
+	keystoreKey, err := getHTTPSKeystoreKey(presto)
+	if err != nil {
+		return nil, err
+	}
+	if  len(presto.Spec.Coordinator.HttpsKeyPairSecretKey) == 0 {
+		return nil, &OperatorError{errormsg: "HttpsKeyPairSecretKey has to be specified when HTTPS is enabled"}
+	}
+	if  len(presto.Spec.Coordinator.HttpsKeyPairSecretName) == 0 {
+		return nil, &OperatorError{errormsg: "HttpsKeyPairSecretName has to be specified when HTTPS is enabled"}
+	}
+	return map[string]string{
+		"http-server.https.keystore.path": httpsVolPath + "/" + presto.Spec.Coordinator.HttpsKeyPairSecretKey,
+		"http-server.https.keystore.key":  keystoreKey,
+	}, nil
+}
+
 func getSystemProps(presto *v1alpha1.Presto, coordinatorInternalName string) (map[string]string, error) {
 	httpPort, httpsPort := getHTTPPort(presto)
 
 	var systemProps = make(map[string]string)
 	if presto.Spec.Coordinator.HttpsEnabled {
-		if  len(presto.Spec.Coordinator.HttpsKeyPairPassword) == 0 {
-			return nil, &OperatorError{errormsg: "HttpsKeyPairPassword has to be specified when HTTPS is enabled"}
-		}
-		if  len(presto.Spec.Coordinator.HttpsKeyPairSecretKey) == 0 {
-			return nil, &OperatorError{errormsg: "HttpsKeyPairSecretKey has to be specified when HTTPS is enabled"}
-		}
-		if  len(presto.Spec.Coordinator.HttpsKeyPairSecretName) == 0 {
-			return nil, &OperatorError{errormsg: "HttpsKeyPairSecretName has to be specified when HTTPS is enabled"}
+		keystoreProps, err := getHTTPSKeystoreProps(presto)
+		if err != nil {
+			return nil, err
 		}
 		systemProps = map[string]string{
 			"coordinator":                        "true",
@@ -169,8 +219,9 @@ func getSystemProps(presto *v1alpha1.Presto, coordinatorInternalName string) (ma
 			"http-server.https.enabled":          "true",
 			"http-server.https.port":             fmt.Sprintf("%d", httpsPort),
 			"http-server.http.port":              fmt.Sprintf("%d", httpPort),
-			"http-server.https.keystore.path":    httpsVolPath + "/" + presto.Spec.Coordinator.HttpsKeyPairSecretKey,
-			"http-server.https.keystore.key":     presto.Spec.Coordinator.HttpsKeyPairPassword,
+		}
+		for k, v := range keystoreProps {
+			systemProps[k] = v
 		}
 	} else {
 		systemProps = map[string]string{
@@ -183,14 +234,30 @@ func getSystemProps(presto *v1alpha1.Presto, coordinatorInternalName string) (ma
 			"http-server.https.enabled":          "false",
 		}
 	}
+	if isInternalTLSEnabled(presto) {
+		for k, v := range getInternalTLSProps(presto) {
+			systemProps[k] = v
+		}
+	}
+	for k, v := range getSharedSecretProps(presto, presto.Spec.Coordinator.AdditionalProps) {
+		systemProps[k] = v
+	}
+	if isPasswordAuthEnabled(presto) {
+		for k, v := range getAuthenticationProps() {
+			systemProps[k] = v
+		}
+	}
+	// the coordinator decides the default of the spill_enabled session property
+	for k, v := range getSpillProps(presto) {
+		systemProps[k] = v
+	}
 	return systemProps, nil
 }
 
 func workerNodePropsMap() string {
 	var sb strings.Builder
 	sb.WriteString(fmt.Sprintf("node.environment=prestoproduction\n"))
-	//TODO: data-dir to be made configurable as a persistent volume
-	sb.WriteString(fmt.Sprintf("node.data-dir=/data/presto\n"))
+	sb.WriteString(fmt.Sprintf("node.data-dir=%s\n", dataDirPath))
 	return sb.String()
 }
 
@@ -226,22 +293,33 @@ func addDefaultJVMProps(sb *strings.Builder) {
 }
 
 func workerConfigPropsMap(presto *v1alpha1.Presto) (string, error) {
-	httpPort, _ := getHTTPPort(presto)
-
 	var systemProps = map[string]string {
 		"coordinator": "false",
 		"http-server.http.port": fmt.Sprintf("%d", prestoPort),
-		"discovery.uri": fmt.Sprintf("http://%s:%d", getCoordinatorInternalName(presto.Status.Uuid), httpPort),
+		"discovery.uri": getDiscoveryURI(presto),
+	}
+	if isInternalTLSEnabled(presto) {
+		// the worker serves HTTPS on the same port
+		delete(systemProps, "http-server.http.port")
+		for k, v := range getWorkerInternalTLSProps(presto) {
+			systemProps[k] = v
+		}
+	}
+	for k, v := range getSharedSecretProps(presto, presto.Spec.Worker.AdditionalProps) {
+		systemProps[k] = v
+	}
+	for k, v := range getSpillProps(presto) {
+		systemProps[k] = v
 	}
 	var sb strings.Builder
-	for key, value := range systemProps {
-		sb.WriteString(fmt.Sprintf("%s=%s\n", key, value))
+	for _, key := range sortedKeys(systemProps) {
+		sb.WriteString(fmt.Sprintf("%s=%s\n", key, systemProps[key]))
 	}
-	for key, value := range presto.Spec.Worker.AdditionalProps {
+	for _, key := range sortedKeys(presto.Spec.Worker.AdditionalProps) {
 		if  _, ok := systemProps[key]; ok {
-			return "", &OperatorError{"%s is a system property. Cannot be specified as additional property"}
+			return "", &OperatorError{fmt.Sprintf("%s is a system property. Cannot be specified as additional property", key)}
 		} else {
-			sb.WriteString(fmt.Sprintf("%s=%s\n", key, value))
+			sb.WriteString(fmt.Sprintf("%s=%s\n", key, presto.Spec.Worker.AdditionalProps[key]))
 		}
 	}
 	return sb.String(), nil
@@ -268,6 +346,10 @@ func getPropsVolumeMount(presto *v1alpha1.Presto, podSpec *corev1.PodSpec,
 			},
 		},
 	}
+	// the password file is kept next to password-authenticator.properties
+	if isCoordinator && isPasswordAuthEnabled(presto) {
+		volumeProjectionsProperties = append(volumeProjectionsProperties, getPasswordFileProjection(presto))
+	}
 
 	propsVolume := corev1.Volume{
 		Name: configMapVol,
@@ -0,0 +1,128 @@
+package presto
+
+import (
+	"fmt"
+	falaricav1alpha1 "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+/*
+  Without probes a hung JVM stays Running and the services send traffic to a coordinator
+  that is still starting. The presto containers get the following probes by default.
+  - startup: GET /v1/info. A JVM with a large heap and many catalogs can take minutes to
+    boot, so the startup probe allows up to 10 minutes. The liveness probe starts after it.
+  - liveness: GET /v1/info. It fails only when the server does not respond for a minute,
+    so that long GC pauses do not restart the container. The startup probe is alpha in
+    Kubernetes 1.16 and 1.17 and is dropped without its feature gate, so the liveness probe
+    also waits for the startup budget before its first check. Otherwise a slow boot would
+    be restarted over and over.
+  - readiness: a script in the config map that checks that /v1/info is not starting and
+    that /v1/info/state is ACTIVE. A worker that is SHUTTING_DOWN leaves the endpoints.
+  The coordinator is probed on the HTTPS port when HTTPS is enabled and on the HTTP port
+  otherwise. The workers always listen on the HTTP port.
+  The fields set in the overrides of the spec replace those of the default probes.
+*/
+
+const (
+	prestoInfoPath = "/v1/info"
+)
+
+func getDefaultStartupProbe(handler corev1.Handler) *corev1.Probe {
+	return &corev1.Probe{
+		Handler:          handler,
+		PeriodSeconds:    10,
+		TimeoutSeconds:   5,
+		FailureThreshold: 60,
+	}
+}
+
+// returns the default liveness probe that starts once the startup probe would have given up
+func getDefaultLivenessProbe(handler corev1.Handler, startupProbe *corev1.Probe) *corev1.Probe {
+	return &corev1.Probe{
+		Handler:             handler,
+		InitialDelaySeconds: startupProbe.InitialDelaySeconds + startupProbe.PeriodSeconds*startupProbe.FailureThreshold,
+		PeriodSeconds:       10,
+		TimeoutSeconds:      5,
+		FailureThreshold:    6,
+	}
+}
+
+func getDefaultReadinessProbe(presto *falaricav1alpha1.Presto) *corev1.Probe {
+	return &corev1.Probe{
+		Handler: corev1.Handler{
+			Exec: &corev1.ExecAction{
+				Command: []string{"/bin/sh", fmt.Sprintf("%s/%s", getPrestoPath(presto), prestoReadinessScript)},
+			},
+		},
+		PeriodSeconds:    10,
+		TimeoutSeconds:   10,
+		FailureThreshold: 3,
+	}
+}
+
+// returns the GET /v1/info handler on the port the container serves
+func getInfoHandler(presto *falaricav1alpha1.Presto, isCoordinator bool) corev1.Handler {
+	port := int32(prestoPort)
+	scheme := corev1.URISchemeHTTP
+	if isCoordinator {
+		httpPort, httpsPort := getHTTPPort(presto)
+		port = httpPort
+		// the HTTPS port asks for a password with authentication
+		if presto.Spec.Coordinator.HttpsEnabled && !isPasswordAuthEnabled(presto) {
+			port = httpsPort
+			scheme = corev1.URISchemeHTTPS
+		}
+	} else if isInternalTLSEnabled(presto) {
+		scheme = corev1.URISchemeHTTPS
+	}
+	return corev1.Handler{
+		HTTPGet: &corev1.HTTPGetAction{
+			Path:   prestoInfoPath,
+			Port:   intstr.FromInt(int(port)),
+			Scheme: scheme,
+		},
+	}
+}
+
+// returns the default probe with the fields set in the override replaced
+func overrideProbe(probe *corev1.Probe, override *corev1.Probe) *corev1.Probe {
+	if override == nil {
+		return probe
+	}
+	if override.Exec != nil || override.HTTPGet != nil || override.TCPSocket != nil {
+		probe.Handler = *override.Handler.DeepCopy()
+	}
+	if override.InitialDelaySeconds != 0 {
+		probe.InitialDelaySeconds = override.InitialDelaySeconds
+	}
+	if override.TimeoutSeconds != 0 {
+		probe.TimeoutSeconds = override.TimeoutSeconds
+	}
+	if override.PeriodSeconds != 0 {
+		probe.PeriodSeconds = override.PeriodSeconds
+	}
+	if override.SuccessThreshold != 0 {
+		probe.SuccessThreshold = override.SuccessThreshold
+	}
+	if override.FailureThreshold != 0 {
+		probe.FailureThreshold = override.FailureThreshold
+	}
+	return probe
+}
+
+// sets the probes of the presto container of the coordinator or the workers
+func applyProbes(presto *falaricav1alpha1.Presto, container *corev1.Container, isCoordinator bool) {
+	probes := presto.Spec.Worker.Probes
+	if isCoordinator {
+		probes = presto.Spec.Coordinator.Probes
+	}
+	if probes == nil {
+		probes = &falaricav1alpha1.ProbesSpec{}
+	}
+	infoHandler := getInfoHandler(presto, isCoordinator)
+	container.StartupProbe = overrideProbe(getDefaultStartupProbe(infoHandler), probes.StartupProbe)
+	container.LivenessProbe = overrideProbe(getDefaultLivenessProbe(infoHandler, container.StartupProbe),
+		probes.LivenessProbe)
+	container.ReadinessProbe = overrideProbe(getDefaultReadinessProbe(presto), probes.ReadinessProbe)
+}
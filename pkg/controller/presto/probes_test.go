@@ -0,0 +1,37 @@
+package presto
+
+import (
+	"testing"
+
+	falaricav1alpha1 "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestLivenessProbeWaitsForStartupBudget(t *testing.T) {
+	tests := []struct {
+		name         string
+		probes       *falaricav1alpha1.ProbesSpec
+		initialDelay int32
+	}{
+		{"default", nil, 600},
+		{"startup override", &falaricav1alpha1.ProbesSpec{
+			StartupProbe: &corev1.Probe{InitialDelaySeconds: 30, FailureThreshold: 120}}, 1230},
+		{"liveness override", &falaricav1alpha1.ProbesSpec{
+			LivenessProbe: &corev1.Probe{InitialDelaySeconds: 120}}, 120},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			presto := newTestPresto()
+			presto.Spec.Coordinator.Probes = test.probes
+			container := &corev1.Container{}
+			applyProbes(presto, container, true)
+			if container.LivenessProbe.InitialDelaySeconds != test.initialDelay {
+				t.Errorf("liveness initial delay %d, expected %d",
+					container.LivenessProbe.InitialDelaySeconds, test.initialDelay)
+			}
+			if container.LivenessProbe.FailureThreshold != 6 || container.LivenessProbe.HTTPGet == nil {
+				t.Errorf("unexpected liveness probe %v", container.LivenessProbe)
+			}
+		})
+	}
+}
@@ -0,0 +1,327 @@
+package presto
+
+import (
+	"context"
+	"fmt"
+	falaricav1alpha1 "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1"
+	"github.com/falarica/steerd-presto-operator/pkg/prestoclient"
+	v1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"time"
+)
+
+/*
+  CPU is a poor signal for Presto. A queue of queries can wait behind workers that
+  look idle. With the QueryLoad autoscaling mode, the operator scales the workers
+  using the query load reported by the coordinator:
+    - queued queries
+    - running queries
+    - splits of the running queries that are waiting for a worker thread
+  Presto reports the blocked splits only in the details of each query, which would take a
+  request per running query on each reconcile. The query list has the queued splits of each
+  query, which are the splits waiting for a worker thread. More workers help those splits,
+  whereas a split blocked on the source or on memory is not helped by more workers. So the
+  queued splits are used in place of the blocked splits.
+  The number of workers needed for each of the configured targets is computed and the
+  largest is taken. Like the HPA, the desired number of workers of each reconcile is
+  remembered. The workers are scaled up only to the lowest number desired during the
+  scale up window and scaled down only to the highest number desired during the scale
+  down window. A cooldown after each scaling prevents the workers from flapping. A scaling
+  deferred by a window or the cooldown is recorded as an event when the number of workers
+  it is waiting to scale to changes.
+  The history is kept in memory, so the windows start afresh when the operator restarts.
+*/
+
+const (
+	defaultScaleUpStabilizationWindowSeconds   = 60
+	defaultScaleDownStabilizationWindowSeconds = 300
+	defaultScaleUpCooldownSeconds              = 60
+	defaultScaleDownCooldownSeconds            = 300
+)
+
+// query load of the cluster as reported by the coordinator
+type queryLoad struct {
+	queuedQueries  int64
+	runningQueries int64
+	queuedSplits   int64
+}
+
+func (l queryLoad) String() string {
+	return fmt.Sprintf("queued queries: %d, running queries: %d, queued splits: %d",
+		l.queuedQueries, l.runningQueries, l.queuedSplits)
+}
+
+// desired number of workers computed at a point of time
+type workerRecommendation struct {
+	time     time.Time
+	replicas int32
+}
+
+func isQueryLoadAutoscalingEnabled(presto *falaricav1alpha1.Presto) bool {
+	return checkAutoscalingEnabled(presto) &&
+		presto.Spec.Worker.Autoscaling.Mode == falaricav1alpha1.QueryLoadAutoscalingMode
+}
+
+func secondsOrDefault(seconds *int32, defaultSeconds int32) time.Duration {
+	if seconds == nil {
+		return time.Duration(defaultSeconds) * time.Second
+	}
+	return time.Duration(*seconds) * time.Second
+}
+
+func ceilDiv(value int64, divisor int32) int32 {
+	return int32((value + int64(divisor) - 1) / int64(divisor))
+}
+
+// returns the number of workers needed for the query load, bounded by min and max replicas
+func getDesiredWorkersForLoad(spec *falaricav1alpha1.QueryLoadAutoscalingSpec, load queryLoad,
+	minReplicas int32, maxReplicas int32) int32 {
+	var desired int32 = 0
+	if spec.TargetQueuedQueriesPerWorker != nil {
+		if n := ceilDiv(load.queuedQueries, *spec.TargetQueuedQueriesPerWorker); n > desired {
+			desired = n
+		}
+	}
+	if spec.TargetRunningQueriesPerWorker != nil {
+		if n := ceilDiv(load.runningQueries, *spec.TargetRunningQueriesPerWorker); n > desired {
+			desired = n
+		}
+	}
+	if spec.TargetQueuedSplitsPerWorker != nil {
+		if n := ceilDiv(load.queuedSplits, *spec.TargetQueuedSplitsPerWorker); n > desired {
+			desired = n
+		}
+	}
+	if desired < minReplicas {
+		desired = minReplicas
+	}
+	if desired > maxReplicas {
+		desired = maxReplicas
+	}
+	return desired
+}
+
+// adds the recommendation to the history and returns the number of workers to scale to.
+// The recommendations older than both the windows are dropped from the history.
+func stabilizeRecommendation(history []workerRecommendation, now time.Time, current int32,
+	desired int32, scaleUpWindow time.Duration,
+	scaleDownWindow time.Duration) (int32, []workerRecommendation) {
+	history = append(history, workerRecommendation{time: now, replicas: desired})
+	retention := scaleUpWindow
+	if scaleDownWindow > retention {
+		retention = scaleDownWindow
+	}
+	var retained []workerRecommendation
+	for _, rec := range history {
+		if now.Sub(rec.time) <= retention {
+			retained = append(retained, rec)
+		}
+	}
+	stabilized := current
+	if desired > current {
+		// lowest recommendation within the scale up window
+		stabilized = desired
+		for _, rec := range retained {
+			if now.Sub(rec.time) <= scaleUpWindow && rec.replicas < stabilized {
+				stabilized = rec.replicas
+			}
+		}
+		if stabilized < current {
+			stabilized = current
+		}
+	} else if desired < current {
+		// highest recommendation within the scale down window
+		stabilized = desired
+		for _, rec := range retained {
+			if now.Sub(rec.time) <= scaleDownWindow && rec.replicas > stabilized {
+				stabilized = rec.replicas
+			}
+		}
+		if stabilized > current {
+			stabilized = current
+		}
+	}
+	return stabilized, retained
+}
+
+// returns whether the cooldown after the last scaling has passed for scaling
+// from current to target number of workers
+func isCooldownOver(spec *falaricav1alpha1.QueryLoadAutoscalingSpec, lastScaleTime *metav1.Time,
+	now time.Time, current int32, target int32) bool {
+	if lastScaleTime == nil {
+		return true
+	}
+	cooldown := secondsOrDefault(spec.ScaleDownCooldownSeconds, defaultScaleDownCooldownSeconds)
+	if target > current {
+		cooldown = secondsOrDefault(spec.ScaleUpCooldownSeconds, defaultScaleUpCooldownSeconds)
+	}
+	return now.Sub(lastScaleTime.Time) >= cooldown
+}
+
+// returns the query load of the cluster reported by the coordinator
+func (r *ReconcilePresto) getQueryLoad(presto *falaricav1alpha1.Presto) (*queryLoad, error) {
+	client := r.prestoClient(presto)
+	ctx := context.Background()
+	stats, err := client.Cluster(ctx)
+	if err != nil {
+		return nil, err
+	}
+	load := &queryLoad{
+		queuedQueries:  stats.QueuedQueries,
+		runningQueries: stats.RunningQueries,
+	}
+	if presto.Spec.Worker.Autoscaling.QueryLoad.TargetQueuedSplitsPerWorker != nil {
+		queries, err := client.Queries(ctx, prestoclient.QueryRunning)
+		if err != nil {
+			return nil, err
+		}
+		for _, query := range queries {
+			if query.State == prestoclient.QueryRunning {
+				load.queuedSplits += query.QueryStats.QueuedDrivers
+			}
+		}
+	}
+	return load, nil
+}
+
+// raises an event for a scaling deferred by the stabilization window or the cooldown. The event
+// is raised only when the number of workers waited for differs from that of the last event.
+func (r *ReconcilePresto) deferScaling(presto *falaricav1alpha1.Presto, key types.NamespacedName,
+	current int32, desired int32, waitingFor string, load *queryLoad) {
+	r.log.Info(fmt.Sprintf("PrestoCluster %s: %d workers desired for the query load, "+
+		"waiting for the %s. %s", presto.Name, desired, waitingFor, load))
+	if value, ok := r.deferredWorkers.Load(key); ok && value.(int32) == desired {
+		return
+	}
+	r.deferredWorkers.Store(key, desired)
+	r.eventRecorder.Eventf(presto, corev1.EventTypeNormal, "ScalingDeferred",
+		"Scaling workers from %d to %d is waiting for the %s. %s", current, desired, waitingFor, load)
+}
+
+// sets the number of replicas of the workload that manages the workers. On a scale down,
+// the workers to be removed are decommissioned first.
+func scaleWorkers(r *ReconcilePresto, presto *falaricav1alpha1.Presto, workerReplicaSet *v1.ReplicaSet,
+	workerDeployment *v1.Deployment, current int32, replicas int32) error {
+	if replicas < current {
+		removable, err := r.decommissionWorkers(presto, workerReplicaSet, workerDeployment, current-replicas)
+		if err != nil {
+			return err
+		}
+		replicas = current - removable
+	}
+	if workerDeployment != nil {
+		deploymentCopy := workerDeployment.DeepCopy()
+		deploymentCopy.Spec.Replicas = &replicas
+		return r.client.Update(context.Background(), deploymentCopy)
+	}
+	replicaSetCopy := workerReplicaSet.DeepCopy()
+	replicaSetCopy.Spec.Replicas = &replicas
+	return r.client.Update(context.Background(), replicaSetCopy)
+}
+
+// scales the workers based on the query load when the QueryLoad autoscaling is enabled.
+// returns error, changesMade
+func (r *ReconcilePresto) queryLoadAutoscale(presto *falaricav1alpha1.Presto,
+	ctx context.Context, workerReplicaSet *v1.ReplicaSet,
+	workerDeployment *v1.Deployment) (error, bool) {
+	key := types.NamespacedName{Namespace: presto.Namespace, Name: presto.Name}
+	if !isQueryLoadAutoscalingEnabled(presto) {
+		r.workerRecommendations.Delete(key)
+		r.deferredWorkers.Delete(key)
+		return nil, false
+	}
+	autoscaling := presto.Spec.Worker.Autoscaling
+	if autoscaling.MinReplicas == nil || autoscaling.MaxReplicas == nil || autoscaling.QueryLoad == nil {
+		err := &OperatorError{errormsg: "MinReplicas, MaxReplicas and QueryLoad have to be specified " +
+			"for QueryLoad autoscaling"}
+		errorReason := err.Error()
+		r.updateStatus(presto, ctx, ClusterUpdateAction{
+			errorReason:  &errorReason,
+			clusterState: falaricav1alpha1.ClusterFailedState,
+			conditions: failureConditions(presto, "AutoscalerFailed", errorReason,
+				falaricav1alpha1.ConditionAutoscalerReady),
+		})
+		r.eventRecorder.Eventf(presto, corev1.EventTypeWarning, "Failed",
+			"Failed to autoscale workers %s", errorReason)
+		return err, false
+	}
+	var current int32
+	if workerDeployment != nil {
+		current = *workerDeployment.Spec.Replicas
+	} else {
+		current = *workerReplicaSet.Spec.Replicas
+	}
+
+	load, err := r.getQueryLoad(presto)
+	if err != nil {
+		// the coordinator may be starting. The workers are scaled on a later reconcile.
+		r.log.Info(fmt.Sprintf("PrestoCluster %s: cannot get the query load: %s", presto.Name, err.Error()))
+		r.updateStatus(presto, ctx, ClusterUpdateAction{
+			conditions: []falaricav1alpha1.PrestoCondition{
+				newCondition(presto, falaricav1alpha1.ConditionAutoscalerReady, corev1.ConditionFalse,
+					"QueryLoadUnavailable", fmt.Sprintf("Cannot get the query load: %s", err.Error())),
+			},
+		})
+		return nil, false
+	}
+	r.updateStatus(presto, ctx, ClusterUpdateAction{
+		conditions: []falaricav1alpha1.PrestoCondition{
+			newCondition(presto, falaricav1alpha1.ConditionAutoscalerReady, corev1.ConditionTrue,
+				"QueryLoadAutoscaling", "Workers are scaled by the operator based on the query load"),
+		},
+	})
+
+	spec := autoscaling.QueryLoad
+	now := r.clock.Now()
+	desired := getDesiredWorkersForLoad(spec, *load, *autoscaling.MinReplicas, *autoscaling.MaxReplicas)
+	var history []workerRecommendation
+	if value, ok := r.workerRecommendations.Load(key); ok {
+		history = value.([]workerRecommendation)
+	}
+	target, history := stabilizeRecommendation(history, now, current, desired,
+		secondsOrDefault(spec.ScaleUpStabilizationWindowSeconds, defaultScaleUpStabilizationWindowSeconds),
+		secondsOrDefault(spec.ScaleDownStabilizationWindowSeconds, defaultScaleDownStabilizationWindowSeconds))
+	r.workerRecommendations.Store(key, history)
+	// bounds may have been changed in the spec
+	if current < *autoscaling.MinReplicas {
+		target = *autoscaling.MinReplicas
+	} else if current > *autoscaling.MaxReplicas {
+		target = *autoscaling.MaxReplicas
+	}
+	if target == current {
+		if desired != current {
+			r.deferScaling(presto, key, current, desired, "stabilization window", load)
+		} else {
+			r.deferredWorkers.Delete(key)
+		}
+		return nil, false
+	}
+	if !isCooldownOver(spec, presto.Status.LastScaleTime, now, current, target) {
+		r.deferScaling(presto, key, current, target, "cooldown", load)
+		return nil, false
+	}
+	r.deferredWorkers.Delete(key)
+
+	err = scaleWorkers(r, presto, workerReplicaSet, workerDeployment, current, target)
+	if err != nil {
+		r.log.Error(err, "failed to scale workers")
+		r.eventRecorder.Eventf(presto, corev1.EventTypeWarning, "Failed",
+			"Failed to scale workers from %d to %d %s", current, target, err.Error())
+		return err, false
+	}
+	reason := "ScaledDown"
+	if target > current {
+		reason = "ScaledUp"
+	}
+	r.eventRecorder.Eventf(presto, corev1.EventTypeNormal, reason,
+		"Scaled workers from %d to %d. %s", current, target, load)
+	r.log.Info(fmt.Sprintf("PrestoCluster %s: scaled workers from %d to %d. %s",
+		presto.Name, current, target, load))
+	scaleTime := metav1.NewTime(now)
+	r.updateStatus(presto, ctx, ClusterUpdateAction{
+		lastScaleTime: &scaleTime,
+	})
+	return nil, true
+}
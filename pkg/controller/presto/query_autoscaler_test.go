@@ -0,0 +1,116 @@
+package presto
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	falaricav1alpha1 "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1"
+	"github.com/falarica/steerd-presto-operator/pkg/prestoclient"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+func TestGetDesiredWorkersForLoad(t *testing.T) {
+	spec := &falaricav1alpha1.QueryLoadAutoscalingSpec{
+		TargetQueuedQueriesPerWorker:  int32Ptr(2),
+		TargetRunningQueriesPerWorker: int32Ptr(4),
+		TargetQueuedSplitsPerWorker:   int32Ptr(100),
+	}
+	tests := []struct {
+		name    string
+		load    queryLoad
+		desired int32
+	}{
+		{name: "idle", load: queryLoad{}, desired: 2},
+		{name: "queued queries", load: queryLoad{queuedQueries: 7}, desired: 4},
+		{name: "running queries", load: queryLoad{queuedQueries: 1, runningQueries: 21}, desired: 6},
+		{name: "queued splits", load: queryLoad{runningQueries: 4, queuedSplits: 750}, desired: 8},
+		{name: "max replicas", load: queryLoad{queuedQueries: 100}, desired: 10},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if desired := getDesiredWorkersForLoad(spec, test.load, 2, 10); desired != test.desired {
+				t.Errorf("%d workers desired, expected %d", desired, test.desired)
+			}
+		})
+	}
+}
+
+func TestQueryLoadAutoscaleDeferredEvents(t *testing.T) {
+	var requests int32
+	responses := map[string]string{"/v1/cluster": `{"queuedQueries":5}`}
+	server := newFakeCoordinator(responses, &requests)
+	defer server.Close()
+
+	ctx := context.Background()
+	now := mustParseTime(t, "2026-10-19T09:00:00Z")
+	presto := newTestPresto()
+	enabled := true
+	presto.Spec.Worker.Autoscaling = falaricav1alpha1.AutoscalingSpec{
+		Enabled:     &enabled,
+		Mode:        falaricav1alpha1.QueryLoadAutoscalingMode,
+		MinReplicas: int32Ptr(1),
+		MaxReplicas: int32Ptr(10),
+		QueryLoad: &falaricav1alpha1.QueryLoadAutoscalingSpec{
+			TargetQueuedQueriesPerWorker:      int32Ptr(1),
+			ScaleUpStabilizationWindowSeconds: int32Ptr(0),
+		},
+	}
+	lastScaleTime := metav1.NewTime(now)
+	presto.Status.LastScaleTime = &lastScaleTime
+	replicaSet := newTestWorkerReplicaSet(presto, 2)
+	r := newTestReconciler(t, presto, replicaSet)
+	fakeClock := clock.NewFakeClock(now)
+	r.clock = fakeClock
+	r.prestoClient = func(presto *falaricav1alpha1.Presto) *prestoclient.Client {
+		return prestoclient.New(server.URL, nil)
+	}
+	autoscale := func() []string {
+		replicaSet := getTestWorkerReplicaSet(t, r)
+		if err, _ := r.queryLoadAutoscale(getTestPresto(t, r), ctx, replicaSet, nil); err != nil {
+			t.Fatal(err)
+		}
+		return getTestEvents(r)
+	}
+
+	// the scale up waits for the cooldown of the last scaling
+	events := autoscale()
+	if len(events) != 1 || !strings.HasPrefix(events[0],
+		"Normal ScalingDeferred Scaling workers from 2 to 5 is waiting for the cooldown. queued queries: 5") {
+		t.Errorf("unexpected events %v", events)
+	}
+	// the same deferred scaling is not recorded again
+	if events = autoscale(); len(events) != 0 {
+		t.Errorf("unexpected events %v", events)
+	}
+	// a different number of workers is recorded
+	responses["/v1/cluster"] = `{"queuedQueries":7}`
+	fakeClock.SetTime(now.Add(time.Second))
+	events = autoscale()
+	if len(events) != 1 || !hasEvent(events, "Normal ScalingDeferred Scaling workers from 2 to 7") {
+		t.Errorf("unexpected events %v", events)
+	}
+
+	fakeClock.SetTime(now.Add(2 * time.Minute))
+	events = autoscale()
+	if len(events) != 1 || !hasEvent(events, "Normal ScaledUp Scaled workers from 2 to 7") {
+		t.Errorf("unexpected events %v", events)
+	}
+	if replicas := getTestWorkerReplicas(t, r); replicas != 7 {
+		t.Errorf("the workers were scaled to %d", replicas)
+	}
+
+	// the scale down waits for the stabilization window
+	responses["/v1/cluster"] = `{"queuedQueries":3}`
+	fakeClock.SetTime(now.Add(3 * time.Minute))
+	events = autoscale()
+	if len(events) != 1 || !hasEvent(events,
+		"Normal ScalingDeferred Scaling workers from 7 to 3 is waiting for the stabilization window") {
+		t.Errorf("unexpected events %v", events)
+	}
+	if events = autoscale(); len(events) != 0 {
+		t.Errorf("unexpected events %v", events)
+	}
+}
@@ -0,0 +1,422 @@
+package presto
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	falaricav1alpha1 "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1"
+	v1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sort"
+)
+
+/*
+  Presto reads config.properties, jvm.config and the catalog files only at startup.
+  A hash of the rendered configuration is stamped on the pod template of the coordinator
+  and the worker replicasets. Similarly, a hash of the pod spec is stamped on the pod
+  template whenever the image, resources or volumes change. A replicaset does not replace
+  its pods when the template changes. So when a pod does not have the hashes of the
+  template of its replicaset, the pods are restarted in the following order:
+    - workers one at a time. Deleting a worker pod runs the preStop shutdown script
+      which drains the worker before it goes away. The next worker is restarted only
+      once the replacement is ready.
+    - coordinator once all the workers are running with the new configuration. When the
+      coordinator runs as a statefulset, the statefulset replaces the coordinator pod.
+  The keystore of the TLS certificate is used only by the coordinator. Its hash is stamped
+  on the pod template of the coordinator alone, so a renewal restarts just the coordinator.
+*/
+
+// returns the hash over the coordinator, worker and catalog config maps, the catalog secrets
+// and the certificates of the internal communication provisioned by the operator
+func getConfigHash(r *ReconcilePresto, presto *falaricav1alpha1.Presto) (string, error) {
+	coordinatorConfig, err := buildConfigMap(presto, true, getCoordinatorConfigMapName(presto.Status.Uuid), nil)
+	if err != nil {
+		return "", err
+	}
+	workerConfig, err := buildConfigMap(presto, false, getWorkerConfigMapName(presto.Status.Uuid), nil)
+	if err != nil {
+		return "", err
+	}
+	catalogConfig, err := buildCatalogConfigMap(presto, getCatalogConfigMapName(presto.Status.Uuid), nil)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	for _, cm := range []*corev1.ConfigMap{coordinatorConfig, workerConfig, catalogConfig} {
+		hash.Write([]byte(cm.Name))
+		for _, key := range sortedKeys(cm.Data) {
+			hash.Write([]byte(key))
+			hash.Write([]byte(cm.Data[key]))
+		}
+	}
+	for _, catalogSecret := range presto.Spec.Catalogs.CatalogSecrets {
+		secret := &corev1.Secret{}
+		err := r.client.Get(context.TODO(), types.NamespacedName{
+			Namespace: presto.Namespace,
+			Name:      catalogSecret.SecretName,
+		}, secret)
+		if errors.IsNotFound(err) {
+			// the pods would not start without the secret. Nothing to hash.
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		hash.Write([]byte(catalogSecret.SecretName))
+		hash.Write([]byte(catalogSecret.SecretKey))
+		hash.Write(secret.Data[catalogSecret.SecretKey])
+	}
+	if isInternalTLSEnabled(presto) {
+		secret := &corev1.Secret{}
+		err := r.client.Get(context.TODO(), types.NamespacedName{
+			Namespace: presto.Namespace,
+			Name:      getInternalTLSSecretName(presto.Status.Uuid),
+		}, secret)
+		if err != nil && !errors.IsNotFound(err) {
+			return "", err
+		}
+		for _, key := range []string{tlsKeystoreKey, tlsTruststoreKey} {
+			hash.Write(secret.Data[key])
+		}
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil))[:16], nil
+}
+
+// returns the hash of the keystore of the coordinator TLS certificate, or an empty string
+// when TLS is not enabled. A renewed certificate is picked up by the coordinator on restart.
+func getCoordinatorTLSHash(r *ReconcilePresto, presto *falaricav1alpha1.Presto) (string, error) {
+	if !isTLSEnabled(presto) {
+		return "", nil
+	}
+	secret := &corev1.Secret{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{
+		Namespace: presto.Namespace,
+		Name:      getCoordinatorTLSSecretName(presto.Status.Uuid),
+	}, secret)
+	if err != nil && !errors.IsNotFound(err) {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(secret.Data[tlsKeystoreKey]))[:16], nil
+}
+
+// returns the hash of the pod spec. The pod template of a replicaset gets defaulted
+// by the API server, so the hash is compared instead of the spec itself
+func getPodSpecHash(podSpec *corev1.PodSpec) (string, error) {
+	podSpecJSON, err := json.Marshal(podSpec)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(podSpecJSON))[:16], nil
+}
+
+// returns whether the pod has been created from the current template of the replicaset
+func isPodUpToDate(pod *corev1.Pod, replicaSet *v1.ReplicaSet) bool {
+	for _, annotation := range []string{configHashAnnotation, podSpecHashAnnotation, tlsHashAnnotation} {
+		if pod.Annotations[annotation] != replicaSet.Spec.Template.Annotations[annotation] {
+			return false
+		}
+	}
+	return true
+}
+
+// replaces the spec of the pod template with the desired one if they differ.
+// returns whether the replicaset was updated
+func updateReplicaSetPodTemplate(r *ReconcilePresto, replicaSet *v1.ReplicaSet,
+	desired *v1.ReplicaSet) (bool, error) {
+	desiredHash := desired.Spec.Template.Annotations[podSpecHashAnnotation]
+	if replicaSet.Spec.Template.Annotations[podSpecHashAnnotation] == desiredHash {
+		return false, nil
+	}
+	replicaSetCopy := replicaSet.DeepCopy()
+	if replicaSetCopy.Spec.Template.Annotations == nil {
+		replicaSetCopy.Spec.Template.Annotations = make(map[string]string)
+	}
+	replicaSetCopy.Spec.Template.Annotations[podSpecHashAnnotation] = desiredHash
+	replicaSetCopy.Spec.Template.Spec = desired.Spec.Template.Spec
+	err := r.client.Update(context.Background(), replicaSetCopy)
+	if err != nil {
+		return false, err
+	}
+	replicaSetCopy.DeepCopyInto(replicaSet)
+	return true, nil
+}
+
+// returns the pods of the cluster for the given role sorted by name
+func getPrestoPods(r *ReconcilePresto, presto *falaricav1alpha1.Presto,
+	getLabel func(string) (string, string)) ([]corev1.Pod, error) {
+	podList := &corev1.PodList{}
+	k, v := getLabel(presto.Status.Uuid)
+	err := r.client.List(context.TODO(),
+		podList,
+		&client.ListOptions{
+			Namespace:     presto.Namespace,
+			LabelSelector: labels.SelectorFromSet(labels.Set{k: v}),
+		})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(podList.Items, func(i, j int) bool {
+		return podList.Items[i].Name < podList.Items[j].Name
+	})
+	return podList.Items, nil
+}
+
+func isPodReady(pod *corev1.Pod) bool {
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodReady {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// sets the config and TLS hashes on the pod template. An empty TLS hash removes it.
+// returns whether the template was changed
+func setTemplateHashes(template *corev1.PodTemplateSpec, configHash string, tlsHash string) bool {
+	if template.Annotations[configHashAnnotation] == configHash &&
+		template.Annotations[tlsHashAnnotation] == tlsHash {
+		return false
+	}
+	if template.Annotations == nil {
+		template.Annotations = make(map[string]string)
+	}
+	template.Annotations[configHashAnnotation] = configHash
+	if len(tlsHash) == 0 {
+		delete(template.Annotations, tlsHashAnnotation)
+	} else {
+		template.Annotations[tlsHashAnnotation] = tlsHash
+	}
+	return true
+}
+
+// stamps the config hash, and the TLS hash of the coordinator, on the pod template so that
+// the new pods come up with the latest hashes. returns whether the replicaset was updated
+func updateReplicaSetConfigHash(r *ReconcilePresto, replicaSet *v1.ReplicaSet,
+	configHash string, tlsHash string) (bool, error) {
+	replicaSetCopy := replicaSet.DeepCopy()
+	if !setTemplateHashes(&replicaSetCopy.Spec.Template, configHash, tlsHash) {
+		return false, nil
+	}
+	err := r.client.Update(context.Background(), replicaSetCopy)
+	if err != nil {
+		return false, err
+	}
+	replicaSetCopy.DeepCopyInto(replicaSet)
+	return true, nil
+}
+
+// restarts at most one pod which is not running with the current template of the
+// replicaset. A pod is restarted only when the pods restarted earlier are ready.
+// returns whether all the pods are up to date, number of up to date pods,
+// the name of the pod that has been restarted, error
+func restartOutdatedPod(r *ReconcilePresto, pods []corev1.Pod,
+	replicaSet *v1.ReplicaSet) (bool, int32, string, error) {
+	replicas := *replicaSet.Spec.Replicas
+	var updated int32 = 0
+	var outdated []corev1.Pod
+	terminating := false
+	settling := false
+	for _, pod := range pods {
+		if pod.DeletionTimestamp != nil {
+			// wait for the pod that is being drained
+			terminating = true
+			continue
+		}
+		if !isPodUpToDate(&pod, replicaSet) {
+			outdated = append(outdated, pod)
+			continue
+		}
+		updated++
+		if !isPodReady(&pod) {
+			settling = true
+		}
+	}
+	if len(outdated) == 0 {
+		return !terminating && updated >= replicas, updated, "", nil
+	}
+	if terminating || settling || updated+int32(len(outdated)) < replicas {
+		// previous restart has not yet settled.
+		return false, updated, "", nil
+	}
+	pod := outdated[0]
+	err := r.client.Delete(context.Background(), &pod)
+	if err != nil && !errors.IsNotFound(err) {
+		return false, updated, "", err
+	}
+	return false, updated, pod.Name, nil
+}
+
+// restarts the workers that are not running with the latest configuration or pod spec.
+// returns whether all the workers are up to date, number of up to date workers, error
+func (r *ReconcilePresto) rollWorkers(presto *falaricav1alpha1.Presto,
+	configHash string) (bool, int32, error) {
+	if isWorkerDeploymentEnabled(presto) {
+		// the deployment replaces the workers as per its update strategy
+		deployment, err := getWorkerDeployment(r, presto)
+		if err != nil {
+			return false, 0, err
+		}
+		if _, err := updateDeploymentConfigHash(r, deployment, configHash); err != nil {
+			r.log.Error(err, "failed to update config hash of deployment "+deployment.Name)
+			return false, 0, err
+		}
+		done, updatedWorkers := isDeploymentRolledOut(deployment)
+		return done, updatedWorkers, nil
+	}
+	workerReplicaSet, err := getReplicaSet(r, presto, getWorkerPodLabel)
+	if err != nil {
+		return false, 0, err
+	}
+	if _, err := updateReplicaSetConfigHash(r, workerReplicaSet, configHash, ""); err != nil {
+		r.log.Error(err, "failed to update config hash of replicaset "+workerReplicaSet.Name)
+		return false, 0, err
+	}
+	workerPods, err := getPrestoPods(r, presto, getWorkerPodLabel)
+	if err != nil {
+		return false, 0, err
+	}
+	workersDone, updatedWorkers, restartedPod, err := restartOutdatedPod(r, workerPods,
+		workerReplicaSet)
+	if err != nil {
+		r.log.Error(err, "failed to restart worker pod")
+		return false, 0, err
+	}
+	if restartedPod != "" {
+		r.eventRecorder.Eventf(presto, corev1.EventTypeNormal, "Restarting",
+			"Restarting worker pod %s to apply the latest configuration", restartedPod)
+	}
+	return workersDone, updatedWorkers, nil
+}
+
+// restarts the coordinator if it is not running with the latest configuration or pod spec.
+// coordinatorReplicaSet is nil when the coordinator runs as a statefulset.
+// returns whether the coordinator is up to date, error
+func (r *ReconcilePresto) rollCoordinator(presto *falaricav1alpha1.Presto,
+	coordinatorReplicaSet *v1.ReplicaSet, configHash string, tlsHash string) (bool, error) {
+	coordinatorPods, err := getPrestoPods(r, presto, getCoordinatorPodLabel)
+	if err != nil {
+		return false, err
+	}
+	var restartedPod string
+	coordinatorDone := false
+	if coordinatorReplicaSet == nil {
+		// the statefulset replaces the coordinator once the config hash is stamped.
+		// It is stamped only after the workers are done.
+		var statefulSet *v1.StatefulSet
+		statefulSet, err = getCoordinatorStatefulSet(r, presto)
+		if err != nil {
+			return false, err
+		}
+		if _, err := updateStatefulSetConfigHash(r, statefulSet, configHash, tlsHash); err != nil {
+			r.log.Error(err, "failed to update config hash of statefulset "+statefulSet.Name)
+			return false, err
+		}
+		coordinatorDone = isStatefulSetRolledOut(statefulSet)
+		if !coordinatorDone {
+			restartedPod, err = restartStuckStatefulSetPod(r, coordinatorPods, statefulSet)
+		}
+	} else {
+		coordinatorDone, _, restartedPod, err = restartOutdatedPod(r, coordinatorPods,
+			coordinatorReplicaSet)
+	}
+	if err != nil {
+		r.log.Error(err, "failed to restart coordinator pod")
+		return false, err
+	}
+	if restartedPod != "" {
+		r.eventRecorder.Eventf(presto, corev1.EventTypeNormal, "Restarting",
+			"Restarting coordinator pod %s to apply the latest configuration", restartedPod)
+	}
+	return coordinatorDone, nil
+}
+
+// rolls the pods of the cluster when the configuration changes.
+// returns error, rolloutInProgress
+func (r *ReconcilePresto) configRollout(presto *falaricav1alpha1.Presto,
+	baseLabels map[string]string,
+	ctx context.Context) (error, bool) {
+	configHash, err := getConfigHash(r, presto)
+	if err != nil {
+		r.log.Error(err, "failed to compute the config hash")
+		return err, false
+	}
+	tlsHash, err := getCoordinatorTLSHash(r, presto)
+	if err != nil {
+		r.log.Error(err, "failed to compute the TLS hash")
+		return err, false
+	}
+	var coordinatorReplicaSet *v1.ReplicaSet
+	if !isCoordinatorStatefulSetEnabled(presto) {
+		coordinatorReplicaSet, err = getReplicaSet(r, presto, getCoordinatorPodLabel)
+		if err != nil {
+			return err, false
+		}
+		if _, err := updateReplicaSetConfigHash(r, coordinatorReplicaSet, configHash, tlsHash); err != nil {
+			r.log.Error(err, "failed to update config hash of replicaset "+coordinatorReplicaSet.Name)
+			return err, false
+		}
+	}
+
+	workersDone, updatedWorkers, err := r.rollWorkers(presto, configHash)
+	if err != nil {
+		return err, false
+	}
+	poolsDone, updatedPoolWorkers, err := r.rollWorkerPools(presto)
+	if err != nil {
+		return err, false
+	}
+	workersDone = workersDone && poolsDone
+	updatedWorkers += updatedPoolWorkers
+	if !workersDone {
+		state := falaricav1alpha1.RolloutWorkers
+		r.updateStatus(presto, ctx, ClusterUpdateAction{
+			rolloutState:   &state,
+			updatedWorkers: &updatedWorkers,
+			conditions: []falaricav1alpha1.PrestoCondition{
+				newCondition(presto, falaricav1alpha1.ConditionConfigApplied, corev1.ConditionFalse,
+					string(state), fmt.Sprintf("%d workers are running with the configuration %s",
+						updatedWorkers, configHash)),
+			},
+		})
+		return nil, true
+	}
+
+	coordinatorDone, err := r.rollCoordinator(presto, coordinatorReplicaSet, configHash, tlsHash)
+	if err != nil {
+		return err, false
+	}
+	if !coordinatorDone {
+		state := falaricav1alpha1.RolloutCoordinator
+		r.updateStatus(presto, ctx, ClusterUpdateAction{
+			rolloutState:   &state,
+			updatedWorkers: &updatedWorkers,
+			conditions: []falaricav1alpha1.PrestoCondition{
+				newCondition(presto, falaricav1alpha1.ConditionConfigApplied, corev1.ConditionFalse,
+					string(state), fmt.Sprintf("Coordinator is being restarted with the configuration %s",
+						configHash)),
+			},
+		})
+		return nil, true
+	}
+
+	if presto.Status.RolloutState != falaricav1alpha1.RolloutComplete {
+		r.eventRecorder.Eventf(presto, corev1.EventTypeNormal, "RolledOut",
+			"All the pods are running with the configuration %s", configHash)
+	}
+	state := falaricav1alpha1.RolloutComplete
+	r.updateStatus(presto, ctx, ClusterUpdateAction{
+		configHash:     &configHash,
+		rolloutState:   &state,
+		updatedWorkers: &updatedWorkers,
+		conditions: []falaricav1alpha1.PrestoCondition{
+			newCondition(presto, falaricav1alpha1.ConditionConfigApplied, corev1.ConditionTrue,
+				"ConfigApplied", fmt.Sprintf("All the pods are running with the configuration %s", configHash)),
+		},
+	})
+	return nil, false
+}
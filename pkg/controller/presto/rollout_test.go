@@ -0,0 +1,90 @@
+package presto
+
+import (
+	"context"
+	"testing"
+
+	falaricav1alpha1 "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestTLSRenewalRestartsOnlyCoordinator(t *testing.T) {
+	presto := newTestPresto()
+	presto.Spec.Coordinator.HttpsEnabled = true
+	presto.Spec.Coordinator.Tls = &falaricav1alpha1.TLSSpec{}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      getCoordinatorTLSSecretName(testClusterUUID),
+			Namespace: testNamespace,
+		},
+		Data: map[string][]byte{tlsKeystoreKey: []byte("certificate")},
+	}
+	r := newTestReconciler(t, presto, secret)
+	configHash, err := getConfigHash(r, presto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tlsHash, err := getCoordinatorTLSHash(r, presto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	workerReplicaSet := newTestWorkerReplicaSet(presto, 2)
+	workerReplicaSet.Spec.Template.Annotations = map[string]string{configHashAnnotation: configHash}
+	coordinatorReplicaSet := newTestWorkerReplicaSet(presto, 1)
+	coordinatorReplicaSet.Name = "coordinator-" + testClusterUUID[:8]
+	coordinatorReplicaSet.Spec.Template.Annotations = map[string]string{
+		configHashAnnotation: configHash, tlsHashAnnotation: tlsHash}
+	if err := r.client.Create(context.Background(), workerReplicaSet); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.client.Create(context.Background(), coordinatorReplicaSet); err != nil {
+		t.Fatal(err)
+	}
+	coordinator := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Annotations: coordinatorReplicaSet.Spec.Template.Annotations}}
+
+	// the certificate is renewed
+	secret.Data[tlsKeystoreKey] = []byte("renewed certificate")
+	if err := r.client.Update(context.Background(), secret); err != nil {
+		t.Fatal(err)
+	}
+	renewedConfigHash, err := getConfigHash(r, presto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renewedConfigHash != configHash {
+		t.Error("the config hash of the workers changed with the certificate")
+	}
+	renewedTLSHash, err := getCoordinatorTLSHash(r, presto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renewedTLSHash == tlsHash {
+		t.Error("the TLS hash did not change with the certificate")
+	}
+	updated, err := updateReplicaSetConfigHash(r, workerReplicaSet, renewedConfigHash, "")
+	if err != nil || updated {
+		t.Errorf("the worker replicaset was updated: %v %v", updated, err)
+	}
+	updated, err = updateReplicaSetConfigHash(r, coordinatorReplicaSet, renewedConfigHash, renewedTLSHash)
+	if err != nil || !updated {
+		t.Errorf("the coordinator replicaset was not updated: %v %v", updated, err)
+	}
+	if isPodUpToDate(coordinator, coordinatorReplicaSet) {
+		t.Error("the coordinator is not restarted with the renewed certificate")
+	}
+
+	// disabling TLS removes the hash
+	presto.Spec.Coordinator.Tls = nil
+	disabledTLSHash, err := getCoordinatorTLSHash(r, presto)
+	if err != nil || len(disabledTLSHash) != 0 {
+		t.Fatalf("TLS hash %q without TLS: %v", disabledTLSHash, err)
+	}
+	if _, err := updateReplicaSetConfigHash(r, coordinatorReplicaSet, renewedConfigHash, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := coordinatorReplicaSet.Spec.Template.Annotations[tlsHashAnnotation]; ok {
+		t.Error("the TLS hash was not removed from the coordinator replicaset")
+	}
+}
@@ -0,0 +1,223 @@
+package presto
+
+import (
+	"context"
+	"fmt"
+	falaricav1alpha1 "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1"
+	"github.com/robfig/cron/v3"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sort"
+	"time"
+)
+
+/*
+  The number of workers can be changed on a schedule. Each scaling schedule is a window
+  that starts at the times matched by its cron expression and lasts for its duration.
+  While a window is active, its count replaces spec.worker.count, or its min and max
+  replicas replace those of the autoscaling. The override is applied to the in-memory
+  copy of the spec before the worker steps of Reconcile, so the replicaset, the deployment,
+  the HPA and the query load autoscaling all see the overridden values. Nothing is written
+  to the spec. When windows overlap, the first one in the list wins.
+  The windows are evaluated on every reconcile using the clock of the reconciler, so a
+  transition is applied within the status update interval.
+*/
+
+// limit on the number of window boundaries looked at to find the next transition
+const maxScheduleTransitionLookahead = 16
+
+type parsedSchedule struct {
+	schedule cron.Schedule
+	duration time.Duration
+}
+
+type scalingScheduleStatus struct {
+	activeSchedule string
+	nextTransition *metav1.Time
+}
+
+func parseScalingSchedules(presto *falaricav1alpha1.Presto) ([]parsedSchedule, error) {
+	var parsed []parsedSchedule
+	names := make(map[string]bool)
+	for _, s := range presto.Spec.Worker.Schedules {
+		if names[s.Name] {
+			return nil, &OperatorError{fmt.Sprintf("duplicate scaling schedule %s", s.Name)}
+		}
+		names[s.Name] = true
+		timeZone := s.TimeZone
+		if len(timeZone) == 0 {
+			timeZone = "UTC"
+		}
+		if _, err := time.LoadLocation(timeZone); err != nil {
+			return nil, &OperatorError{fmt.Sprintf("invalid time zone of scaling schedule %s: "+
+				"'%v': %v", s.Name, s.TimeZone, err)}
+		}
+		schedule, err := cron.ParseStandard(fmt.Sprintf("CRON_TZ=%s %s", timeZone, s.Schedule))
+		if err != nil {
+			return nil, &OperatorError{fmt.Sprintf("invalid cron expression of scaling schedule %s: "+
+				"'%v': %v", s.Name, s.Schedule, err)}
+		}
+		if s.Duration.Duration <= 0 {
+			return nil, &OperatorError{fmt.Sprintf("duration of scaling schedule %s has to be positive",
+				s.Name)}
+		}
+		// a schedule that would not change anything is rejected rather than ignored
+		if checkAutoscalingEnabled(presto) && s.MinReplicas == nil && s.MaxReplicas == nil {
+			return nil, &OperatorError{fmt.Sprintf("scaling schedule %s has to specify minReplicas or "+
+				"maxReplicas as autoscaling is enabled. count applies only without autoscaling", s.Name)}
+		}
+		// the bounds are checked as merged with those of the spec, as they are applied
+		if checkAutoscalingEnabled(presto) {
+			minReplicas, maxReplicas := presto.Spec.Worker.Autoscaling.MinReplicas, presto.Spec.Worker.Autoscaling.MaxReplicas
+			if s.MinReplicas != nil {
+				minReplicas = s.MinReplicas
+			}
+			if s.MaxReplicas != nil {
+				maxReplicas = s.MaxReplicas
+			}
+			if minReplicas != nil && maxReplicas != nil && *minReplicas > *maxReplicas {
+				return nil, &OperatorError{fmt.Sprintf("scaling schedule %s would set minReplicas %d above "+
+					"maxReplicas %d", s.Name, *minReplicas, *maxReplicas)}
+			}
+		}
+		if !checkAutoscalingEnabled(presto) && s.Count == nil {
+			return nil, &OperatorError{fmt.Sprintf("scaling schedule %s has to specify count as autoscaling "+
+				"is not enabled. minReplicas and maxReplicas apply only with autoscaling", s.Name)}
+		}
+		parsed = append(parsed, parsedSchedule{schedule: schedule, duration: s.Duration.Duration})
+	}
+	return parsed, nil
+}
+
+// returns whether the window is active at the time and, if so, the end of the window
+// that started first
+func getScheduleWindow(schedule parsedSchedule, t time.Time) (bool, time.Time) {
+	start := schedule.schedule.Next(t.Add(-schedule.duration))
+	if start.IsZero() || start.After(t) {
+		return false, time.Time{}
+	}
+	return true, start.Add(schedule.duration)
+}
+
+// returns the index of the schedule active at the time or -1
+func getActiveSchedule(schedules []parsedSchedule, t time.Time) int {
+	for i, schedule := range schedules {
+		if active, _ := getScheduleWindow(schedule, t); active {
+			return i
+		}
+	}
+	return -1
+}
+
+// returns the next time after now at which the active schedule changes, or nil if
+// it does not change within the lookahead
+func getNextScheduleTransition(schedules []parsedSchedule, now time.Time) *time.Time {
+	current := getActiveSchedule(schedules, now)
+	from := now
+	for i := 0; i < maxScheduleTransitionLookahead; i++ {
+		var boundaries []time.Time
+		for _, schedule := range schedules {
+			if next := schedule.schedule.Next(from); !next.IsZero() {
+				boundaries = append(boundaries, next)
+			}
+			if active, end := getScheduleWindow(schedule, from); active {
+				boundaries = append(boundaries, end)
+			}
+		}
+		if len(boundaries) == 0 {
+			return nil
+		}
+		sort.Slice(boundaries, func(i, j int) bool { return boundaries[i].Before(boundaries[j]) })
+		for _, boundary := range boundaries {
+			if getActiveSchedule(schedules, boundary) != current {
+				return &boundary
+			}
+		}
+		from = boundaries[len(boundaries)-1]
+	}
+	return nil
+}
+
+// overrides the worker count or the autoscaling bounds in the in-memory spec
+func applyScalingSchedule(presto *falaricav1alpha1.Presto, schedule falaricav1alpha1.ScalingSchedule) {
+	if checkAutoscalingEnabled(presto) {
+		if schedule.MinReplicas != nil {
+			presto.Spec.Worker.Autoscaling.MinReplicas = schedule.MinReplicas
+		}
+		if schedule.MaxReplicas != nil {
+			presto.Spec.Worker.Autoscaling.MaxReplicas = schedule.MaxReplicas
+		}
+	} else if schedule.Count != nil {
+		presto.Spec.Worker.Count = schedule.Count
+	}
+}
+
+func describeScalingSchedule(schedule falaricav1alpha1.ScalingSchedule) string {
+	description := ""
+	if schedule.Count != nil {
+		description += fmt.Sprintf(" count: %d", *schedule.Count)
+	}
+	if schedule.MinReplicas != nil {
+		description += fmt.Sprintf(" minReplicas: %d", *schedule.MinReplicas)
+	}
+	if schedule.MaxReplicas != nil {
+		description += fmt.Sprintf(" maxReplicas: %d", *schedule.MaxReplicas)
+	}
+	return description
+}
+
+// applies the active scaling schedule to the spec and records it in the status.
+// An event is raised when a window starts or ends.
+func (r *ReconcilePresto) scalingSchedule(presto *falaricav1alpha1.Presto, ctx context.Context) error {
+	schedules, err := parseScalingSchedules(presto)
+	if err != nil {
+		errorReason := err.Error()
+		r.updateStatus(presto, ctx, ClusterUpdateAction{
+			errorReason:  &errorReason,
+			clusterState: falaricav1alpha1.ClusterFailedState,
+			conditions:   failureConditions(presto, "InvalidSchedule", errorReason),
+		})
+		r.eventRecorder.Eventf(presto, corev1.EventTypeWarning, "Failed",
+			"Failed to apply scaling schedules %s", errorReason)
+		return err
+	}
+	now := r.clock.Now()
+	activeSchedule := ""
+	activeDescription := ""
+	var nextTransition *metav1.Time
+	if len(schedules) != 0 {
+		if active := getActiveSchedule(schedules, now); active >= 0 {
+			schedule := presto.Spec.Worker.Schedules[active]
+			activeSchedule = schedule.Name
+			activeDescription = describeScalingSchedule(schedule)
+			applyScalingSchedule(presto, schedule)
+		}
+		if next := getNextScheduleTransition(schedules, now); next != nil {
+			nextTime := metav1.NewTime(*next)
+			nextTransition = &nextTime
+		}
+	}
+
+	previousSchedule := presto.Status.ActiveSchedule
+	if previousSchedule != activeSchedule {
+		if len(previousSchedule) != 0 {
+			r.eventRecorder.Eventf(presto, corev1.EventTypeNormal, "ScheduleEnded",
+				"Scaling schedule %s has ended", previousSchedule)
+		}
+		if len(activeSchedule) != 0 {
+			r.eventRecorder.Eventf(presto, corev1.EventTypeNormal, "ScheduleStarted",
+				"Scaling schedule %s has started.%s", activeSchedule, activeDescription)
+		}
+		r.log.Info(fmt.Sprintf("PrestoCluster %s: active scaling schedule changed from '%s' to '%s'",
+			presto.Name, previousSchedule, activeSchedule))
+	}
+	if previousSchedule != activeSchedule || !presto.Status.NextScheduleTransition.Equal(nextTransition) {
+		r.updateStatus(presto, ctx, ClusterUpdateAction{
+			scalingSchedule: &scalingScheduleStatus{
+				activeSchedule: activeSchedule,
+				nextTransition: nextTransition,
+			},
+		})
+	}
+	return nil
+}
@@ -0,0 +1,307 @@
+package presto
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	falaricav1alpha1 "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/tools/record"
+)
+
+func mustParseTime(t *testing.T, value string) time.Time {
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed
+}
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
+
+func newTestSchedule(name string, cronExpr string, duration time.Duration, timeZone string) falaricav1alpha1.ScalingSchedule {
+	return falaricav1alpha1.ScalingSchedule{
+		Name:     name,
+		Schedule: cronExpr,
+		Duration: metav1.Duration{Duration: duration},
+		TimeZone: timeZone,
+		Count:    int32Ptr(10),
+	}
+}
+
+func mustParseSchedules(t *testing.T, schedules ...falaricav1alpha1.ScalingSchedule) []parsedSchedule {
+	presto := newTestPresto()
+	presto.Spec.Worker.Schedules = schedules
+	parsed, err := parseScalingSchedules(presto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed
+}
+
+// 2026-10-19 is a Monday. New York is on EDT, UTC-4, at that time.
+func TestGetScheduleWindow(t *testing.T) {
+	weekdays := newTestSchedule("business", "0 8 * * 1-5", 10*time.Hour, "")
+	overnight := newTestSchedule("overnight", "0 22 * * *", 4*time.Hour, "UTC")
+	newYork := newTestSchedule("newyork", "0 8 * * *", 2*time.Hour, "America/New_York")
+	tests := []struct {
+		name     string
+		schedule falaricav1alpha1.ScalingSchedule
+		now      string
+		active   bool
+		end      string
+	}{
+		{"inside the window", weekdays, "2026-10-19T09:00:00Z", true, "2026-10-19T18:00:00Z"},
+		{"at the start", weekdays, "2026-10-19T08:00:00Z", true, "2026-10-19T18:00:00Z"},
+		{"at the end", weekdays, "2026-10-19T18:00:00Z", false, ""},
+		{"before the start", weekdays, "2026-10-19T07:59:00Z", false, ""},
+		{"on a day not in the schedule", weekdays, "2026-10-24T10:00:00Z", false, ""},
+		{"across midnight", overnight, "2026-10-20T01:00:00Z", true, "2026-10-20T02:00:00Z"},
+		{"in the time zone", newYork, "2026-10-19T12:30:00Z", true, "2026-10-19T14:00:00Z"},
+		{"at the same hour in UTC", newYork, "2026-10-19T08:30:00Z", false, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			parsed := mustParseSchedules(t, test.schedule)
+			active, end := getScheduleWindow(parsed[0], mustParseTime(t, test.now))
+			if active != test.active {
+				t.Fatalf("active %v, expected %v", active, test.active)
+			}
+			if test.active && !end.Equal(mustParseTime(t, test.end)) {
+				t.Errorf("end %v, expected %s", end, test.end)
+			}
+		})
+	}
+}
+
+func TestGetNextScheduleTransition(t *testing.T) {
+	business := newTestSchedule("business", "0 8 * * 1-5", 10*time.Hour, "")
+	peak := newTestSchedule("peak", "0 12 * * *", 2*time.Hour, "")
+	newYork := newTestSchedule("newyork", "0 8 * * *", 2*time.Hour, "America/New_York")
+	tests := []struct {
+		name      string
+		schedules []falaricav1alpha1.ScalingSchedule
+		now       string
+		active    int
+		next      string
+	}{
+		{"before the window", []falaricav1alpha1.ScalingSchedule{business},
+			"2026-10-19T07:00:00Z", -1, "2026-10-19T08:00:00Z"},
+		{"inside the window", []falaricav1alpha1.ScalingSchedule{business},
+			"2026-10-19T09:00:00Z", 0, "2026-10-19T18:00:00Z"},
+		{"over the weekend", []falaricav1alpha1.ScalingSchedule{business},
+			"2026-10-23T19:00:00Z", -1, "2026-10-26T08:00:00Z"},
+		{"overlap with the first one winning", []falaricav1alpha1.ScalingSchedule{business, peak},
+			"2026-10-19T13:00:00Z", 0, "2026-10-19T18:00:00Z"},
+		{"before an overlapping window", []falaricav1alpha1.ScalingSchedule{peak, business},
+			"2026-10-19T11:00:00Z", 1, "2026-10-19T12:00:00Z"},
+		{"inside an overlapping window", []falaricav1alpha1.ScalingSchedule{peak, business},
+			"2026-10-19T13:00:00Z", 0, "2026-10-19T14:00:00Z"},
+		{"in the time zone", []falaricav1alpha1.ScalingSchedule{newYork},
+			"2026-10-19T11:00:00Z", -1, "2026-10-19T12:00:00Z"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			parsed := mustParseSchedules(t, test.schedules...)
+			now := mustParseTime(t, test.now)
+			if active := getActiveSchedule(parsed, now); active != test.active {
+				t.Errorf("active schedule %d, expected %d", active, test.active)
+			}
+			next := getNextScheduleTransition(parsed, now)
+			if next == nil || !next.Equal(mustParseTime(t, test.next)) {
+				t.Errorf("next transition %v, expected %s", next, test.next)
+			}
+		})
+	}
+	if next := getNextScheduleTransition(nil, time.Now()); next != nil {
+		t.Errorf("next transition %v without schedules", next)
+	}
+}
+
+func getTestPresto(t *testing.T, r *ReconcilePresto) *falaricav1alpha1.Presto {
+	presto := &falaricav1alpha1.Presto{}
+	err := r.client.Get(context.Background(),
+		types.NamespacedName{Namespace: testNamespace, Name: testClusterName}, presto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return presto
+}
+
+// returns the events recorded so far
+func getTestEvents(r *ReconcilePresto) []string {
+	var events []string
+	recorder := r.eventRecorder.(*record.FakeRecorder)
+	for {
+		select {
+		case event := <-recorder.Events:
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+func hasEvent(events []string, prefix string) bool {
+	for _, event := range events {
+		if strings.HasPrefix(event, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestScalingSchedule(t *testing.T) {
+	presto := newTestPresto()
+	presto.Spec.Worker.Count = int32Ptr(2)
+	presto.Spec.Worker.Schedules = []falaricav1alpha1.ScalingSchedule{
+		newTestSchedule("business", "0 8 * * 1-5", 10*time.Hour, "")}
+	r := newTestReconciler(t, presto)
+	fakeClock := clock.NewFakeClock(mustParseTime(t, "2026-10-19T09:00:00Z"))
+	r.clock = fakeClock
+
+	presto = getTestPresto(t, r)
+	if err := r.scalingSchedule(presto, context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if *presto.Spec.Worker.Count != 10 {
+		t.Errorf("count %d inside the window, expected 10", *presto.Spec.Worker.Count)
+	}
+	status := getTestPresto(t, r).Status
+	if status.ActiveSchedule != "business" ||
+		!status.NextScheduleTransition.Time.Equal(mustParseTime(t, "2026-10-19T18:00:00Z")) {
+		t.Errorf("unexpected status inside the window %s %v", status.ActiveSchedule, status.NextScheduleTransition)
+	}
+	if events := getTestEvents(r); !hasEvent(events, "Normal ScheduleStarted") {
+		t.Errorf("no ScheduleStarted event in %v", events)
+	}
+
+	fakeClock.SetTime(mustParseTime(t, "2026-10-19T18:30:00Z"))
+	presto = getTestPresto(t, r)
+	if err := r.scalingSchedule(presto, context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if *presto.Spec.Worker.Count != 2 {
+		t.Errorf("count %d after the window, expected 2", *presto.Spec.Worker.Count)
+	}
+	status = getTestPresto(t, r).Status
+	if status.ActiveSchedule != "" ||
+		!status.NextScheduleTransition.Time.Equal(mustParseTime(t, "2026-10-20T08:00:00Z")) {
+		t.Errorf("unexpected status after the window %s %v", status.ActiveSchedule, status.NextScheduleTransition)
+	}
+	if events := getTestEvents(r); !hasEvent(events, "Normal ScheduleEnded") {
+		t.Errorf("no ScheduleEnded event in %v", events)
+	}
+}
+
+func TestScalingScheduleWithAutoscaling(t *testing.T) {
+	enabled := true
+	presto := newTestPresto()
+	presto.Spec.Worker.Autoscaling = falaricav1alpha1.AutoscalingSpec{
+		Enabled: &enabled, MinReplicas: int32Ptr(1), MaxReplicas: int32Ptr(5)}
+	schedule := newTestSchedule("business", "0 8 * * 1-5", 10*time.Hour, "")
+	schedule.Count = nil
+	schedule.MinReplicas = int32Ptr(3)
+	schedule.MaxReplicas = int32Ptr(8)
+	presto.Spec.Worker.Schedules = []falaricav1alpha1.ScalingSchedule{schedule}
+	r := newTestReconciler(t, presto)
+	r.clock = clock.NewFakeClock(mustParseTime(t, "2026-10-19T09:00:00Z"))
+
+	presto = getTestPresto(t, r)
+	if err := r.scalingSchedule(presto, context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	autoscaling := presto.Spec.Worker.Autoscaling
+	if *autoscaling.MinReplicas != 3 || *autoscaling.MaxReplicas != 8 {
+		t.Errorf("autoscaling bounds %d-%d inside the window, expected 3-8",
+			*autoscaling.MinReplicas, *autoscaling.MaxReplicas)
+	}
+}
+
+func TestInvalidScalingSchedule(t *testing.T) {
+	enabled := true
+	valid := newTestSchedule("business", "0 8 * * 1-5", 10*time.Hour, "")
+	tests := []struct {
+		name        string
+		autoscaling bool
+		schedules   func() []falaricav1alpha1.ScalingSchedule
+	}{
+		{"only count with autoscaling", true, func() []falaricav1alpha1.ScalingSchedule {
+			return []falaricav1alpha1.ScalingSchedule{valid}
+		}},
+		{"only minReplicas without autoscaling", false, func() []falaricav1alpha1.ScalingSchedule {
+			schedule := valid
+			schedule.Count = nil
+			schedule.MinReplicas = int32Ptr(3)
+			return []falaricav1alpha1.ScalingSchedule{schedule}
+		}},
+		{"minReplicas above the maxReplicas of the spec", true, func() []falaricav1alpha1.ScalingSchedule {
+			schedule := valid
+			schedule.Count = nil
+			schedule.MinReplicas = int32Ptr(10)
+			return []falaricav1alpha1.ScalingSchedule{schedule}
+		}},
+		{"maxReplicas below the minReplicas of the spec", true, func() []falaricav1alpha1.ScalingSchedule {
+			schedule := valid
+			schedule.Count = nil
+			schedule.MaxReplicas = int32Ptr(0)
+			return []falaricav1alpha1.ScalingSchedule{schedule}
+		}},
+		{"invalid cron expression", false, func() []falaricav1alpha1.ScalingSchedule {
+			schedule := valid
+			schedule.Schedule = "0 8 * *"
+			return []falaricav1alpha1.ScalingSchedule{schedule}
+		}},
+		{"invalid time zone", false, func() []falaricav1alpha1.ScalingSchedule {
+			schedule := valid
+			schedule.TimeZone = "Mars/Olympus"
+			return []falaricav1alpha1.ScalingSchedule{schedule}
+		}},
+		{"duplicate name", false, func() []falaricav1alpha1.ScalingSchedule {
+			return []falaricav1alpha1.ScalingSchedule{valid, valid}
+		}},
+		{"zero duration", false, func() []falaricav1alpha1.ScalingSchedule {
+			schedule := valid
+			schedule.Duration = metav1.Duration{}
+			return []falaricav1alpha1.ScalingSchedule{schedule}
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			presto := newTestPresto()
+			presto.Spec.Worker.Count = int32Ptr(2)
+			if test.autoscaling {
+				presto.Spec.Worker.Autoscaling = falaricav1alpha1.AutoscalingSpec{
+					Enabled: &enabled, MinReplicas: int32Ptr(1), MaxReplicas: int32Ptr(5)}
+			}
+			presto.Spec.Worker.Schedules = test.schedules()
+			r := newTestReconciler(t, presto)
+			r.clock = clock.NewFakeClock(mustParseTime(t, "2026-10-19T09:00:00Z"))
+
+			presto = getTestPresto(t, r)
+			if err := r.scalingSchedule(presto, context.Background()); err == nil {
+				t.Fatal("expected an error")
+			}
+			if *presto.Spec.Worker.Count != 2 {
+				t.Errorf("count changed to %d by an invalid schedule", *presto.Spec.Worker.Count)
+			}
+			status := getTestPresto(t, r).Status
+			if status.ClusterState != falaricav1alpha1.ClusterFailedState {
+				t.Errorf("cluster state %s, expected %s", status.ClusterState, falaricav1alpha1.ClusterFailedState)
+			}
+			condition := findCondition(status.Conditions, falaricav1alpha1.ConditionReady)
+			if condition == nil || condition.Reason != "InvalidSchedule" {
+				t.Errorf("unexpected ready condition %v", condition)
+			}
+			if events := getTestEvents(r); !hasEvent(events, "Warning Failed") {
+				t.Errorf("no warning event in %v", events)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package presto
+
+import (
+	falaricav1alpha1 "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+/*
+  The node selector, tolerations, affinity, topology spread constraints and priority class
+  of the coordinator and of the workers are copied to their pod specs. A busy worker can
+  starve the coordinator of CPU and memory, so by default the coordinator and the workers
+  of a cluster prefer not to be scheduled on the same node. The preference is a soft
+  anti-affinity, so a cluster still comes up on a single node. It is not added when the
+  affinity of the spec has podAntiAffinity, and an empty podAntiAffinity turns it off.
+*/
+
+// weight of the default anti-affinity between the coordinator and the workers
+const defaultAntiAffinityWeight = 100
+
+func getSchedulingSpec(presto *falaricav1alpha1.Presto, isCoordinator bool) falaricav1alpha1.SchedulingSpec {
+	if isCoordinator {
+		return presto.Spec.Coordinator.SchedulingSpec
+	}
+	return presto.Spec.Worker.SchedulingSpec
+}
+
+// returns the anti-affinity of the coordinator to the workers of the cluster, including
+// the workers of the worker pools, or of the workers to the coordinator
+func getDefaultPodAntiAffinity(presto *falaricav1alpha1.Presto, isCoordinator bool) *corev1.PodAntiAffinity {
+	otherPodLabels := []map[string]string{getCoordinatorPodLabels(nil, presto.Status.Uuid)}
+	if isCoordinator {
+		pk, pv := getWorkerPoolPodLabel(presto.Status.Uuid)
+		otherPodLabels = []map[string]string{
+			getWorkerPodLabels(nil, presto.Status.Uuid),
+			{pk: pv},
+		}
+	}
+	antiAffinity := &corev1.PodAntiAffinity{}
+	for _, podLabels := range otherPodLabels {
+		antiAffinity.PreferredDuringSchedulingIgnoredDuringExecution = append(
+			antiAffinity.PreferredDuringSchedulingIgnoredDuringExecution, corev1.WeightedPodAffinityTerm{
+				Weight: defaultAntiAffinityWeight,
+				PodAffinityTerm: corev1.PodAffinityTerm{
+					LabelSelector: &metav1.LabelSelector{MatchLabels: podLabels},
+					TopologyKey:   corev1.LabelHostname,
+				},
+			})
+	}
+	return antiAffinity
+}
+
+// sets the scheduling fields of the pod spec of the coordinator or the workers
+func applySchedulingSpec(presto *falaricav1alpha1.Presto, podSpec *corev1.PodSpec, isCoordinator bool) {
+	scheduling := getSchedulingSpec(presto, isCoordinator)
+	podSpec.NodeSelector = scheduling.NodeSelector
+	podSpec.Tolerations = scheduling.Tolerations
+	podSpec.TopologySpreadConstraints = scheduling.TopologySpreadConstraints
+	podSpec.PriorityClassName = scheduling.PriorityClassName
+	affinity := &corev1.Affinity{}
+	if scheduling.Affinity != nil {
+		affinity = scheduling.Affinity.DeepCopy()
+	}
+	if affinity.PodAntiAffinity == nil {
+		affinity.PodAntiAffinity = getDefaultPodAntiAffinity(presto, isCoordinator)
+	}
+	podSpec.Affinity = affinity
+}
@@ -0,0 +1,228 @@
+package presto
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	falaricav1alpha1 "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+/*
+  The nodes of a cluster authenticate each other with internal-communication.shared-secret,
+  which newer Presto and Trino releases require once authentication is enabled. Older releases
+  such as the default prestosql 333 do not know the property, so it is set only when
+  authentication or internal TLS is enabled. The operator generates a random secret per
+  cluster and keeps it in a secret owned by the cluster. It is passed to the coordinator and
+  the workers in an environment variable, and config.properties refers to it with
+  ${ENV:PRESTO_SHARED_SECRET}, so the value is not in the config maps.
+  A value in the additionalProps of the coordinator or the workers takes precedence.
+  The secret is rotated by the falarica.io/rotate-shared-secret annotation, which the operator
+  removes. A node with the old secret cannot talk to a node with the new one, so the pods are
+  not rolled one at a time. Instead, the coordinator and all the workers are deleted together
+  and come back with the new secret. The queries running at that time fail. The shared secret
+  is therefore not part of the config hash.
+  The secret is created only once authentication or internal TLS is enabled. Before that, the
+  nodes do not use it, so the rotate annotation only discards an existing secret and no pod
+  is restarted.
+*/
+
+const (
+	sharedSecretKey = "shared-secret"
+	// environment variable of the presto nodes that holds the shared secret
+	sharedSecretEnv = "PRESTO_SHARED_SECRET"
+	// length of the generated shared secret in bytes
+	sharedSecretLength = 32
+	// annotation on the presto object that rotates the shared secret
+	rotateSharedSecretAnnotation = "falarica.io/rotate-shared-secret"
+	sharedSecretProperty         = "internal-communication.shared-secret"
+)
+
+// the nodes need the shared secret only when their requests to each other are authenticated
+func isSharedSecretRequired(presto *falaricav1alpha1.Presto) bool {
+	return isPasswordAuthEnabled(presto) || isInternalTLSEnabled(presto)
+}
+
+// returns the shared secret property when it is required, unless it is given in the
+// additional properties
+func getSharedSecretProps(presto *falaricav1alpha1.Presto, additionalProps map[string]string) map[string]string {
+	if !isSharedSecretRequired(presto) {
+		return nil
+	}
+	if _, ok := additionalProps[sharedSecretProperty]; ok {
+		return nil
+	}
+	return map[string]string{
+		sharedSecretProperty: fmt.Sprintf("${ENV:%s}", sharedSecretEnv),
+	}
+}
+
+// passes the shared secret to the presto container when it is required
+func applySharedSecret(presto *falaricav1alpha1.Presto, podSpec *corev1.PodSpec) {
+	if !isSharedSecretRequired(presto) {
+		return
+	}
+	podSpec.Containers[0].Env = append(podSpec.Containers[0].Env, corev1.EnvVar{
+		Name: sharedSecretEnv,
+		ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: getSharedSecretName(presto.Status.Uuid)},
+			Key:                  sharedSecretKey,
+		}},
+	})
+}
+
+func generateSharedSecret() ([]byte, error) {
+	secret := make([]byte, sharedSecretLength)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return []byte(hex.EncodeToString(secret)), nil
+}
+
+// removes the rotate annotation from the presto object
+func removeRotateSharedSecretAnnotation(r *ReconcilePresto, presto *falaricav1alpha1.Presto) error {
+	prestoCopy, err := r.getPresto(presto)
+	if err != nil {
+		return err
+	}
+	if prestoCopy == nil {
+		return nil
+	}
+	patchBase := prestoCopy.DeepCopy()
+	delete(prestoCopy.Annotations, rotateSharedSecretAnnotation)
+	return r.client.Patch(context.Background(), prestoCopy, client.MergeFrom(patchBase))
+}
+
+// deletes the coordinator and the worker pods together so that they come back with the
+// new shared secret. returns the number of pods deleted
+func restartPrestoPods(r *ReconcilePresto, presto *falaricav1alpha1.Presto) (int, error) {
+	restarted := 0
+	for _, podLabel := range []func(string) (string, string){getCoordinatorPodLabel, getWorkerPodLabel,
+		getWorkerPoolPodLabel, getDecommissioningPodLabel} {
+		pods, err := getPrestoPods(r, presto, podLabel)
+		if err != nil {
+			return restarted, err
+		}
+		for i := range pods {
+			if pods[i].DeletionTimestamp != nil {
+				continue
+			}
+			if err := r.client.Delete(context.TODO(), &pods[i]); err != nil && !errors.IsNotFound(err) {
+				return restarted, err
+			}
+			restarted++
+		}
+	}
+	return restarted, nil
+}
+
+// creates the shared secret of the cluster, and rotates it when the presto object has the
+// rotate annotation. returns error, changesMade
+func (r *ReconcilePresto) sharedSecret(presto *falaricav1alpha1.Presto,
+	baseLabels map[string]string,
+	ctx context.Context) (error, bool) {
+	secretName := getSharedSecretName(presto.Status.Uuid)
+	_, rotate := presto.Annotations[rotateSharedSecretAnnotation]
+	if !isSharedSecretRequired(presto) {
+		if !rotate {
+			return nil, false
+		}
+		return r.discardSharedSecret(presto, ctx)
+	}
+	secret := &corev1.Secret{}
+	err := r.client.Get(ctx, types.NamespacedName{Namespace: presto.Namespace, Name: secretName}, secret)
+	created := false
+	var value []byte
+	if errors.IsNotFound(err) {
+		value, err = generateSharedSecret()
+		if err == nil {
+			secret = &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:            secretName,
+					Namespace:       presto.Namespace,
+					Labels:          baseLabels,
+					OwnerReferences: []metav1.OwnerReference{*getOwnerReference(presto)},
+				},
+				Data: map[string][]byte{sharedSecretKey: value},
+			}
+			err = r.client.Create(ctx, secret)
+			created = true
+		}
+	} else if err == nil && (rotate || len(secret.Data[sharedSecretKey]) == 0) {
+		value, err = generateSharedSecret()
+		if err == nil {
+			secretCopy := secret.DeepCopy()
+			secretCopy.Data = map[string][]byte{sharedSecretKey: value}
+			err = r.client.Update(ctx, secretCopy)
+		}
+	} else if err == nil {
+		if presto.Status.SharedSecret != secretName {
+			r.updateStatus(presto, ctx, ClusterUpdateAction{sharedSecret: &secretName})
+		}
+		return nil, false
+	}
+
+	var restarted int
+	if err == nil && !created {
+		restarted, err = restartPrestoPods(r, presto)
+	}
+	if err == nil && rotate {
+		err = removeRotateSharedSecretAnnotation(r, presto)
+	}
+	if err != nil {
+		r.sharedSecretFailed(presto, ctx, err)
+		return err, false
+	}
+	now := metav1.NewTime(r.clock.Now())
+	if created {
+		r.eventRecorder.Eventf(presto, corev1.EventTypeNormal, "Created",
+			"Created Shared Secret. %s", secretName)
+	} else {
+		r.eventRecorder.Eventf(presto, corev1.EventTypeNormal, "Rotated",
+			"Rotated the shared secret %s. Restarted %d pods", secretName, restarted)
+	}
+	r.updateStatus(presto, ctx, ClusterUpdateAction{
+		sharedSecret:             &secretName,
+		sharedSecretRotationTime: &now,
+	})
+	return nil, true
+}
+
+// deletes the shared secret that the nodes do not use, so that a new one is generated once
+// they do, and removes the rotate annotation. returns error, changesMade
+func (r *ReconcilePresto) discardSharedSecret(presto *falaricav1alpha1.Presto,
+	ctx context.Context) (error, bool) {
+	secretName := getSharedSecretName(presto.Status.Uuid)
+	err := r.client.Delete(ctx, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: secretName, Namespace: presto.Namespace},
+	})
+	if err == nil || errors.IsNotFound(err) {
+		err = removeRotateSharedSecretAnnotation(r, presto)
+	}
+	if err != nil {
+		r.sharedSecretFailed(presto, ctx, err)
+		return err, false
+	}
+	r.eventRecorder.Eventf(presto, corev1.EventTypeNormal, "Rotated",
+		"Discarded the shared secret %s. It is generated when authentication or internal TLS "+
+			"is enabled", secretName)
+	noSecret := ""
+	r.updateStatus(presto, ctx, ClusterUpdateAction{sharedSecret: &noSecret})
+	return nil, true
+}
+
+func (r *ReconcilePresto) sharedSecretFailed(presto *falaricav1alpha1.Presto, ctx context.Context, err error) {
+	r.log.Error(err, "failed to create the shared secret")
+	errorReason := fmt.Sprintf("Failed to create the shared secret %s", err.Error())
+	r.updateStatus(presto, ctx, ClusterUpdateAction{
+		errorReason:  &errorReason,
+		clusterState: falaricav1alpha1.ClusterFailedState,
+		conditions:   failureConditions(presto, "SharedSecretFailed", errorReason),
+	})
+	r.eventRecorder.Eventf(presto, corev1.EventTypeWarning, "Failed", "%s", errorReason)
+}
@@ -0,0 +1,90 @@
+package presto
+
+import (
+	"context"
+	"testing"
+
+	falaricav1alpha1 "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestRotateSharedSecret(t *testing.T) {
+	tests := []struct {
+		name      string
+		required  bool
+		restarted bool
+		secret    bool
+	}{
+		{"required", true, true, true},
+		{"not required", false, false, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			presto := newTestPresto()
+			presto.Annotations = map[string]string{rotateSharedSecretAnnotation: "true"}
+			if test.required {
+				presto.Spec.InternalTLS = &falaricav1alpha1.InternalTLSSpec{}
+			}
+			secretName := getSharedSecretName(testClusterUUID)
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: secretName, Namespace: testNamespace},
+				Data:       map[string][]byte{sharedSecretKey: []byte("old")},
+			}
+			coordinator := newTestPod("coordinator-0", getCoordinatorPodLabel, "node-1", true)
+			r := newTestReconciler(t, presto, secret, coordinator)
+
+			if err, _ := r.sharedSecret(getTestPresto(t, r), nil, context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := getTestPresto(t, r).Annotations[rotateSharedSecretAnnotation]; ok {
+				t.Error("the rotate annotation was not removed")
+			}
+			pods, err := getPrestoPods(r, presto, getCoordinatorPodLabel)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if restarted := len(pods) == 0; restarted != test.restarted {
+				t.Errorf("coordinator restarted: %v, expected %v", restarted, test.restarted)
+			}
+			err = r.client.Get(context.Background(), types.NamespacedName{Namespace: testNamespace, Name: secretName}, secret)
+			if errors.IsNotFound(err) == test.secret {
+				t.Errorf("shared secret exists: %v, expected %v", !test.secret, test.secret)
+			}
+			if test.secret && string(secret.Data[sharedSecretKey]) == "old" {
+				t.Error("the shared secret was not rotated")
+			}
+			if !hasEvent(getTestEvents(r), "Normal Rotated") {
+				t.Error("no event for the rotation")
+			}
+		})
+	}
+}
+
+func TestSharedSecretCreatedOnlyWhenRequired(t *testing.T) {
+	presto := newTestPresto()
+	r := newTestReconciler(t, presto)
+	secret := &corev1.Secret{}
+	key := types.NamespacedName{Namespace: testNamespace, Name: getSharedSecretName(testClusterUUID)}
+
+	if err, _ := r.sharedSecret(getTestPresto(t, r), nil, context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.client.Get(context.Background(), key, secret); !errors.IsNotFound(err) {
+		t.Errorf("shared secret created without authentication and internal TLS: %v", err)
+	}
+
+	presto = getTestPresto(t, r)
+	presto.Spec.InternalTLS = &falaricav1alpha1.InternalTLSSpec{}
+	if err, _ := r.sharedSecret(presto, nil, context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.client.Get(context.Background(), key, secret); err != nil {
+		t.Fatal(err)
+	}
+	if len(secret.Data[sharedSecretKey]) != 2*sharedSecretLength {
+		t.Errorf("unexpected shared secret %q", secret.Data[sharedSecretKey])
+	}
+}
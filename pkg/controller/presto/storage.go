@@ -0,0 +1,141 @@
+package presto
+
+import (
+	"fmt"
+	falaricav1alpha1 "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+/*
+  Without storage, node.data-dir and the spill files of the presto pods live in the
+  writable layer of the container. The storage of the coordinator and of the workers
+  mounts a volume at node.data-dir. It can be an EmptyDir on the disk of the node or in
+  memory, or a directory of the node such as the mount point of a local SSD. Pods
+  sharing a HostPath on a node get a sub directory named after the pod.
+  With spill enabled on the workers, the spill path is a directory within node.data-dir.
+  spill-enabled is set on the coordinator as well, since it decides the default of the
+  spill_enabled session property, and the coordinator requires a spill path for it.
+  The ephemeral-storage request and limit of the storage are added to the resources of
+  the container.
+*/
+
+const (
+	spillDirName = "spill"
+	// environment variable with the name of the pod, used for the HostPath sub directory
+	podNameEnv = "POD_NAME"
+)
+
+func getStorage(presto *falaricav1alpha1.Presto, isCoordinator bool) *falaricav1alpha1.StorageSpec {
+	if isCoordinator {
+		return presto.Spec.Coordinator.Storage
+	}
+	return presto.Spec.Worker.Storage
+}
+
+func isSpillEnabled(presto *falaricav1alpha1.Presto) bool {
+	return presto.Spec.Worker.Storage != nil && presto.Spec.Worker.Storage.SpillEnabled
+}
+
+// returns the properties of config.properties for spilling
+func getSpillProps(presto *falaricav1alpha1.Presto) map[string]string {
+	if !isSpillEnabled(presto) {
+		return nil
+	}
+	return map[string]string{
+		"spill-enabled":      "true",
+		"spiller-spill-path": dataDirPath + "/" + spillDirName,
+	}
+}
+
+// validates the storage and adds its ephemeral-storage to the resources of the container
+func addStorageResources(presto *falaricav1alpha1.Presto, isCoordinator bool,
+	limitResource corev1.ResourceList, requestResource corev1.ResourceList) error {
+	storage := getStorage(presto, isCoordinator)
+	if storage == nil {
+		return nil
+	}
+	role := "Worker"
+	if isCoordinator {
+		role = "Coordinator"
+		if isCoordinatorStatefulSetEnabled(presto) && presto.Spec.Coordinator.DataVolume != nil {
+			return &OperatorError{"presto.Spec.Coordinator.Storage cannot be specified along with " +
+				"presto.Spec.Coordinator.DataVolume"}
+		}
+	}
+	if storage.Type == falaricav1alpha1.HostPathStorage && len(storage.Path) == 0 {
+		return &OperatorError{fmt.Sprintf("presto.Spec.%s.Storage.Path has to be specified "+
+			"for HostPath storage", role)}
+	}
+	if storage.Type != falaricav1alpha1.EmptyDirStorage &&
+		(len(storage.Medium) != 0 || len(storage.SizeLimit) != 0) {
+		return &OperatorError{fmt.Sprintf("presto.Spec.%s.Storage.Medium and SizeLimit are "+
+			"applicable only to EmptyDir storage", role)}
+	}
+	if len(storage.SizeLimit) != 0 {
+		if _, err := resource.ParseQuantity(storage.SizeLimit); err != nil {
+			return &OperatorError{fmt.Sprintf("cannot parse presto.Spec.%s.Storage.SizeLimit: "+
+				"'%v': %v", role, storage.SizeLimit, err)}
+		}
+	}
+	if len(storage.EphemeralStorageLimit) != 0 {
+		quantity, err := resource.ParseQuantity(storage.EphemeralStorageLimit)
+		if err != nil {
+			return &OperatorError{fmt.Sprintf("cannot parse presto.Spec.%s.Storage.EphemeralStorageLimit: "+
+				"'%v': %v", role, storage.EphemeralStorageLimit, err)}
+		}
+		limitResource[corev1.ResourceEphemeralStorage] = quantity
+	}
+	if len(storage.EphemeralStorageRequest) != 0 {
+		quantity, err := resource.ParseQuantity(storage.EphemeralStorageRequest)
+		if err != nil {
+			return &OperatorError{fmt.Sprintf("cannot parse presto.Spec.%s.Storage.EphemeralStorageRequest: "+
+				"'%v': %v", role, storage.EphemeralStorageRequest, err)}
+		}
+		requestResource[corev1.ResourceEphemeralStorage] = quantity
+	}
+	return nil
+}
+
+// adds the volume of the storage to the pod spec and returns its mount.
+// returns nil when there is no storage. The storage has been validated by addStorageResources.
+func getStorageVolumeMount(presto *falaricav1alpha1.Presto, podSpec *corev1.PodSpec,
+	isCoordinator bool) *corev1.VolumeMount {
+	storage := getStorage(presto, isCoordinator)
+	if storage == nil {
+		return nil
+	}
+	volName := getWorkerDataVolName(presto.Status.Uuid)
+	if isCoordinator {
+		volName = getCoordinatorDataVolName(presto.Status.Uuid)
+	}
+	volumeMount := &corev1.VolumeMount{
+		Name:      volName,
+		MountPath: dataDirPath,
+	}
+	volume := corev1.Volume{Name: volName}
+	if storage.Type == falaricav1alpha1.HostPathStorage {
+		hostPathType := corev1.HostPathDirectoryOrCreate
+		volume.HostPath = &corev1.HostPathVolumeSource{
+			Path: storage.Path,
+			Type: &hostPathType,
+		}
+		// the pods on a node do not share node.data-dir
+		volumeMount.SubPathExpr = fmt.Sprintf("$(%s)", podNameEnv)
+		podSpec.Containers[0].Env = append(podSpec.Containers[0].Env, corev1.EnvVar{
+			Name: podNameEnv,
+			ValueFrom: &corev1.EnvVarSource{
+				FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"},
+			},
+		})
+	} else {
+		emptyDir := &corev1.EmptyDirVolumeSource{Medium: storage.Medium}
+		if len(storage.SizeLimit) != 0 {
+			sizeLimit := resource.MustParse(storage.SizeLimit)
+			emptyDir.SizeLimit = &sizeLimit
+		}
+		volume.EmptyDir = emptyDir
+	}
+	podSpec.Volumes = append(podSpec.Volumes, volume)
+	return volumeMount
+}
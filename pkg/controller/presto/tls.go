@@ -0,0 +1,581 @@
+package presto
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	falaricav1alpha1 "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"math/big"
+	"reflect"
+	"sort"
+	"time"
+)
+
+/*
+  With spec.coordinator.tls, the operator provisions the certificate of the HTTPS port of the
+  coordinator instead of the keystore uploaded by the user. The certificate covers the external
+  service, the DNS name of the coordinator pod in the pod discovery service and the hostnames
+  of the spec. It is kept as PEM in a secret owned by the cluster that has
+    - tls.crt, tls.key and ca.crt
+    - keystore.pem, the private key followed by the certificate chain. The coordinator is
+      configured with http-server.https.keystore.path pointing to it, so no JKS is needed.
+  In the SelfSigned mode, the operator generates a CA and signs the server certificate with it.
+  The CA is kept in the secret as well. On each reconcile, the server certificate is generated
+  again when it expires within renewBefore or when its DNS names change. The CA is generated
+  again when it expires within renewBefore.
+  In the CertManager mode, the operator creates a cert-manager Certificate and copies the
+  certificate issued in its secret to the secret owned by the cluster. cert-manager renews
+  the certificate. cert-manager is not watched, so a new certificate is picked up by the
+  periodic reconcile. The coordinator is not started till the certificate is issued.
+  The keystore is part of the config hash, so a renewed certificate rolls the cluster.
+*/
+
+const (
+	tlsCertKey     = "tls.crt"
+	tlsKeyKey      = "tls.key"
+	caCertKey      = "ca.crt"
+	caKeyKey       = "ca.key"
+	tlsKeystoreKey = "keystore.pem"
+	// annotation on the TLS secret that holds the mode that provisioned the certificate
+	tlsModeAnnotation     = "falarica.io/tls-mode"
+	defaultTLSDuration    = 90 * 24 * time.Hour
+	defaultTLSRenewBefore = 30 * 24 * time.Hour
+	// validity of the CA generated by the operator
+	caValidity    = 10 * 365 * 24 * time.Hour
+	tlsKeySize    = 2048
+	clusterDomain = "cluster.local"
+)
+
+var certificateGVK = schema.GroupVersionKind{Group: "cert-manager.io", Version: "v1", Kind: "Certificate"}
+
+type tlsStatus struct {
+	secret   string
+	notAfter *metav1.Time
+}
+
+func isTLSEnabled(presto *falaricav1alpha1.Presto) bool {
+	return presto.Spec.Coordinator.HttpsEnabled && presto.Spec.Coordinator.Tls != nil
+}
+
+func getTLSMode(presto *falaricav1alpha1.Presto) falaricav1alpha1.TLSMode {
+	if presto.Spec.Coordinator.Tls.Mode == "" {
+		return falaricav1alpha1.TLSSelfSigned
+	}
+	return presto.Spec.Coordinator.Tls.Mode
+}
+
+func getTLSDuration(presto *falaricav1alpha1.Presto) time.Duration {
+	if presto.Spec.Coordinator.Tls.Duration == nil {
+		return defaultTLSDuration
+	}
+	return presto.Spec.Coordinator.Tls.Duration.Duration
+}
+
+func getTLSRenewBefore(presto *falaricav1alpha1.Presto) time.Duration {
+	if presto.Spec.Coordinator.Tls.RenewBefore == nil {
+		return defaultTLSRenewBefore
+	}
+	return presto.Spec.Coordinator.Tls.RenewBefore.Duration
+}
+
+func validateTLS(presto *falaricav1alpha1.Presto) error {
+	tls := presto.Spec.Coordinator.Tls
+	if len(presto.Spec.Coordinator.HttpsKeyPairSecretName) != 0 {
+		return &OperatorError{errormsg: "tls cannot be specified along with HttpsKeyPairSecretName"}
+	}
+	if getTLSMode(presto) == falaricav1alpha1.TLSCertManager && tls.IssuerRef == nil {
+		return &OperatorError{errormsg: "issuerRef has to be specified when the tls mode is CertManager"}
+	}
+	if getTLSDuration(presto) <= getTLSRenewBefore(presto) {
+		return &OperatorError{errormsg: "duration of the tls certificate has to be more than renewBefore"}
+	}
+	return nil
+}
+
+// returns the name qualified with the namespace, .svc and the cluster domain
+func getQualifiedDNSNames(name string, namespace string) []string {
+	return []string{name,
+		fmt.Sprintf("%s.%s", name, namespace),
+		fmt.Sprintf("%s.%s.svc", name, namespace),
+		fmt.Sprintf("%s.%s.svc.%s", name, namespace, clusterDomain)}
+}
+
+// returns the DNS names of the coordinator service and of the coordinator pod
+func getCoordinatorDNSNames(presto *falaricav1alpha1.Presto) []string {
+	names := getQualifiedDNSNames(getExternalServiceName(presto.Status.Uuid), presto.Namespace)
+	return append(names, getQualifiedDNSNames(getCoordinatorInternalName(presto), presto.Namespace)...)
+}
+
+// returns the DNS names of the certificate of the coordinator
+func getTLSDNSNames(presto *falaricav1alpha1.Presto) []string {
+	names := getCoordinatorDNSNames(presto)
+	for _, hostname := range presto.Spec.Coordinator.Tls.Hostnames {
+		if !containsString(names, hostname) {
+			names = append(names, hostname)
+		}
+	}
+	return names
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func sameStrings(a []string, b []string) bool {
+	sortedA := append([]string(nil), a...)
+	sortedB := append([]string(nil), b...)
+	sort.Strings(sortedA)
+	sort.Strings(sortedB)
+	return reflect.DeepEqual(sortedA, sortedB)
+}
+
+func encodeCertificate(cert *x509.Certificate) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+}
+
+func encodePrivateKey(key *rsa.PrivateKey) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+func decodeCertificate(data []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, &OperatorError{errormsg: "no PEM encoded certificate found"}
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+func decodePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, &OperatorError{errormsg: "no PEM encoded private key found"}
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, &OperatorError{errormsg: "private key is not an RSA key"}
+	}
+	return rsaKey, nil
+}
+
+func newSerialNumber() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+// returns a certificate signed by the parent. The certificate is self signed when parent is nil.
+func generateCertificate(template *x509.Certificate, parent *x509.Certificate,
+	parentKey *rsa.PrivateKey) (*x509.Certificate, *rsa.PrivateKey, error) {
+	key, err := rsa.GenerateKey(rand.Reader, tlsKeySize)
+	if err != nil {
+		return nil, nil, err
+	}
+	serialNumber, err := newSerialNumber()
+	if err != nil {
+		return nil, nil, err
+	}
+	template.SerialNumber = serialNumber
+	if parent == nil {
+		parent = template
+		parentKey = key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cert, key, nil
+}
+
+func generateCA(presto *falaricav1alpha1.Presto, now time.Time) (*x509.Certificate, *rsa.PrivateKey, error) {
+	return generateCertificate(&x509.Certificate{
+		Subject: pkix.Name{CommonName: fmt.Sprintf("%s-ca-%s", presto.Name, presto.Status.Uuid[:8])},
+		// allows for the clock skew between the nodes
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(caValidity),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+	}, nil, nil)
+}
+
+// returns the server certificate with the DNS names signed by the CA. The certificate does
+// not outlive the CA.
+func generateServerCertificate(dnsNames []string, duration time.Duration, ca *x509.Certificate,
+	caKey *rsa.PrivateKey, now time.Time) (*x509.Certificate, *rsa.PrivateKey, error) {
+	notAfter := now.Add(duration)
+	if notAfter.After(ca.NotAfter) {
+		notAfter = ca.NotAfter
+	}
+	return generateCertificate(&x509.Certificate{
+		Subject:               pkix.Name{CommonName: dnsNames[0]},
+		DNSNames:              dnsNames,
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              notAfter,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}, ca, caKey)
+}
+
+// returns the reason the self signed certificates in data have to be generated again or
+// an empty string if they are valid
+func getSelfSignedRenewalReason(data map[string][]byte, dnsNames []string,
+	renewBefore time.Duration, now time.Time) (string, bool) {
+	ca, err := decodeCertificate(data[caCertKey])
+	if err != nil {
+		return "The CA is generated", true
+	}
+	if _, err := decodePrivateKey(data[caKeyKey]); err != nil {
+		return "The CA is generated", true
+	}
+	if now.Add(renewBefore).After(ca.NotAfter) {
+		return fmt.Sprintf("The CA expires at %s", ca.NotAfter.Format(time.RFC3339)), true
+	}
+	cert, err := decodeCertificate(data[tlsCertKey])
+	if err != nil || cert.CheckSignatureFrom(ca) != nil {
+		return "The certificate is generated", false
+	}
+	if now.Add(renewBefore).After(cert.NotAfter) {
+		return fmt.Sprintf("The certificate expires at %s", cert.NotAfter.Format(time.RFC3339)), false
+	}
+	if !sameStrings(cert.DNSNames, dnsNames) {
+		return "The DNS names of the certificate changed", false
+	}
+	return "", false
+}
+
+// returns the self signed certificates of the coordinator
+func getSelfSignedTLSData(presto *falaricav1alpha1.Presto, data map[string][]byte,
+	now time.Time) (map[string][]byte, string, error) {
+	return getSelfSignedCertificates(presto, data, getTLSDNSNames(presto), getTLSDuration(presto),
+		getTLSRenewBefore(presto), now)
+}
+
+// returns the self signed certificates. The certificates in data are returned if they are
+// valid. Otherwise, the server certificate and if needed the CA are generated again.
+// returns the certificates, the reason they were generated, error
+func getSelfSignedCertificates(presto *falaricav1alpha1.Presto, data map[string][]byte,
+	dnsNames []string, duration time.Duration, renewBefore time.Duration,
+	now time.Time) (map[string][]byte, string, error) {
+	reason, renewCA := getSelfSignedRenewalReason(data, dnsNames, renewBefore, now)
+	if len(reason) == 0 {
+		return data, "", nil
+	}
+	var ca *x509.Certificate
+	var caKey *rsa.PrivateKey
+	var err error
+	if renewCA {
+		ca, caKey, err = generateCA(presto, now)
+	} else {
+		ca, err = decodeCertificate(data[caCertKey])
+		if err == nil {
+			caKey, err = decodePrivateKey(data[caKeyKey])
+		}
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	cert, key, err := generateServerCertificate(dnsNames, duration, ca, caKey, now)
+	if err != nil {
+		return nil, "", err
+	}
+	keyPEM, err := encodePrivateKey(key)
+	if err != nil {
+		return nil, "", err
+	}
+	caKeyPEM, err := encodePrivateKey(caKey)
+	if err != nil {
+		return nil, "", err
+	}
+	return map[string][]byte{
+		tlsCertKey: encodeCertificate(cert),
+		tlsKeyKey:  keyPEM,
+		caCertKey:  encodeCertificate(ca),
+		caKeyKey:   caKeyPEM,
+	}, reason, nil
+}
+
+// returns the keystore read by presto, the private key followed by the certificate chain
+func buildKeystore(data map[string][]byte) []byte {
+	var keystore bytes.Buffer
+	keystore.Write(data[tlsKeyKey])
+	keystore.Write(data[tlsCertKey])
+	if ca := data[caCertKey]; len(ca) != 0 && !bytes.Contains(data[tlsCertKey], ca) {
+		keystore.Write(ca)
+	}
+	return keystore.Bytes()
+}
+
+func buildCertificate(presto *falaricav1alpha1.Presto, baseLabels map[string]string) *unstructured.Unstructured {
+	issuerRef := presto.Spec.Coordinator.Tls.IssuerRef
+	kind := issuerRef.Kind
+	if len(kind) == 0 {
+		kind = "Issuer"
+	}
+	group := issuerRef.Group
+	if len(group) == 0 {
+		group = certificateGVK.Group
+	}
+	var dnsNames []interface{}
+	for _, name := range getTLSDNSNames(presto) {
+		dnsNames = append(dnsNames, name)
+	}
+	certificate := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"secretName":  getCertificateSecretName(presto.Status.Uuid),
+			"dnsNames":    dnsNames,
+			"duration":    getTLSDuration(presto).String(),
+			"renewBefore": getTLSRenewBefore(presto).String(),
+			// the keystore of presto is read as PKCS8
+			"privateKey": map[string]interface{}{"encoding": "PKCS8"},
+			"issuerRef": map[string]interface{}{
+				"name":  issuerRef.Name,
+				"kind":  kind,
+				"group": group,
+			},
+		},
+	}}
+	certificate.SetGroupVersionKind(certificateGVK)
+	certificate.SetName(getCertificateName(presto.Status.Uuid))
+	certificate.SetNamespace(presto.Namespace)
+	certificate.SetLabels(baseLabels)
+	certificate.SetOwnerReferences([]metav1.OwnerReference{*getOwnerReference(presto)})
+	return certificate
+}
+
+// creates or updates the cert-manager Certificate.
+// returns the certificates issued by cert-manager or nil if not issued yet, created, error
+func syncCertificate(r *ReconcilePresto, presto *falaricav1alpha1.Presto,
+	baseLabels map[string]string) (map[string][]byte, bool, error) {
+	ctx := context.Background()
+	desired := buildCertificate(presto, baseLabels)
+	existing := &unstructured.Unstructured{}
+	existing.SetGroupVersionKind(certificateGVK)
+	err := r.client.Get(ctx, types.NamespacedName{Namespace: presto.Namespace, Name: desired.GetName()}, existing)
+	created := false
+	if errors.IsNotFound(err) {
+		if err := r.client.Create(ctx, desired); err != nil {
+			return nil, false, err
+		}
+		created = true
+	} else if err != nil {
+		return nil, false, err
+	} else {
+		spec, _, _ := unstructured.NestedMap(existing.Object, "spec")
+		if spec == nil {
+			spec = make(map[string]interface{})
+		}
+		update := false
+		for key, value := range desired.Object["spec"].(map[string]interface{}) {
+			if !reflect.DeepEqual(spec[key], value) {
+				spec[key] = value
+				update = true
+			}
+		}
+		if update {
+			existing.Object["spec"] = spec
+			if err := r.client.Update(ctx, existing); err != nil {
+				return nil, false, err
+			}
+		}
+	}
+
+	secret := &corev1.Secret{}
+	err = r.client.Get(ctx, types.NamespacedName{Namespace: presto.Namespace,
+		Name: getCertificateSecretName(presto.Status.Uuid)}, secret)
+	if errors.IsNotFound(err) {
+		return nil, created, nil
+	}
+	if err != nil {
+		return nil, created, err
+	}
+	if len(secret.Data[tlsCertKey]) == 0 || len(secret.Data[tlsKeyKey]) == 0 {
+		return nil, created, nil
+	}
+	data := map[string][]byte{
+		tlsCertKey: secret.Data[tlsCertKey],
+		tlsKeyKey:  secret.Data[tlsKeyKey],
+	}
+	if ca := secret.Data[caCertKey]; len(ca) != 0 {
+		data[caCertKey] = ca
+	}
+	return data, created, nil
+}
+
+func deleteCertificate(r *ReconcilePresto, presto *falaricav1alpha1.Presto) error {
+	certificate := &unstructured.Unstructured{}
+	certificate.SetGroupVersionKind(certificateGVK)
+	_, err := deleteIfExists(r, presto, getCertificateName(presto.Status.Uuid), certificate)
+	return err
+}
+
+// creates or updates the secret of certificates provisioned by the operator. mode is the
+// mode that provisioned the certificates. existing is nil if the secret does not exist.
+// returns the action taken, error
+func syncTLSSecret(r *ReconcilePresto, presto *falaricav1alpha1.Presto, name string, mode string,
+	baseLabels map[string]string, existing *corev1.Secret, data map[string][]byte) (string, error) {
+	if existing == nil {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            name,
+				Namespace:       presto.Namespace,
+				Labels:          baseLabels,
+				Annotations:     map[string]string{tlsModeAnnotation: mode},
+				OwnerReferences: []metav1.OwnerReference{*getOwnerReference(presto)},
+			},
+			Type: corev1.SecretTypeTLS,
+			Data: data,
+		}
+		return "Created", r.client.Create(context.Background(), secret)
+	}
+	if existing.Annotations[tlsModeAnnotation] == mode && reflect.DeepEqual(existing.Data, data) {
+		return "", nil
+	}
+	secretCopy := existing.DeepCopy()
+	if secretCopy.Annotations == nil {
+		secretCopy.Annotations = make(map[string]string)
+	}
+	secretCopy.Annotations[tlsModeAnnotation] = mode
+	secretCopy.Data = data
+	return "Updated", r.client.Update(context.Background(), secretCopy)
+}
+
+// provisions the certificate of the coordinator when spec.coordinator.tls is set and
+// removes it otherwise.
+// returns error, changesMade
+func (r *ReconcilePresto) coordinatorTLS(presto *falaricav1alpha1.Presto,
+	baseLabels map[string]string,
+	ctx context.Context) (error, bool) {
+	secretName := getCoordinatorTLSSecretName(presto.Status.Uuid)
+	existing := &corev1.Secret{}
+	err := r.client.Get(ctx, types.NamespacedName{Namespace: presto.Namespace, Name: secretName}, existing)
+	if errors.IsNotFound(err) {
+		existing = nil
+	} else if err != nil {
+		return err, false
+	}
+	if existing != nil && existing.Annotations[tlsModeAnnotation] == string(falaricav1alpha1.TLSCertManager) &&
+		(!isTLSEnabled(presto) || getTLSMode(presto) != falaricav1alpha1.TLSCertManager) {
+		if err := deleteCertificate(r, presto); err != nil {
+			r.log.Error(err, "failed to delete the certificate of the coordinator")
+			return err, false
+		}
+	}
+	if !isTLSEnabled(presto) {
+		if existing != nil {
+			if err := r.client.Delete(ctx, existing); err != nil && !errors.IsNotFound(err) {
+				r.log.Error(err, "failed to delete the tls secret "+secretName)
+				return err, false
+			}
+			r.eventRecorder.Eventf(presto, corev1.EventTypeNormal, "Deleted",
+				"Deleted the certificate of the coordinator. %s", secretName)
+		}
+		if len(presto.Status.TlsSecret) != 0 {
+			r.updateStatus(presto, ctx, ClusterUpdateAction{tls: &tlsStatus{}})
+		}
+		return nil, false
+	}
+
+	data, reason, err := r.getTLSData(presto, baseLabels, existing)
+	if err == nil && data == nil {
+		r.log.Info("waiting for cert-manager to issue the certificate " +
+			getCertificateName(presto.Status.Uuid))
+		return nil, true
+	}
+	var cert *x509.Certificate
+	if err == nil {
+		data[tlsKeystoreKey] = buildKeystore(data)
+		cert, err = decodeCertificate(data[tlsCertKey])
+	}
+	var action string
+	if err == nil {
+		action, err = syncTLSSecret(r, presto, secretName, string(getTLSMode(presto)), baseLabels,
+			existing, data)
+	}
+	if err != nil {
+		r.log.Error(err, "failed to provision the certificate of the coordinator")
+		errorReason := fmt.Sprintf("Failed to provision the certificate of the coordinator %s", err.Error())
+		r.updateStatus(presto, ctx, ClusterUpdateAction{
+			errorReason:  &errorReason,
+			clusterState: falaricav1alpha1.ClusterFailedState,
+			conditions:   failureConditions(presto, "TLSFailed", errorReason),
+		})
+		r.eventRecorder.Eventf(presto, corev1.EventTypeWarning, "Failed", "%s", errorReason)
+		return err, false
+	}
+	if len(action) != 0 {
+		r.eventRecorder.Eventf(presto, corev1.EventTypeNormal, action,
+			"%s the certificate of the coordinator %s valid till %s. %s", action, secretName,
+			cert.NotAfter.Format(time.RFC3339), reason)
+	}
+	notAfter := metav1.NewTime(cert.NotAfter)
+	if presto.Status.TlsSecret != secretName || presto.Status.TlsNotAfter == nil ||
+		!presto.Status.TlsNotAfter.Equal(&notAfter) {
+		r.updateStatus(presto, ctx, ClusterUpdateAction{
+			tls: &tlsStatus{secret: secretName, notAfter: &notAfter},
+		})
+	}
+	return nil, action == "Created"
+}
+
+// returns the certificates of the coordinator or nil if cert-manager has not issued them yet,
+// the reason the certificates were generated, error
+func (r *ReconcilePresto) getTLSData(presto *falaricav1alpha1.Presto, baseLabels map[string]string,
+	existing *corev1.Secret) (map[string][]byte, string, error) {
+	if err := validateTLS(presto); err != nil {
+		return nil, "", err
+	}
+	if getTLSMode(presto) == falaricav1alpha1.TLSCertManager {
+		data, created, err := syncCertificate(r, presto, baseLabels)
+		if err != nil {
+			return nil, "", err
+		}
+		if created {
+			r.eventRecorder.Eventf(presto, corev1.EventTypeNormal, "Created",
+				"Created Certificate. %s", getCertificateName(presto.Status.Uuid))
+		}
+		return data, "The certificate is issued by cert-manager", nil
+	}
+	var current map[string][]byte
+	if existing != nil && existing.Annotations[tlsModeAnnotation] == string(falaricav1alpha1.TLSSelfSigned) {
+		current = getCertificateData(existing)
+	}
+	return getSelfSignedTLSData(presto, current, r.clock.Now())
+}
+
+// returns the certificates and the keys of the secret without the files built from them
+func getCertificateData(secret *corev1.Secret) map[string][]byte {
+	data := make(map[string][]byte)
+	for _, key := range []string{tlsCertKey, tlsKeyKey, caCertKey, caKeyKey} {
+		if value, ok := secret.Data[key]; ok {
+			data[key] = value
+		}
+	}
+	return data
+}
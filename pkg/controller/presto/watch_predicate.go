@@ -1,6 +1,8 @@
 package presto
 
 import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
@@ -36,4 +38,77 @@ func (GenerationChangedPredicate) Update(e event.UpdateEvent) bool {
 		return false
 	}
 	return true
-}
\ No newline at end of file
+}
+type NodeTerminationPredicate struct {
+	predicate.Funcs
+}
+
+// Predicate to pass only the Update events where the taints, the cordon or the status of
+// the conditions of a node have changed. The heartbeats of the kubelet are filtered out.
+func (NodeTerminationPredicate) Update(e event.UpdateEvent) bool {
+	oldNode, ok := e.ObjectOld.(*corev1.Node)
+	if !ok {
+		return false
+	}
+	newNode, ok := e.ObjectNew.(*corev1.Node)
+	if !ok {
+		return false
+	}
+	if oldNode.Spec.Unschedulable != newNode.Spec.Unschedulable ||
+		!equality.Semantic.DeepEqual(oldNode.Spec.Taints, newNode.Spec.Taints) {
+		return true
+	}
+	conditions := make(map[corev1.NodeConditionType]corev1.ConditionStatus)
+	for _, condition := range oldNode.Status.Conditions {
+		conditions[condition.Type] = condition.Status
+	}
+	for _, condition := range newNode.Status.Conditions {
+		if conditions[condition.Type] != condition.Status {
+			return true
+		}
+	}
+	return false
+}
+
+// a node that is created or deleted is not being terminated
+func (NodeTerminationPredicate) Create(e event.CreateEvent) bool {
+	return false
+}
+
+func (NodeTerminationPredicate) Delete(e event.DeleteEvent) bool {
+	return false
+}
+
+func (NodeTerminationPredicate) Generic(e event.GenericEvent) bool {
+	return false
+}
+
+type PodIPPredicate struct {
+	predicate.Funcs
+}
+
+// Predicate to pass only the Update events where the IP of a pod has changed
+func (PodIPPredicate) Update(e event.UpdateEvent) bool {
+	oldPod, ok := e.ObjectOld.(*corev1.Pod)
+	if !ok {
+		return false
+	}
+	newPod, ok := e.ObjectNew.(*corev1.Pod)
+	if !ok {
+		return false
+	}
+	return oldPod.Status.PodIP != newPod.Status.PodIP
+}
+
+// a pod gets its IP after it is created
+func (PodIPPredicate) Create(e event.CreateEvent) bool {
+	return false
+}
+
+func (PodIPPredicate) Delete(e event.DeleteEvent) bool {
+	return true
+}
+
+func (PodIPPredicate) Generic(e event.GenericEvent) bool {
+	return false
+}
@@ -0,0 +1,275 @@
+package presto
+
+import (
+	"context"
+	"fmt"
+	falaricav1alpha1 "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1"
+	v1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+/*
+  Workers can be managed through a Deployment instead of a bare ReplicaSet. The Deployment
+  replaces the workers as per the update strategy and keeps the rollout history so that
+  kubectl rollout undo works on the workers.
+  The hashes of the configuration and the pod spec last applied by the operator are kept
+  on the Deployment itself. The pod template is changed only when the desired hashes change
+  so that a rollback done using kubectl is not reverted by the operator.
+  When the workload of a running cluster is changed, the new workload is created with the
+  current worker count and the old one is deleted once all the new workers are available.
+*/
+
+func isWorkerDeploymentEnabled(presto *falaricav1alpha1.Presto) bool {
+	return presto.Spec.Worker.Workload == falaricav1alpha1.WorkerDeploymentWorkload
+}
+
+func getWorkerDeployment(r *ReconcilePresto, presto *falaricav1alpha1.Presto) (*v1.Deployment, error) {
+	deployment := &v1.Deployment{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{
+		Namespace: presto.Namespace,
+		Name:      getWorkerDeploymentName(presto.Status.Uuid),
+	}, deployment)
+	if err != nil {
+		return nil, err
+	}
+	return deployment, nil
+}
+
+func getWorkerUpdateStrategy(presto *falaricav1alpha1.Presto) v1.DeploymentStrategy {
+	if presto.Spec.Worker.UpdateStrategy != nil {
+		return *presto.Spec.Worker.UpdateStrategy
+	}
+	// bring up a new worker before draining an old one
+	maxSurge := intstr.FromInt(1)
+	maxUnavailable := intstr.FromInt(0)
+	return v1.DeploymentStrategy{
+		Type: v1.RollingUpdateDeploymentStrategyType,
+		RollingUpdate: &v1.RollingUpdateDeployment{
+			MaxUnavailable: &maxUnavailable,
+			MaxSurge:       &maxSurge,
+		},
+	}
+}
+
+func createDeploymentForWorker(r *ReconcilePresto, presto *falaricav1alpha1.Presto,
+	lbls map[string]string, workerCount int32) (*v1.Deployment, error) {
+	// the pod template is same as that of the worker replicaset
+	replicaSet, err := createReplicaSetForWorker(r, presto, lbls, workerCount)
+	if err != nil {
+		return nil, err
+	}
+	template := replicaSet.Spec.Template
+	// the owner of the pods is the replicaset created by the deployment
+	template.OwnerReferences = nil
+	return &v1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            getWorkerDeploymentName(presto.Status.Uuid),
+			Namespace:       presto.Namespace,
+			OwnerReferences: []metav1.OwnerReference{*getOwnerReference(presto)},
+			Labels:          lbls,
+			Annotations: map[string]string{
+				configHashAnnotation:  template.Annotations[configHashAnnotation],
+				podSpecHashAnnotation: template.Annotations[podSpecHashAnnotation],
+			},
+		},
+		Spec: v1.DeploymentSpec{
+			Replicas: func() *int32 { i := workerCount; return &i }(),
+			Selector: &metav1.LabelSelector{
+				MatchLabels: lbls,
+			},
+			Template:             template,
+			Strategy:             getWorkerUpdateStrategy(presto),
+			RevisionHistoryLimit: presto.Spec.Worker.RevisionHistoryLimit,
+		},
+	}, nil
+}
+
+// returns deployment, created, updated, error
+func createUpdateDeploymentForWorker(r *ReconcilePresto, presto *falaricav1alpha1.Presto,
+	lbls map[string]string) (*v1.Deployment, bool, bool, error) {
+	created := false
+	updated := false
+	deployment, err := getWorkerDeployment(r, presto)
+	if errors.IsNotFound(err) {
+		workerCount := *presto.Spec.Worker.Count
+		// while migrating, start with as many workers as the replicaset has
+		replicaSet, err := getReplicaSet(r, presto, getWorkerPodLabel)
+		if err == nil {
+			workerCount = *replicaSet.Spec.Replicas
+		} else if !errors.IsNotFound(err) {
+			return nil, created, updated, err
+		}
+		deployment, err = createDeploymentForWorker(r, presto, lbls, workerCount)
+		if err != nil {
+			r.log.Error(err, "Failed to create deployment object")
+			return nil, created, updated, err
+		}
+		err = r.client.Create(context.Background(), deployment)
+		if err != nil {
+			r.log.Error(err, "Failed to create deployment")
+			return nil, created, updated, err
+		}
+		created = true
+		return deployment, created, updated, nil
+	}
+	if err != nil {
+		r.log.Error(err, "Failed to get deployment")
+		return nil, created, updated, err
+	}
+	desired, err := createDeploymentForWorker(r, presto, lbls, *deployment.Spec.Replicas)
+	if err != nil {
+		return nil, created, updated, err
+	}
+	deploymentCopy := deployment.DeepCopy()
+	// worker count shall be updated only if autoscaling is not enabled
+	if !checkAutoscalingEnabled(presto) &&
+		presto.Spec.Worker.Count != nil && *presto.Spec.Worker.Count != *deployment.Spec.Replicas {
+		r.log.Info(fmt.Sprintf("PrestoCluster %s workerCount: %d, deployment replicas: %d",
+			presto.Name, *presto.Spec.Worker.Count, *deployment.Spec.Replicas))
+		replicas := *presto.Spec.Worker.Count
+		if replicas < *deployment.Spec.Replicas {
+			removable, err := r.decommissionWorkers(presto, nil, deployment,
+				*deployment.Spec.Replicas-replicas)
+			if err != nil {
+				return nil, created, updated, err
+			}
+			replicas = *deployment.Spec.Replicas - removable
+		}
+		deploymentCopy.Spec.Replicas = &replicas
+		updated = true
+	}
+	if deployment.Annotations[podSpecHashAnnotation] != desired.Annotations[podSpecHashAnnotation] {
+		if deploymentCopy.Annotations == nil {
+			deploymentCopy.Annotations = make(map[string]string)
+		}
+		deploymentCopy.Annotations[podSpecHashAnnotation] = desired.Annotations[podSpecHashAnnotation]
+		if deploymentCopy.Spec.Template.Annotations == nil {
+			deploymentCopy.Spec.Template.Annotations = make(map[string]string)
+		}
+		deploymentCopy.Spec.Template.Annotations[podSpecHashAnnotation] =
+			desired.Spec.Template.Annotations[podSpecHashAnnotation]
+		deploymentCopy.Spec.Template.Spec = desired.Spec.Template.Spec
+		updated = true
+	}
+	if deploymentStrategyChanged(deployment, desired) {
+		deploymentCopy.Spec.Strategy = desired.Spec.Strategy
+		deploymentCopy.Spec.RevisionHistoryLimit = desired.Spec.RevisionHistoryLimit
+		updated = true
+	}
+	if updated {
+		err = r.client.Update(context.Background(), deploymentCopy)
+		if err != nil {
+			return nil, created, false, err
+		}
+		deployment = deploymentCopy
+	}
+	return deployment, created, updated, nil
+}
+
+// returns whether the strategy or the history limit differs. The fields that are not
+// specified in the desired deployment get defaulted by the API server and are ignored
+func deploymentStrategyChanged(deployment *v1.Deployment, desired *v1.Deployment) bool {
+	if desired.Spec.RevisionHistoryLimit != nil &&
+		!equality.Semantic.DeepEqual(deployment.Spec.RevisionHistoryLimit, desired.Spec.RevisionHistoryLimit) {
+		return true
+	}
+	liveStrategy := deployment.Spec.Strategy
+	desiredStrategy := desired.Spec.Strategy
+	if desiredStrategy.Type != "" && liveStrategy.Type != desiredStrategy.Type {
+		return true
+	}
+	if desiredStrategy.RollingUpdate == nil {
+		return false
+	}
+	if liveStrategy.RollingUpdate == nil {
+		return true
+	}
+	if desiredStrategy.RollingUpdate.MaxSurge != nil &&
+		!equality.Semantic.DeepEqual(liveStrategy.RollingUpdate.MaxSurge, desiredStrategy.RollingUpdate.MaxSurge) {
+		return true
+	}
+	return desiredStrategy.RollingUpdate.MaxUnavailable != nil &&
+		!equality.Semantic.DeepEqual(liveStrategy.RollingUpdate.MaxUnavailable, desiredStrategy.RollingUpdate.MaxUnavailable)
+}
+
+// stamps the config hash on the pod template so that the deployment rolls the workers.
+// returns whether the deployment was updated
+func updateDeploymentConfigHash(r *ReconcilePresto, deployment *v1.Deployment,
+	configHash string) (bool, error) {
+	if deployment.Annotations[configHashAnnotation] == configHash {
+		return false, nil
+	}
+	deploymentCopy := deployment.DeepCopy()
+	if deploymentCopy.Annotations == nil {
+		deploymentCopy.Annotations = make(map[string]string)
+	}
+	deploymentCopy.Annotations[configHashAnnotation] = configHash
+	if deploymentCopy.Spec.Template.Annotations == nil {
+		deploymentCopy.Spec.Template.Annotations = make(map[string]string)
+	}
+	deploymentCopy.Spec.Template.Annotations[configHashAnnotation] = configHash
+	err := r.client.Update(context.Background(), deploymentCopy)
+	if err != nil {
+		return false, err
+	}
+	deploymentCopy.DeepCopyInto(deployment)
+	return true, nil
+}
+
+// returns whether the deployment has replaced all its workers and the number of updated workers
+func isDeploymentRolledOut(deployment *v1.Deployment) (bool, int32) {
+	replicas := *deployment.Spec.Replicas
+	status := deployment.Status
+	done := status.ObservedGeneration >= deployment.Generation &&
+		status.UpdatedReplicas >= replicas &&
+		status.Replicas == status.UpdatedReplicas &&
+		status.AvailableReplicas >= replicas
+	return done, status.UpdatedReplicas
+}
+
+// deletes the worker replicaset once the deployment has all its workers available.
+// returns whether the replicaset was deleted
+func removeWorkerReplicaSetAfterMigration(r *ReconcilePresto, presto *falaricav1alpha1.Presto,
+	deployment *v1.Deployment) (bool, error) {
+	replicaSet, err := getReplicaSet(r, presto, getWorkerPodLabel)
+	if errors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if done, _ := isDeploymentRolledOut(deployment); !done {
+		return false, nil
+	}
+	// the workers of the replicaset are drained by the preStop hook
+	err = r.client.Delete(context.Background(), replicaSet)
+	if err != nil && !errors.IsNotFound(err) {
+		return false, err
+	}
+	return true, nil
+}
+
+// deletes the worker deployment once the replicaset has all its workers available.
+// returns whether the deployment was deleted
+func removeWorkerDeploymentAfterMigration(r *ReconcilePresto, presto *falaricav1alpha1.Presto,
+	replicaSet *v1.ReplicaSet) (bool, error) {
+	deployment, err := getWorkerDeployment(r, presto)
+	if errors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if replicaSet.Status.AvailableReplicas < *replicaSet.Spec.Replicas {
+		return false, nil
+	}
+	err = r.client.Delete(context.Background(), deployment)
+	if err != nil && !errors.IsNotFound(err) {
+		return false, err
+	}
+	return true, nil
+}
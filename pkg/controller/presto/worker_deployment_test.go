@@ -0,0 +1,196 @@
+package presto
+
+import (
+	"context"
+	"testing"
+
+	falaricav1alpha1 "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1"
+	v1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func getTestWorkerDeployment(t *testing.T, r *ReconcilePresto) *v1.Deployment {
+	deployment, err := getWorkerDeployment(r, getTestPresto(t, r))
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return deployment
+}
+
+func getTestWorkerReplicaSet(t *testing.T, r *ReconcilePresto) *v1.ReplicaSet {
+	replicaSet, err := getReplicaSet(r, getTestPresto(t, r), getWorkerPodLabel)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return replicaSet
+}
+
+func TestMigrateWorkersToDeployment(t *testing.T) {
+	ctx := context.Background()
+	baseLabels := map[string]string{"clusterName": testClusterName}
+	presto := newTestPresto()
+	presto.Spec.Worker.Count = int32Ptr(4)
+	presto.Spec.Worker.Workload = falaricav1alpha1.WorkerDeploymentWorkload
+	replicaSet := newTestWorkerReplicaSet(presto, 4)
+	replicaSet.Status.AvailableReplicas = 4
+	r := newTestReconciler(t, presto, replicaSet)
+
+	// the deployment starts with as many workers as the replicaset has
+	err, _, deployment := r.workerDeployment(getTestPresto(t, r), baseLabels, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *deployment.Spec.Replicas != 4 {
+		t.Errorf("the deployment was created with %d replicas", *deployment.Spec.Replicas)
+	}
+	if getTestWorkerReplicaSet(t, r) == nil {
+		t.Fatal("the replicaset was deleted before the deployment had any workers")
+	}
+
+	// the replicaset is kept till all the workers of the deployment are available
+	deployment = getTestWorkerDeployment(t, r)
+	deployment.Status = v1.DeploymentStatus{Replicas: 4, UpdatedReplicas: 4, AvailableReplicas: 3}
+	if err = r.client.Status().Update(ctx, deployment); err != nil {
+		t.Fatal(err)
+	}
+	if err, _, _ = r.workerDeployment(getTestPresto(t, r), baseLabels, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if getTestWorkerReplicaSet(t, r) == nil {
+		t.Fatal("the replicaset was deleted before all the workers of the deployment were available")
+	}
+
+	deployment = getTestWorkerDeployment(t, r)
+	deployment.Status.AvailableReplicas = 4
+	if err = r.client.Status().Update(ctx, deployment); err != nil {
+		t.Fatal(err)
+	}
+	if err, _, _ = r.workerDeployment(getTestPresto(t, r), baseLabels, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if getTestWorkerReplicaSet(t, r) != nil {
+		t.Error("the replicaset was not deleted once the deployment had all its workers")
+	}
+	if getTestWorkerDeployment(t, r) == nil {
+		t.Error("the deployment was deleted")
+	}
+}
+
+func TestMigrateWorkersToReplicaSet(t *testing.T) {
+	ctx := context.Background()
+	baseLabels := map[string]string{"clusterName": testClusterName}
+	presto := newTestPresto()
+	presto.Spec.Worker.Count = int32Ptr(4)
+	presto.Spec.Worker.Workload = falaricav1alpha1.WorkerDeploymentWorkload
+	r := newTestReconciler(t, presto)
+	deployment, err := createDeploymentForWorker(r, presto, getWorkerPodLabels(baseLabels, testClusterUUID), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deployment.Status = v1.DeploymentStatus{Replicas: 4, UpdatedReplicas: 4, AvailableReplicas: 4}
+	if err = r.client.Create(ctx, deployment); err != nil {
+		t.Fatal(err)
+	}
+	presto = getTestPresto(t, r)
+	presto.Spec.Worker.Workload = falaricav1alpha1.WorkerReplicaSetWorkload
+	if err = r.client.Update(ctx, presto); err != nil {
+		t.Fatal(err)
+	}
+
+	// the replicaset starts with as many workers as the deployment has
+	err, _, replicaSet := r.workerReplicaset(getTestPresto(t, r), baseLabels, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *replicaSet.Spec.Replicas != 4 {
+		t.Errorf("the replicaset was created with %d replicas", *replicaSet.Spec.Replicas)
+	}
+	if getTestWorkerDeployment(t, r) == nil {
+		t.Fatal("the deployment was deleted before the replicaset had any workers")
+	}
+
+	// the deployment is kept till all the workers of the replicaset are available
+	replicaSet = getTestWorkerReplicaSet(t, r)
+	replicaSet.Status.AvailableReplicas = 3
+	if err = r.client.Status().Update(ctx, replicaSet); err != nil {
+		t.Fatal(err)
+	}
+	if err, _, _ = r.workerReplicaset(getTestPresto(t, r), baseLabels, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if getTestWorkerDeployment(t, r) == nil {
+		t.Fatal("the deployment was deleted before all the workers of the replicaset were available")
+	}
+
+	replicaSet = getTestWorkerReplicaSet(t, r)
+	replicaSet.Status.AvailableReplicas = 4
+	if err = r.client.Status().Update(ctx, replicaSet); err != nil {
+		t.Fatal(err)
+	}
+	if err, _, _ = r.workerReplicaset(getTestPresto(t, r), baseLabels, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if getTestWorkerDeployment(t, r) != nil {
+		t.Error("the deployment was not deleted once the replicaset had all its workers")
+	}
+	if getTestWorkerReplicaSet(t, r) == nil {
+		t.Error("the replicaset was deleted")
+	}
+}
+
+func TestDeploymentStrategyChanged(t *testing.T) {
+	maxSurge := func(i int) *v1.RollingUpdateDeployment {
+		surge := intstr.FromInt(i)
+		return &v1.RollingUpdateDeployment{MaxSurge: &surge}
+	}
+	historyLimit := int32(5)
+	tests := []struct {
+		name    string
+		live    v1.DeploymentSpec
+		desired v1.DeploymentSpec
+		changed bool
+	}{
+		{
+			name: "defaults filled by the api server",
+			live: v1.DeploymentSpec{RevisionHistoryLimit: int32Ptr(10), Strategy: v1.DeploymentStrategy{
+				Type: v1.RollingUpdateDeploymentStrategyType, RollingUpdate: maxSurge(1)}},
+			desired: v1.DeploymentSpec{},
+		},
+		{
+			name: "type",
+			live: v1.DeploymentSpec{Strategy: v1.DeploymentStrategy{
+				Type: v1.RollingUpdateDeploymentStrategyType, RollingUpdate: maxSurge(1)}},
+			desired: v1.DeploymentSpec{Strategy: v1.DeploymentStrategy{Type: v1.RecreateDeploymentStrategyType}},
+			changed: true,
+		},
+		{
+			name: "max surge",
+			live: v1.DeploymentSpec{Strategy: v1.DeploymentStrategy{
+				Type: v1.RollingUpdateDeploymentStrategyType, RollingUpdate: maxSurge(1)}},
+			desired: v1.DeploymentSpec{Strategy: v1.DeploymentStrategy{
+				Type: v1.RollingUpdateDeploymentStrategyType, RollingUpdate: maxSurge(2)}},
+			changed: true,
+		},
+		{
+			name:    "history limit",
+			live:    v1.DeploymentSpec{RevisionHistoryLimit: int32Ptr(10)},
+			desired: v1.DeploymentSpec{RevisionHistoryLimit: &historyLimit},
+			changed: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			changed := deploymentStrategyChanged(&v1.Deployment{Spec: test.live}, &v1.Deployment{Spec: test.desired})
+			if changed != test.changed {
+				t.Errorf("changed %v, expected %v", changed, test.changed)
+			}
+		})
+	}
+}
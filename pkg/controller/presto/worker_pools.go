@@ -0,0 +1,542 @@
+package presto
+
+import (
+	"context"
+	"fmt"
+	falaricav1alpha1 "github.com/falarica/steerd-presto-operator/pkg/apis/falarica/v1alpha1"
+	v1 "k8s.io/api/apps/v1"
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
+)
+
+/*
+  A worker pool is a group of workers with resources, scheduling, storage and scaling of
+  its own, e.g. workers on spot nodes along with the workers on on-demand nodes. Each pool
+  has a ReplicaSet, a worker config map, a PodDisruptionBudget and, when autoscaling is
+  enabled, an HPA. The workers of all the pools register with the same coordinator.
+  The fields of a pool that are not specified are taken from spec.worker. The spec of a
+  pool is turned into a copy of the Presto object whose spec.worker is that of the pool,
+  so the pod spec, the config and the HPA are built by the same code as for the workers.
+  The pods of a pool have the workerpool label instead of the worker label, so they are
+  not picked up by the steps that manage the workers of spec.worker.
+  On a scale down, the workers of a pool are decommissioned like the other workers. When a
+  pool is removed from the spec, its workers are decommissioned and its resources deleted.
+  A change of the configuration restarts the workers of each pool one at a time, before
+  the coordinator. The scaling schedules and the QueryLoad autoscaling apply only to
+  spec.worker.
+*/
+
+// prefix of the label with the name of the worker pool
+const workerPoolLabelPrefix = "workerpool-"
+
+// returns a copy of the presto object whose worker spec is that of the pool
+func getWorkerPoolPresto(presto *falaricav1alpha1.Presto,
+	pool *falaricav1alpha1.WorkerPoolSpec) *falaricav1alpha1.Presto {
+	poolPresto := presto.DeepCopy()
+	poolPresto.Spec.WorkerPools = nil
+	worker := &poolPresto.Spec.Worker
+	worker.Workload = falaricav1alpha1.WorkerReplicaSetWorkload
+	worker.UpdateStrategy = nil
+	worker.Schedules = nil
+	worker.Count = pool.Count
+	if worker.Count == nil {
+		count := int32(1)
+		worker.Count = &count
+	}
+	worker.Autoscaling = falaricav1alpha1.AutoscalingSpec{}
+	if pool.Autoscaling != nil {
+		worker.Autoscaling = *pool.Autoscaling.DeepCopy()
+	}
+	if len(pool.MemoryLimit) != 0 {
+		worker.MemoryLimit = pool.MemoryLimit
+	}
+	if len(pool.CpuLimit) != 0 {
+		worker.CpuLimit = pool.CpuLimit
+		if len(pool.CpuRequest) == 0 {
+			// the request defaults to the limit of the pool
+			worker.CpuRequest = ""
+		}
+	}
+	if len(pool.CpuRequest) != 0 {
+		worker.CpuRequest = pool.CpuRequest
+	}
+	if len(pool.AdditionalJVMConfig) != 0 {
+		worker.AdditionalJVMConfig = pool.AdditionalJVMConfig
+	}
+	if len(pool.AdditionalProps) != 0 {
+		additionalProps := make(map[string]string)
+		for k, v := range worker.AdditionalProps {
+			additionalProps[k] = v
+		}
+		for k, v := range pool.AdditionalProps {
+			additionalProps[k] = v
+		}
+		worker.AdditionalProps = additionalProps
+	}
+	if pool.Storage != nil {
+		worker.Storage = pool.Storage.DeepCopy()
+	}
+	scheduling := pool.SchedulingSpec.DeepCopy()
+	if scheduling.NodeSelector != nil {
+		worker.NodeSelector = scheduling.NodeSelector
+	}
+	if scheduling.Tolerations != nil {
+		worker.Tolerations = scheduling.Tolerations
+	}
+	if scheduling.Affinity != nil {
+		worker.Affinity = scheduling.Affinity
+	}
+	if scheduling.TopologySpreadConstraints != nil {
+		worker.TopologySpreadConstraints = scheduling.TopologySpreadConstraints
+	}
+	if len(scheduling.PriorityClassName) != 0 {
+		worker.PriorityClassName = scheduling.PriorityClassName
+	}
+	return poolPresto
+}
+
+func getWorkerPoolLabels(baseLabels map[string]string, clusterUUID string, pool string) map[string]string {
+	lbls := make(map[string]string)
+	for key, value := range baseLabels {
+		lbls[key] = value
+	}
+	k, v := getWorkerPoolPodLabel(clusterUUID)
+	lbls[k] = v
+	k, v = getWorkerPoolNamePodLabel(clusterUUID, pool)
+	lbls[k] = v
+	return lbls
+}
+
+// returns the name of the pool from the labels of its replicaset
+func getWorkerPoolName(lbls map[string]string) string {
+	for key := range lbls {
+		if strings.HasPrefix(key, workerPoolLabelPrefix) {
+			return strings.TrimPrefix(key, workerPoolLabelPrefix)
+		}
+	}
+	return ""
+}
+
+func validateWorkerPools(presto *falaricav1alpha1.Presto) error {
+	poolNames := make(map[string]bool)
+	for i := range presto.Spec.WorkerPools {
+		pool := &presto.Spec.WorkerPools[i]
+		if poolNames[pool.Name] {
+			return &OperatorError{fmt.Sprintf("worker pool %s is specified more than once", pool.Name)}
+		}
+		poolNames[pool.Name] = true
+		if pool.Autoscaling != nil && pool.Autoscaling.Mode == falaricav1alpha1.QueryLoadAutoscalingMode {
+			return &OperatorError{fmt.Sprintf("QueryLoad autoscaling is not supported for worker pool %s",
+				pool.Name)}
+		}
+		// spill_enabled is a session property, so all the workers have to be able to spill
+		if isSpillEnabled(getWorkerPoolPresto(presto, pool)) != isSpillEnabled(presto) {
+			return &OperatorError{fmt.Sprintf("spillEnabled of worker pool %s has to be the same "+
+				"as that of spec.worker", pool.Name)}
+		}
+	}
+	return nil
+}
+
+// returns the number of workers a pool starts with or is resumed with
+func getWorkerPoolInitialCount(poolPresto *falaricav1alpha1.Presto) int32 {
+	if checkAutoscalingEnabled(poolPresto) && poolPresto.Spec.Worker.Autoscaling.MinReplicas != nil {
+		return *poolPresto.Spec.Worker.Autoscaling.MinReplicas
+	}
+	return *poolPresto.Spec.Worker.Count
+}
+
+func getWorkerPoolReplicaSet(r *ReconcilePresto, presto *falaricav1alpha1.Presto,
+	pool string) (*v1.ReplicaSet, error) {
+	replicaSet := &v1.ReplicaSet{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{
+		Namespace: presto.Namespace,
+		Name:      getWorkerPoolReplicaSetName(presto.Status.Uuid, pool),
+	}, replicaSet)
+	if err != nil {
+		return nil, err
+	}
+	return replicaSet, nil
+}
+
+// returns the replicasets of all the worker pools, including those removed from the spec
+func listWorkerPoolReplicaSets(r *ReconcilePresto, presto *falaricav1alpha1.Presto) ([]v1.ReplicaSet, error) {
+	replicaSets := &v1.ReplicaSetList{}
+	k, v := getWorkerPoolPodLabel(presto.Status.Uuid)
+	err := r.client.List(context.TODO(), replicaSets, &client.ListOptions{
+		Namespace:     presto.Namespace,
+		LabelSelector: labels.SelectorFromSet(labels.Set{k: v}),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return replicaSets.Items, nil
+}
+
+// returns the pods selected by the replicaset of a worker pool
+func getWorkerPoolPods(r *ReconcilePresto, presto *falaricav1alpha1.Presto,
+	replicaSet *v1.ReplicaSet) ([]corev1.Pod, error) {
+	pods := &corev1.PodList{}
+	err := r.client.List(context.TODO(), pods, &client.ListOptions{
+		Namespace:     presto.Namespace,
+		LabelSelector: labels.SelectorFromSet(replicaSet.Spec.Selector.MatchLabels),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pods.Items, nil
+}
+
+func createReplicaSetForWorkerPool(r *ReconcilePresto, poolPresto *falaricav1alpha1.Presto,
+	pool string, lbls map[string]string, workerCount int32) (*v1.ReplicaSet, error) {
+	replicaSet, err := createReplicaSetForWorker(r, poolPresto, lbls, workerCount)
+	if err != nil {
+		return nil, err
+	}
+	replicaSet.GenerateName = ""
+	replicaSet.Name = getWorkerPoolReplicaSetName(poolPresto.Status.Uuid, pool)
+	// the workers of the pool read the config map of the pool
+	configVolume := getWorkerConfigVolumeName(poolPresto.Status.Uuid)
+	for _, volume := range replicaSet.Spec.Template.Spec.Volumes {
+		if volume.Name == configVolume {
+			volume.Projected.Sources[0].ConfigMap.Name = getWorkerPoolConfigMapName(poolPresto.Status.Uuid, pool)
+		}
+	}
+	return replicaSet, nil
+}
+
+// returns replicaSet, created, updated, error
+func createUpdateReplicaSetForWorkerPool(r *ReconcilePresto, presto *falaricav1alpha1.Presto,
+	poolPresto *falaricav1alpha1.Presto, pool string,
+	lbls map[string]string) (*v1.ReplicaSet, bool, bool, error) {
+	ctx := context.Background()
+	replicaSet, err := getWorkerPoolReplicaSet(r, presto, pool)
+	if errors.IsNotFound(err) {
+		replicaSet, err = createReplicaSetForWorkerPool(r, poolPresto, pool, lbls,
+			getWorkerPoolInitialCount(poolPresto))
+		if err != nil {
+			return nil, false, false, err
+		}
+		return replicaSet, true, false, r.client.Create(ctx, replicaSet)
+	}
+	if err != nil {
+		return nil, false, false, err
+	}
+	updated := false
+	current := *replicaSet.Spec.Replicas
+	desired := current
+	if !checkAutoscalingEnabled(poolPresto) {
+		desired = *poolPresto.Spec.Worker.Count
+	} else if current < getWorkerPoolInitialCount(poolPresto) {
+		// the HPA does not scale up a replicaset that has been scaled to zero on hibernation
+		desired = getWorkerPoolInitialCount(poolPresto)
+	}
+	if desired != current {
+		r.log.Info(fmt.Sprintf("PrestoCluster %s worker pool %s: workerCount: %d, replicaSet replicas: %d",
+			presto.Name, pool, desired, current))
+		if desired < current {
+			removable, err := r.decommissionWorkers(presto, replicaSet, nil, current-desired)
+			if err != nil {
+				return nil, false, false, err
+			}
+			desired = current - removable
+		}
+		replicaSetCopy := replicaSet.DeepCopy()
+		replicaSetCopy.Spec.Replicas = &desired
+		err = r.client.Update(ctx, replicaSetCopy)
+		if err != nil {
+			return nil, false, false, err
+		}
+		replicaSet = replicaSetCopy
+		updated = true
+	}
+	desiredReplicaSet, err := createReplicaSetForWorkerPool(r, poolPresto, pool, lbls, desired)
+	if err != nil {
+		return nil, false, updated, err
+	}
+	templateUpdated, err := updateReplicaSetPodTemplate(r, replicaSet, desiredReplicaSet)
+	if err != nil {
+		return nil, false, updated, err
+	}
+	return replicaSet, false, updated || templateUpdated, nil
+}
+
+// deletes the resource of the given name if it exists. returns whether it was deleted
+func deleteIfExists(r *ReconcilePresto, presto *falaricav1alpha1.Presto, name string,
+	obj runtime.Object) (bool, error) {
+	ctx := context.Background()
+	err := r.client.Get(ctx, types.NamespacedName{Namespace: presto.Namespace, Name: name}, obj)
+	if err == nil {
+		err = r.client.Delete(ctx, obj)
+		if err == nil {
+			return true, nil
+		}
+	}
+	if errors.IsNotFound(err) {
+		return false, nil
+	}
+	return false, err
+}
+
+// decommissions the workers of the pools removed from the spec and deletes their resources.
+// returns the names of the removed pools
+func removeStaleWorkerPools(r *ReconcilePresto, presto *falaricav1alpha1.Presto) ([]string, error) {
+	poolNames := make(map[string]bool)
+	for _, pool := range presto.Spec.WorkerPools {
+		poolNames[pool.Name] = true
+	}
+	replicaSets, err := listWorkerPoolReplicaSets(r, presto)
+	if err != nil {
+		return nil, err
+	}
+	var removed []string
+	for i := range replicaSets {
+		replicaSet := &replicaSets[i]
+		pool := getWorkerPoolName(replicaSet.Labels)
+		if poolNames[pool] || replicaSet.DeletionTimestamp != nil {
+			continue
+		}
+		if *replicaSet.Spec.Replicas > 0 {
+			removable, err := r.decommissionWorkers(presto, replicaSet, nil, *replicaSet.Spec.Replicas)
+			if err != nil {
+				return removed, err
+			}
+			// deleting the replicaset would delete the workers that failed to be detached
+			if removable < *replicaSet.Spec.Replicas {
+				continue
+			}
+		}
+		err = r.client.Delete(context.Background(), replicaSet)
+		if err != nil && !errors.IsNotFound(err) {
+			return removed, err
+		}
+		_, err = deleteIfExists(r, presto, getWorkerPoolHPAName(presto.Status.Uuid, pool),
+			&autoscalingv2beta2.HorizontalPodAutoscaler{})
+		if err != nil {
+			return removed, err
+		}
+		_, err = deleteIfExists(r, presto, getWorkerPoolConfigMapName(presto.Status.Uuid, pool),
+			&corev1.ConfigMap{})
+		if err != nil {
+			return removed, err
+		}
+		_, err = deleteIfExists(r, presto, getWorkerPoolPDBName(presto.Status.Uuid, pool),
+			&policyv1beta1.PodDisruptionBudget{})
+		if err != nil {
+			return removed, err
+		}
+		removed = append(removed, pool)
+	}
+	return removed, nil
+}
+
+// scales the worker pools of a hibernated cluster to zero and deletes their HPAs.
+// returns whether any of them was changed
+func hibernateWorkerPools(r *ReconcilePresto, presto *falaricav1alpha1.Presto) (bool, error) {
+	changed := false
+	replicaSets, err := listWorkerPoolReplicaSets(r, presto)
+	if err != nil {
+		return changed, err
+	}
+	for i := range replicaSets {
+		replicaSet := &replicaSets[i]
+		deleted, err := deleteIfExists(r, presto,
+			getWorkerPoolHPAName(presto.Status.Uuid, getWorkerPoolName(replicaSet.Labels)),
+			&autoscalingv2beta2.HorizontalPodAutoscaler{})
+		if err != nil {
+			return changed, err
+		}
+		changed = changed || deleted
+		if *replicaSet.Spec.Replicas == 0 {
+			continue
+		}
+		replicaSetCopy := replicaSet.DeepCopy()
+		zero := int32(0)
+		replicaSetCopy.Spec.Replicas = &zero
+		err = r.client.Update(context.Background(), replicaSetCopy)
+		if err != nil {
+			return changed, err
+		}
+		changed = true
+	}
+	return changed, nil
+}
+
+// restarts the workers of each pool that are not running with the latest configuration
+// or pod spec. returns whether all the pools are up to date, number of up to date workers, error
+func (r *ReconcilePresto) rollWorkerPools(presto *falaricav1alpha1.Presto) (bool, int32, error) {
+	done := true
+	var updatedWorkers int32
+	for i := range presto.Spec.WorkerPools {
+		pool := &presto.Spec.WorkerPools[i]
+		configHash, err := getConfigHash(r, getWorkerPoolPresto(presto, pool))
+		if err != nil {
+			return false, 0, err
+		}
+		replicaSet, err := getWorkerPoolReplicaSet(r, presto, pool.Name)
+		if err != nil {
+			return false, 0, err
+		}
+		if _, err := updateReplicaSetConfigHash(r, replicaSet, configHash, ""); err != nil {
+			r.log.Error(err, "failed to update config hash of replicaset "+replicaSet.Name)
+			return false, 0, err
+		}
+		pods, err := getWorkerPoolPods(r, presto, replicaSet)
+		if err != nil {
+			return false, 0, err
+		}
+		poolDone, poolUpdated, restartedPod, err := restartOutdatedPod(r, pods, replicaSet)
+		if err != nil {
+			r.log.Error(err, "failed to restart worker pod")
+			return false, 0, err
+		}
+		if restartedPod != "" {
+			r.eventRecorder.Eventf(presto, corev1.EventTypeNormal, "Restarting",
+				"Restarting worker pod %s of worker pool %s to apply the latest configuration",
+				restartedPod, pool.Name)
+		}
+		done = done && poolDone
+		updatedWorkers += poolUpdated
+	}
+	return done, updatedWorkers, nil
+}
+
+// creates or updates the config map, the replicaset and the HPA of each worker pool and
+// removes the pools that are no longer in the spec.
+// returns error, changesMade, the replicasets of the pools
+func (r *ReconcilePresto) workerPools(presto *falaricav1alpha1.Presto,
+	baseLabels map[string]string,
+	ctx context.Context) (error, bool, []*v1.ReplicaSet) {
+	failed := func(err error, message string) (error, bool, []*v1.ReplicaSet) {
+		r.log.Error(err, message)
+		errorReason := fmt.Sprintf("%s %s", message, err.Error())
+		r.updateStatus(presto, ctx, ClusterUpdateAction{
+			errorReason:  &errorReason,
+			clusterState: falaricav1alpha1.ClusterFailedState,
+			conditions: failureConditions(presto, "WorkerPoolsFailed", errorReason,
+				falaricav1alpha1.ConditionWorkersAvailable),
+		})
+		r.eventRecorder.Eventf(presto, corev1.EventTypeWarning, "Failed", "%s %s", message, err.Error())
+		return err, false, nil
+	}
+	err := validateWorkerPools(presto)
+	if err != nil {
+		return failed(err, "Invalid worker pools")
+	}
+
+	changesMade := false
+	var replicaSets []*v1.ReplicaSet
+	poolStatuses := []falaricav1alpha1.WorkerPoolStatus{}
+	for i := range presto.Spec.WorkerPools {
+		pool := &presto.Spec.WorkerPools[i]
+		poolPresto := getWorkerPoolPresto(presto, pool)
+		lbls := getWorkerPoolLabels(baseLabels, presto.Status.Uuid, pool.Name)
+
+		configMapName := getWorkerPoolConfigMapName(presto.Status.Uuid, pool.Name)
+		configMap, err := buildConfigMap(poolPresto, false, configMapName, lbls)
+		if err != nil {
+			return failed(err, fmt.Sprintf("Failed to create config map of worker pool %s", pool.Name))
+		}
+		_, _, err = createOrUpdateConfigMap(configMapName, presto, r.client, configMap, lbls)
+		if err != nil {
+			return failed(err, fmt.Sprintf("Failed to create config map of worker pool %s", pool.Name))
+		}
+
+		replicaSet, created, updated, err := createUpdateReplicaSetForWorkerPool(r, presto, poolPresto,
+			pool.Name, lbls)
+		if err != nil {
+			return failed(err, fmt.Sprintf("Failed to create replicaset of worker pool %s", pool.Name))
+		}
+		if created {
+			r.eventRecorder.Eventf(presto, corev1.EventTypeNormal, "Created",
+				"Created Worker Replicaset of worker pool %s. %s", pool.Name, replicaSet.Name)
+			r.log.Info(fmt.Sprintf("PrestoCluster %s: created worker pool %s", presto.Name, pool.Name))
+		}
+		if updated {
+			r.eventRecorder.Eventf(presto, corev1.EventTypeNormal, "Updated",
+				"Updated Worker Replicaset of worker pool %s. %s", pool.Name, replicaSet.Name)
+			r.log.Info(fmt.Sprintf("PrestoCluster %s: updated worker pool %s", presto.Name, pool.Name))
+		}
+		changesMade = changesMade || created || updated
+
+		hpaName := getWorkerPoolHPAName(presto.Status.Uuid, pool.Name)
+		// a dropped behavior is reported by an event
+		hpaCreated, hpaUpdated, hpaDeleted, _, err := handleReplicaSet(r, poolPresto,
+			getWorkerScaleTarget(replicaSet, nil), hpaName, lbls, ctx)
+		if err != nil {
+			return failed(err, fmt.Sprintf("Failed to create autoscale config of worker pool %s", pool.Name))
+		}
+		if hpaCreated || hpaUpdated || hpaDeleted {
+			action := "Created"
+			if hpaUpdated {
+				action = "Updated"
+			} else if hpaDeleted {
+				action = "Deleted"
+			}
+			r.eventRecorder.Eventf(presto, corev1.EventTypeNormal, action,
+				"%s HPA of worker pool %s. %s", action, pool.Name, hpaName)
+			changesMade = true
+		}
+
+		pdbName := getWorkerPoolPDBName(presto.Status.Uuid, pool.Name)
+		pk, pv := getWorkerPoolPodLabel(presto.Status.Uuid)
+		nk, nv := getWorkerPoolNamePodLabel(presto.Status.Uuid, pool.Name)
+		pdbEnabled := isDisruptionBudgetEnabled(poolPresto.Spec.Worker.DisruptionBudget)
+		pdbAction, err := syncPodDisruptionBudget(r, presto,
+			getWorkerPDB(poolPresto, pdbName, map[string]string{pk: pv, nk: nv}, lbls), pdbEnabled)
+		if err != nil {
+			return failed(err, fmt.Sprintf("Failed to create disruption budget of worker pool %s", pool.Name))
+		}
+		if len(pdbAction) != 0 {
+			r.eventRecorder.Eventf(presto, corev1.EventTypeNormal, pdbAction,
+				"%s PodDisruptionBudget of worker pool %s. %s", pdbAction, pool.Name, pdbName)
+			changesMade = true
+		}
+
+		poolStatus := falaricav1alpha1.WorkerPoolStatus{
+			Name:           pool.Name,
+			ReplicaSet:     replicaSet.Name,
+			DesiredWorkers: *replicaSet.Spec.Replicas,
+			CurrentWorkers: replicaSet.Status.AvailableReplicas,
+		}
+		if checkAutoscalingEnabled(poolPresto) {
+			poolStatus.HpaName = hpaName
+		}
+		if pdbEnabled {
+			poolStatus.PdbName = pdbName
+		}
+		poolStatuses = append(poolStatuses, poolStatus)
+		replicaSets = append(replicaSets, replicaSet)
+	}
+
+	removed, err := removeStaleWorkerPools(r, presto)
+	if err != nil {
+		return failed(err, "Failed to remove worker pools")
+	}
+	for _, pool := range removed {
+		r.eventRecorder.Eventf(presto, corev1.EventTypeNormal, "Deleted",
+			"Deleted worker pool %s. Its workers are decommissioned", pool)
+		r.log.Info(fmt.Sprintf("PrestoCluster %s: deleted worker pool %s", presto.Name, pool))
+		changesMade = true
+	}
+
+	if !equality.Semantic.DeepEqual(poolStatuses, presto.Status.WorkerPools) &&
+		(len(poolStatuses) != 0 || len(presto.Status.WorkerPools) != 0) {
+		action := ClusterUpdateAction{
+			workerPools: &poolStatuses,
+		}
+		if changesMade {
+			action.clusterState = falaricav1alpha1.ClusterPending
+		}
+		r.updateStatus(presto, ctx, action)
+	}
+	return nil, changesMade, replicaSets
+}
@@ -0,0 +1,184 @@
+// Package prestoclient is a client for the REST API of the Presto coordinator.
+// It is used by the operator to find the state of a Presto cluster.
+package prestoclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// user sent in the X-Presto-User header. Presto needs a user for the cluster stats.
+	operatorUser = "steerd-presto-operator"
+	// the response bodies are small. Anything larger is not read.
+	maxResponseBytes = 4 << 20
+)
+
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	user       string
+}
+
+// New returns a client for the coordinator at baseURL e.g. http://host:8080.
+// httpClient can be nil in which case a client with a timeout of 10 seconds is used.
+func New(baseURL string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = NewHTTPClient(10*time.Second, false)
+	}
+	return &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: httpClient,
+		user:       operatorUser,
+	}
+}
+
+// NewHTTPClient returns an http client with the given timeout. When insecureSkipVerify
+// is true, the certificate of the coordinator is not verified. The keystores of the
+// coordinator are usually self signed.
+func NewHTTPClient(timeout time.Duration, insecureSkipVerify bool) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: insecureSkipVerify}
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+	}
+}
+
+// Error is returned when the coordinator responds with a status other than 200
+type Error struct {
+	Path       string
+	StatusCode int
+	Body       string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s returned %d: %s", e.Path, e.StatusCode, e.Body)
+}
+
+func (c *Client) get(ctx context.Context, path string, out interface{}) error {
+	return c.do(ctx, http.MethodGet, path, nil, out)
+}
+
+func (c *Client) do(ctx context.Context, method string, path string, in interface{}, out interface{}) error {
+	var reqBody io.Reader
+	if in != nil {
+		inJSON, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(inJSON)
+	}
+	req, err := http.NewRequest(method, c.baseURL+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("X-Presto-User", c.user)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return &Error{Path: path, StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(body))}
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("cannot parse the response of %s: %v", path, err)
+	}
+	return nil
+}
+
+// Info returns the version of the server and whether it is still starting
+func (c *Client) Info(ctx context.Context) (*ServerInfo, error) {
+	info := &ServerInfo{}
+	if err := c.get(ctx, "/v1/info", info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// Nodes returns the nodes that are responding to the coordinator
+func (c *Client) Nodes(ctx context.Context) ([]NodeStatus, error) {
+	var nodes []NodeStatus
+	if err := c.get(ctx, "/v1/node", &nodes); err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+
+// FailedNodes returns the nodes that the coordinator has failed to reach
+func (c *Client) FailedNodes(ctx context.Context) ([]NodeStatus, error) {
+	var nodes []NodeStatus
+	if err := c.get(ctx, "/v1/node/failed", &nodes); err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+
+// Cluster returns the number of active workers and the query counts
+func (c *Client) Cluster(ctx context.Context) (*ClusterStats, error) {
+	stats := &ClusterStats{}
+	if err := c.get(ctx, "/v1/cluster", stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// Queries returns the queries known to the coordinator. The optional state
+// e.g. RUNNING filters the queries.
+func (c *Client) Queries(ctx context.Context, state string) ([]BasicQueryInfo, error) {
+	path := "/v1/query"
+	if len(state) != 0 {
+		path = path + "?state=" + url.QueryEscape(state)
+	}
+	var queries []BasicQueryInfo
+	if err := c.get(ctx, path, &queries); err != nil {
+		return nil, err
+	}
+	return queries, nil
+}
+
+// State returns the state of the node e.g. ACTIVE or SHUTTING_DOWN
+func (c *Client) State(ctx context.Context) (string, error) {
+	var state string
+	if err := c.get(ctx, "/v1/info/state", &state); err != nil {
+		return "", err
+	}
+	return state, nil
+}
+
+// SetState changes the state of the node. A worker that is put into SHUTTING_DOWN
+// stops taking new tasks, waits for the running tasks to finish and then exits.
+func (c *Client) SetState(ctx context.Context, state string) error {
+	return c.do(ctx, http.MethodPut, "/v1/info/state", state, nil)
+}
+
+// Tasks returns the tasks of a worker
+func (c *Client) Tasks(ctx context.Context) ([]TaskInfo, error) {
+	var tasks []TaskInfo
+	if err := c.get(ctx, "/v1/task", &tasks); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
@@ -0,0 +1,159 @@
+package prestoclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// returns a fake coordinator that responds to the given paths with the given bodies
+func newFakeCoordinator(t *testing.T, responses map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("X-Presto-User") != operatorUser {
+			t.Errorf("request to %s has the user %q", req.URL.Path, req.Header.Get("X-Presto-User"))
+		}
+		body, ok := responses[req.URL.Path]
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+}
+
+func TestInfo(t *testing.T) {
+	server := newFakeCoordinator(t, map[string]string{
+		"/v1/info": `{"nodeVersion":{"version":"333"},"environment":"prestoproduction",` +
+			`"coordinator":true,"starting":false,"uptime":"1.00m"}`,
+	})
+	defer server.Close()
+
+	info, err := New(server.URL, nil).Info(context.Background())
+	if err != nil {
+		t.Fatalf("Info failed: %v", err)
+	}
+	if info.NodeVersion.Version != "333" || !info.Coordinator || info.Starting {
+		t.Errorf("unexpected info %+v", info)
+	}
+}
+
+func TestNodes(t *testing.T) {
+	server := newFakeCoordinator(t, map[string]string{
+		"/v1/node": `[{"uri":"http://10.0.0.1:8080","recentRequests":10.5,"recentFailures":0},` +
+			`{"uri":"http://10.0.0.2:8080","recentRequests":9.1,"recentFailures":0.2}]`,
+		"/v1/node/failed": `[]`,
+	})
+	defer server.Close()
+
+	client := New(server.URL, nil)
+	nodes, err := client.Nodes(context.Background())
+	if err != nil {
+		t.Fatalf("Nodes failed: %v", err)
+	}
+	if len(nodes) != 2 || nodes[1].URI != "http://10.0.0.2:8080" || nodes[1].RecentFailures != 0.2 {
+		t.Errorf("unexpected nodes %+v", nodes)
+	}
+	failedNodes, err := client.FailedNodes(context.Background())
+	if err != nil {
+		t.Fatalf("FailedNodes failed: %v", err)
+	}
+	if len(failedNodes) != 0 {
+		t.Errorf("unexpected failed nodes %+v", failedNodes)
+	}
+}
+
+func TestCluster(t *testing.T) {
+	server := newFakeCoordinator(t, map[string]string{
+		"/v1/cluster": `{"runningQueries":3,"blockedQueries":1,"queuedQueries":2,` +
+			`"activeCoordinators":1,"activeWorkers":4,"runningDrivers":12,"reservedMemory":1024.0}`,
+	})
+	defer server.Close()
+
+	stats, err := New(server.URL, nil).Cluster(context.Background())
+	if err != nil {
+		t.Fatalf("Cluster failed: %v", err)
+	}
+	if stats.RunningQueries != 3 || stats.QueuedQueries != 2 || stats.ActiveWorkers != 4 ||
+		stats.RunningDrivers != 12 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, "server is shutting down", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := New(server.URL, nil)
+	for path, call := range map[string]func() error{
+		"/v1/info": func() error {
+			_, err := client.Info(context.Background())
+			return err
+		},
+		"/v1/node": func() error {
+			_, err := client.Nodes(context.Background())
+			return err
+		},
+		"/v1/cluster": func() error {
+			_, err := client.Cluster(context.Background())
+			return err
+		},
+	} {
+		err := call()
+		apiErr, ok := err.(*Error)
+		if !ok {
+			t.Errorf("%s: expected *Error, got %v", path, err)
+			continue
+		}
+		if apiErr.Path != path || apiErr.StatusCode != http.StatusServiceUnavailable ||
+			apiErr.Body != "server is shutting down" {
+			t.Errorf("%s: unexpected error %+v", path, apiErr)
+		}
+	}
+}
+
+func TestMalformedResponse(t *testing.T) {
+	server := newFakeCoordinator(t, map[string]string{
+		"/v1/cluster": `{"runningQueries":`,
+	})
+	defer server.Close()
+
+	if _, err := New(server.URL, nil).Cluster(context.Background()); err == nil {
+		t.Error("expected an error for a malformed response")
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+	defer close(done)
+
+	client := New(server.URL, NewHTTPClient(50*time.Millisecond, false))
+	start := time.Now()
+	if _, err := client.Info(context.Background()); err == nil {
+		t.Fatal("expected a timeout")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("the request took %v in spite of the timeout", elapsed)
+	}
+}
+
+func TestUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	if _, err := New(url, nil).Nodes(context.Background()); err == nil {
+		t.Error("expected an error for an unreachable coordinator")
+	}
+}
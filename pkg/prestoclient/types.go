@@ -0,0 +1,87 @@
+package prestoclient
+
+// ServerInfo is the response of /v1/info
+type ServerInfo struct {
+	NodeVersion NodeVersion `json:"nodeVersion"`
+	Environment string      `json:"environment"`
+	Coordinator bool        `json:"coordinator"`
+	// true till the server has loaded the plugins and the catalogs
+	Starting bool   `json:"starting"`
+	Uptime   string `json:"uptime,omitempty"`
+}
+
+type NodeVersion struct {
+	Version string `json:"version"`
+}
+
+// NodeStatus is an element of the response of /v1/node and /v1/node/failed
+type NodeStatus struct {
+	URI              string  `json:"uri"`
+	RecentRequests   float64 `json:"recentRequests"`
+	RecentFailures   float64 `json:"recentFailures"`
+	RecentSuccesses  float64 `json:"recentSuccesses"`
+	LastRequestTime  string  `json:"lastRequestTime,omitempty"`
+	LastResponseTime string  `json:"lastResponseTime,omitempty"`
+	Age              string  `json:"age,omitempty"`
+}
+
+// ClusterStats is the response of /v1/cluster
+type ClusterStats struct {
+	RunningQueries           int64   `json:"runningQueries"`
+	BlockedQueries           int64   `json:"blockedQueries"`
+	QueuedQueries            int64   `json:"queuedQueries"`
+	ActiveCoordinators       int64   `json:"activeCoordinators"`
+	ActiveWorkers            int64   `json:"activeWorkers"`
+	RunningDrivers           int64   `json:"runningDrivers"`
+	TotalAvailableProcessors int64   `json:"totalAvailableProcessors"`
+	ReservedMemory           float64 `json:"reservedMemory"`
+	TotalInputRows           int64   `json:"totalInputRows"`
+	TotalInputBytes          int64   `json:"totalInputBytes"`
+	TotalCPUTimeSecs         int64   `json:"totalCpuTimeSecs"`
+}
+
+// BasicQueryInfo is an element of the response of /v1/query
+type BasicQueryInfo struct {
+	QueryID    string          `json:"queryId"`
+	State      string          `json:"state"`
+	QueryStats BasicQueryStats `json:"queryStats"`
+}
+
+type BasicQueryStats struct {
+	TotalDrivers     int64 `json:"totalDrivers"`
+	QueuedDrivers    int64 `json:"queuedDrivers"`
+	RunningDrivers   int64 `json:"runningDrivers"`
+	CompletedDrivers int64 `json:"completedDrivers"`
+	FullyBlocked     bool  `json:"fullyBlocked"`
+}
+
+// states of a query as reported in BasicQueryInfo
+const (
+	QueryQueued  = "QUEUED"
+	QueryRunning = "RUNNING"
+)
+
+// states of a node as reported by /v1/info/state
+const (
+	NodeActive       = "ACTIVE"
+	NodeShuttingDown = "SHUTTING_DOWN"
+)
+
+// TaskInfo is an element of the response of /v1/task of a worker
+type TaskInfo struct {
+	TaskStatus TaskStatus `json:"taskStatus"`
+}
+
+type TaskStatus struct {
+	TaskID string `json:"taskId"`
+	State  string `json:"state"`
+}
+
+// returns whether the task is yet to finish
+func (t TaskInfo) IsActive() bool {
+	switch t.TaskStatus.State {
+	case "PLANNED", "RUNNING", "FLUSHING":
+		return true
+	}
+	return false
+}